@@ -4,6 +4,7 @@ import (
 	"context"
 	"embed"
 	"errors"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"time"
@@ -24,7 +25,7 @@ type APIError struct{ Error string }
 // It is constructed once and its services are reused across handlers.
 type APIServer struct {
 	embedStore   storage.Storage
-	gcsStore     storage.Storage
+	postStore    storage.Storage // backend selected by STORAGE_BACKEND (gcs, fs or sqlite)
 	sessionStore *sessions.CookieStore
 	cfg          *config.Config
 
@@ -38,6 +39,8 @@ type APIServer struct {
 	postService  *services.PostService
 	tagService   *services.TagService
 	graphService *services.GraphService // optional; nil if backing store doesn't support graphs
+	feedService  *services.FeedService
+	siteService  *services.SiteService
 }
 
 // route represents a single endpoint registration.
@@ -49,7 +52,7 @@ type middlewareFunc func(http.Handler) http.Handler
 // Returns an error instead of exiting so callers (main/tests) decide lifecycle.
 // NewAPIServer constructs the server and all required services.
 // Returns an error instead of exiting so callers (main/tests) decide lifecycle.
-func NewAPIServer(embed storage.Storage, gcs storage.Storage, logger *slog.Logger, assets embed.FS, cfg *config.Config) (*APIServer, error) {
+func NewAPIServer(embed storage.Storage, posts storage.Storage, logger *slog.Logger, assets embed.FS, cfg *config.Config) (*APIServer, error) {
 	store := sessions.NewCookieStore([]byte(cfg.SessionSecret))
 	store.Options = &sessions.Options{
 		Path:     "/",
@@ -59,7 +62,7 @@ func NewAPIServer(embed storage.Storage, gcs storage.Storage, logger *slog.Logge
 	}
 	server := &APIServer{
 		embedStore:   embed,
-		gcsStore:     gcs,
+		postStore:    posts,
 		sessionStore: store,
 		cfg:          cfg,
 
@@ -73,13 +76,20 @@ func NewAPIServer(embed storage.Storage, gcs storage.Storage, logger *slog.Logge
 	if err != nil {
 		return nil, err
 	}
-	tagSvc := services.NewTagService()
-	postSvc := services.NewPostService(gcs, authSvc)
+	tagSvc := services.NewTagService(storage.ActiveTaxonomy())
+	postSvc := services.NewPostService(posts, authSvc)
+	public, err := fs.Sub(assets, "public")
+	if err != nil {
+		return nil, err
+	}
+	postSvc.SetAssets(public)
 	server.authService = authSvc
 	server.tagService = tagSvc
 	server.postService = postSvc
+	server.feedService = services.NewFeedService(postSvc, cfg.BaseURL, server.domainName)
+	server.siteService = services.NewSiteService(postSvc, cfg.BaseURL, server.domainName, cfg.RobotsDisallow)
 	// Optional graph service (only if storage implements GraphBuilder)
-	if gb, ok := gcs.(services.GraphBuilder); ok {
+	if gb, ok := posts.(services.GraphBuilder); ok {
 		server.graphService = services.NewGraphService(gb, tagSvc)
 	}
 	return server, nil
@@ -150,11 +160,11 @@ func (s *APIServer) InitRoutes() (*http.ServeMux, error) {
 func (s *APIServer) registerPublic(register func(string, http.Handler, ...middlewareFunc)) {
 	login := handlers.NewLoginHandler(s.logger)
 	callback := handlers.NewAuthCallbackHandler(s.logger)
-	post := handlers.NewPostHandler(s.postService, s.logger)
-	home := handlers.NewHomeHandler(s.postService, s.tagService, s.logger)
+	post := handlers.NewPostHandler(s.postService, s.siteService, s.logger)
+	home := handlers.NewHomeHandler(s.postService, s.tagService, s.siteService, s.logger)
 	fragments := handlers.NewPostFragmentsHandler(s.postService, s.logger)
 	tagPosts := handlers.NewTagPostsHandler(s.postService, s.logger)
-	graph := handlers.NewGraphHandler(s.logger, s.graphService, s.postService)
+	graph := handlers.NewGraphHandler(s.logger, s.graphService, s.postService, s.siteService)
 
 	register("GET /auth", login)
 	// Callback: GET for redirect completion; POST carries ID token JSON.
@@ -165,11 +175,23 @@ func (s *APIServer) registerPublic(register func(string, http.Handler, ...middle
 		http.StripPrefix("/assets/", s.embedStore.GetAssets()).ServeHTTP(w, r)
 	}))
 	register("GET /posts/{id}", post)
+	attachments := handlers.NewAttachmentHandler(s.postService, s.logger)
+	register("GET /attachments/{path...}", attachments)
+	register("GET /variants/{width}/{path...}", attachments)
 	register("GET /posts/{id}/fragment", post)
+	register("GET /posts/{id}/sections/{heading}", post)
 	register("GET /", home)
 	register("GET /posts/fragments", fragments)
 	register("GET /tags/{tag}/posts", tagPosts)
 	register("GET /graph", graph)
+	register("GET /search", handlers.NewSearchHandler(s.postService, s.siteService, s.logger))
+	register("GET /sitemap.xml", handlers.NewSitemapHandler(s.siteService, s.logger))
+	register("GET /robots.txt", handlers.NewRobotsHandler(s.siteService, s.logger))
+	// Subscription feeds (published posts only).
+	register("GET /feed.xml", handlers.NewFeedHandler(s.feedService, services.FeedAtom, s.logger))
+	register("GET /rss.xml", handlers.NewFeedHandler(s.feedService, services.FeedRSS, s.logger))
+	register("GET /feed.json", handlers.NewFeedHandler(s.feedService, services.FeedJSON, s.logger))
+	register("GET /tags/{tag}/feed.xml", handlers.NewFeedHandler(s.feedService, services.FeedAtom, s.logger))
 }
 
 // apiRoutes returns JSON API endpoints (versionless initial design).
@@ -179,6 +201,9 @@ func (s *APIServer) registerAPI(register func(string, http.Handler, ...middlewar
 		register("GET /api/graph", handlers.NewGraphAPIHandler(s.logger, s.graphService))
 	}
 	register("GET /api/posts/{id}/adjacency", handlers.NewAdjacencyHandler(s.postService, s.tagService, s.logger))
+	register("GET /api/posts/{id}/toc", handlers.NewTOCHandler(s.postService, s.logger))
+	register("GET /api/search", handlers.NewSearchAPIHandler(s.postService, s.logger))
+	register("GET /api/taxonomy", handlers.NewTaxonomyAPIHandler(s.tagService, s.logger))
 }
 
 // secureRoutes adds authenticated endpoints (CSRF protected).
@@ -187,7 +212,7 @@ func (s *APIServer) registerSecure(register func(string, http.Handler, ...middle
 		middleware.WithCSRF(s.cfg.CSRFSecret, !s.cfg.LocalDev),
 		middleware.WithAuthentication(s.authService, s.sessionStore, s.logger),
 	}
-	register("GET /admin", handlers.NewAdminHandler(s.postService, s.authService, s.logger), secure...)
+	register("GET /admin", handlers.NewAdminHandler(s.postService, s.authService, s.tagService, s.logger), secure...)
 }
 
 // roleRoutes attaches role-gated write operations.
@@ -199,7 +224,35 @@ func (s *APIServer) registerRole(register func(string, http.Handler, ...middlewa
 	role := []middlewareFunc{
 		middleware.WithRole("user", s.logger),
 	}
-	register("POST /posts", handlers.NewPostHandler(s.postService, s.logger), append(secure, role...)...)
+	posts := handlers.NewPostHandler(s.postService, s.siteService, s.logger)
+	register("POST /posts", posts, append(secure, role...)...)
+	register("PUT /posts/{id}", posts, append(secure, role...)...)
+	register("DELETE /posts/{id}", posts, append(secure, role...)...)
+	attachments := handlers.NewAttachmentHandler(s.postService, s.logger)
+	register("POST /posts/{id}/attachments", attachments, append(secure, role...)...)
+	register("DELETE /attachments/{path...}", attachments, append(secure, role...)...)
+	register("POST /api/posts/lint", handlers.NewLintHandler(s.postService, s.logger), append(secure, role...)...)
+	register("POST /api/posts/validate", handlers.NewValidateHandler(s.postService, s.logger), append(secure, role...)...)
+	register("GET /admin/render-stats", handlers.NewRenderStatsHandler(s.postService, s.logger), append(secure, role...)...)
+	register("GET /admin/broken-links", handlers.NewBrokenLinksHandler(s.postService, s.logger), append(secure, role...)...)
+	if _, ok := s.postStore.(storage.RevisionStore); ok {
+		revisions := handlers.NewRevisionHandler(s.postService, s.logger)
+		register("GET /api/posts/{id}/revisions", revisions, append(secure, role...)...)
+		register("GET /posts/{id}/revisions/{rev}", revisions, append(secure, role...)...)
+		register("POST /api/posts/{id}/revisions/{rev}/restore", revisions, append(secure, role...)...)
+		register("GET /admin/posts/{id}/revisions", revisions, append(secure, role...)...)
+	}
+	if _, ok := s.postStore.(storage.RedirectStore); ok {
+		redirects := handlers.NewRedirectHandler(s.postService, s.logger)
+		register("GET /api/redirects", redirects, append(secure, role...)...)
+		register("POST /api/redirects", redirects, append(secure, role...)...)
+		register("DELETE /api/redirects", redirects, append(secure, role...)...)
+		register("POST /api/posts/{id}/rename", redirects, append(secure, role...)...)
+		register("GET /admin/redirects", redirects, append(secure, role...)...)
+	}
+	if syncer, ok := s.postStore.(storage.Syncer); ok {
+		register("POST /admin/resync", handlers.NewResyncHandler(syncer, s.logger), append(secure, role...)...)
+	}
 }
 
 // makeHTTPHandleFunc removed; handlers now implement http.Handler directly with internal error handling.
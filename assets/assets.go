@@ -0,0 +1,10 @@
+// Package assets embeds the public static files and bundled content into the binary so the
+// server and the static exporter ship the same files.
+package assets
+
+import "embed"
+
+// FS holds content/ (bundled markdown such as the about page) and public/ (CSS, JS, images).
+//
+//go:embed content public
+var FS embed.FS
@@ -1,6 +1,7 @@
 // Floating fragment containers logic
 // Creates resizable, scrollable containers that persist across navigation.
 // Multiple containers can be opened. Each container fetches /posts/{slug}/fragment
+// (or /posts/{slug}/sections/{heading} when opened for a single section)
 // and wraps it with draggable header + close/collapse actions.
 (function() {
   const STORAGE_KEY = 'floatingFragments.v1';
@@ -25,6 +26,7 @@
     return {
       id: container.dataset.id,
       slug: container.dataset.slug,
+      section: container.dataset.section || '',
       top: container.style.top || rect.top + 'px',
       left: container.style.left || rect.left + 'px',
       width: container.style.width || rect.width + 'px',
@@ -52,6 +54,7 @@
     const container = document.createElement('div');
     container.className = 'floating-fragment';
     container.dataset.slug = slug;
+    if (preset?.section) container.dataset.section = preset.section;
     container.dataset.id = String(idCounter++);
     container.style.top = preset?.top || (nextOffset(existingCount) + 'px');
     container.style.left = preset?.left || (nextOffset(existingCount) + 'px');
@@ -133,7 +136,7 @@
     closeBtn.addEventListener('click', () => { container.remove(); saveAll(); });
 
     document.body.appendChild(container);
-    fetchFragmentInto(slug, body, titleSpan);
+    fetchFragmentInto(slug, preset?.section, body, titleSpan);
     // Persist after initial creation & after interactions
     persist(container);
     container.addEventListener('mouseup', () => persist(container)); // resize end
@@ -141,8 +144,11 @@
     return container;
   }
 
-  function fetchFragmentInto(slug, target, titleSpan) {
-    fetch('/posts/' + encodeURIComponent(slug) + '/fragment')
+  function fetchFragmentInto(slug, section, target, titleSpan) {
+    const path = section
+      ? '/posts/' + encodeURIComponent(slug) + '/sections/' + encodeURIComponent(section)
+      : '/posts/' + encodeURIComponent(slug) + '/fragment';
+    fetch(path)
       .then(r => { if (!r.ok) throw new Error('HTTP ' + r.status); return r.text(); })
       .then(html => {
         target.innerHTML = html;
@@ -162,7 +168,7 @@
       a.addEventListener('click', (e) => {
         e.preventDefault();
         const slug = a.getAttribute('data-slug');
-        if (slug) createContainer(slug);
+        if (slug) createContainer(slug, { section: a.getAttribute('data-section') || '' });
       });
     });
     // Provide close button inside fragment if present
@@ -179,7 +185,7 @@
     document.querySelectorAll('.pop-fragment-btn[data-slug]').forEach(btn => {
       btn.addEventListener('click', () => {
         const slug = btn.getAttribute('data-slug');
-        if (slug) createContainer(slug);
+        if (slug) createContainer(slug, { section: btn.getAttribute('data-section') || '' });
       });
     });
     // Rehydrate saved containers
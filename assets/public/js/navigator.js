@@ -15,6 +15,14 @@
       pushFragmentHTML(html);
     });
   }
+  function openSection(slug, heading){
+    fetch(`/posts/${slug}/sections/${encodeURIComponent(heading)}`).then(r=>{
+      if(!r.ok) throw new Error('HTTP ' + r.status);
+      return r.text();
+    }).then(html=>{
+      pushFragmentHTML(html);
+    }).catch(()=>{ window.location.href = `/posts/${slug}#${heading}`; });
+  }
   function openThemeBatch(tag){
     fetch(`/theme/fragments?tag=${encodeURIComponent(tag)}&limit=3`).then(r=>r.text()).then(html=>{
       const temp = document.createElement('div');
@@ -37,6 +45,13 @@
         openSlug(a.dataset.slug);
       });
     });
+    // Contents entries open just that section on top of the stack
+    root.querySelectorAll('.post-toc a[data-heading]').forEach(a=>{
+      a.addEventListener('click', e=>{
+        e.preventDefault();
+        openSection(a.dataset.slug, a.dataset.heading);
+      });
+    });
     const closeBtn = root.querySelector('.close-fragment');
     if(closeBtn){ closeBtn.addEventListener('click', e=>{ e.preventDefault(); closeTop(); }); }
   }
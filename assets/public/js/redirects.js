@@ -0,0 +1,51 @@
+const csrfToken = document.getElementById("csrf-token").value;
+
+document.querySelectorAll(".delete-redirect").forEach((button) => {
+  button.addEventListener("click", () => removeRedirect(button.dataset.from));
+});
+
+document.getElementById("add-redirect").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const form = new FormData(event.target);
+  send("/api/redirects", { method: "POST", body: new URLSearchParams(form) });
+});
+
+// Renaming sends the version the page was loaded with as If-Match, so a post saved since is
+// not moved unseen.
+document.getElementById("rename-post").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const form = event.target;
+  const option = form.elements.post.selectedOptions[0];
+  const headers = {};
+  if (option.dataset.version) {
+    headers["If-Match"] = `"${option.dataset.version}"`;
+  }
+  const body = new URLSearchParams({ slug: form.elements.slug.value });
+  send(`/api/posts/${encodeURIComponent(option.value)}/rename`, { method: "POST", headers, body });
+});
+
+function removeRedirect(from) {
+  if (!confirm(`Delete the redirect from ${from}?`)) {
+    return;
+  }
+  send(`/api/redirects?from=${encodeURIComponent(from)}`, { method: "DELETE" });
+}
+
+// send performs a change and reloads the page, or reports why the server refused it.
+async function send(url, init) {
+  init.headers = { ...(init.headers || {}), "X-CSRF-Token": csrfToken };
+  try {
+    const response = await fetch(url, init);
+    if (!response.ok) {
+      let message = (await response.text()).trim();
+      if (response.status === 412) {
+        message = "The post changed since this page was loaded. Reload and try again.";
+      }
+      throw new Error(message || "Request failed");
+    }
+    window.location.reload();
+  } catch (err) {
+    console.error(err);
+    alert(err.message || "Request error");
+  }
+}
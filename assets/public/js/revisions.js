@@ -0,0 +1,41 @@
+const csrfToken = document.getElementById("csrf-token").value;
+const currentVersion = document.getElementById("current-version").value;
+
+document.querySelectorAll(".restore-revision").forEach((button) => {
+  button.addEventListener("click", () => restore(button.dataset.url));
+});
+
+// restore writes a revision back as the post. If-Match keeps it from replacing a version
+// saved after this page was loaded.
+async function restore(url) {
+  if (!confirm("Restore this revision? It is saved as a new revision of the post.")) {
+    return;
+  }
+  const headers = { "X-CSRF-Token": csrfToken };
+  if (currentVersion) {
+    headers["If-Match"] = `"${currentVersion}"`;
+  }
+  try {
+    const response = await fetch(url, { method: "POST", headers });
+    if (!response.ok) {
+      const raw = await response.text();
+      let message = raw.trim();
+      try {
+        const data = JSON.parse(raw);
+        if (Array.isArray(data.errors)) {
+          message = data.errors.map((issue) => `${issue.field || ""} (${issue.severity}): ${issue.message}`).join("\n");
+        }
+      } catch (_) {
+        // plain text error
+      }
+      if (response.status === 412) {
+        message = "The post changed since this page was loaded. Reload and try again.";
+      }
+      throw new Error(message || "Restore failed");
+    }
+    window.location.href = window.location.pathname;
+  } catch (err) {
+    console.error(err);
+    alert(err.message || "Restore error");
+  }
+}
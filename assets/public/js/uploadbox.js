@@ -29,26 +29,27 @@ async function handleFile(file) {
     return;
   }
 
-  const formData = new FormData();
-  formData.append("file", file);
-
   try {
-    const response = await fetch("/posts", {
-      method: "POST",
-      headers: {
-        "X-CSRF-Token": csrfToken
-      },
-      body: formData
-    });
-    const raw = await response.text();
+    let response = await upload(file, false);
+    let raw = await response.text();
+    // 409: a post with the same slug exists; replace it only when confirmed.
+    if (response.status === 409 && confirm(`${raw.trim()}\n\nOverwrite the existing post?`)) {
+      response = await upload(file, true);
+      raw = await response.text();
+    }
     let slug = "";
     let errMsg = "";
     try {
       const data = JSON.parse(raw);
       slug = data.slug || "";
       errMsg = data.error || "";
+      // 422 problem+json: list every validation issue.
+      if (Array.isArray(data.errors)) {
+        errMsg = data.errors.map(formatIssue).join("\n");
+      }
     } catch (_) {
-      // Non-JSON response (ignore)
+      // Non-JSON response: plain text error message
+      errMsg = raw.trim();
     }
     if (!response.ok) {
       throw new Error(errMsg || "Upload failed");
@@ -58,4 +59,26 @@ async function handleFile(file) {
     console.error(err);
     alert(err.message || "Upload error");
   }
-}
\ No newline at end of file
+}
+
+// upload posts file; overwrite replaces an existing post with the same slug.
+function upload(file, overwrite) {
+  const formData = new FormData();
+  formData.append("file", file);
+  if (overwrite) {
+    formData.append("overwrite", "true");
+  }
+  return fetch("/posts", {
+    method: "POST",
+    headers: {
+      "X-CSRF-Token": csrfToken
+    },
+    body: formData
+  });
+}
+
+// formatIssue renders one validation issue, e.g. "line 4 tags[1] (error): unknown tag family: foo".
+function formatIssue(issue) {
+  const where = [issue.line ? `line ${issue.line}` : "", issue.field || ""].filter(Boolean).join(" ");
+  return `${where ? where + " " : ""}(${issue.severity}): ${issue.message}`;
+}
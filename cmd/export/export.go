@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/soockee/cybersocke.com/assets"
+	"github.com/soockee/cybersocke.com/handlers"
+	"github.com/soockee/cybersocke.com/services"
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// Report summarizes an export run.
+type Report struct {
+	Pages    int
+	Assets   int
+	Failures []Failure
+}
+
+// Failure records a page that did not render. Slug or Tag names the content it belongs to.
+type Failure struct {
+	Path   string
+	Slug   string
+	Tag    string
+	Status int
+	Err    string
+}
+
+func (f Failure) String() string {
+	subject := f.Path
+	if f.Slug != "" {
+		subject += " (post " + f.Slug + ")"
+	} else if f.Tag != "" {
+		subject += " (tag " + f.Tag + ")"
+	}
+	if f.Status != 0 {
+		return fmt.Sprintf("%s: status %d: %s", subject, f.Status, f.Err)
+	}
+	return fmt.Sprintf("%s: %s", subject, f.Err)
+}
+
+// exporter renders pages through the live server's handlers, services and components and
+// writes them as static files.
+type exporter struct {
+	logger  *slog.Logger
+	out     string
+	baseURL string
+	posts   *services.PostService
+	handler http.Handler
+	public  fs.FS // embedded assets served under /assets/
+	report  Report
+
+	attachments storage.AttachmentStore // nil when the backend keeps none
+}
+
+func newExporter(store storage.Storage, out, baseURL string, logger *slog.Logger) (*exporter, error) {
+	postSvc := services.NewPostService(store, nil)
+	public, err := fs.Sub(assets.FS, "public")
+	if err != nil {
+		return nil, err
+	}
+	postSvc.SetAssets(public)
+	tagSvc := services.NewTagService(storage.ActiveTaxonomy())
+	siteSvc := services.NewSiteService(postSvc, baseURL, "cybersocke.com", nil)
+	feedSvc := services.NewFeedService(postSvc, baseURL, "cybersocke.com")
+	var graphSvc *services.GraphService
+	if gb, ok := store.(services.GraphBuilder); ok {
+		graphSvc = services.NewGraphService(gb, tagSvc)
+	}
+
+	// Same handlers as the public routes in api.go; no session or auth middleware, so every
+	// page is rendered as an anonymous visitor sees it.
+	mux := http.NewServeMux()
+	post := handlers.NewPostHandler(postSvc, siteSvc, logger)
+	mux.Handle("GET /", handlers.NewHomeHandler(postSvc, tagSvc, siteSvc, logger))
+	mux.Handle("GET /posts/{id}", post)
+	mux.Handle("GET /posts/{id}/fragment", post)
+	mux.Handle("GET /tags/{tag}/posts", handlers.NewTagPostsHandler(postSvc, logger))
+	mux.Handle("GET /graph", handlers.NewGraphHandler(logger, graphSvc, postSvc, siteSvc))
+	mux.Handle("GET /feed.xml", handlers.NewFeedHandler(feedSvc, services.FeedAtom, logger))
+	mux.Handle("GET /rss.xml", handlers.NewFeedHandler(feedSvc, services.FeedRSS, logger))
+	mux.Handle("GET /feed.json", handlers.NewFeedHandler(feedSvc, services.FeedJSON, logger))
+	mux.Handle("GET /tags/{tag}/feed.xml", handlers.NewFeedHandler(feedSvc, services.FeedAtom, logger))
+	mux.Handle("GET /sitemap.xml", handlers.NewSitemapHandler(siteSvc, logger))
+	mux.Handle("GET /robots.txt", handlers.NewRobotsHandler(siteSvc, logger))
+
+	e := &exporter{logger: logger, out: out, baseURL: baseURL, posts: postSvc, handler: mux, public: public}
+	e.attachments, _ = store.(storage.AttachmentStore)
+	return e, nil
+}
+
+// Run exports all pages and assets. Page failures are collected in the report; only errors
+// that make the export meaningless (listing posts, writing files) are returned.
+func (e *exporter) Run(ctx context.Context) (*Report, error) {
+	if err := os.MkdirAll(e.out, 0o755); err != nil {
+		return nil, err
+	}
+	posts, err := e.posts.GetPosts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list posts: %w", err)
+	}
+	slugs := make([]string, 0, len(posts))
+	tagSet := map[string]struct{}{}
+	for slug, p := range posts {
+		slugs = append(slugs, slug)
+		for _, t := range p.Meta.Tags {
+			tagSet[t] = struct{}{}
+		}
+	}
+	sort.Strings(slugs)
+	tags := make([]string, 0, len(tagSet))
+	for t := range tagSet {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+
+	for _, path := range []string{"/", "/graph", "/feed.xml", "/rss.xml", "/feed.json", "/sitemap.xml", "/robots.txt"} {
+		if err := e.page(path, Failure{}); err != nil {
+			return nil, err
+		}
+	}
+	for _, slug := range slugs {
+		base := "/posts/" + url.PathEscape(slug)
+		for _, path := range []string{base, base + "/fragment"} {
+			if err := e.page(path, Failure{Slug: slug}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, tag := range tags {
+		if !safeTag(tag) {
+			e.report.Failures = append(e.report.Failures, Failure{Path: "/tags/" + tag, Tag: tag, Err: "tag cannot be mapped to a file path"})
+			continue
+		}
+		escaped := url.PathEscape(tag)
+		for _, path := range []string{"/?tags=" + url.QueryEscape(tag), "/tags/" + escaped + "/posts", "/tags/" + escaped + "/feed.xml"} {
+			if err := e.page(path, Failure{Tag: tag}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := e.copyAssets(); err != nil {
+		return nil, err
+	}
+	if err := e.copyAttachments(ctx); err != nil {
+		return nil, err
+	}
+	return &e.report, nil
+}
+
+// page renders path and writes it to its static location. Render failures (non-200 status or
+// a panic in rendering) are recorded in the report with the given subject.
+func (e *exporter) page(path string, subject Failure) error {
+	rec, err := e.render(path)
+	if err == nil && rec.Code != http.StatusOK {
+		err = fmt.Errorf("%s", strings.TrimSpace(rec.Body.String()))
+	}
+	if err != nil {
+		subject.Path = path
+		subject.Err = err.Error()
+		subject.Status = rec.Code
+		e.logger.Warn("page failed", slog.String("path", path), slog.Any("err", err))
+		e.report.Failures = append(e.report.Failures, subject)
+		return nil
+	}
+	body := rec.Body.Bytes()
+	contentType := rec.Header().Get("Content-Type")
+	if rewritable(contentType) {
+		body = e.rewriteLinks(body)
+	}
+	file := filepath.Join(e.out, filepath.FromSlash(outputFile(path, contentType)))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, body, 0o644); err != nil {
+		return err
+	}
+	e.report.Pages++
+	return nil
+}
+
+func (e *exporter) render(path string) (rec *httptest.ResponseRecorder, err error) {
+	rec = httptest.NewRecorder()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("render panic: %v", r)
+		}
+	}()
+	e.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec, nil
+}
+
+// copyAssets writes the embedded public files to <out>/assets, where pages reference them.
+func (e *exporter) copyAssets() error {
+	return fs.WalkDir(e.public, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(e.public, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(e.out, "assets", filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		e.report.Assets++
+		return os.WriteFile(dst, data, 0o644)
+	})
+}
+
+// copyAttachments writes the stored attachments to <out>/attachments and their image variants
+// to <out>/variants, matching the URLs rendered posts use.
+func (e *exporter) copyAttachments(ctx context.Context) error {
+	if e.attachments == nil {
+		return nil
+	}
+	list, err := e.attachments.ListAttachments(ctx)
+	if err != nil {
+		return fmt.Errorf("list attachments: %w", err)
+	}
+	for _, att := range list {
+		_, data, err := e.attachments.GetAttachment(ctx, att.Path)
+		if err != nil {
+			return fmt.Errorf("read attachment %s: %w", att.Path, err)
+		}
+		if err := e.writeStatic("attachments/"+att.Path, data); err != nil {
+			return err
+		}
+		for _, width := range services.VariantWidths {
+			_, variant, err := e.posts.ImageVariant(att.Path, width, ctx)
+			if errors.Is(err, services.ErrNoVariant) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("resize attachment %s: %w", att.Path, err)
+			}
+			if err := e.writeStatic(strings.TrimPrefix(services.VariantURL(att.Path, width), "/"), variant); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeStatic writes a non-page file below the output directory.
+func (e *exporter) writeStatic(name string, data []byte) error {
+	dst := filepath.Join(e.out, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	e.report.Assets++
+	return os.WriteFile(dst, data, 0o644)
+}
+
+// outputFile maps a request path to the file that serves it statically. HTML pages become
+// directory indexes (/posts/a.md -> posts/a.md/index.html) so their links only need a trailing
+// slash; tag views move from the query string to /tags/{tag}/.
+func outputFile(path, contentType string) string {
+	u, err := url.Parse(path)
+	if err != nil {
+		return path
+	}
+	p := u.Path // decoded: /tags/theme%2Fgo/posts nests as tags/theme/go/posts
+	if tag := u.Query().Get("tags"); p == "/" && tag != "" {
+		p = "/tags/" + tag
+	}
+	if !strings.HasPrefix(contentType, "text/html") {
+		return strings.TrimPrefix(p, "/")
+	}
+	return strings.TrimPrefix(strings.TrimSuffix(p, "/")+"/index.html", "/")
+}
+
+// safeTag rejects tags that would escape or collide with the tags/ directory layout.
+func safeTag(tag string) bool {
+	for _, seg := range strings.Split(tag, "/") {
+		if seg == "" || seg == "." || seg == ".." || seg == "posts" || seg == "index.html" || seg == "feed.xml" {
+			return false
+		}
+	}
+	return true
+}
+
+func rewritable(contentType string) bool {
+	for _, t := range []string{"text/html", "application/xml", "application/atom+xml", "application/rss+xml", "application/feed+json"} {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkRe finds site URLs in HTML attributes, Atom/RSS/sitemap elements and JSON Feed fields.
+var linkRe = regexp.MustCompile(`(href="|src="|hx-get="|<link>|<loc>|"url": "|"home_page_url": ")([^"<\s]*)`)
+
+// rewriteLinks points links at the static layout produced by outputFile.
+func (e *exporter) rewriteLinks(body []byte) []byte {
+	return linkRe.ReplaceAllFunc(body, func(m []byte) []byte {
+		parts := linkRe.FindSubmatch(m)
+		return append(append([]byte{}, parts[1]...), e.staticURL(string(parts[2]))...)
+	})
+}
+
+func (e *exporter) staticURL(raw string) string {
+	prefix := ""
+	link := raw
+	if e.baseURL != "" && strings.HasPrefix(link, e.baseURL+"/") {
+		prefix, link = e.baseURL, strings.TrimPrefix(link, e.baseURL)
+	}
+	if !strings.HasPrefix(link, "/") || strings.HasPrefix(link, "//") {
+		return raw
+	}
+	link = strings.ReplaceAll(link, "&amp;", "&")
+	fragment := ""
+	if i := strings.Index(link, "#"); i >= 0 {
+		link, fragment = link[:i], link[i:]
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return raw
+	}
+	segs := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	switch {
+	case u.Path == "/" && u.Query().Get("tags") != "":
+		tags := strings.Split(u.Query().Get("tags"), ",")
+		if len(tags) != 1 {
+			return raw // multi-tag views only exist on the live server
+		}
+		link = (&url.URL{Path: "/tags/" + tags[0] + "/"}).EscapedPath()
+	case segs[0] == "posts" && (len(segs) == 2 || (len(segs) == 3 && segs[2] == "fragment")):
+		link = u.EscapedPath() + "/"
+	case segs[0] == "tags" && len(segs) > 2:
+		// Tag directories are nested by the tag's own slashes: /tags/theme%2Fgo/posts -> /tags/theme/go/posts/
+		link = (&url.URL{Path: u.Path}).EscapedPath()
+		if segs[len(segs)-1] == "posts" {
+			link += "/"
+		}
+	case u.Path == "/graph":
+		link = "/graph/"
+	default:
+		return raw
+	}
+	return prefix + link + fragment
+}
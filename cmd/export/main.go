@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/soockee/cybersocke.com/services"
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// export renders every public page of the site into a directory that any static host can serve.
+// Usage:
+//
+//	go run ./cmd/export --out dist --backend fs --content-dir ./vault
+//
+// Flags default to the server's environment variables (STORAGE_BACKEND, CONTENT_DIR,
+// SQLITE_PATH, GCS_BUCKET, BASE_URL, SANITIZE_POLICY_PATH, TAXONOMY_PATH); GCS_CREDENTIALS_BASE64 is read
+// from the environment.
+// Only published posts are exported. Pages that fail to render are listed at the end and the
+// command exits non-zero.
+func main() {
+	out := flag.String("out", "dist", "Output directory")
+	backend := flag.String("backend", envOr("STORAGE_BACKEND", "gcs"), "Storage backend: gcs, fs or sqlite")
+	contentDir := flag.String("content-dir", os.Getenv("CONTENT_DIR"), "Markdown directory for the fs backend")
+	sqlitePath := flag.String("sqlite-path", os.Getenv("SQLITE_PATH"), "Database file for the sqlite backend")
+	bucket := flag.String("gcs-bucket", os.Getenv("GCS_BUCKET"), "Bucket for the gcs backend")
+	baseURL := flag.String("base-url", envOr("BASE_URL", "https://cybersocke.com"), "Absolute site URL for canonical links, feeds and the sitemap")
+	policyPath := flag.String("sanitize-policy", os.Getenv("SANITIZE_POLICY_PATH"), "HTML sanitization policy file (embedded default when empty)")
+	taxonomyPath := flag.String("taxonomy", os.Getenv("TAXONOMY_PATH"), "Tag taxonomy file (embedded default when empty)")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	if *policyPath != "" {
+		policy, err := services.LoadSanitizePolicyFile(*policyPath)
+		if err != nil {
+			logger.Error("Failed to load sanitize policy", slog.String("path", *policyPath), slog.Any("err", err))
+			os.Exit(1)
+		}
+		services.SetSanitizePolicy(policy)
+	}
+	if *taxonomyPath != "" {
+		taxonomy, err := storage.LoadTaxonomyFile(*taxonomyPath)
+		if err != nil {
+			logger.Error("Failed to load tag taxonomy", slog.String("path", *taxonomyPath), slog.Any("err", err))
+			os.Exit(1)
+		}
+		storage.SetTaxonomy(taxonomy)
+	}
+	ctx := context.Background()
+	store, err := storage.Open(ctx, logger, storage.Options{
+		Backend:              strings.ToLower(*backend),
+		ContentDir:           *contentDir,
+		SQLitePath:           *sqlitePath,
+		GCSBucket:            *bucket,
+		GCSCredentialsBase64: os.Getenv("GCS_CREDENTIALS_BASE64"),
+	})
+	if err != nil {
+		logger.Error("Failed to open storage", slog.String("backend", *backend), slog.Any("err", err))
+		os.Exit(1)
+	}
+	if closer, ok := store.(interface{ Close() error }); ok {
+		defer closer.Close()
+	}
+
+	exp, err := newExporter(store, *out, strings.TrimRight(*baseURL, "/"), logger)
+	if err != nil {
+		logger.Error("Export failed", slog.Any("err", err))
+		os.Exit(1)
+	}
+	report, err := exp.Run(ctx)
+	if err != nil {
+		logger.Error("Export failed", slog.Any("err", err))
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "exported %d pages and %d assets to %s\n", report.Pages, report.Assets, *out)
+	if len(report.Failures) > 0 {
+		fmt.Fprintf(os.Stderr, "%d pages could not be rendered:\n", len(report.Failures))
+		for _, f := range report.Failures {
+			fmt.Fprintf(os.Stderr, "  %s\n", f)
+		}
+		os.Exit(1)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
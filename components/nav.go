@@ -6,6 +6,7 @@ func GetNavItems(authed bool) []NavItem {
 	items := []NavItem{
 		NewNavItem("Home", "/"),
 		NewNavItem("Graph", "/graph"),
+		NewNavItem("Search", "/search"),
 	}
 	if authed {
 		items = append(items, NewNavItem("Admin", "/admin"))
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -19,8 +20,16 @@ type Config struct {
 	GCPProjectName            string
 	GCSBucket                 string
 	GCSCredentialsBase64      string
+	GCSSyncInterval           time.Duration // how often the gcs backend reconciles its cache with the bucket; 0 disables
+	StorageBackend            string        // "gcs" (default), "fs" or "sqlite"
+	ContentDir                string        // markdown directory for the fs backend (e.g. an Obsidian vault)
+	SQLitePath                string        // database file for the sqlite backend
+	TaxonomyPath              string        // optional YAML/JSON tag taxonomy; the embedded default is used when empty
+	SanitizePolicyPath        string        // optional YAML/JSON HTML allow-list; the embedded default is used when empty
 	Origin                    string
-	Environment               string // e.g. production, staging, dev
+	BaseURL                   string   // absolute site URL used in feeds and other external links
+	RobotsDisallow            []string // path prefixes robots.txt asks crawlers to skip
+	Environment               string   // e.g. production, staging, dev
 	LocalDev                  bool
 }
 
@@ -33,6 +42,10 @@ func Load() (*Config, error) {
 	// Defaults
 	v.SetDefault("ENVIRONMENT", "development")
 	v.SetDefault("LOCAL_DEV", false)
+	v.SetDefault("STORAGE_BACKEND", "gcs")
+	v.SetDefault("GCS_SYNC_INTERVAL", "1m")
+	v.SetDefault("BASE_URL", "https://cybersocke.com")
+	v.SetDefault("ROBOTS_DISALLOW", "/admin,/auth,/api/")
 
 	cfg := &Config{
 		SessionSecret:             v.GetString("SESSION_SECRET"),
@@ -43,7 +56,15 @@ func Load() (*Config, error) {
 		GCPProjectName:            v.GetString("GCP_PROJECT_NAME"),
 		GCSBucket:                 v.GetString("GCS_BUCKET"),
 		GCSCredentialsBase64:      v.GetString("GCS_CREDENTIALS_BASE64"),
+		GCSSyncInterval:           v.GetDuration("GCS_SYNC_INTERVAL"),
+		StorageBackend:            strings.ToLower(v.GetString("STORAGE_BACKEND")),
+		ContentDir:                v.GetString("CONTENT_DIR"),
+		SQLitePath:                v.GetString("SQLITE_PATH"),
+		TaxonomyPath:              v.GetString("TAXONOMY_PATH"),
+		SanitizePolicyPath:        v.GetString("SANITIZE_POLICY_PATH"),
 		Origin:                    v.GetString("ORIGIN"),
+		BaseURL:                   strings.TrimRight(v.GetString("BASE_URL"), "/"),
+		RobotsDisallow:            splitList(v.GetString("ROBOTS_DISALLOW")),
 		Environment:               v.GetString("ENVIRONMENT"),
 		LocalDev:                  v.GetBool("LOCAL_DEV"),
 	}
@@ -59,14 +80,39 @@ func Load() (*Config, error) {
 	if cfg.FirebaseCredentialsBase64 == "" {
 		missing = append(missing, "FIREBASE_CREDENTIALS_BASE64")
 	}
-	if cfg.GCSBucket == "" {
-		missing = append(missing, "GCS_BUCKET")
-	}
-	if cfg.GCSCredentialsBase64 == "" {
-		missing = append(missing, "GCS_CREDENTIALS_BASE64")
+	// Backend specific requirements.
+	switch cfg.StorageBackend {
+	case "gcs":
+		if cfg.GCSBucket == "" {
+			missing = append(missing, "GCS_BUCKET")
+		}
+		if cfg.GCSCredentialsBase64 == "" {
+			missing = append(missing, "GCS_CREDENTIALS_BASE64")
+		}
+	case "fs":
+		if cfg.ContentDir == "" {
+			missing = append(missing, "CONTENT_DIR")
+		}
+	case "sqlite":
+		if cfg.SQLitePath == "" {
+			missing = append(missing, "SQLITE_PATH")
+		}
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q (want gcs, fs or sqlite)", cfg.StorageBackend)
 	}
 	if len(missing) > 0 {
 		return nil, fmt.Errorf("missing required config: %s", strings.Join(missing, ", "))
 	}
 	return cfg, nil
 }
+
+// splitList parses a comma-separated environment value, dropping blanks.
+func splitList(raw string) []string {
+	out := []string{}
+	for _, part := range strings.Split(raw, ",") {
+		if p := strings.TrimSpace(part); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"encoding/json"
 	"log/slog"
 	"net/http"
 
 	"github.com/gorilla/csrf"
 	"github.com/soockee/cybersocke.com/components"
 	"github.com/soockee/cybersocke.com/services"
+	"github.com/soockee/cybersocke.com/storage"
 )
 
 // AdminHandler serves the admin navigator interface (post list + upload box)
@@ -14,10 +16,11 @@ type AdminHandler struct {
 	Log         *slog.Logger
 	postService *services.PostService
 	authService *services.AuthService
+	tagService  *services.TagService
 }
 
-func NewAdminHandler(posts *services.PostService, auth *services.AuthService, log *slog.Logger) *AdminHandler {
-	return &AdminHandler{Log: log, postService: posts, authService: auth}
+func NewAdminHandler(posts *services.PostService, auth *services.AuthService, tags *services.TagService, log *slog.Logger) *AdminHandler {
+	return &AdminHandler{Log: log, postService: posts, authService: auth, tagService: tags}
 }
 
 func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -37,13 +40,73 @@ func (h *AdminHandler) Get(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
+	// Determine authentication from context (verified id token presence).
+	authed := isAuthed(r)
+	var brokenLinks []services.BrokenLinks
+	if authed {
+		if brokenLinks, err = h.postService.BrokenLinks(ctx); err != nil {
+			return Internal(err)
+		}
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	// Retrieve real CSRF token provided by gorilla/csrf middleware.
 	csrfToken := csrf.Token(r)
-	// Determine authentication from context (verified id token presence).
-	authed := isAuthed(r)
-	props := components.AdminViewProps{Posts: posts, CSRFToken: csrfToken, Authed: authed, ThemeTags: services.CollectThemeTags(posts)}
+	props := components.AdminViewProps{
+		Posts:       posts,
+		CSRFToken:   csrfToken,
+		Authed:      authed,
+		ThemeTags:   h.tagService.ListFamilyTags(posts, "theme"),
+		Taxonomy:    h.tagService.Taxonomy(),
+		BrokenLinks: brokenLinks,
+		Covers:      h.postService.CoverImages(posts, ctx),
+	}
 	components.Admin(props).Render(ctx, w)
 	return nil
 }
+
+// ResyncHandler triggers an immediate storage reconciliation (POST /admin/resync) and reports
+// what changed as JSON. Only registered when the backend implements storage.Syncer.
+type ResyncHandler struct {
+	Log    *slog.Logger
+	syncer storage.Syncer
+}
+
+func NewResyncHandler(syncer storage.Syncer, log *slog.Logger) *ResyncHandler {
+	return &ResyncHandler{Log: log, syncer: syncer}
+}
+
+func (h *ResyncHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeHTTPError(w, r, h.Log, ErrMethodNotAllowed)
+		return
+	}
+	result, err := h.syncer.Sync(r.Context())
+	if err != nil {
+		writeHTTPError(w, r, h.Log, Internal(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// RenderStatsHandler reports rendered-HTML cache counters as JSON (GET /admin/render-stats).
+type RenderStatsHandler struct {
+	Log         *slog.Logger
+	postService *services.PostService
+}
+
+func NewRenderStatsHandler(posts *services.PostService, log *slog.Logger) *RenderStatsHandler {
+	return &RenderStatsHandler{Log: log, postService: posts}
+}
+
+func (h *RenderStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, r, h.Log, ErrMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(h.postService.RenderStats())
+}
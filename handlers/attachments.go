@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/soockee/cybersocke.com/services"
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// maxAttachmentUpload bounds a whole upload request; each file is limited to
+// storage.MaxAttachmentSize on its own.
+const maxAttachmentUpload = 4*storage.MaxAttachmentSize + 1<<20
+
+// AttachmentHandler serves and manages post attachments.
+// Routes:
+//
+//	GET    /attachments/{path...}    public, cached for a day
+//	GET    /variants/{width}/{path...}  resized PNG/JPEG attachment, public
+//	POST   /posts/{id}/attachments   multipart "file" fields (several allowed)
+//	DELETE /attachments/{path...}
+type AttachmentHandler struct {
+	Log         *slog.Logger
+	postService *services.PostService
+}
+
+func NewAttachmentHandler(posts *services.PostService, log *slog.Logger) *AttachmentHandler {
+	return &AttachmentHandler{Log: log, postService: posts}
+}
+
+// attachmentResponse describes a stored attachment; URL is where it is served.
+type attachmentResponse struct {
+	*storage.Attachment
+	URL string `json:"url"`
+}
+
+func (h *AttachmentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if r.PathValue("width") != "" {
+			err = h.Variant(w, r)
+		} else {
+			err = h.Get(w, r)
+		}
+	case http.MethodPost:
+		err = h.Upload(w, r)
+	case http.MethodDelete:
+		err = h.Delete(w, r)
+	default:
+		err = ErrMethodNotAllowed
+	}
+	if err != nil {
+		writeHTTPError(w, r, h.Log, err)
+	}
+}
+
+// Get serves the attachment at /attachments/{path...} with its stored content type.
+func (h *AttachmentHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	p, ok := storage.CleanAttachmentPath(r.PathValue("path"))
+	if !ok {
+		return NotFound("attachment not found")
+	}
+	att, data, err := h.postService.GetAttachment(p, r.Context())
+	if errors.Is(err, services.ErrAttachmentsUnsupported) {
+		return NotFound("attachment not found")
+	}
+	if err != nil {
+		return attachmentError(err)
+	}
+	serveAttachment(w, r, att, data)
+	return nil
+}
+
+// Variant serves the resized copy at /variants/{width}/{path...}, generating it on first use.
+func (h *AttachmentHandler) Variant(w http.ResponseWriter, r *http.Request) error {
+	width, err := strconv.Atoi(r.PathValue("width"))
+	if err != nil {
+		return NotFound("variant not found")
+	}
+	p, ok := storage.CleanAttachmentPath(r.PathValue("path"))
+	if !ok {
+		return NotFound("variant not found")
+	}
+	att, data, err := h.postService.ImageVariant(p, width, r.Context())
+	switch {
+	case errors.Is(err, services.ErrNoVariant), errors.Is(err, services.ErrAttachmentsUnsupported):
+		return NotFound("variant not found")
+	case err != nil:
+		return attachmentError(err)
+	}
+	serveAttachment(w, r, att, data)
+	return nil
+}
+
+// serveAttachment writes data with att's content type and caching headers. SVGs are sandboxed
+// so that scripts inside them never run on this origin.
+func serveAttachment(w http.ResponseWriter, r *http.Request, att *storage.Attachment, data []byte) {
+	sum := sha256.Sum256(data)
+	w.Header().Set("Content-Type", att.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if att.ContentType == "image/svg+xml" {
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	}
+	http.ServeContent(w, r, path.Base(att.Path), att.Modified, bytes.NewReader(data))
+}
+
+// Upload stores every multipart "file" field next to the post /posts/{id} and answers with
+// the stored attachments.
+func (h *AttachmentHandler) Upload(w http.ResponseWriter, r *http.Request) error {
+	slug := r.PathValue("id")
+	if slug == "" {
+		return BadRequest("missing post id", nil)
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentUpload)
+	if err := r.ParseMultipartForm(storage.MaxAttachmentSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return PayloadTooLarge("upload too large", err)
+		}
+		return BadRequest("invalid upload", err)
+	}
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		return BadRequest("invalid upload", errors.New("no file field"))
+	}
+	stored := make([]attachmentResponse, 0, len(files))
+	for _, header := range files {
+		data, err := readAttachment(header)
+		if err != nil {
+			return err
+		}
+		att, err := h.postService.UploadAttachment(slug, header.Filename, data, r.Context())
+		if err != nil {
+			return attachmentError(err)
+		}
+		stored = append(stored, attachmentResponse{Attachment: att, URL: "/attachments/" + att.Path})
+	}
+	h.Log.Info("attachments uploaded", slog.String("slug", slug), slog.Int("count", len(stored)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(stored)
+}
+
+// readAttachment reads one uploaded file, reading at most one byte past the size limit so
+// that the store can reject it.
+func readAttachment(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, BadRequest("invalid upload", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, storage.MaxAttachmentSize+1))
+	if err != nil {
+		return nil, BadRequest("failed to read file", err)
+	}
+	return data, nil
+}
+
+// Delete removes the attachment at /attachments/{path...}.
+func (h *AttachmentHandler) Delete(w http.ResponseWriter, r *http.Request) error {
+	p, ok := storage.CleanAttachmentPath(r.PathValue("path"))
+	if !ok {
+		return NotFound("attachment not found")
+	}
+	if err := h.postService.DeleteAttachment(p, r.Context()); err != nil {
+		return attachmentError(err)
+	}
+	h.Log.Info("attachment deleted", slog.String("path", p))
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// attachmentError maps store errors to responses.
+func attachmentError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrAttachmentNotFound):
+		return NotFound("attachment not found")
+	case errors.Is(err, storage.ErrPostNotFound):
+		return NotFound("post not found")
+	case errors.Is(err, storage.ErrAttachmentTooLarge):
+		return PayloadTooLarge(err.Error(), err)
+	case errors.Is(err, storage.ErrAttachmentType):
+		return &HTTPError{Status: http.StatusUnsupportedMediaType, Message: err.Error(), Cause: err}
+	case errors.Is(err, storage.ErrAttachmentName):
+		return BadRequest(err.Error(), err)
+	case errors.Is(err, services.ErrAttachmentsUnsupported):
+		return &HTTPError{Status: http.StatusNotImplemented, Message: err.Error(), Cause: err}
+	}
+	return err
+}
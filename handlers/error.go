@@ -4,6 +4,8 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+
+	"github.com/soockee/cybersocke.com/storage"
 )
 
 // writeHTTPError centralizes error classification, logging, and response writing.
@@ -12,6 +14,14 @@ func writeHTTPError(w http.ResponseWriter, r *http.Request, logger *slog.Logger,
 	if err == nil {
 		return
 	}
+	var issues storage.ValidationErrors
+	if errors.As(err, &issues) {
+		if logger != nil {
+			logger.Info("validation failed", slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.String("err", issues.Error()))
+		}
+		writeValidationProblem(w, issues)
+		return
+	}
 	status := http.StatusInternalServerError
 	msg := err.Error()
 	var he *HTTPError
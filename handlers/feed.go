@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/soockee/cybersocke.com/services"
+)
+
+// FeedHandler serves a subscription feed in one format:
+//
+//	GET /feed.xml              -> Atom
+//	GET /rss.xml               -> RSS 2.0
+//	GET /feed.json             -> JSON Feed
+//	GET /tags/{tag}/feed.xml   -> Atom, posts carrying tag
+//
+// Responses carry ETag and Last-Modified; conditional requests are answered with 304 before
+// any markdown is rendered.
+type FeedHandler struct {
+	log         *slog.Logger
+	feedService *services.FeedService
+	format      services.FeedFormat
+}
+
+func NewFeedHandler(feeds *services.FeedService, format services.FeedFormat, log *slog.Logger) *FeedHandler {
+	return &FeedHandler{log: log, feedService: feeds, format: format}
+}
+
+func (h *FeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeHTTPError(w, r, h.log, ErrMethodNotAllowed)
+		return
+	}
+	if err := h.Get(w, r); err != nil {
+		writeHTTPError(w, r, h.log, err)
+	}
+}
+
+func (h *FeedHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	tag := r.PathValue("tag")
+	feed, err := h.feedService.Feed(r.Context(), tag, r.URL.EscapedPath())
+	if err != nil {
+		return err
+	}
+	if tag != "" && len(feed.Posts) == 0 {
+		return NotFound("no posts for tag")
+	}
+	etag, err := feed.ETag(r.Context(), h.format)
+	if err != nil {
+		return Internal(err)
+	}
+	lastModified := feed.LastModified()
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	body, err := feed.Encode(r.Context(), h.format)
+	if err != nil {
+		return Internal(err)
+	}
+	w.Header().Set("Content-Type", h.format.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+	return nil
+}
+
+// notModified evaluates If-None-Match and, when absent, If-Modified-Since (RFC 9110 13.2.2).
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !lastModified.After(t)
+		}
+	}
+	return false
+}
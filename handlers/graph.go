@@ -19,10 +19,11 @@ type GraphHandler struct {
 	Log          *slog.Logger
 	GraphService *services.GraphService
 	PostService  *services.PostService
+	SiteService  *services.SiteService
 }
 
-func NewGraphHandler(log *slog.Logger, gs *services.GraphService, ps *services.PostService) *GraphHandler {
-	return &GraphHandler{Log: log, GraphService: gs, PostService: ps}
+func NewGraphHandler(log *slog.Logger, gs *services.GraphService, ps *services.PostService, site *services.SiteService) *GraphHandler {
+	return &GraphHandler{Log: log, GraphService: gs, PostService: ps, SiteService: site}
 }
 
 func (h *GraphHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -39,7 +40,8 @@ func (h *GraphHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	authed := isAuthed(r)
-	if err := components.TagNoteGraph(components.TagNoteGraphProps{Posts: posts, TagCounts: tagCounts, Authed: authed}).Render(r.Context(), w); err != nil {
+	meta := h.SiteService.PageMeta("Graph", "How notes and tags connect", "/graph")
+	if err := components.TagNoteGraph(components.TagNoteGraphProps{Posts: posts, TagCounts: tagCounts, Authed: authed, Meta: meta}).Render(r.Context(), w); err != nil {
 		writeHTTPError(w, r, h.Log, err)
 	}
 }
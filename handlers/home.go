@@ -16,13 +16,15 @@ type HomeHandler struct {
 	Log         *slog.Logger
 	postService *services.PostService
 	tagService  *services.TagService
+	siteService *services.SiteService
 }
 
-func NewHomeHandler(post *services.PostService, tags *services.TagService, log *slog.Logger) *HomeHandler {
+func NewHomeHandler(post *services.PostService, tags *services.TagService, site *services.SiteService, log *slog.Logger) *HomeHandler {
 	return &HomeHandler{
 		Log:         log,
 		postService: post,
 		tagService:  tags,
+		siteService: site,
 	}
 }
 
@@ -41,6 +43,13 @@ func (h *HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (h *HomeHandler) Get(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
+	// "GET /" also catches every path no other route serves; old URLs among them are redirected.
+	if r.URL.Path != "/" {
+		if target, err := h.postService.PathRedirect(r.URL.Path, ctx); err == nil {
+			http.Redirect(w, r, redirectTarget(target, "", r.URL.RawQuery), http.StatusMovedPermanently)
+			return nil
+		}
+	}
 	selected := h.tagService.ParseSelectedTags(r.URL.Query().Get("tags"))
 	posts, err := h.postService.GetPosts(ctx)
 	if err != nil {
@@ -66,7 +75,8 @@ func (h *HomeHandler) Get(w http.ResponseWriter, r *http.Request) error {
 			Tags:    selected,
 			Related: []*storage.Post{},
 		}
-		components.ExplorerLayout(components.ExplorerLayoutProps{Post: props, Adjacency: entries, Tags: selected, Authed: authed}).Render(ctx, w)
+		meta := h.siteService.TagPageMeta(selected)
+		components.ExplorerLayout(components.ExplorerLayoutProps{Meta: meta, Post: props, Adjacency: entries, Tags: selected, Authed: authed}).Render(ctx, w)
 		return nil
 	}
 	// Default starting post view
@@ -74,7 +84,10 @@ func (h *HomeHandler) Get(w http.ResponseWriter, r *http.Request) error {
 	if starting == nil {
 		return NotFound("no posts available")
 	}
-	md := services.RenderMD(starting.Content)
+	md, err := renderPost(h.postService, starting, ctx)
+	if err != nil {
+		return err
+	}
 	neighbors, err := services.ComputeAdjacency(h.postService, starting.Meta.Slug, nil, 1, 12, ctx)
 	if err != nil {
 		return err
@@ -112,7 +125,12 @@ func (h *HomeHandler) Get(w http.ResponseWriter, r *http.Request) error {
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	components.ExplorerLayout(components.ExplorerLayoutProps{Post: props, Adjacency: entries, Tags: []string{}, Authed: authed}).Render(ctx, w)
+	// The home page shows the newest post but is canonical for "/", not for the post.
+	meta := h.siteService.PageMeta(starting.Meta.Name, starting.Meta.Lead, "/")
+	if !starting.Meta.Published {
+		meta = h.siteService.PostPageMeta(starting)
+	}
+	components.ExplorerLayout(components.ExplorerLayoutProps{Meta: meta, Post: props, Adjacency: entries, Tags: []string{}, Authed: authed}).Render(ctx, w)
 	return nil
 }
 
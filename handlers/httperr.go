@@ -20,8 +20,24 @@ func NotFound(message string) error {
 	return &HTTPError{Status: http.StatusNotFound, Message: message}
 }
 
+func Forbidden(message string) error {
+	return &HTTPError{Status: http.StatusForbidden, Message: message}
+}
+
+func Conflict(message string) error {
+	return &HTTPError{Status: http.StatusConflict, Message: message}
+}
+
+func PreconditionFailed(message string) error {
+	return &HTTPError{Status: http.StatusPreconditionFailed, Message: message}
+}
+
 func Internal(cause error) error {
 	return &HTTPError{Status: http.StatusInternalServerError, Message: "internal error", Cause: cause}
 }
 
+func PayloadTooLarge(message string, cause error) error {
+	return &HTTPError{Status: http.StatusRequestEntityTooLarge, Message: message, Cause: cause}
+}
+
 var ErrMethodNotAllowed = &HTTPError{Status: http.StatusMethodNotAllowed, Message: "method not allowed"}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/soockee/cybersocke.com/services"
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// LintHandler checks the internal links of a document without storing it.
+// Route: POST /api/posts/lint (multipart "file" or raw body; ?name= names raw bodies)
+// Response: JSON { slug, brokenLinks: [ { kind, target, line, reason } ] }
+type LintHandler struct {
+	log         *slog.Logger
+	postService *services.PostService
+}
+
+func NewLintHandler(posts *services.PostService, log *slog.Logger) *LintHandler {
+	return &LintHandler{log: log, postService: posts}
+}
+
+type lintResponse struct {
+	Slug        string               `json:"slug"`
+	BrokenLinks []services.LinkIssue `json:"brokenLinks"`
+}
+
+func (h *LintHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeHTTPError(w, r, h.log, ErrMethodNotAllowed)
+		return
+	}
+	content, name, err := readDocument(r)
+	if err != nil {
+		writeHTTPError(w, r, h.log, err)
+		return
+	}
+	if name == "" {
+		name = r.URL.Query().Get("name")
+	}
+	if name == "" {
+		name = "untitled.md"
+	}
+	issues, err := h.postService.CheckLinks(content, name, r.Context())
+	if err != nil {
+		writeHTTPError(w, r, h.log, BadRequest("invalid document", err))
+		return
+	}
+	if issues == nil {
+		issues = []services.LinkIssue{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(lintResponse{Slug: storage.SanitizeFilename(name), BrokenLinks: issues})
+}
+
+// BrokenLinksHandler reports every post with broken internal links as JSON
+// (GET /admin/broken-links).
+type BrokenLinksHandler struct {
+	Log         *slog.Logger
+	postService *services.PostService
+}
+
+func NewBrokenLinksHandler(posts *services.PostService, log *slog.Logger) *BrokenLinksHandler {
+	return &BrokenLinksHandler{Log: log, postService: posts}
+}
+
+func (h *BrokenLinksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, r, h.Log, ErrMethodNotAllowed)
+		return
+	}
+	report, err := h.postService.BrokenLinks(r.Context())
+	if err != nil {
+		writeHTTPError(w, r, h.Log, Internal(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,12 +24,14 @@ import (
 type PostHandler struct {
 	Log         *slog.Logger
 	postService *services.PostService
+	siteService *services.SiteService
 }
 
-func NewPostHandler(postService *services.PostService, log *slog.Logger) *PostHandler {
+func NewPostHandler(postService *services.PostService, site *services.SiteService, log *slog.Logger) *PostHandler {
 	return &PostHandler{
 		Log:         log,
 		postService: postService,
+		siteService: site,
 	}
 }
 
@@ -35,7 +41,21 @@ func (h *PostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if err := h.Post(w, r); err != nil {
 			writeHTTPError(w, r, h.Log, err)
 		}
+	case http.MethodPut:
+		if err := h.Put(w, r); err != nil {
+			writeHTTPError(w, r, h.Log, err)
+		}
+	case http.MethodDelete:
+		if err := h.Delete(w, r); err != nil {
+			writeHTTPError(w, r, h.Log, err)
+		}
 	case http.MethodGet:
+		if r.PathValue("heading") != "" {
+			if err := h.Section(w, r); err != nil {
+				writeHTTPError(w, r, h.Log, err)
+			}
+			return
+		}
 		if strings.HasSuffix(r.URL.Path, "/fragment") {
 			if err := h.Fragment(w, r); err != nil {
 				writeHTTPError(w, r, h.Log, err)
@@ -53,11 +73,24 @@ func (h *PostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (h *PostHandler) Get(w http.ResponseWriter, r *http.Request) error {
 	idStr := r.PathValue("id")
 	post, err := h.postService.GetPost(idStr, r.Context())
+	if post == nil && (err == nil || errors.Is(err, storage.ErrPostNotFound)) {
+		return h.redirectMissing(w, r, "")
+	}
+	if err != nil {
+		return err
+	}
+	md, err := renderPost(h.postService, post, r.Context())
+	if err != nil {
+		return err
+	}
+	toc, err := h.postService.TableOfContents(post, r.Context())
+	if err != nil {
+		return Internal(err)
+	}
+	backlinks, err := h.postService.GetBacklinks(post.Meta.Slug, r.Context())
 	if err != nil {
 		return err
 	}
-	cleaned := services.StripDataview(post.Content)
-	md := services.RenderMD(cleaned)
 	// Build adjacency via service (include all tags, minShared=1, limit=12)
 	neighbors, err := services.ComputeAdjacency(h.postService, post.Meta.Slug, map[string]struct{}{}, 1, 12, r.Context())
 	if err != nil {
@@ -87,17 +120,21 @@ func (h *PostHandler) Get(w http.ResponseWriter, r *http.Request) error {
 		Slug:        post.Meta.Slug,
 		Tags:        post.Meta.Tags,
 		Related:     []*storage.Post{},
+		Backlinks:   backlinks,
 		Lead:        post.Meta.Lead,
 		Created:     createdTs,
 		Updated:     post.Meta.Updated,
 		Published:   post.Meta.Published,
 		Aliases:     post.Meta.Aliases,
 		TagFamilies: families,
+		TOC:         toc,
+		Editable:    isAuthed(r),
 	}
 	authed := isAuthed(r)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	components.ExplorerLayout(components.ExplorerLayoutProps{Post: props, Adjacency: entries, Authed: authed}).Render(r.Context(), w)
+	meta := h.siteService.PostPageMeta(post)
+	components.ExplorerLayout(components.ExplorerLayoutProps{Meta: meta, Post: props, Adjacency: entries, Authed: authed}).Render(r.Context(), w)
 	return nil
 }
 
@@ -128,22 +165,163 @@ func (h *PostHandler) Post(w http.ResponseWriter, r *http.Request) error {
 	original := filepath.Base(header.Filename)
 	slug := storage.SanitizeFilename(original)
 	logger.Debug("upload parsed", slog.String("filename", original), slog.Int("size", len(content)), slog.String("slug", slug))
-
-	if err := h.postService.CreatePost(content, original, r.Context()); err != nil {
-		logger.Error("create post failed", slog.String("slug", slug), slog.Any("err", err))
+	opts, err := writeOptions(r)
+	if err != nil {
 		return err
 	}
 
+	version, links, err := h.postService.CreatePost(content, original, opts, r.Context())
+	if err != nil {
+		if errors.Is(err, storage.ErrPostExists) {
+			return Conflict("post " + slug + " already exists; upload with overwrite=true or If-Match to replace it")
+		}
+		return writeError(err)
+	}
+
+	warnings := h.contentWarnings(slug, r.Context(), logger)
 	logger.Info("upload stored", slog.String("slug", slug), slog.Duration("took", time.Since(start)))
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", strconv.Quote(version))
 	w.WriteHeader(http.StatusCreated)
 
-	err = json.NewEncoder(w).Encode(struct {
-		Slug string `json:"slug"`
-	}{Slug: slug})
+	err = json.NewEncoder(w).Encode(writeResponse{Slug: slug, Version: version, Warnings: warnings, BrokenLinks: links})
 	return err
 }
 
+// writeResponse answers uploads and updates. Version is the stored version, also sent as ETag,
+// to pass as If-Match on the next write; Warnings list markup the sanitizer strips when the
+// post is rendered; BrokenLinks the internal links that lead nowhere.
+type writeResponse struct {
+	Slug        string               `json:"slug"`
+	Version     string               `json:"version"`
+	Warnings    []string             `json:"warnings,omitempty"`
+	BrokenLinks []services.LinkIssue `json:"brokenLinks,omitempty"`
+}
+
+// contentWarnings reports what sanitization removes from the stored post. A failure only costs
+// the warnings, never the write that already succeeded.
+func (h *PostHandler) contentWarnings(slug string, ctx context.Context, logger *slog.Logger) []string {
+	warnings, err := h.postService.ContentWarnings(slug, ctx)
+	if err != nil {
+		logger.Warn("content warnings failed", slog.String("slug", slug), slog.Any("err", err))
+		return nil
+	}
+	if len(warnings) > 0 {
+		logger.Info("uploaded content will be sanitized", slog.String("slug", slug), slog.Any("warnings", warnings))
+	}
+	return warnings
+}
+
+// Put replaces the post at /posts/{id}. The document is sent either as multipart "file" field
+// (like uploads) or as the raw request body. The slug is taken from the path.
+func (h *PostHandler) Put(w http.ResponseWriter, r *http.Request) error {
+	start := time.Now()
+	logger := h.Log.With(
+		slog.String("path", r.URL.Path),
+		slog.String("method", r.Method),
+	)
+	slug := r.PathValue("id")
+	if slug == "" {
+		return BadRequest("missing post id", nil)
+	}
+	content, _, err := readDocument(r)
+	if err != nil {
+		return err
+	}
+	opts, err := writeOptions(r)
+	if err != nil {
+		return err
+	}
+
+	version, links, err := h.postService.UpdatePost(slug, content, opts, r.Context())
+	if err != nil {
+		return writeError(err)
+	}
+	if !strings.HasSuffix(slug, ".md") {
+		slug = slug + ".md"
+	}
+	warnings := h.contentWarnings(slug, r.Context(), logger)
+	logger.Info("post updated", slog.String("slug", slug), slog.Duration("took", time.Since(start)))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", strconv.Quote(version))
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(writeResponse{Slug: slug, Version: version, Warnings: warnings, BrokenLinks: links})
+}
+
+// writeOptions reads the preconditions of an upload or update: the expected version from the
+// If-Match header (or the "version" form field) and the "overwrite" flag from the form or query.
+// Call it after the document is read.
+func writeOptions(r *http.Request) (storage.WriteOptions, error) {
+	opts := storage.WriteOptions{IfMatch: r.FormValue("version")}
+	if tag := strings.TrimSpace(r.Header.Get("If-Match")); tag != "" {
+		opts.IfMatch = strings.Trim(strings.TrimPrefix(tag, "W/"), `"`)
+	}
+	if v := r.FormValue("overwrite"); v != "" {
+		overwrite, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, BadRequest("invalid overwrite flag", err)
+		}
+		opts.Overwrite = overwrite
+	}
+	return opts, nil
+}
+
+// writeError maps the storage errors of a post write to responses. Anything else is returned
+// unchanged; invalid documents are answered 422 by writeHTTPError.
+func writeError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrPostNotFound):
+		return NotFound("post not found")
+	case errors.Is(err, storage.ErrPostExists), errors.Is(err, storage.ErrAliasConflict):
+		return Conflict(err.Error())
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return PreconditionFailed(err.Error())
+	case errors.Is(err, storage.ErrTrustedHTMLForbidden):
+		return Forbidden(err.Error())
+	}
+	return err
+}
+
+// readDocument reads a markdown document sent either as multipart "file" field (like uploads)
+// or as the raw request body. name is the uploaded filename, empty for raw bodies.
+func readDocument(r *http.Request) (content []byte, name string, err error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", BadRequest("invalid upload", err)
+		}
+		defer file.Close()
+		content, err = io.ReadAll(file)
+		if err != nil {
+			return nil, "", BadRequest("failed to read file", err)
+		}
+		name = filepath.Base(header.Filename)
+	} else {
+		content, err = io.ReadAll(r.Body)
+		if err != nil {
+			return nil, "", BadRequest("failed to read body", err)
+		}
+	}
+	if len(content) == 0 {
+		return nil, "", BadRequest("empty file", errors.New("empty file"))
+	}
+	return content, name, nil
+}
+
+// Delete removes the post at /posts/{id}.
+func (h *PostHandler) Delete(w http.ResponseWriter, r *http.Request) error {
+	slug := r.PathValue("id")
+	if slug == "" {
+		return BadRequest("missing post id", nil)
+	}
+	if err := h.postService.DeletePost(slug, r.Context()); err != nil {
+		return writeError(err)
+	}
+	h.Log.Info("post deleted", slog.String("slug", slug))
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
 func (h *PostHandler) View(w http.ResponseWriter, r *http.Request, props components.PostViewProps) {
 	// Legacy view fallback (still available if needed elsewhere)
 	components.Post(props).Render(r.Context(), w)
@@ -153,13 +331,23 @@ func (h *PostHandler) View(w http.ResponseWriter, r *http.Request, props compone
 func (h *PostHandler) Fragment(w http.ResponseWriter, r *http.Request) error {
 	idStr := r.PathValue("id")
 	post, err := h.postService.GetPost(idStr, r.Context())
+	if post == nil && (err == nil || errors.Is(err, storage.ErrPostNotFound)) {
+		return h.redirectMissing(w, r, "/fragment")
+	}
 	if err != nil {
 		return err
 	}
 	related, _ := h.postService.GetRelatedPosts(post.Meta.Slug, 12, r.Context()) // ignore classification for related fetch errors
 	// Render markdown for fragment (same as full post view)
-	cleaned := services.StripDataview(post.Content)
-	md := services.RenderMD(cleaned)
+	md, err := renderPost(h.postService, post, r.Context())
+	if err != nil {
+		return err
+	}
+	toc, err := h.postService.TableOfContents(post, r.Context())
+	if err != nil {
+		return Internal(err)
+	}
+	backlinks, _ := h.postService.GetBacklinks(post.Meta.Slug, r.Context())
 	// Build tag families
 	families := map[string][]string{}
 	for _, t := range post.Meta.Tags {
@@ -178,14 +366,74 @@ func (h *PostHandler) Fragment(w http.ResponseWriter, r *http.Request) error {
 		Slug:        post.Meta.Slug,
 		Tags:        post.Meta.Tags,
 		Related:     related,
+		Backlinks:   backlinks,
 		Lead:        post.Meta.Lead,
 		Created:     post.Meta.Created,
 		Updated:     post.Meta.Updated,
 		Published:   post.Meta.Published,
 		Aliases:     post.Meta.Aliases,
 		TagFamilies: families,
+		TOC:         toc,
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	return components.PostFragment(props).Render(r.Context(), w)
 }
+
+// Section returns one section of a post, /posts/{id}/sections/{heading}, as an HTML fragment
+// for the overlay. heading is the heading text or its anchor, or "^id" for a block.
+func (h *PostHandler) Section(w http.ResponseWriter, r *http.Request) error {
+	post, err := h.postService.GetPost(r.PathValue("id"), r.Context())
+	if post == nil && (err == nil || errors.Is(err, storage.ErrPostNotFound)) {
+		return h.redirectMissing(w, r, "/sections/"+url.PathEscape(r.PathValue("heading")))
+	}
+	if err != nil {
+		return err
+	}
+	heading := r.PathValue("heading")
+	html, ok, err := h.postService.RenderSection(post, heading, r.Context())
+	if err != nil {
+		return Internal(err)
+	}
+	if !ok {
+		return NotFound("section not found")
+	}
+	var md bytes.Buffer
+	md.Write(html)
+	props := components.PostViewProps{
+		Content:   md,
+		Title:     post.Meta.Name + " › " + strings.TrimPrefix(heading, "^"),
+		Slug:      post.Meta.Slug,
+		Tags:      post.Meta.Tags,
+		Created:   post.Meta.Created,
+		Updated:   post.Meta.Updated,
+		Published: post.Meta.Published,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	return components.PostFragment(props).Render(r.Context(), w)
+}
+
+// redirectMissing answers a read of /posts/{id}<suffix> that matched no post visible to the
+// caller: 301 to the same view of the post id was renamed from or is an alias of, or to the
+// target of a redirect from /posts/{id}, and 404 otherwise. The query is kept.
+func (h *PostHandler) redirectMissing(w http.ResponseWriter, r *http.Request, suffix string) error {
+	target, err := h.postService.PostRedirect(r.PathValue("id"), r.Context())
+	if err != nil {
+		return NotFound("post not found")
+	}
+	http.Redirect(w, r, redirectTarget(target, suffix, r.URL.RawQuery), http.StatusMovedPermanently)
+	return nil
+}
+
+// renderPost renders post through the service's cache into the buffer PostViewProps expects.
+// Conversion failures surface as 500s.
+func renderPost(ps *services.PostService, post *storage.Post, ctx context.Context) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	html, err := ps.RenderPost(post, ctx)
+	if err != nil {
+		return buf, Internal(err)
+	}
+	buf.Write(html)
+	return buf, nil
+}
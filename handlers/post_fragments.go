@@ -57,12 +57,12 @@ func (h *PostFragmentsHandler) GetFragments(w http.ResponseWriter, r *http.Reque
 		return nil
 	}
 	// Aggregate fragments via FragmentBatch component (no layout wrapper).
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
 	var viewProps []components.PostViewProps
 	for _, p := range posts {
-		clean := services.StripDataview(p.Content)
-		md := services.RenderMD(clean)
+		md, err := renderPost(h.postService, p, ctx)
+		if err != nil {
+			return err
+		}
 		families := map[string][]string{}
 		for _, t := range p.Meta.Tags {
 			parts := strings.SplitN(t, "/", 2)
@@ -89,6 +89,8 @@ func (h *PostFragmentsHandler) GetFragments(w http.ResponseWriter, r *http.Reque
 		}
 		viewProps = append(viewProps, props)
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
 	components.FragmentBatch(tag, false, viewProps).Render(ctx, w)
 	return nil
 }
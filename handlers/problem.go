@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// problem is an RFC 9457 problem details object. Errors lists every validation issue, so a
+// client can show them all at once.
+type problem struct {
+	Type   string                   `json:"type"`
+	Title  string                   `json:"title"`
+	Status int                      `json:"status"`
+	Detail string                   `json:"detail,omitempty"`
+	Errors storage.ValidationErrors `json:"errors,omitempty"`
+}
+
+// writeValidationProblem answers a rejected document with 422 and its issues as
+// application/problem+json.
+func writeValidationProblem(w http.ResponseWriter, issues storage.ValidationErrors) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	_ = json.NewEncoder(w).Encode(problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusUnprocessableEntity),
+		Status: http.StatusUnprocessableEntity,
+		Detail: issues.Error(),
+		Errors: issues,
+	})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/csrf"
+	"github.com/soockee/cybersocke.com/components"
+	"github.com/soockee/cybersocke.com/services"
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// RedirectHandler manages post renames and the redirect table.
+// Routes:
+//
+//	GET    /api/redirects              JSON table and alias conflicts
+//	POST   /api/redirects              adds the redirect from the "from" to the "to" form field
+//	DELETE /api/redirects?from=        removes a redirect
+//	POST   /api/posts/{id}/rename      renames a post to the "slug" form field (If-Match honoured)
+//	GET    /admin/redirects            management page
+type RedirectHandler struct {
+	Log         *slog.Logger
+	postService *services.PostService
+}
+
+func NewRedirectHandler(posts *services.PostService, log *slog.Logger) *RedirectHandler {
+	return &RedirectHandler{Log: log, postService: posts}
+}
+
+func (h *RedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch {
+	case r.Method == http.MethodPost && r.PathValue("id") != "":
+		err = h.Rename(w, r)
+	case r.Method == http.MethodPost:
+		err = h.Put(w, r)
+	case r.Method == http.MethodDelete:
+		err = h.Delete(w, r)
+	case r.Method != http.MethodGet:
+		err = ErrMethodNotAllowed
+	case strings.HasPrefix(r.URL.Path, "/admin/"):
+		err = h.Page(w, r)
+	default:
+		err = h.List(w, r)
+	}
+	if err != nil {
+		writeHTTPError(w, r, h.Log, err)
+	}
+}
+
+// redirectsResponse answers GET /api/redirects.
+type redirectsResponse struct {
+	Redirects      []storage.Redirect      `json:"redirects"`
+	AliasConflicts []storage.AliasConflict `json:"aliasConflicts"`
+}
+
+// List answers the redirect table and the aliases claimed by more than one post.
+func (h *RedirectHandler) List(w http.ResponseWriter, r *http.Request) error {
+	redirects, err := h.postService.Redirects(r.Context())
+	if err != nil {
+		return redirectError(err)
+	}
+	conflicts, err := h.postService.AliasConflicts(r.Context())
+	if err != nil {
+		return redirectError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(redirectsResponse{Redirects: redirects, AliasConflicts: conflicts})
+}
+
+// Put adds or replaces the redirect from the "from" path to the "to" path or URL.
+func (h *RedirectHandler) Put(w http.ResponseWriter, r *http.Request) error {
+	from, to := r.FormValue("from"), r.FormValue("to")
+	if from == "" || to == "" {
+		return BadRequest("from and to are required", nil)
+	}
+	redirect, err := h.postService.PutRedirect(from, to, r.Context())
+	if err != nil {
+		return redirectError(err)
+	}
+	h.Log.Info("redirect added", slog.String("from", redirect.From), slog.String("to", redirect.To))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(redirect)
+}
+
+// Delete removes the redirect from the "from" path.
+func (h *RedirectHandler) Delete(w http.ResponseWriter, r *http.Request) error {
+	from := r.FormValue("from")
+	if from == "" {
+		return BadRequest("from is required", nil)
+	}
+	if err := h.postService.DeleteRedirect(from, r.Context()); err != nil {
+		return redirectError(err)
+	}
+	h.Log.Info("redirect deleted", slog.String("from", from))
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Rename moves post {id} to the slug the "slug" form field sanitizes to and answers like an
+// update of the renamed post; the old URL redirects to the new one from then on.
+func (h *RedirectHandler) Rename(w http.ResponseWriter, r *http.Request) error {
+	slug, name := r.PathValue("id"), r.FormValue("slug")
+	if strings.TrimSpace(name) == "" {
+		return BadRequest("slug is required", nil)
+	}
+	opts, err := writeOptions(r)
+	if err != nil {
+		return err
+	}
+	newSlug, version, err := h.postService.RenamePost(slug, name, opts, r.Context())
+	if err != nil {
+		return redirectError(writeError(err))
+	}
+	h.Log.Info("post renamed", slog.String("from", slug), slog.String("to", newSlug), slog.String("version", version))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", strconv.Quote(version))
+	return json.NewEncoder(w).Encode(writeResponse{Slug: newSlug, Version: version})
+}
+
+// Page renders the redirect table with forms to add redirects and rename posts, and the alias
+// conflicts to resolve.
+func (h *RedirectHandler) Page(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	redirects, err := h.postService.Redirects(ctx)
+	if err != nil {
+		return redirectError(err)
+	}
+	conflicts, err := h.postService.AliasConflicts(ctx)
+	if err != nil {
+		return redirectError(err)
+	}
+	posts, err := h.postService.GetPosts(ctx)
+	if err != nil {
+		return err
+	}
+	props := components.RedirectsViewProps{
+		Redirects: redirects,
+		Conflicts: conflicts,
+		Posts:     storage.SortPostMap(posts),
+		CSRFToken: csrf.Token(r),
+		Authed:    isAuthed(r),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return components.Redirects(props).Render(ctx, w)
+}
+
+// redirectError maps redirect management failures to responses.
+func redirectError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrInvalidRedirect):
+		return BadRequest(err.Error(), err)
+	case errors.Is(err, storage.ErrRedirectNotFound):
+		return NotFound("redirect not found")
+	case errors.Is(err, services.ErrRedirectsUnsupported):
+		return &HTTPError{Status: http.StatusNotImplemented, Message: err.Error(), Cause: err}
+	}
+	return err
+}
+
+// redirectTarget carries the view suffix (/fragment, /sections/...) and the query of a request
+// for a moved post over to the redirect target. The suffix only applies to post targets and
+// neither is added to a target with a query or fragment of its own.
+func redirectTarget(target, suffix, query string) string {
+	if strings.ContainsAny(target, "?#") {
+		return target
+	}
+	if strings.HasPrefix(target, "/posts/") {
+		target += suffix
+	}
+	if query != "" {
+		target += "?" + query
+	}
+	return target
+}
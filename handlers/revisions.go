@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/csrf"
+	"github.com/soockee/cybersocke.com/components"
+	"github.com/soockee/cybersocke.com/services"
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// RevisionHandler serves the revision history of posts.
+// Routes:
+//
+//	GET  /api/posts/{id}/revisions                JSON list, newest first
+//	GET  /posts/{id}/revisions/{rev}              the revision's markdown document
+//	POST /api/posts/{id}/revisions/{rev}/restore  writes the revision back (If-Match honoured)
+//	GET  /admin/posts/{id}/revisions              history page; ?from=&to= picks the diff
+type RevisionHandler struct {
+	Log         *slog.Logger
+	postService *services.PostService
+}
+
+func NewRevisionHandler(posts *services.PostService, log *slog.Logger) *RevisionHandler {
+	return &RevisionHandler{Log: log, postService: posts}
+}
+
+func (h *RevisionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch {
+	case r.Method == http.MethodPost:
+		err = h.Restore(w, r)
+	case r.Method != http.MethodGet:
+		err = ErrMethodNotAllowed
+	case r.PathValue("rev") != "":
+		err = h.Document(w, r)
+	case strings.HasPrefix(r.URL.Path, "/admin/"):
+		err = h.Page(w, r)
+	default:
+		err = h.List(w, r)
+	}
+	if err != nil {
+		writeHTTPError(w, r, h.Log, err)
+	}
+}
+
+// List answers the revisions of /api/posts/{id}/revisions.
+func (h *RevisionHandler) List(w http.ResponseWriter, r *http.Request) error {
+	revs, err := h.postService.Revisions(storage.SanitizeFilename(r.PathValue("id")), r.Context())
+	if err != nil {
+		return revisionError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(revs)
+}
+
+// Document serves the markdown of /posts/{id}/revisions/{rev} as stored.
+func (h *RevisionHandler) Document(w http.ResponseWriter, r *http.Request) error {
+	rev, data, err := h.postService.Revision(storage.SanitizeFilename(r.PathValue("id")), r.PathValue("rev"), r.Context())
+	if err != nil {
+		return revisionError(err)
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", strconv.Quote(rev.Version))
+	_, err = w.Write(data)
+	return err
+}
+
+// Restore writes revision {rev} back as post {id} and answers like an update.
+func (h *RevisionHandler) Restore(w http.ResponseWriter, r *http.Request) error {
+	slug, id := storage.SanitizeFilename(r.PathValue("id")), r.PathValue("rev")
+	opts, err := writeOptions(r)
+	if err != nil {
+		return err
+	}
+	version, links, err := h.postService.RestoreRevision(slug, id, opts, r.Context())
+	if err != nil {
+		return revisionError(writeError(err))
+	}
+	h.Log.Info("revision restored", slog.String("slug", slug), slog.String("revision", id), slog.String("version", version))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", strconv.Quote(version))
+	return json.NewEncoder(w).Encode(writeResponse{Slug: slug, Version: version, BrokenLinks: links})
+}
+
+// Page renders the history of a post with the line diff between two revisions, by default the
+// previous and the newest one.
+func (h *RevisionHandler) Page(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	slug := storage.SanitizeFilename(r.PathValue("id"))
+	revs, err := h.postService.Revisions(slug, ctx)
+	if err != nil {
+		return revisionError(err)
+	}
+	props := components.RevisionsViewProps{
+		Slug:      slug,
+		Name:      storage.DeriveDisplayName(slug),
+		Revisions: revs,
+		From:      r.URL.Query().Get("from"),
+		To:        r.URL.Query().Get("to"),
+		CSRFToken: csrf.Token(r),
+		Authed:    isAuthed(r),
+	}
+	if post, err := h.postService.GetPost(slug, ctx); err == nil && post != nil {
+		props.Name = post.Meta.Name
+	}
+	if props.To == "" {
+		props.To = revs[0].ID
+	}
+	if props.From == "" && len(revs) > 1 {
+		props.From = revs[1].ID
+	}
+	if props.From != "" {
+		if props.Diff, err = h.postService.CompareRevisions(slug, props.From, props.To, ctx); err != nil {
+			return revisionError(err)
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return components.Revisions(props).Render(ctx, w)
+}
+
+// revisionError maps revision lookups to responses.
+func revisionError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrPostNotFound):
+		return NotFound("post not found")
+	case errors.Is(err, storage.ErrRevisionNotFound):
+		return NotFound("revision not found")
+	case errors.Is(err, services.ErrRevisionsUnsupported):
+		return &HTTPError{Status: http.StatusNotImplemented, Message: err.Error(), Cause: err}
+	}
+	return err
+}
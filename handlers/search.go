@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/soockee/cybersocke.com/components"
+	"github.com/soockee/cybersocke.com/services"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchHandler serves full-text search results as HTML.
+// Route: /search?q=kubernetes&limit=20
+// Requests sent by htmx (HX-Request header) receive only the results partial.
+type SearchHandler struct {
+	log         *slog.Logger
+	postService *services.PostService
+	siteService *services.SiteService
+}
+
+func NewSearchHandler(posts *services.PostService, site *services.SiteService, log *slog.Logger) *SearchHandler {
+	return &SearchHandler{log: log, postService: posts, siteService: site}
+}
+
+func (h *SearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, r, h.log, ErrMethodNotAllowed)
+		return
+	}
+	if err := h.Get(w, r); err != nil {
+		writeHTTPError(w, r, h.log, err)
+	}
+}
+
+func (h *SearchHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	query, limit := parseSearchParams(r)
+	meta := h.siteService.PageMeta("Search", "Full-text search over all notes", "/search")
+	meta.NoIndex = query != "" // result pages are not worth indexing
+	props := components.SearchViewProps{Query: query, Authed: isAuthed(r), Meta: meta}
+	if query != "" {
+		hits, err := h.postService.SearchPosts(query, limit, r.Context())
+		if err != nil {
+			return err
+		}
+		props.Hits = hits
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Vary", "HX-Request")
+	w.WriteHeader(http.StatusOK)
+	if r.Header.Get("HX-Request") == "true" {
+		return components.SearchResults(props).Render(r.Context(), w)
+	}
+	return components.Search(props).Render(r.Context(), w)
+}
+
+// SearchAPIHandler serves full-text search results as JSON at /api/search.
+type SearchAPIHandler struct {
+	log         *slog.Logger
+	postService *services.PostService
+}
+
+func NewSearchAPIHandler(posts *services.PostService, log *slog.Logger) *SearchAPIHandler {
+	return &SearchAPIHandler{log: log, postService: posts}
+}
+
+type searchResultJSON struct {
+	Slug    string   `json:"slug"`
+	Name    string   `json:"name"`
+	Lead    string   `json:"lead"`
+	Tags    []string `json:"tags"`
+	Score   float64  `json:"score"`
+	Snippet string   `json:"snippet"` // escaped HTML, matches wrapped in <mark>
+}
+
+func (h *SearchAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, r, h.log, ErrMethodNotAllowed)
+		return
+	}
+	query, limit := parseSearchParams(r)
+	if query == "" {
+		writeHTTPError(w, r, h.log, BadRequest("missing q", nil))
+		return
+	}
+	hits, err := h.postService.SearchPosts(query, limit, r.Context())
+	if err != nil {
+		writeHTTPError(w, r, h.log, err)
+		return
+	}
+	results := make([]searchResultJSON, 0, len(hits))
+	for _, hit := range hits {
+		results = append(results, searchResultJSON{
+			Slug:    hit.Post.Meta.Slug,
+			Name:    hit.Post.Meta.Name,
+			Lead:    hit.Post.Meta.Lead,
+			Tags:    hit.Post.Meta.Tags,
+			Score:   hit.Score,
+			Snippet: hit.SnippetHTML(),
+		})
+	}
+	body, err := json.Marshal(map[string]any{"query": query, "results": results})
+	if err != nil {
+		writeHTTPError(w, r, h.log, Internal(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// parseSearchParams reads q and limit, clamping limit to [1, maxSearchLimit].
+func parseSearchParams(r *http.Request) (string, int) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	limit := defaultSearchLimit
+	if lstr := r.URL.Query().Get("limit"); lstr != "" {
+		if li, err := strconv.Atoi(lstr); err == nil && li > 0 {
+			limit = min(li, maxSearchLimit)
+		}
+	}
+	return query, limit
+}
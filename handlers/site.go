@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/soockee/cybersocke.com/services"
+)
+
+// SitemapHandler serves /sitemap.xml listing published posts, tag pages and the graph page.
+type SitemapHandler struct {
+	log         *slog.Logger
+	siteService *services.SiteService
+}
+
+func NewSitemapHandler(site *services.SiteService, log *slog.Logger) *SitemapHandler {
+	return &SitemapHandler{log: log, siteService: site}
+}
+
+func (h *SitemapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, r, h.log, ErrMethodNotAllowed)
+		return
+	}
+	body, err := h.siteService.Sitemap(r.Context())
+	if err != nil {
+		writeHTTPError(w, r, h.log, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// RobotsHandler serves /robots.txt built from configuration.
+type RobotsHandler struct {
+	log         *slog.Logger
+	siteService *services.SiteService
+}
+
+func NewRobotsHandler(site *services.SiteService, log *slog.Logger) *RobotsHandler {
+	return &RobotsHandler{log: log, siteService: site}
+}
+
+func (h *RobotsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, r, h.log, ErrMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(h.siteService.Robots())
+}
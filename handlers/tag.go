@@ -55,13 +55,13 @@ func (h *TagPostsHandler) Get(w http.ResponseWriter, r *http.Request) error {
 		components.FragmentBatch(tag, true, nil).Render(r.Context(), w)
 		return nil
 	}
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
 	var viewProps []components.PostViewProps
 	for _, p := range posts {
 		// Render markdown + build tag families for each fragment
-		clean := services.StripDataview(p.Content)
-		md := services.RenderMD(clean)
+		md, err := renderPost(h.postService, p, r.Context())
+		if err != nil {
+			return err
+		}
 		families := map[string][]string{}
 		for _, t := range p.Meta.Tags {
 			parts := strings.SplitN(t, "/", 2)
@@ -88,6 +88,8 @@ func (h *TagPostsHandler) Get(w http.ResponseWriter, r *http.Request) error {
 		}
 		viewProps = append(viewProps, props)
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
 	components.FragmentBatch(tag, false, viewProps).Render(r.Context(), w)
 	return nil
 }
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/soockee/cybersocke.com/services"
+)
+
+// TaxonomyAPIHandler serves the tag taxonomy at /api/taxonomy so editors and tooling can
+// validate tags locally with the same rules as uploads.
+type TaxonomyAPIHandler struct {
+	Log        *slog.Logger
+	tagService *services.TagService
+}
+
+func NewTaxonomyAPIHandler(tags *services.TagService, log *slog.Logger) *TaxonomyAPIHandler {
+	return &TaxonomyAPIHandler{Log: log, tagService: tags}
+}
+
+func (h *TaxonomyAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, r, h.Log, ErrMethodNotAllowed)
+		return
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(h.tagService.Taxonomy()); err != nil {
+		writeHTTPError(w, r, h.Log, Internal(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
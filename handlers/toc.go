@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/soockee/cybersocke.com/services"
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// TOCHandler returns the heading tree of a post so scripts can deep-link to its sections.
+// Route: GET /api/posts/{id}/toc
+// Response: JSON { slug, title, headings: [ { level, text, id, children } ] }
+type TOCHandler struct {
+	Log         *slog.Logger
+	postService *services.PostService
+}
+
+func NewTOCHandler(posts *services.PostService, log *slog.Logger) *TOCHandler {
+	return &TOCHandler{Log: log, postService: posts}
+}
+
+type tocResponse struct {
+	Slug     string              `json:"slug"`
+	Title    string              `json:"title"`
+	Headings []*services.Heading `json:"headings"`
+}
+
+func (h *TOCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, r, h.Log, ErrMethodNotAllowed)
+		return
+	}
+	post, err := h.postService.GetPost(r.PathValue("id"), r.Context())
+	if post == nil && (err == nil || errors.Is(err, storage.ErrPostNotFound)) {
+		writeHTTPError(w, r, h.Log, NotFound("post not found"))
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, r, h.Log, err)
+		return
+	}
+	headings, err := h.postService.TableOfContents(post, r.Context())
+	if err != nil {
+		writeHTTPError(w, r, h.Log, Internal(err))
+		return
+	}
+	if headings == nil {
+		headings = []*services.Heading{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tocResponse{Slug: post.Meta.Slug, Title: post.Meta.Name, Headings: headings})
+}
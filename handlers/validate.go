@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/soockee/cybersocke.com/services"
+)
+
+// ValidateHandler is a dry run of an upload: it validates and renders a document without
+// storing it.
+// Route: POST /api/posts/validate (multipart "file" or raw body; ?name= names raw bodies)
+// Response: JSON services.UploadPreview; invalid documents answer 200 with valid false.
+type ValidateHandler struct {
+	log         *slog.Logger
+	postService *services.PostService
+}
+
+func NewValidateHandler(posts *services.PostService, log *slog.Logger) *ValidateHandler {
+	return &ValidateHandler{log: log, postService: posts}
+}
+
+func (h *ValidateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeHTTPError(w, r, h.log, ErrMethodNotAllowed)
+		return
+	}
+	content, name, err := readDocument(r)
+	if err != nil {
+		writeHTTPError(w, r, h.log, err)
+		return
+	}
+	if name == "" {
+		name = r.URL.Query().Get("name")
+	}
+	if name == "" {
+		name = "untitled.md"
+	}
+	preview, err := h.postService.PreviewUpload(content, name, r.Context())
+	if err != nil {
+		writeHTTPError(w, r, h.log, Internal(err))
+		return
+	}
+	h.log.Debug("upload validated", slog.String("slug", preview.Slug), slog.Bool("valid", preview.Valid), slog.Int("issues", len(preview.Issues)))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(preview)
+}
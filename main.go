@@ -2,19 +2,15 @@ package main
 
 import (
 	"context"
-	"embed"
 	"log/slog"
 	"os"
 
+	"github.com/soockee/cybersocke.com/assets"
 	"github.com/soockee/cybersocke.com/config"
+	"github.com/soockee/cybersocke.com/services"
 	"github.com/soockee/cybersocke.com/storage"
 )
 
-// Embed the assets directory into the binary
-//
-//go:embed assets/*
-var assets embed.FS
-
 func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
@@ -26,21 +22,39 @@ func main() {
 		os.Exit(1)
 	}
 
+	if cfg.TaxonomyPath != "" {
+		logger.Info("Load tag taxonomy...", slog.String("path", cfg.TaxonomyPath))
+		taxonomy, err := storage.LoadTaxonomyFile(cfg.TaxonomyPath)
+		if err != nil {
+			logger.Error("Failed to load tag taxonomy", slog.Any("error msg", err))
+			os.Exit(1)
+		}
+		storage.SetTaxonomy(taxonomy)
+	}
+	if cfg.SanitizePolicyPath != "" {
+		logger.Info("Load sanitize policy...", slog.String("path", cfg.SanitizePolicyPath))
+		policy, err := services.LoadSanitizePolicyFile(cfg.SanitizePolicyPath)
+		if err != nil {
+			logger.Error("Failed to load sanitize policy", slog.Any("error msg", err))
+			os.Exit(1)
+		}
+		services.SetSanitizePolicy(policy)
+	}
+
 	logger.Info("Setup Embed Storage...")
-	embedStore, err := storage.NewEmbedStore("assets/content/blog", "assets/public", assets)
+	embedStore, err := storage.NewEmbedStore("content/blog", "public", assets.FS)
 	if err != nil {
 		logger.Error("Failed to setup embedStore", slog.Any("error msg", err))
 		os.Exit(1)
 	}
 	ctx := context.Background()
-	logger.Info("Setup GCS Storage...", slog.String("bucket", cfg.GCSBucket))
-	gcsStore, err := storage.NewGCSStore(ctx, logger, cfg.GCSBucket, cfg.GCSCredentialsBase64)
+	postStore, err := openPostStore(ctx, logger, cfg)
 	if err != nil {
-		logger.Error("Failed to setup gcsStore", slog.Any("error msg", err))
+		logger.Error("Failed to setup post storage", slog.String("backend", cfg.StorageBackend), slog.Any("error msg", err))
 		os.Exit(1)
 	}
 
-	server, err := NewAPIServer(embedStore, gcsStore, logger, assets, cfg)
+	server, err := NewAPIServer(embedStore, postStore, logger, assets.FS, cfg)
 	if err != nil {
 		logger.Error("Failed to initialize server", slog.Any("err", err))
 		os.Exit(1)
@@ -49,3 +63,15 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// openPostStore constructs the post storage backend selected by STORAGE_BACKEND.
+func openPostStore(ctx context.Context, logger *slog.Logger, cfg *config.Config) (storage.Storage, error) {
+	return storage.Open(ctx, logger, storage.Options{
+		Backend:              cfg.StorageBackend,
+		ContentDir:           cfg.ContentDir,
+		SQLitePath:           cfg.SQLitePath,
+		GCSBucket:            cfg.GCSBucket,
+		GCSCredentialsBase64: cfg.GCSCredentialsBase64,
+		GCSSyncInterval:      cfg.GCSSyncInterval,
+	})
+}
@@ -30,7 +30,7 @@ func WithRole(required string, logger *slog.Logger) func(http.Handler) http.Hand
 				_, _ = w.Write([]byte("unauthorized"))
 				return
 			}
-			if !hasRole(tok, required) {
+			if !session.HasRole(tok, required) {
 				if logger != nil {
 					claimsSummary := summarizeRoleClaims(tok)
 					logger.Info("role check forbidden", slog.String("required", required), slog.String("uid", tok.UID), slog.String("claims", claimsSummary), slog.String("path", r.URL.Path), slog.String("method", r.Method))
@@ -48,46 +48,6 @@ func WithRole(required string, logger *slog.Logger) func(http.Handler) http.Hand
 	}
 }
 
-// hasRole checks various claim patterns to determine role possession.
-func hasRole(tok *firebaseauth.Token, want string) bool {
-	if tok == nil {
-		return false
-	}
-	c := tok.Claims
-	roleStr, _ := c["role"].(string)
-	rolesSlice, _ := c["roles"].([]any)
-	claimTrue := func(name string) bool {
-		if b, ok := c[name].(bool); ok && b {
-			return true
-		}
-		return false
-	}
-	rolePresent := func(name string) bool {
-		if roleStr == name {
-			return true
-		}
-		for _, v := range rolesSlice {
-			if s, ok := v.(string); ok && s == name {
-				return true
-			}
-		}
-		return false
-	}
-	admin := rolePresent("admin") || claimTrue("admin")
-	writer := rolePresent("writer") || claimTrue("writer") || admin // admin supersets writer
-	user := rolePresent("user") || claimTrue("user") || writer      // writer/admin supersets user
-	switch want {
-	case "admin":
-		return admin
-	case "writer":
-		return writer
-	case "user":
-		return user
-	default:
-		return rolePresent(want) || claimTrue(want)
-	}
-}
-
 // summarizeRoleClaims builds a compact string listing role-related claims for logging.
 func summarizeRoleClaims(tok *firebaseauth.Token) string {
 	if tok == nil {
@@ -0,0 +1,539 @@
+package frontmatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v2"
+)
+
+// entry is a top-level front matter key and its value.
+type entry struct {
+	key   string
+	value interface{}
+
+	// raw is the entry as written in the document: the key line and all lines
+	// belonging to it for YAML, the value for JSON. It is written back while
+	// the value is unchanged.
+	raw []byte
+
+	// lead holds the comments and blank lines before a YAML entry.
+	lead []byte
+}
+
+// mapping is a decoded front matter, its keys in document order. tail holds
+// the comments after the last YAML entry.
+type mapping struct {
+	entries []entry
+	tail    []byte
+}
+
+// codec reads and writes a front matter format key by key, which lets
+// Rewrite keep the keys it does not change as they are.
+type codec struct {
+	decode func(data []byte) (*mapping, error)
+	encode func(m *mapping) ([]byte, error)
+
+	// scalar decodes a scalar written in the format's syntax.
+	scalar func(s string) (interface{}, error)
+
+	// offsets maps keys to their byte offset in the data (see Positions).
+	offsets func(data []byte) map[string]int
+
+	// errorLine returns the 1-based line of the data a decoding error
+	// points at, or 0.
+	errorLine func(err error, data []byte) int
+}
+
+var (
+	yamlCodec = &codec{decode: decodeYAML, encode: encodeYAML, scalar: yamlScalar, offsets: yamlOffsets, errorLine: yamlErrorLine}
+	tomlCodec = &codec{decode: decodeTOML, encode: encodeTOML, scalar: tomlScalar, offsets: tomlOffsets, errorLine: tomlErrorLine}
+	jsonCodec = &codec{decode: decodeJSON, encode: encodeJSON, scalar: jsonScalar, offsets: jsonOffsets, errorLine: jsonErrorLine}
+)
+
+// YAML.
+
+func decodeYAML(data []byte) (*mapping, error) {
+	var items yaml.MapSlice
+	if err := yaml.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	m := &mapping{}
+	for _, item := range items {
+		m.entries = append(m.entries, entry{key: fmt.Sprint(item.Key), value: item.Value})
+	}
+
+	// Keep the text of the entries if the lines split into the same keys;
+	// otherwise (flow mappings, complex keys) every entry is re-encoded.
+	spans, tail, ok := yamlSpans(data)
+	if !ok || len(spans) != len(m.entries) {
+		return m, nil
+	}
+	for i, s := range spans {
+		if s.key != m.entries[i].key {
+			return m, nil
+		}
+	}
+	for i, s := range spans {
+		m.entries[i].lead, m.entries[i].raw = s.lead, s.raw
+	}
+	m.tail = tail
+	return m, nil
+}
+
+type yamlSpan struct {
+	key       string
+	lead, raw []byte
+}
+
+// yamlSpans splits a YAML mapping into the lines of each top-level key. Lines
+// that are indented or continue a block sequence belong to the key above;
+// comments and blank lines between keys lead the key below.
+func yamlSpans(data []byte) (spans []yamlSpan, tail []byte, ok bool) {
+	var pending []byte
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		switch {
+		case len(bytes.TrimSpace(line)) == 0 || line[0] == '#':
+			pending = append(pending, line...)
+		case line[0] == ' ' || line[0] == '\t' || line[0] == '-' && (len(line) == 1 || line[1] == ' ' || line[1] == '\n'):
+			if len(spans) == 0 {
+				return nil, nil, false
+			}
+			cur := &spans[len(spans)-1]
+			cur.raw = append(append(cur.raw, pending...), line...)
+			pending = nil
+		default:
+			key, ok := yamlKey(line)
+			if !ok {
+				return nil, nil, false
+			}
+			spans = append(spans, yamlSpan{key: key, lead: pending, raw: append([]byte(nil), line...)})
+			pending = nil
+		}
+	}
+	return spans, pending, true
+}
+
+// yamlKey returns the key of a `key: value` line.
+func yamlKey(line []byte) (string, bool) {
+	if line[0] == '"' || line[0] == '\'' {
+		end := bytes.IndexByte(line[1:], line[0])
+		if end < 0 {
+			return "", false
+		}
+		var key string
+		if err := yaml.Unmarshal(line[:end+2], &key); err != nil {
+			return "", false
+		}
+		return key, true
+	}
+	for i := 0; i < len(line); i++ {
+		if line[i] == ':' && (i+1 == len(line) || line[i+1] == ' ' || line[i+1] == '\t' || line[i+1] == '\r' || line[i+1] == '\n') {
+			return string(bytes.TrimSpace(line[:i])), true
+		}
+	}
+	return "", false
+}
+
+func encodeYAML(m *mapping) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, e := range m.entries {
+		if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		buf.Write(e.lead)
+		if e.raw != nil {
+			buf.Write(e.raw)
+			continue
+		}
+		out, err := yaml.Marshal(yaml.MapSlice{{Key: e.key, Value: e.value}})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(out)
+	}
+	buf.Write(m.tail)
+	return buf.Bytes(), nil
+}
+
+// yamlOffsets finds the top-level keys and the items of their block
+// sequences, which it keys "key[i]".
+func yamlOffsets(data []byte) map[string]int {
+	out := map[string]int{}
+	key, block := "", false
+	seqIndent, item := -1, 0
+	off := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		start := off
+		off += len(line)
+		trimmed := bytes.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		item0 := len(trimmed) > 0 && trimmed[0] == '-' && (len(trimmed) == 1 || trimmed[1] == ' ' || trimmed[1] == '\n')
+		switch {
+		case len(bytes.TrimSpace(line)) == 0 || trimmed[0] == '#':
+		case indent == 0 && !item0:
+			key = ""
+			if k, ok := yamlKey(line); ok {
+				key, out[k] = k, start
+				// Lines of a |literal or >folded value are no sequence items.
+				_, value, _ := bytes.Cut(line, []byte(":"))
+				value = bytes.TrimSpace(value)
+				block = len(value) > 0 && (value[0] == '|' || value[0] == '>')
+				seqIndent, item = -1, 0
+			}
+		case key != "" && !block && item0:
+			if seqIndent < 0 {
+				seqIndent = indent
+			}
+			if indent == seqIndent {
+				out[key+"["+strconv.Itoa(item)+"]"] = start + indent
+				item++
+			}
+		}
+	}
+	return out
+}
+
+var yamlLineRe = regexp.MustCompile(`line (\d+):`)
+
+func yamlErrorLine(err error, _ []byte) int {
+	if m := yamlLineRe.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return line
+	}
+	return 0
+}
+
+func yamlScalar(s string) (interface{}, error) {
+	var v interface{}
+	err := yaml.Unmarshal([]byte(s), &v)
+	return v, err
+}
+
+// yamlValue converts decoded TOML or JSON values to ones YAML encodes in the
+// form the YAML decoder reads: dates and times become strings.
+func yamlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[k] = yamlValue(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = yamlValue(val)
+		}
+		return out
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case toml.LocalDate:
+		return v.String()
+	case toml.LocalDateTime:
+		return v.String()
+	case toml.LocalTime:
+		return v.String()
+	}
+	return v
+}
+
+// TOML.
+
+func decodeTOML(data []byte) (*mapping, error) {
+	values := map[string]interface{}{}
+	if err := toml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	// The decoded map has no order; take it from the document.
+	keys, err := tomlKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	m := &mapping{}
+	for _, k := range keys {
+		m.entries = append(m.entries, entry{key: k.key, value: values[k.key]})
+	}
+	return m, nil
+}
+
+type tomlKey struct {
+	key    string
+	offset int
+}
+
+// tomlKeys lists the top-level keys in document order, each at its first
+// key/value pair or table header. Pairs below a table header belong to the
+// table.
+func tomlKeys(data []byte) ([]tomlKey, error) {
+	var keys []tomlKey
+	seen := map[string]bool{}
+	inTable := false
+	var p unstable.Parser
+	p.Reset(data)
+	for p.NextExpression() {
+		n := p.Expression()
+		switch n.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			inTable = true
+		case unstable.KeyValue:
+			if inTable {
+				continue
+			}
+		default:
+			continue
+		}
+		it := n.Key()
+		if !it.Next() {
+			continue
+		}
+		k := it.Node()
+		if !seen[string(k.Data)] {
+			seen[string(k.Data)] = true
+			keys = append(keys, tomlKey{string(k.Data), int(k.Raw.Offset)})
+		}
+	}
+	return keys, p.Error()
+}
+
+// encodeTOML writes the entries as the fields of a struct built for them,
+// the only way to have go-toml keep their order.
+func encodeTOML(m *mapping) ([]byte, error) {
+	var fields []reflect.StructField
+	var values []reflect.Value
+	for _, e := range m.entries {
+		v := tomlValue(e.value)
+		if v == nil {
+			continue // TOML has no null
+		}
+		fields = append(fields, reflect.StructField{
+			Name: "F" + strconv.Itoa(len(fields)),
+			Type: reflect.TypeOf(v),
+			Tag:  reflect.StructTag("toml:" + strconv.Quote(e.key)),
+		})
+		values = append(values, reflect.ValueOf(v))
+	}
+	s := reflect.New(reflect.StructOf(fields)).Elem()
+	for i, v := range values {
+		s.Field(i).Set(v)
+	}
+	return toml.Marshal(s.Interface())
+}
+
+// tomlValue converts values decoded from YAML to ones go-toml encodes.
+func tomlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		out := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			if val := tomlValue(item.Value); val != nil {
+				out[fmt.Sprint(item.Key)] = val
+			}
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			if val := tomlValue(val); val != nil {
+				out[fmt.Sprint(k)] = val
+			}
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			if val := tomlValue(val); val != nil {
+				out[k] = val
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, 0, len(v))
+		for _, val := range v {
+			if val := tomlValue(val); val != nil {
+				out = append(out, val)
+			}
+		}
+		return out
+	}
+	return v
+}
+
+func tomlOffsets(data []byte) map[string]int {
+	keys, _ := tomlKeys(data) // keys up to a syntax error are still useful
+	out := make(map[string]int, len(keys))
+	for _, k := range keys {
+		out[k.key] = k.offset
+	}
+	return out
+}
+
+func tomlErrorLine(err error, _ []byte) int {
+	var derr *toml.DecodeError
+	if errors.As(err, &derr) {
+		line, _ := derr.Position()
+		return line
+	}
+	return 0
+}
+
+func tomlScalar(s string) (interface{}, error) {
+	var v struct{ V interface{} }
+	err := toml.Unmarshal([]byte("V = "+s), &v)
+	return v.V, err
+}
+
+// JSON.
+
+var errJSONObject = errors.New("front matter is not a JSON object")
+
+func decodeJSON(data []byte) (*mapping, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, errJSONObject
+	}
+	m := &mapping{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+		m.entries = append(m.entries, entry{key: tok.(string), value: value, raw: raw})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func encodeJSON(m *mapping) ([]byte, error) {
+	obj := make(jsonObject, 0, len(m.entries))
+	for _, e := range m.entries {
+		if e.raw != nil {
+			obj = append(obj, jsonMember{e.key, json.RawMessage(e.raw)})
+		} else {
+			obj = append(obj, jsonMember{e.key, jsonValue(e.value)})
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(obj); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonObject is a JSON object that keeps the order of its members.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	key   string
+	value interface{}
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := enc.Encode(m.key); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1) // Encode appends a newline
+		buf.WriteByte(':')
+		if err := enc.Encode(m.value); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonValue converts values decoded from YAML to ones encoding/json encodes.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		out := make(jsonObject, 0, len(v))
+		for _, item := range v {
+			out = append(out, jsonMember{fmt.Sprint(item.Key), jsonValue(item.Value)})
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[fmt.Sprint(k)] = jsonValue(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = jsonValue(val)
+		}
+		return out
+	}
+	return v
+}
+
+// jsonOffsets finds the members of the top-level object.
+func jsonOffsets(data []byte) map[string]int {
+	out := map[string]int{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return out
+	}
+	for dec.More() {
+		off := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return out
+		}
+		if i := bytes.IndexByte(data[off:], '"'); i >= 0 {
+			out[tok.(string)] = off + i
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return out
+		}
+	}
+	return out
+}
+
+func jsonErrorLine(err error, data []byte) int {
+	var serr *json.SyntaxError
+	if errors.As(err, &serr) && serr.Offset <= int64(len(data)) {
+		return bytes.Count(data[:serr.Offset], []byte("\n")) + 1
+	}
+	return 0
+}
+
+func jsonScalar(s string) (interface{}, error) {
+	var v interface{}
+	err := json.Unmarshal([]byte(s), &v)
+	return v, err
+}
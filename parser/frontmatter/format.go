@@ -1,6 +1,9 @@
 package frontmatter
 
 import (
+	"encoding/json"
+
+	"github.com/pelletier/go-toml/v2"
 	"gopkg.in/yaml.v2"
 )
 
@@ -33,8 +36,19 @@ type Format struct {
 	// required after the front matter.
 	// Should be `false` in most cases.
 	RequiresNewLine bool
+
+	// codec writes the front matter back; only the built-in formats have one.
+	codec *codec
 }
 
+// The formats Marshal writes. Parse detects them along with the other
+// default formats.
+var (
+	YAML = yamlFormat("---", "---")
+	TOML = tomlFormat("+++", "+++")
+	JSON = jsonFormat("{", "}", true, true)
+)
+
 // NewFormat returns a new front matter format.
 func NewFormat(start, end string, unmarshal UnmarshalFunc) *Format {
 	return newFormat(start, end, unmarshal, false, false)
@@ -51,10 +65,54 @@ func newFormat(start, end string, unmarshal UnmarshalFunc,
 	}
 }
 
+func yamlFormat(start, end string) *Format {
+	f := newFormat(start, end, yaml.Unmarshal, false, false)
+	f.codec = yamlCodec
+	return f
+}
+
+func tomlFormat(start, end string) *Format {
+	f := newFormat(start, end, viaYAML(toml.Unmarshal), false, false)
+	f.codec = tomlCodec
+	return f
+}
+
+func jsonFormat(start, end string, unmarshalDelims, requiresNewLine bool) *Format {
+	f := newFormat(start, end, viaYAML(json.Unmarshal), unmarshalDelims, requiresNewLine)
+	f.codec = jsonCodec
+	return f
+}
+
 func defaultFormats() []*Format {
 	return []*Format{
 		// YAML.
-		newFormat("---", "---", yaml.Unmarshal, false, false),
-		newFormat("---yaml", "---", yaml.Unmarshal, false, false),
+		yamlFormat("---", "---"),
+		yamlFormat("---yaml", "---"),
+
+		// TOML.
+		tomlFormat("+++", "+++"),
+		tomlFormat("---toml", "---"),
+
+		// JSON.
+		jsonFormat(";;;", ";;;", false, false),
+		jsonFormat("---json", "---", false, false),
+		jsonFormat("{", "}", true, true),
+	}
+}
+
+// viaYAML adapts unmarshal, which must be able to decode into a generic map,
+// to values tagged for YAML: the data is decoded, re-encoded as YAML and
+// decoded into v. One set of `yaml` struct tags thus serves every format.
+func viaYAML(unmarshal UnmarshalFunc) UnmarshalFunc {
+	return func(data []byte, v interface{}) error {
+		var values map[string]interface{}
+		if err := unmarshal(data, &values); err != nil {
+			return err
+		}
+		out, err := yaml.Marshal(yamlValue(values))
+		if err != nil {
+			return err
+		}
+		return yaml.Unmarshal(out, v)
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"unicode/utf8"
 )
 
 /*
@@ -16,11 +17,64 @@ front matter formats.
   - YAML identified by:
     • opening and closing `---` lines.
     • opening `---yaml` and closing `---` lines.
+
+  - TOML identified by:
+    • opening and closing `+++` lines.
+    • opening `---toml` and closing `---` lines.
+
+  - JSON identified by:
+    • opening and closing `;;;` lines.
+    • opening `---json` and closing `---` lines.
+    • a single JSON object followed by an empty line.
+
+  Every format fills structs through their `yaml` tags (see viaYAML). Marshal and
+  Rewrite write front matter back.
 */
 
 // ErrNotFound is reported by `MustParse` when a front matter is not found.
 var ErrNotFound = errors.New("not found")
 
+// SyntaxError is reported when a front matter cannot be decoded. Line is the
+// 1-based line of the document the decoder points at, 0 if it does not say.
+type SyntaxError struct {
+	Line int
+	Err  error
+}
+
+func (e *SyntaxError) Error() string { return e.Err.Error() }
+
+func (e *SyntaxError) Unwrap() error { return e.Err }
+
+// Position is a 1-based line and column (counted in runes) in a document.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Positions reports where the top-level keys of the front matter of `doc`
+// are written and, for YAML block sequences, each of their items, keyed
+// "key[i]". It returns nil if `doc` has no front matter or its format does
+// not support it. Keys that fail to parse are left out.
+// Front matters are detected based on the passed in `formats`.
+// If no formats are provided, the default formats are used.
+func Positions(doc []byte, formats ...*Format) (map[string]Position, error) {
+	s, err := locate(doc, formats)
+	if err != nil || s == nil || s.format.codec == nil {
+		return nil, err
+	}
+	offsets := s.format.codec.offsets(doc[s.start:s.end])
+	out := make(map[string]Position, len(offsets))
+	for key, off := range offsets {
+		off += s.start
+		lineStart := bytes.LastIndexByte(doc[:off], '\n') + 1
+		out[key] = Position{
+			Line:   bytes.Count(doc[:off], []byte("\n")) + 1,
+			Column: utf8.RuneCount(doc[lineStart:off]) + 1,
+		}
+	}
+	return out, nil
+}
+
 // Parse decodes the front matter from the specified reader into the value
 // pointed to by `v`, and returns the rest of the data. If a front matter
 // is not present, the original data is returned and `v` is left unchanged.
@@ -43,9 +97,10 @@ type parser struct {
 	reader *bufio.Reader
 	output *bytes.Buffer
 
-	read  int
-	start int
-	end   int
+	read    int
+	start   int
+	end     int
+	dataEnd int // end of the front matter data, see extract
 }
 
 func newParser(r io.Reader) *parser {
@@ -142,15 +197,32 @@ func (p *parser) extract(f *Format, v interface{}) (bool, error) {
 			read = p.read
 		}
 
-		if err := f.Unmarshal(p.output.Bytes()[p.start:read], v); err != nil {
-			return false, err
+		// A nil v only locates the front matter (see locate).
+		if v != nil {
+			data := p.output.Bytes()[p.start:read]
+			if err := f.Unmarshal(data, v); err != nil {
+				return false, p.syntaxError(f, data, err)
+			}
 		}
 
+		p.dataEnd = read
 		p.end = p.read
 		return true, nil
 	}
 }
 
+// syntaxError wraps an error decoding data, locating it in the document
+// when the format's decoder reports where it occurred.
+func (p *parser) syntaxError(f *Format, data []byte, err error) error {
+	serr := &SyntaxError{Err: err}
+	if f.codec != nil {
+		if line := f.codec.errorLine(err, data); line > 0 {
+			serr.Line = bytes.Count(p.output.Bytes()[:p.start], []byte("\n")) + line
+		}
+	}
+	return serr
+}
+
 func (p *parser) readLine() (string, bool, error) {
 	line, err := p.reader.ReadBytes('\n')
 
@@ -163,3 +235,29 @@ func (p *parser) readLine() (string, bool, error) {
 	_, err = p.output.Write(line)
 	return string(bytes.TrimSpace(line)), atEOF, err
 }
+
+// span is the location of a front matter within a document.
+type span struct {
+	format *Format
+	// start and end delimit the front matter data; the delimiters are included
+	// when the format unmarshals them.
+	start, end int
+}
+
+// locate finds the front matter of doc without decoding it. It returns nil
+// if doc has none.
+func locate(doc []byte, formats []*Format) (*span, error) {
+	if len(formats) == 0 {
+		formats = defaultFormats()
+	}
+	p := newParser(bytes.NewReader(doc))
+	f, err := p.detect(formats)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	found, err := p.extract(f, nil)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &span{format: f, start: p.start, end: p.dataEnd}, nil
+}
@@ -1,6 +1,8 @@
 package frontmatter
 
 import (
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -31,3 +33,147 @@ func TestParseStripsFrontmatter(t *testing.T) {
 		t.Fatalf("body missing markdown heading; got: %q", string(body))
 	}
 }
+
+type testMeta struct {
+	Name      string   `yaml:"name"`
+	Tags      []string `yaml:"tags"`
+	Created   string   `yaml:"created"`
+	Published string   `yaml:"published"`
+	Cover     string   `yaml:"cover"`
+}
+
+func TestParseFormats(t *testing.T) {
+	docs := map[string]string{
+		"toml":      "+++\nname = \"Note\"\ntags = [\"a/b\"]\ncreated = 2025-11-21\npublished = true\n+++\nBody\n",
+		"---toml":   "---toml\nname = \"Note\"\ntags = [\"a/b\"]\ncreated = 2025-11-21\npublished = true\n---\nBody\n",
+		"json":      "{\n  \"name\": \"Note\",\n  \"tags\": [\"a/b\"],\n  \"created\": \"2025-11-21\",\n  \"published\": true\n}\n\nBody\n",
+		"---json":   "---json\n{\"name\": \"Note\", \"tags\": [\"a/b\"], \"created\": \"2025-11-21\", \"published\": true}\n---\nBody\n",
+		"semicolon": ";;;\n{\"name\": \"Note\", \"tags\": [\"a/b\"], \"created\": \"2025-11-21\", \"published\": true}\n;;;\nBody\n",
+	}
+	want := testMeta{Name: "Note", Tags: []string{"a/b"}, Created: "2025-11-21", Published: "true"}
+	for name, doc := range docs {
+		var meta testMeta
+		body, err := Parse(strings.NewReader(doc), &meta)
+		if err != nil {
+			t.Fatalf("%s: Parse returned error: %v", name, err)
+		}
+		if !reflect.DeepEqual(meta, want) {
+			t.Fatalf("%s: got %+v, want %+v", name, meta, want)
+		}
+		if strings.TrimSpace(string(body)) != "Body" {
+			t.Fatalf("%s: unexpected body %q", name, body)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	meta := testMeta{Name: "Note", Tags: []string{"a/b", "c"}, Published: "true"}
+	cases := map[*Format]string{
+		YAML: "---\nname: Note\ntags:\n- a/b\n- c\npublished: \"true\"\n---\n",
+		TOML: "+++\nname = 'Note'\ntags = ['a/b', 'c']\npublished = 'true'\n+++\n",
+		JSON: "{\n  \"name\": \"Note\",\n  \"tags\": [\n    \"a/b\",\n    \"c\"\n  ],\n  \"published\": \"true\"\n}\n\n",
+	}
+	for f, want := range cases {
+		out, err := Marshal(meta, f)
+		if err != nil {
+			t.Fatalf("%s: Marshal returned error: %v", f.Start, err)
+		}
+		if string(out) != want {
+			t.Fatalf("%s: Marshal =\n%s\nwant\n%s", f.Start, out, want)
+		}
+		var back testMeta
+		if _, err := MustParse(strings.NewReader(string(out)+"Body\n"), &back); err != nil || !reflect.DeepEqual(back, meta) {
+			t.Fatalf("%s: round trip = %+v, %v", f.Start, back, err)
+		}
+	}
+	if _, err := Marshal(meta, NewFormat("~~~", "~~~", nil)); !errors.Is(err, ErrNotWritable) {
+		t.Fatalf("expected ErrNotWritable, got %v", err)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	cases := []struct {
+		name, doc, want string
+	}{
+		{
+			"yaml",
+			"---\n# Imported from the old blog\nname: Note\ntags:\n  - a/b\n  - old\nextra:\n  keep: [1, 2]\n\ncreated: 2025-11-21 # first draft\npublished: yes\n---\n\n# Heading\n\n---\nBody text.\n",
+			"---\n# Imported from the old blog\nname: Note\ntags:\n- a/b\n- new\nextra:\n  keep: [1, 2]\n\ncreated: 2025-11-21 # first draft\npublished: false\ncover: pic.png\n---\n\n# Heading\n\n---\nBody text.\n",
+		},
+		{
+			"toml",
+			"+++\nname = \"Note\"\ntags = [\"a/b\", \"old\"]\ncreated = 2025-11-21\npublished = true\n\n[extra]\nkeep = 1\n+++\nBody text.\n",
+			"+++\nname = 'Note'\ntags = ['a/b', 'new']\ncreated = 2025-11-21\npublished = false\ncover = 'pic.png'\n\n[extra]\nkeep = 1\n+++\nBody text.\n",
+		},
+		{
+			"json",
+			"{\n  \"name\": \"Note\",\n  \"extra\": {\"z\": 1, \"a\": \"<b>\"},\n  \"tags\": [\"a/b\", \"old\"],\n  \"created\": \"2025-11-21\",\n  \"published\": true\n}\n\nBody text.\n",
+			"{\n  \"name\": \"Note\",\n  \"extra\": {\n    \"z\": 1,\n    \"a\": \"<b>\"\n  },\n  \"tags\": [\n    \"a/b\",\n    \"new\"\n  ],\n  \"created\": \"2025-11-21\",\n  \"published\": false,\n  \"cover\": \"pic.png\"\n}\n\nBody text.\n",
+		},
+		{
+			"none",
+			"# Heading\n",
+			"---\nname: Note\ntags:\n- a/b\n- new\ncreated: \"2025-11-21\"\npublished: \"false\"\ncover: pic.png\n---\n\n# Heading\n",
+		},
+	}
+	for _, c := range cases {
+		var meta testMeta
+		if _, err := Parse(strings.NewReader(c.doc), &meta); err != nil {
+			t.Fatalf("%s: Parse returned error: %v", c.name, err)
+		}
+		if c.name == "none" {
+			meta = testMeta{Name: "Note", Tags: []string{"a/b", "old"}, Created: "2025-11-21"}
+		}
+		meta.Tags[1] = "new"
+		meta.Published = "false"
+		meta.Cover = "pic.png"
+
+		out, err := Rewrite([]byte(c.doc), meta)
+		if err != nil {
+			t.Fatalf("%s: Rewrite returned error: %v", c.name, err)
+		}
+		if string(out) != c.want {
+			t.Fatalf("%s: Rewrite =\n%s\nwant\n%s", c.name, out, c.want)
+		}
+		unchanged, err := Rewrite(out, meta)
+		if err != nil || string(unchanged) != string(out) {
+			t.Fatalf("%s: second Rewrite changed the document:\n%s", c.name, unchanged)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	cases := map[string]map[string]Position{
+		"\n---\nname: Note\n# comment\ntags:\n  - a/b\n  - c\nlead: |\n  - not an item\n---\nBody\n": {
+			"name": {3, 1}, "tags": {5, 1}, "tags[0]": {6, 3}, "tags[1]": {7, 3}, "lead": {8, 1},
+		},
+		"+++\nname = \"Note\"\n\n[extra]\nkeep = 1\n+++\n": {"name": {2, 1}, "extra": {4, 2}},
+		"{\n  \"name\": \"Nöte\", \"tags\": []\n}\n\n":     {"name": {2, 3}, "tags": {2, 19}},
+	}
+	for doc, want := range cases {
+		got, err := Positions([]byte(doc))
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("Positions(%q) = %v, %v; want %v", doc, got, err, want)
+		}
+	}
+}
+
+func TestSyntaxErrorLine(t *testing.T) {
+	cases := map[string]int{
+		"---\nname: Note\ntags: [a\nlead: x\n---\n":                  0,
+		"\n---\nname: Note\ntags:\n  nested: {a: 1}\n---\n":          5,
+		"+++\nname = \"Note\"\ntags = \n+++\n":                       3,
+		"---json\n{\n  \"name\": \"Note\",\n  \"tags\": ]\n}\n---\n": 4,
+	}
+	for doc, line := range cases {
+		var meta testMeta
+		_, err := Parse(strings.NewReader(doc), &meta)
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Fatalf("Parse(%q): expected SyntaxError, got %v", doc, err)
+		}
+		if line > 0 && serr.Line != line {
+			t.Fatalf("Parse(%q): error line %d, want %d (%v)", doc, serr.Line, line, err)
+		}
+	}
+}
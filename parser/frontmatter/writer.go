@@ -0,0 +1,218 @@
+package frontmatter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ErrNotWritable is reported by `Marshal` and `Rewrite` for formats they
+// cannot write, i.e. formats created with `NewFormat`.
+var ErrNotWritable = errors.New("format cannot be written")
+
+// Marshal encodes the value `v` as a front matter in format `f` (YAML if
+// nil), delimiters included. `v` is encoded through its `yaml` tags; keys
+// with zero values are left out.
+func Marshal(v interface{}, f *Format) ([]byte, error) {
+	if f == nil {
+		f = YAML
+	}
+	if f.codec == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotWritable, f.Start)
+	}
+	items, err := toMapSlice(v)
+	if err != nil {
+		return nil, err
+	}
+	m := &mapping{}
+	for _, item := range items {
+		if !isZero(item.Value) {
+			m.entries = append(m.entries, entry{key: fmt.Sprint(item.Key), value: item.Value})
+		}
+	}
+	data, err := f.codec.encode(m)
+	if err != nil {
+		return nil, err
+	}
+	return wrap(f, bytes.TrimRight(data, "\n")), nil
+}
+
+// Rewrite returns the document `doc` with its front matter updated to the
+// value `v`, encoded as by `Marshal`. Keys `v` does not know are kept, keys
+// keep their order and unchanged YAML and JSON entries keep their text; new
+// keys with non-zero values are appended. The rest of the document is kept
+// verbatim. A document without front matter gets a YAML one.
+// Front matters are detected based on the passed in `formats`.
+// If no formats are provided, the default formats are used.
+func Rewrite(doc []byte, v interface{}, formats ...*Format) ([]byte, error) {
+	s, err := locate(doc, formats)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		fm, err := Marshal(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(doc) > 0 && doc[0] != '\n' {
+			fm = append(fm, '\n')
+		}
+		return append(fm, doc...), nil
+	}
+	if s.format.codec == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotWritable, s.format.Start)
+	}
+
+	data := doc[s.start:s.end]
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	m, err := s.format.codec.decode(trimmed)
+	if err != nil {
+		return nil, err
+	}
+	items, err := toMapSlice(v)
+	if err != nil {
+		return nil, err
+	}
+	merge(m, items, s.format.codec)
+	out, err := s.format.codec.encode(m)
+	if err != nil {
+		return nil, err
+	}
+	out = bytes.TrimRight(out, "\n")
+
+	trailing := data[len(trimmed):]
+	if len(trailing) == 0 && len(out) > 0 && !s.format.UnmarshalDelims {
+		trailing = []byte("\n") // the end delimiter needs a line of its own
+	}
+	result := make([]byte, 0, len(doc)-len(data)+len(out)+len(trailing))
+	result = append(result, doc[:s.start]...)
+	result = append(result, out...)
+	result = append(result, trailing...)
+	return append(result, doc[s.end:]...), nil
+}
+
+// wrap adds the delimiters of `f` to front matter data.
+func wrap(f *Format, data []byte) []byte {
+	var buf bytes.Buffer
+	if !f.UnmarshalDelims {
+		buf.WriteString(f.Start + "\n")
+	}
+	if len(data) > 0 {
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if !f.UnmarshalDelims {
+		buf.WriteString(f.End + "\n")
+	}
+	if f.RequiresNewLine {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// merge updates the entries of `m` to the values of `items`.
+func merge(m *mapping, items yaml.MapSlice, c *codec) {
+	index := make(map[string]int, len(m.entries))
+	for i, e := range m.entries {
+		index[e.key] = i
+	}
+	for _, item := range items {
+		key := fmt.Sprint(item.Key)
+		i, ok := index[key]
+		if !ok {
+			if !isZero(item.Value) {
+				m.entries = append(m.entries, entry{key: key, value: item.Value})
+			}
+			continue
+		}
+		e := &m.entries[i]
+		if isZero(e.value) && isZero(item.Value) || reflect.DeepEqual(plain(e.value), plain(item.Value)) {
+			continue
+		}
+		e.value, e.raw = retype(e.value, item.Value, c), nil
+	}
+}
+
+// retype returns the new value of an entry. Structs often hold scalars as
+// strings (`published: true` in a string field); such a value keeps the type
+// it had in the document if it still parses as one.
+func retype(old, new interface{}, c *codec) interface{} {
+	s, ok := new.(string)
+	if !ok || old == nil {
+		return new
+	}
+	if _, ok := old.(string); ok {
+		return new
+	}
+	v, err := c.scalar(s)
+	if err != nil || reflect.TypeOf(v) != reflect.TypeOf(old) {
+		return new
+	}
+	return v
+}
+
+// plain converts a value for comparison: maps of every format become
+// map[string]interface{} and scalars their text.
+func plain(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case yaml.MapSlice:
+		out := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			out[fmt.Sprint(item.Key)] = plain(item.Value)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[fmt.Sprint(k)] = plain(val)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[k] = plain(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = plain(val)
+		}
+		return out
+	}
+	return fmt.Sprint(yamlValue(v))
+}
+
+// isZero reports whether `v` is a zero value or an empty sequence or map.
+func isZero(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
+
+// toMapSlice encodes `v` as a YAML mapping.
+func toMapSlice(v interface{}) (yaml.MapSlice, error) {
+	if items, ok := v.(yaml.MapSlice); ok {
+		return items, nil
+	}
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var items yaml.MapSlice
+	if err := yaml.Unmarshal(out, &items); err != nil {
+		return nil, fmt.Errorf("front matter must encode as a mapping: %w", err)
+	}
+	return items, nil
+}
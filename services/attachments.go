@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// Attachments: ![[image.png]] embeds and relative references such as ![](image.png) or
+// [slides](files/deck.pdf) point at files stored next to the posts. They are rewritten to
+// /attachments/<path> when the document renders.
+
+// AttachmentFunc maps an attachment reference of the document being rendered to the stored
+// attachment's path. ok is false when no attachment matches.
+type AttachmentFunc func(ref string) (path string, ok bool)
+
+// ErrAttachmentsUnsupported is returned when the storage backend keeps no attachments.
+var ErrAttachmentsUnsupported = errors.New("attachments are not supported by this storage backend")
+
+// KindAttachment is the AST node kind of an ![[attachment]] embed.
+var KindAttachment = ast.NewNodeKind("Attachment")
+
+// AttachmentNode is an embedded image or document. An empty Href renders as missing; Image is
+// set for images whose dimensions are known.
+type AttachmentNode struct {
+	ast.BaseInline
+	Link  storage.WikiLink
+	Href  string
+	Image *ImageInfo
+}
+
+func (n *AttachmentNode) Kind() ast.NodeKind { return KindAttachment }
+
+func (n *AttachmentNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Link.Target, "Href": n.Href}, nil)
+}
+
+var attachmentFuncKey = parser.NewContextKey()
+
+// isAttachmentRef reports whether a wikilink target or link destination names an attachment.
+func isAttachmentRef(ref string) bool {
+	_, ok := storage.AttachmentContentType(ref)
+	return ok
+}
+
+// attachmentHref returns the URL of the attachment ref refers to, or "" when it matches none.
+// Documents rendered without an AttachmentFunc map every reference to /attachments/<ref>.
+func attachmentHref(pc parser.Context, ref string) string {
+	if unescaped, err := url.PathUnescape(ref); err == nil {
+		ref = unescaped
+	}
+	p, ok := "", false
+	if fn, _ := pc.Get(attachmentFuncKey).(AttachmentFunc); fn != nil {
+		p, ok = fn(ref)
+	} else {
+		p, ok = storage.CleanAttachmentPath(ref)
+	}
+	if !ok {
+		return ""
+	}
+	return "/attachments/" + p
+}
+
+// attachmentLinkTransformer points relative link and image destinations that name attachments
+// at /attachments/ and sizes the images. Unresolved destinations are left alone.
+type attachmentLinkTransformer struct{}
+
+func (t *attachmentLinkTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		var dest *[]byte
+		img, isImage := n.(*ast.Image)
+		switch n := n.(type) {
+		case *ast.Image:
+			dest = &n.Destination
+		case *ast.Link:
+			dest = &n.Destination
+		default:
+			return ast.WalkContinue, nil
+		}
+		ref, ok := relativeRef(string(*dest))
+		if !ok || !isAttachmentRef(ref) {
+			return ast.WalkContinue, nil
+		}
+		href := attachmentHref(pc, ref)
+		if href == "" {
+			return ast.WalkContinue, nil
+		}
+		*dest = []byte(href)
+		if info := attachmentImage(pc, href); isImage && info != nil {
+			setImageAttrs(img, responsiveAttrs(href, info, 0, 0))
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// relativeRef returns the path of a relative URL without query or fragment.
+func relativeRef(dest string) (string, bool) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return "", false
+	}
+	return u.Path, true
+}
+
+var attachmentSizeRe = regexp.MustCompile(`^(\d+)(?:x(\d+))?$`)
+
+type attachmentRenderer struct{}
+
+func (r *attachmentRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindAttachment, r.render)
+}
+
+// render writes images as <img> (Obsidian's ![[image.png|300]] or |300x200 sets the size, any
+// other label the alt text; known images get a srcset over their variants) and other
+// attachments as links.
+func (r *attachmentRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	node := n.(*AttachmentNode)
+	name := path.Base(node.Link.Target)
+	if node.Href == "" {
+		_, _ = w.WriteString(`<span class="attachment attachment-missing" title="Attachment not found">`)
+		_, _ = w.Write(util.EscapeHTML([]byte(name)))
+		_, _ = w.WriteString(`</span>`)
+		return ast.WalkSkipChildren, nil
+	}
+	href := util.EscapeHTML(util.URLEscape([]byte(node.Href), false))
+	ct, _ := storage.AttachmentContentType(node.Link.Target)
+	if !strings.HasPrefix(ct, "image/") {
+		label := name
+		if node.Link.Label != "" {
+			label = node.Link.Label
+		}
+		_, _ = w.WriteString(`<a class="attachment" href="`)
+		_, _ = w.Write(href)
+		_, _ = w.WriteString(`">`)
+		_, _ = w.Write(util.EscapeHTML([]byte(label)))
+		_, _ = w.WriteString(`</a>`)
+		return ast.WalkSkipChildren, nil
+	}
+	alt, size := name, attachmentSizeRe.FindStringSubmatch(node.Link.Label)
+	var width, height int
+	if size != nil {
+		width, _ = strconv.Atoi(size[1])
+		height, _ = strconv.Atoi(size[2])
+	} else if node.Link.Label != "" {
+		alt = node.Link.Label
+	}
+	attrs := imageAttrs{width: width, height: height}
+	if node.Image != nil {
+		attrs = responsiveAttrs(node.Href, node.Image, width, height)
+	}
+	_, _ = w.WriteString(`<img class="attachment" src="`)
+	_, _ = w.Write(href)
+	_, _ = w.WriteString(`" alt="`)
+	_, _ = w.Write(util.EscapeHTML([]byte(alt)))
+	_, _ = w.WriteString(`"`)
+	if attrs.width > 0 {
+		_, _ = w.WriteString(` width="` + strconv.Itoa(attrs.width) + `"`)
+	}
+	if attrs.height > 0 {
+		_, _ = w.WriteString(` height="` + strconv.Itoa(attrs.height) + `"`)
+	}
+	if attrs.srcset != "" {
+		_, _ = w.WriteString(` srcset="`)
+		_, _ = w.Write(util.EscapeHTML([]byte(attrs.srcset)))
+		_, _ = w.WriteString(`" sizes="` + attrs.sizes + `"`)
+	}
+	_, _ = w.WriteString(` loading="lazy" />`)
+	return ast.WalkSkipChildren, nil
+}
+
+type attachments struct{}
+
+// Attachments is a goldmark extension rendering ![[attachment]] embeds and rewriting relative
+// attachment links through the AttachmentFunc in the parser context (see convertMarkdown).
+var Attachments goldmark.Extender = &attachments{}
+
+func (e *attachments) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(&attachmentLinkTransformer{}, 100)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&attachmentRenderer{}, 199)))
+}
+
+// attachmentIndex resolves attachment references like Obsidian: relative to the note first,
+// then as a full path, then by file name anywhere (shortest path wins).
+type attachmentIndex struct {
+	paths  map[string]*storage.Attachment
+	byName map[string][]string // lowercased base name -> paths, shortest first
+}
+
+func newAttachmentIndex(list []*storage.Attachment) *attachmentIndex {
+	idx := &attachmentIndex{paths: map[string]*storage.Attachment{}, byName: map[string][]string{}}
+	for _, a := range list {
+		idx.paths[a.Path] = a
+		name := strings.ToLower(path.Base(a.Path))
+		idx.byName[name] = append(idx.byName[name], a.Path)
+	}
+	for _, paths := range idx.byName {
+		sort.Slice(paths, func(i, j int) bool {
+			if len(paths[i]) != len(paths[j]) {
+				return len(paths[i]) < len(paths[j])
+			}
+			return paths[i] < paths[j]
+		})
+	}
+	return idx
+}
+
+// resolve finds ref as written in post. Notes in folders (filesystem backend) look next to
+// themselves; other backends keep a post's uploads under its slug.
+func (idx *attachmentIndex) resolve(post *storage.Post, ref string) (string, bool) {
+	var candidates []string
+	if post != nil {
+		candidates = append(candidates,
+			path.Join(post.Meta.Folder, ref),
+			path.Join(post.Meta.Folder, "attachments", ref),
+			path.Join(strings.TrimSuffix(post.Meta.Slug, ".md"), ref))
+	}
+	candidates = append(candidates, ref)
+	for _, c := range candidates {
+		if p, ok := storage.CleanAttachmentPath(c); ok {
+			if _, found := idx.paths[p]; found {
+				return p, true
+			}
+		}
+	}
+	if paths := idx.byName[strings.ToLower(path.Base(ref))]; len(paths) > 0 {
+		return paths[0], true
+	}
+	return "", false
+}
+
+// attachmentsDep marks renders that resolved attachments; uploads and deletions drop them.
+const attachmentsDep = "@attachments"
+
+// attachmentStore returns the backend's attachment store, if it has one.
+func (s *PostService) attachmentStore() (storage.AttachmentStore, error) {
+	as, ok := s.store.(storage.AttachmentStore)
+	if !ok {
+		return nil, ErrAttachmentsUnsupported
+	}
+	return as, nil
+}
+
+// attachmentIndex lists the stored attachments; nil when the backend has none.
+func (s *PostService) attachmentIndex(ctx context.Context) *attachmentIndex {
+	as, err := s.attachmentStore()
+	if err != nil {
+		return nil
+	}
+	list, err := as.ListAttachments(ctx)
+	if err != nil {
+		return nil
+	}
+	return newAttachmentIndex(list)
+}
+
+// attachmentResolver resolves the attachment references of post and describes the images
+// among them. The attachment list is only fetched once the document references an attachment.
+func (s *PostService) attachmentResolver(post *storage.Post, ctx context.Context) (AttachmentFunc, ImageFunc) {
+	index := sync.OnceValue(func() *attachmentIndex { return s.attachmentIndex(ctx) })
+	resolve := func(ref string) (string, bool) {
+		idx := index()
+		if idx == nil {
+			return "", false
+		}
+		return idx.resolve(post, ref)
+	}
+	image := func(path string) (ImageInfo, bool) {
+		idx := index()
+		if idx == nil || idx.paths[path] == nil {
+			return ImageInfo{}, false
+		}
+		return s.imageInfo(idx.paths[path], ctx)
+	}
+	return resolve, image
+}
+
+// UploadAttachment stores an image or PDF next to the post slug and refreshes the renders that
+// resolved attachments.
+func (s *PostService) UploadAttachment(slug, filename string, data []byte, ctx context.Context) (*storage.Attachment, error) {
+	as, err := s.attachmentStore()
+	if err != nil {
+		return nil, err
+	}
+	att, err := as.PutAttachment(ctx, slug, filename, data)
+	if err != nil {
+		return nil, err
+	}
+	s.render.Invalidate(attachmentsDep)
+	s.links.reset()
+	return att, nil
+}
+
+// GetAttachment returns the attachment at path and its content.
+func (s *PostService) GetAttachment(path string, ctx context.Context) (*storage.Attachment, []byte, error) {
+	as, err := s.attachmentStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	return as.GetAttachment(ctx, path)
+}
+
+// DeleteAttachment removes the attachment at path.
+func (s *PostService) DeleteAttachment(path string, ctx context.Context) error {
+	as, err := s.attachmentStore()
+	if err != nil {
+		return err
+	}
+	if err := as.DeleteAttachment(ctx, path); err != nil {
+		return err
+	}
+	s.render.Invalidate(attachmentsDep)
+	s.links.reset()
+	return nil
+}
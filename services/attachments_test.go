@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	firebaseauth "firebase.google.com/go/v4/auth"
+
+	"github.com/soockee/cybersocke.com/session"
+)
+
+func TestRenderAttachments(t *testing.T) {
+	ps := newTestPostService(t, map[string]string{
+		"host.md": testNote("Host", "2024-01-02", true, "![[logo.png|300]]\n\n![[logo.png|300x200]] ![[logo.png|Our logo]] ![rel](logo.png \"Logo\") [deck](deck.pdf)\n\n"+
+			"![[deck.pdf|Slides]] ![[none.png]] ![ext](https://example.com/x.png) [post](other.md)", "type/note"),
+		"logo.png": "\x89PNG",
+	})
+	ctx := context.Background()
+	out := renderSlug(t, ps, "host.md", ctx)
+	for _, want := range []string{
+		`<img class="attachment" src="/attachments/logo.png" alt="logo.png" width="300" loading="lazy" />`,
+		`<img class="attachment" src="/attachments/logo.png" alt="logo.png" width="300" height="200" loading="lazy" />`,
+		`<img class="attachment" src="/attachments/logo.png" alt="Our logo" loading="lazy" />`,
+		`<img src="/attachments/logo.png" alt="rel" title="Logo" />`,
+		`<a href="deck.pdf">deck</a>`,
+		`<span class="attachment attachment-missing" title="Attachment not found">deck.pdf</span>`,
+		`<span class="attachment attachment-missing" title="Attachment not found">none.png</span>`,
+		`<img src="https://example.com/x.png" alt="ext" />`,
+		`<a href="other.md">post</a>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("render missing %q:\n%s", want, out)
+		}
+	}
+
+	// Uploading the missing PDF refreshes the cached render.
+	authed := context.WithValue(ctx, session.IdTokenKey, &firebaseauth.Token{UID: "u1"})
+	att, err := ps.UploadAttachment("host.md", "deck.pdf", []byte("%PDF-1.7\n"), authed)
+	if err != nil {
+		t.Fatalf("UploadAttachment: %v", err)
+	}
+	if att.Path != "attachments/deck.pdf" {
+		t.Fatalf("unexpected attachment path %q", att.Path)
+	}
+	out = renderSlug(t, ps, "host.md", ctx)
+	for _, want := range []string{
+		`<a href="/attachments/attachments/deck.pdf">deck</a>`,
+		`<a class="attachment" href="/attachments/attachments/deck.pdf">Slides</a>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("render after upload missing %q:\n%s", want, out)
+		}
+	}
+
+	if err := ps.DeleteAttachment(att.Path, authed); err != nil {
+		t.Fatalf("DeleteAttachment: %v", err)
+	}
+	if out = renderSlug(t, ps, "host.md", ctx); !strings.Contains(out, `attachment-missing" title="Attachment not found">deck.pdf`) {
+		t.Fatalf("deleted attachment still rendered:\n%s", out)
+	}
+}
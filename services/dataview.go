@@ -0,0 +1,865 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// A small subset of the Obsidian Dataview query language, evaluated over the post cache:
+//
+//	LIST [field]
+//	TABLE [WITHOUT ID] field [AS "Header"], ...
+//	FROM #tag | "folder"   combined with and, or, - (not) and parentheses
+//	WHERE expression       comparisons (= != < <= > >=), and, or, !, contains(a, b), date(x)
+//	SORT field [ASC|DESC], ...
+//	LIMIT n
+//
+// Clauses may share a line or span several. Fields are the frontmatter fields of PostMeta plus
+// the file.* fields Dataview provides (see dataviewField).
+
+// DataviewQuery is a parsed dataview block.
+type DataviewQuery struct {
+	Table   bool
+	WithID  bool     // show the file column in tables
+	Fields  []string // LIST: at most one field shown after the link; TABLE: one per column
+	Headers []string // column headers, parallel to Fields
+	from    func(*storage.Post) bool
+	where   func(*storage.Post) bool
+	sort    []dataviewSortKey
+	limit   int
+}
+
+type dataviewSortKey struct {
+	field string
+	desc  bool
+}
+
+// DataviewResult is the outcome of running a query.
+type DataviewResult struct {
+	Query *DataviewQuery
+	Rows  []DataviewRow
+}
+
+// DataviewRow is one matching post with the values of the query's fields.
+type DataviewRow struct {
+	Slug   string
+	Name   string
+	Values []any
+}
+
+// DataviewLink is a field value pointing at a post, rendered as a link.
+type DataviewLink struct {
+	Slug string
+	Name string
+}
+
+// DataviewFunc runs the query in a ```dataview block of the document being rendered.
+type DataviewFunc func(query string) (*DataviewResult, error)
+
+// Run evaluates q over posts.
+func (q *DataviewQuery) Run(posts []*storage.Post) *DataviewResult {
+	var rows []*storage.Post
+	for _, p := range posts {
+		if (q.from == nil || q.from(p)) && (q.where == nil || q.where(p)) {
+			rows = append(rows, p)
+		}
+	}
+	keys := q.sort
+	if len(keys) == 0 {
+		keys = []dataviewSortKey{{field: "file.name"}}
+	}
+	slices.SortStableFunc(rows, func(a, b *storage.Post) int {
+		for _, k := range keys {
+			c, _ := compareDataview(dataviewField(a, k.field), dataviewField(b, k.field))
+			if k.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(a.Meta.Slug, b.Meta.Slug)
+	})
+	if q.limit > 0 && len(rows) > q.limit {
+		rows = rows[:q.limit]
+	}
+	res := &DataviewResult{Query: q, Rows: make([]DataviewRow, 0, len(rows))}
+	for _, p := range rows {
+		row := DataviewRow{Slug: p.Meta.Slug, Name: p.Meta.Name}
+		for _, f := range q.Fields {
+			row.Values = append(row.Values, dataviewField(p, f))
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	return res
+}
+
+// dataviewField returns the value of a field of p: string, bool, time.Time, []string,
+// DataviewLink or nil when the post has no such field. Names are case-insensitive.
+func dataviewField(p *storage.Post, name string) any {
+	m := p.Meta
+	switch strings.ToLower(name) {
+	case "file.name":
+		return strings.TrimSuffix(m.Slug, ".md")
+	case "file.link":
+		return DataviewLink{Slug: m.Slug, Name: m.Name}
+	case "file.folder", "folder":
+		return m.Folder
+	case "file.path":
+		if m.Folder == "" {
+			return m.Slug
+		}
+		return m.Folder + "/" + m.Slug
+	case "file.tags", "tags":
+		return m.Tags
+	case "file.aliases", "aliases":
+		return m.Aliases
+	case "file.ctime", "file.cday", "created":
+		return dataviewTime(m.Created)
+	case "file.mtime", "file.mday", "updated":
+		return dataviewTime(m.Updated)
+	case "name", "title":
+		return m.Name
+	case "slug":
+		return m.Slug
+	case "lead", "description":
+		return m.Lead
+	case "published":
+		return m.Published
+	}
+	return nil
+}
+
+func dataviewTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// compareDataview orders two values. ok is false when they cannot be compared; nil sorts first.
+func compareDataview(a, b any) (c int, ok bool) {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0, true
+		case a == nil:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+	switch av := a.(type) {
+	case string:
+		switch bv := b.(type) {
+		case string:
+			return strings.Compare(av, bv), true
+		case time.Time, float64:
+			c, ok := compareDataview(b, a)
+			return -c, ok
+		}
+	case float64:
+		switch bv := b.(type) {
+		case float64:
+			return cmpOrdered(av, bv), true
+		case string:
+			if f, err := strconv.ParseFloat(bv, 64); err == nil {
+				return cmpOrdered(av, f), true
+			}
+		}
+	case bool:
+		if bv, isBool := b.(bool); isBool {
+			switch {
+			case av == bv:
+				return 0, true
+			case !av:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	case time.Time:
+		bt, isTime := b.(time.Time)
+		if s, isString := b.(string); isString {
+			bt, isTime = parseDataviewDate(s)
+		}
+		if isTime {
+			return av.Compare(bt), true
+		}
+	case []string:
+		if bv, isList := b.([]string); isList {
+			return slices.Compare(av, bv), true
+		}
+	case DataviewLink:
+		if bv, isLink := b.(DataviewLink); isLink {
+			return strings.Compare(av.Slug, bv.Slug), true
+		}
+	}
+	return 0, false
+}
+
+func cmpOrdered(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func parseDataviewDate(s string) (time.Time, bool) {
+	switch strings.ToLower(s) {
+	case "today":
+		now := time.Now().UTC()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), true
+	case "now":
+		return time.Now().UTC(), true
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// dataviewDateKeyword reports whether word names a moment rather than a field.
+func dataviewDateKeyword(word string) bool {
+	return strings.EqualFold(word, "today") || strings.EqualFold(word, "now")
+}
+
+// dataviewContains reports whether list contains value (lists, tags without their "#") or
+// string contains substring.
+func dataviewContains(haystack, needle any) bool {
+	switch h := haystack.(type) {
+	case []string:
+		want := strings.TrimPrefix(fmt.Sprint(needle), "#")
+		for _, v := range h {
+			if v == want {
+				return true
+			}
+		}
+	case string:
+		if n, ok := needle.(string); ok {
+			return strings.Contains(h, n)
+		}
+	}
+	return false
+}
+
+func dataviewTruthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	case []string:
+		return len(v) > 0
+	case time.Time:
+		return !v.IsZero()
+	}
+	return true
+}
+
+// ParseDataviewQuery parses the body of a ```dataview block.
+func ParseDataviewQuery(src string) (*DataviewQuery, error) {
+	toks, err := lexDataview(src)
+	if err != nil {
+		return nil, fmt.Errorf("dataview: %w", err)
+	}
+	p := &dataviewParser{toks: toks}
+	q := &DataviewQuery{WithID: true}
+	switch {
+	case p.keyword("LIST"):
+		if p.peek().kind == dvWord && !p.atClause() {
+			q.Fields = []string{p.next().text}
+			q.Headers = q.Fields
+		}
+	case p.keyword("TABLE"):
+		q.Table = true
+		if p.keyword("WITHOUT") {
+			if !p.keyword("ID") {
+				return nil, p.errorf("expected ID after WITHOUT")
+			}
+			q.WithID = false
+		}
+		for p.peek().kind == dvWord && !p.atClause() {
+			field := p.next().text
+			header := field
+			if p.keyword("AS") {
+				t := p.next()
+				if t.kind != dvWord && t.kind != dvString {
+					return nil, p.errorf("expected a column name after AS")
+				}
+				header = t.text
+			}
+			q.Fields = append(q.Fields, field)
+			q.Headers = append(q.Headers, header)
+			if !p.punct(",") {
+				break
+			}
+			// A comma must be followed by another field, not a clause or the end.
+			if p.peek().kind != dvWord || p.atClause() {
+				return nil, p.errorf("expected a field after ,")
+			}
+		}
+	default:
+		return nil, p.errorf("query must start with LIST or TABLE")
+	}
+	for p.peek().kind != dvEOF {
+		switch {
+		case p.keyword("FROM"):
+			if q.from, err = p.sourceOr(); err != nil {
+				return nil, err
+			}
+		case p.keyword("WHERE"):
+			if q.where, err = p.exprOr(); err != nil {
+				return nil, err
+			}
+		case p.keyword("SORT"):
+			for {
+				t := p.next()
+				if t.kind != dvWord {
+					return nil, p.errorf("expected a field to sort by")
+				}
+				key := dataviewSortKey{field: t.text}
+				if p.keyword("DESC") {
+					key.desc = true
+				} else {
+					p.keyword("ASC")
+				}
+				q.sort = append(q.sort, key)
+				if !p.punct(",") {
+					break
+				}
+			}
+		case p.keyword("LIMIT"):
+			t := p.next()
+			n, err := strconv.Atoi(t.text)
+			if t.kind != dvNumber || err != nil || n < 0 {
+				return nil, p.errorf("LIMIT needs a number")
+			}
+			q.limit = n
+		default:
+			return nil, p.errorf("unexpected %q", p.peek().text)
+		}
+	}
+	return q, nil
+}
+
+type dvKind int
+
+const (
+	dvEOF dvKind = iota
+	dvWord
+	dvString
+	dvNumber
+	dvTag
+	dvOp
+	dvPunct
+)
+
+type dvToken struct {
+	kind dvKind
+	text string
+}
+
+func lexDataview(src string) ([]dvToken, error) {
+	var toks []dvToken
+	isWord := func(c byte) bool {
+		return c == '_' || c == '.' || c == '-' || c == '/' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				b.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			toks = append(toks, dvToken{dvString, b.String()})
+			i = j + 1
+		case c == '#':
+			j := i + 1
+			for j < len(src) && isWord(src[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("empty tag")
+			}
+			toks = append(toks, dvToken{dvTag, src[i+1 : j]})
+			i = j
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || strings.IndexByte(".-:T", src[j]) >= 0) {
+				j++
+			}
+			toks = append(toks, dvToken{dvNumber, src[i:j]})
+			i = j
+		case strings.IndexByte("=!<>", c) >= 0:
+			j := i + 1
+			if j < len(src) && src[j] == '=' {
+				j++
+			}
+			toks = append(toks, dvToken{dvOp, src[i:j]})
+			i = j
+		case strings.IndexByte(",()", c) >= 0 || c == '-' && i+1 < len(src) && (src[i+1] == '#' || src[i+1] == '"'):
+			toks = append(toks, dvToken{dvPunct, string(c)})
+			i++
+		case isWord(c):
+			j := i
+			for j < len(src) && isWord(src[j]) {
+				j++
+			}
+			toks = append(toks, dvToken{dvWord, src[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return toks, nil
+}
+
+type dataviewParser struct {
+	toks []dvToken
+	pos  int
+}
+
+func (p *dataviewParser) peek() dvToken {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return dvToken{kind: dvEOF}
+}
+
+func (p *dataviewParser) next() dvToken {
+	t := p.peek()
+	if p.pos < len(p.toks) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *dataviewParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("dataview: "+format, args...)
+}
+
+// keyword consumes the next token if it is the (case-insensitive) keyword kw.
+func (p *dataviewParser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == dvWord && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *dataviewParser) punct(s string) bool {
+	if t := p.peek(); t.kind == dvPunct && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *dataviewParser) atClause() bool {
+	t := p.peek()
+	if t.kind != dvWord {
+		return false
+	}
+	switch strings.ToUpper(t.text) {
+	case "FROM", "WHERE", "SORT", "LIMIT":
+		return true
+	}
+	return false
+}
+
+type dvPredicate = func(*storage.Post) bool
+
+func (p *dataviewParser) sourceOr() (dvPredicate, error) {
+	left, err := p.sourceAnd()
+	for err == nil && p.keyword("or") {
+		var right dvPredicate
+		if right, err = p.sourceAnd(); err == nil {
+			l := left
+			left = func(post *storage.Post) bool { return l(post) || right(post) }
+		}
+	}
+	return left, err
+}
+
+func (p *dataviewParser) sourceAnd() (dvPredicate, error) {
+	left, err := p.source()
+	for err == nil && p.keyword("and") {
+		var right dvPredicate
+		if right, err = p.source(); err == nil {
+			l := left
+			left = func(post *storage.Post) bool { return l(post) && right(post) }
+		}
+	}
+	return left, err
+}
+
+func (p *dataviewParser) source() (dvPredicate, error) {
+	if p.punct("-") {
+		inner, err := p.source()
+		if err != nil {
+			return nil, err
+		}
+		return func(post *storage.Post) bool { return !inner(post) }, nil
+	}
+	if p.punct("(") {
+		inner, err := p.sourceOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.punct(")") {
+			return nil, p.errorf("missing ) in FROM")
+		}
+		return inner, nil
+	}
+	t := p.next()
+	switch t.kind {
+	case dvTag:
+		// #theme matches theme and nested tags such as theme/kubernetes, like in Obsidian.
+		tag := t.text
+		return func(post *storage.Post) bool {
+			for _, have := range post.Meta.Tags {
+				if have == tag || strings.HasPrefix(have, tag+"/") {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case dvString:
+		folder := strings.Trim(t.text, "/")
+		return func(post *storage.Post) bool {
+			return folder == "" || post.Meta.Folder == folder || strings.HasPrefix(post.Meta.Folder, folder+"/")
+		}, nil
+	}
+	return nil, p.errorf("FROM expects #tag or \"folder\"")
+}
+
+type dvOperand = func(*storage.Post) any
+
+func (p *dataviewParser) exprOr() (dvPredicate, error) {
+	left, err := p.exprAnd()
+	for err == nil && p.keyword("or") {
+		var right dvPredicate
+		if right, err = p.exprAnd(); err == nil {
+			l := left
+			left = func(post *storage.Post) bool { return l(post) || right(post) }
+		}
+	}
+	return left, err
+}
+
+func (p *dataviewParser) exprAnd() (dvPredicate, error) {
+	left, err := p.exprNot()
+	for err == nil && p.keyword("and") {
+		var right dvPredicate
+		if right, err = p.exprNot(); err == nil {
+			l := left
+			left = func(post *storage.Post) bool { return l(post) && right(post) }
+		}
+	}
+	return left, err
+}
+
+func (p *dataviewParser) exprNot() (dvPredicate, error) {
+	if t := p.peek(); t.kind == dvOp && t.text == "!" {
+		p.pos++
+		inner, err := p.exprNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(post *storage.Post) bool { return !inner(post) }, nil
+	}
+	if p.punct("(") {
+		inner, err := p.exprOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.punct(")") {
+			return nil, p.errorf("missing ) in WHERE")
+		}
+		return inner, nil
+	}
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	if op.kind != dvOp || op.text == "!" {
+		return func(post *storage.Post) bool { return dataviewTruthy(left(post)) }, nil
+	}
+	p.pos++
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	var test func(c int) bool
+	switch op.text {
+	case "=", "==":
+		test = func(c int) bool { return c == 0 }
+	case "!=":
+		return func(post *storage.Post) bool {
+			c, ok := compareDataview(left(post), right(post))
+			return !ok || c != 0
+		}, nil
+	case "<":
+		test = func(c int) bool { return c < 0 }
+	case "<=":
+		test = func(c int) bool { return c <= 0 }
+	case ">":
+		test = func(c int) bool { return c > 0 }
+	case ">=":
+		test = func(c int) bool { return c >= 0 }
+	default:
+		return nil, p.errorf("unknown operator %q", op.text)
+	}
+	return func(post *storage.Post) bool {
+		c, ok := compareDataview(left(post), right(post))
+		return ok && test(c)
+	}, nil
+}
+
+func (p *dataviewParser) operand() (dvOperand, error) {
+	t := p.next()
+	switch t.kind {
+	case dvString:
+		return func(*storage.Post) any { return t.text }, nil
+	case dvTag:
+		return func(*storage.Post) any { return t.text }, nil
+	case dvNumber:
+		if d, ok := parseDataviewDate(t.text); ok {
+			return func(*storage.Post) any { return d }, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf("bad number %q", t.text)
+		}
+		return func(*storage.Post) any { return f }, nil
+	case dvWord:
+		switch strings.ToLower(t.text) {
+		case "true", "false":
+			b := strings.EqualFold(t.text, "true")
+			return func(*storage.Post) any { return b }, nil
+		}
+		if !p.punct("(") {
+			field := t.text
+			return func(post *storage.Post) any { return dataviewField(post, field) }, nil
+		}
+		return p.call(strings.ToLower(t.text))
+	}
+	return nil, p.errorf("expected a field or value, got %q", t.text)
+}
+
+// call parses the arguments of a function whose name and "(" were consumed.
+func (p *dataviewParser) call(name string) (dvOperand, error) {
+	var args []dvOperand
+	for !p.punct(")") {
+		if len(args) > 0 && !p.punct(",") {
+			return nil, p.errorf("expected , or ) in %s()", name)
+		}
+		// date(today) and date(2024-01-01) take the keyword or number as text; any other word,
+		// as in date(updated), is a field.
+		if t := p.peek(); name == "date" && (t.kind == dvNumber || t.kind == dvWord && dataviewDateKeyword(t.text)) {
+			p.pos++
+			args = append(args, func(*storage.Post) any { return t.text })
+			continue
+		}
+		arg, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	switch {
+	case name == "contains" && len(args) == 2:
+		return func(post *storage.Post) any { return dataviewContains(args[0](post), args[1](post)) }, nil
+	case name == "date" && len(args) == 1:
+		return func(post *storage.Post) any {
+			switch v := args[0](post).(type) {
+			case time.Time:
+				return v
+			case string:
+				if d, ok := parseDataviewDate(v); ok {
+					return d
+				}
+			}
+			return nil
+		}, nil
+	}
+	return nil, p.errorf("unknown function %s with %d arguments", name, len(args))
+}
+
+// KindDataview is the AST node kind of an evaluated ```dataview block.
+var KindDataview = ast.NewNodeKind("Dataview")
+
+// DataviewNode replaces a ```dataview code block once its query has run.
+type DataviewNode struct {
+	ast.BaseBlock
+	Query  string
+	Result *DataviewResult
+	Err    error
+}
+
+func (n *DataviewNode) Kind() ast.NodeKind { return KindDataview }
+
+func (n *DataviewNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Query": n.Query}, nil)
+}
+
+var dataviewFuncKey = parser.NewContextKey()
+
+// dataviewTransformer runs the ```dataview blocks of a document through the DataviewFunc in the
+// parser context. Without one the blocks stay code.
+type dataviewTransformer struct{}
+
+func (t *dataviewTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	run, _ := pc.Get(dataviewFuncKey).(DataviewFunc)
+	if run == nil {
+		return
+	}
+	source := reader.Source()
+	var blocks []*ast.FencedCodeBlock
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if fc, ok := n.(*ast.FencedCodeBlock); ok && entering && strings.EqualFold(string(fc.Language(source)), "dataview") {
+			blocks = append(blocks, fc)
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, fc := range blocks {
+		var b strings.Builder
+		lines := fc.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			seg := lines.At(i)
+			b.Write(seg.Value(source))
+		}
+		node := &DataviewNode{Query: b.String()}
+		node.Result, node.Err = run(node.Query)
+		fc.Parent().ReplaceChild(fc.Parent(), fc, node)
+	}
+}
+
+type dataviewRenderer struct{}
+
+func (r *dataviewRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindDataview, r.render)
+}
+
+func (r *dataviewRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	node := n.(*DataviewNode)
+	esc := func(s string) { _, _ = w.Write(util.EscapeHTML([]byte(s))) }
+	if node.Err != nil {
+		_, _ = w.WriteString(`<div class="dataview dataview-error">` + "\n<p>")
+		esc(node.Err.Error())
+		_, _ = w.WriteString("</p>\n<pre><code>")
+		esc(node.Query)
+		_, _ = w.WriteString("</code></pre>\n</div>\n")
+		return ast.WalkSkipChildren, nil
+	}
+	res, q := node.Result, node.Result.Query
+	if len(res.Rows) == 0 {
+		_, _ = w.WriteString(`<p class="dataview dataview-empty">No results.</p>` + "\n")
+		return ast.WalkSkipChildren, nil
+	}
+	if !q.Table {
+		_, _ = w.WriteString(`<ul class="dataview dataview-list">` + "\n")
+		for _, row := range res.Rows {
+			_, _ = w.WriteString("<li>")
+			writeDataviewValue(w, DataviewLink{Slug: row.Slug, Name: row.Name})
+			if len(row.Values) > 0 && row.Values[0] != nil {
+				_, _ = w.WriteString(": ")
+				writeDataviewValue(w, row.Values[0])
+			}
+			_, _ = w.WriteString("</li>\n")
+		}
+		_, _ = w.WriteString("</ul>\n")
+		return ast.WalkSkipChildren, nil
+	}
+	_, _ = w.WriteString(`<table class="dataview dataview-table">` + "\n<thead>\n<tr>")
+	if q.WithID {
+		_, _ = w.WriteString(fmt.Sprintf("<th>File (%d)</th>", len(res.Rows)))
+	}
+	for _, h := range q.Headers {
+		_, _ = w.WriteString("<th>")
+		esc(h)
+		_, _ = w.WriteString("</th>")
+	}
+	_, _ = w.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for _, row := range res.Rows {
+		_, _ = w.WriteString("<tr>")
+		if q.WithID {
+			_, _ = w.WriteString("<td>")
+			writeDataviewValue(w, DataviewLink{Slug: row.Slug, Name: row.Name})
+			_, _ = w.WriteString("</td>")
+		}
+		for _, v := range row.Values {
+			_, _ = w.WriteString("<td>")
+			writeDataviewValue(w, v)
+			_, _ = w.WriteString("</td>")
+		}
+		_, _ = w.WriteString("</tr>\n")
+	}
+	_, _ = w.WriteString("</tbody>\n</table>\n")
+	return ast.WalkSkipChildren, nil
+}
+
+func writeDataviewValue(w util.BufWriter, v any) {
+	switch v := v.(type) {
+	case nil:
+	case DataviewLink:
+		_, _ = w.WriteString(`<a class="wikilink" href="`)
+		_, _ = w.Write(util.EscapeHTML(util.URLEscape([]byte("/posts/"+v.Slug), false)))
+		_, _ = w.WriteString(`">`)
+		_, _ = w.Write(util.EscapeHTML([]byte(v.Name)))
+		_, _ = w.WriteString(`</a>`)
+	case time.Time:
+		_, _ = w.WriteString(v.Format("2006-01-02"))
+	case []string:
+		_, _ = w.Write(util.EscapeHTML([]byte(strings.Join(v, ", "))))
+	default:
+		_, _ = w.Write(util.EscapeHTML([]byte(fmt.Sprint(v))))
+	}
+}
+
+type dataview struct{}
+
+// Dataview is a goldmark extension evaluating ```dataview blocks through the DataviewFunc in
+// the parser context (see convertMarkdown).
+var Dataview goldmark.Extender = &dataview{}
+
+func (e *dataview) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(&dataviewTransformer{}, 90)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&dataviewRenderer{}, 199)))
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+func dataviewPost(slug, name, folder, updated string, tags ...string) *storage.Post {
+	u, _ := time.Parse("2006-01-02", updated)
+	return &storage.Post{Meta: storage.PostMeta{Slug: slug, Name: name, Folder: folder, Updated: u, Tags: tags, Published: true}}
+}
+
+func TestDataviewQueries(t *testing.T) {
+	posts := []*storage.Post{
+		dataviewPost("k8s-intro.md", "K8s Intro", "notes/k8s", "2024-01-10", "theme/kubernetes", "type/note"),
+		dataviewPost("k8s-operators.md", "Operators", "notes/k8s", "2024-03-01", "theme/kubernetes/operators"),
+		dataviewPost("go-tips.md", "Go Tips", "notes/go", "2024-02-01", "theme/go", "type/note"),
+		dataviewPost("journal.md", "Journal", "", "2024-04-01", "type/journal"),
+	}
+	slugs := func(res *DataviewResult) string {
+		var out []string
+		for _, r := range res.Rows {
+			out = append(out, r.Slug)
+		}
+		return strings.Join(out, ",")
+	}
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"LIST FROM #theme/kubernetes SORT updated DESC", "k8s-operators.md,k8s-intro.md"},
+		{"LIST\nFROM #theme\nSORT updated ASC\nLIMIT 2", "k8s-intro.md,go-tips.md"},
+		{`LIST FROM "notes" and -#theme/go`, "k8s-intro.md,k8s-operators.md"},
+		{`LIST FROM "notes/k8s" or #type/journal SORT file.name DESC`, "k8s-operators.md,k8s-intro.md,journal.md"},
+		{`LIST WHERE updated >= 2024-02-01 and !contains(tags, "#type/journal")`, "go-tips.md,k8s-operators.md"},
+		{`LIST WHERE folder = "notes/go" or (name = "Journal")`, "go-tips.md,journal.md"},
+		{`LIST WHERE updated < date("2024-02-01")`, "k8s-intro.md"},
+		{`LIST WHERE date(updated) > date(2024-02-15)`, "journal.md,k8s-operators.md"},
+		{`LIST WHERE date(file.mtime) <= date(2024-01-31) and updated < date(today)`, "k8s-intro.md"},
+		{`LIST FROM #nothing`, ""},
+	}
+	for _, c := range cases {
+		q, err := ParseDataviewQuery(c.query)
+		if err != nil {
+			t.Errorf("%q: %v", c.query, err)
+			continue
+		}
+		if got := slugs(q.Run(posts)); got != c.want {
+			t.Errorf("%q = %s; want %s", c.query, got, c.want)
+		}
+	}
+
+	q, err := ParseDataviewQuery(`TABLE WITHOUT ID file.link AS "Note", updated, tags FROM #type/note SORT name`)
+	if err != nil {
+		t.Fatalf("parse table: %v", err)
+	}
+	res := q.Run(posts)
+	if !q.Table || q.WithID || strings.Join(q.Headers, "|") != "Note|updated|tags" || len(res.Rows) != 2 {
+		t.Fatalf("unexpected table: %+v %+v", q, res.Rows)
+	}
+	if link, ok := res.Rows[0].Values[0].(DataviewLink); !ok || link.Slug != "go-tips.md" {
+		t.Fatalf("first cell = %#v", res.Rows[0].Values[0])
+	}
+
+	for _, bad := range []string{"", "SELECT *", "LIST FROM", "LIST WHERE", `LIST WHERE name = "x`, "LIST LIMIT many", "LIST SORT", "LIST WHERE nope(name)", "TABLE WITHOUT name", "TABLE a, FROM #x", "TABLE a,"} {
+		if _, err := ParseDataviewQuery(bad); err == nil {
+			t.Errorf("%q parsed without error", bad)
+		}
+	}
+}
+
+func TestDataviewRendering(t *testing.T) {
+	ps := newTestPostService(t, map[string]string{
+		"index.md": testNote("Index", "2024-01-02", true, "Notes:\n\n```dataview\nLIST lead FROM #type/note SORT updated DESC\n```\n\n```dataview\nTABLE updated FROM #type/note\n```\n\n```dataview\nLIST FROM\n```", "type/note"),
+		"a.md":     testNote("Alpha", "2024-01-02", true, "alpha", "type/note"),
+		"draft.md": testNote("Draft", "2024-01-02", false, "draft", "type/note"),
+	})
+	ctx := context.Background()
+	out := renderSlug(t, ps, "index.md", ctx)
+	for _, want := range []string{
+		`<ul class="dataview dataview-list">`,
+		`<li><a class="wikilink" href="/posts/a.md">Alpha</a>: about Alpha</li>`,
+		`<table class="dataview dataview-table">`,
+		`<th>File (2)</th><th>updated</th>`,
+		`<td>2024-01-02</td>`,
+		`<div class="dataview dataview-error">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Draft") {
+		t.Fatalf("unpublished post listed for anonymous reader:\n%s", out)
+	}
+
+	// Any post change may alter query results, so it drops the cached render.
+	if st := ps.RenderStats(); st.Entries != 1 {
+		t.Fatalf("expected one cached render, got %+v", st)
+	}
+	ps.render.Invalidate("unrelated.md")
+	if st := ps.RenderStats(); st.Entries != 0 {
+		t.Fatalf("dataview render survived a post change: %+v", st)
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import "strings"
+
+// DiffOp marks a line of a line diff.
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = "="
+	DiffDelete DiffOp = "-"
+	DiffInsert DiffOp = "+"
+)
+
+// DiffLine is one line of a line diff. Old and New are its line numbers in the old and new
+// document, 0 for the side that does not have it.
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Old  int    `json:"old,omitempty"`
+	New  int    `json:"new,omitempty"`
+	Text string `json:"text"`
+}
+
+// maxDiffCells bounds the table DiffLines solves the changed region with; larger changes are
+// reported as the whole region deleted and inserted.
+const maxDiffCells = 4 << 20
+
+// DiffLines returns the line diff turning old into new: the lines of a longest common
+// subsequence are kept, the others deleted or inserted, deletions first.
+func DiffLines(old, new []byte) []DiffLine {
+	a, b := splitLines(old), splitLines(new)
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	out := make([]DiffLine, 0, max(len(a), len(b)))
+	for i := 0; i < prefix; i++ {
+		out = append(out, DiffLine{Op: DiffEqual, Old: i + 1, New: i + 1, Text: a[i]})
+	}
+	out = diffRegion(out, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)
+	for k := suffix; k > 0; k-- {
+		i, j := len(a)-k, len(b)-k
+		out = append(out, DiffLine{Op: DiffEqual, Old: i + 1, New: j + 1, Text: a[i]})
+	}
+	return out
+}
+
+// diffRegion appends the diff of the changed lines a and b, which start after line oldAt of the
+// old and newAt of the new document.
+func diffRegion(out []DiffLine, a, b []string, oldAt, newAt int) []DiffLine {
+	n, m := len(a), len(b)
+	if n*m > maxDiffCells {
+		for i, line := range a {
+			out = append(out, DiffLine{Op: DiffDelete, Old: oldAt + i + 1, Text: line})
+		}
+		for j, line := range b {
+			out = append(out, DiffLine{Op: DiffInsert, New: newAt + j + 1, Text: line})
+		}
+		return out
+	}
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			out = append(out, DiffLine{Op: DiffEqual, Old: oldAt + i + 1, New: newAt + j + 1, Text: a[i]})
+			i++
+			j++
+		case j == m || i < n && lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, DiffLine{Op: DiffDelete, Old: oldAt + i + 1, Text: a[i]})
+			i++
+		default:
+			out = append(out, DiffLine{Op: DiffInsert, New: newAt + j + 1, Text: b[j]})
+			j++
+		}
+	}
+	return out
+}
+
+// splitLines splits a document into lines without their terminators.
+func splitLines(doc []byte) []string {
+	s := strings.ReplaceAll(string(doc), "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
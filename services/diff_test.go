@@ -0,0 +1,46 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	old := "---\nname: A\n---\nintro\nkeep\nold line\nend\n"
+	new := "---\nname: B\n---\nintro\nkeep\nnew line\nadded\nend\n"
+	want := []DiffLine{
+		{DiffEqual, 1, 1, "---"},
+		{DiffDelete, 2, 0, "name: A"},
+		{DiffInsert, 0, 2, "name: B"},
+		{DiffEqual, 3, 3, "---"},
+		{DiffEqual, 4, 4, "intro"},
+		{DiffEqual, 5, 5, "keep"},
+		{DiffDelete, 6, 0, "old line"},
+		{DiffInsert, 0, 6, "new line"},
+		{DiffInsert, 0, 7, "added"},
+		{DiffEqual, 7, 8, "end"},
+	}
+	if got := DiffLines([]byte(old), []byte(new)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("DiffLines =\n%v\nwant\n%v", got, want)
+	}
+
+	if got := DiffLines([]byte("a\r\nb\r\n"), []byte("a\nb")); len(got) != 2 || got[0].Op != DiffEqual || got[1].Op != DiffEqual {
+		t.Fatalf("line endings reported as changes: %v", got)
+	}
+	if got := DiffLines(nil, []byte("x\n")); len(got) != 1 || got[0] != (DiffLine{DiffInsert, 0, 1, "x"}) {
+		t.Fatalf("diff from empty document = %v", got)
+	}
+}
+
+func TestDiffLinesLargeRegion(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < 3000; i++ {
+		a.WriteString("a\n")
+		b.WriteString("b\n")
+	}
+	got := DiffLines([]byte(a.String()), []byte(b.String()))
+	if len(got) != 6000 || got[0].Op != DiffDelete || got[5999] != (DiffLine{DiffInsert, 0, 3000, "b"}) {
+		t.Fatalf("large rewrite: %d lines, first %v, last %v", len(got), got[0], got[len(got)-1])
+	}
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// FeedFormat selects the syndication format a Feed is encoded in.
+type FeedFormat string
+
+const (
+	FeedAtom FeedFormat = "atom"
+	FeedRSS  FeedFormat = "rss"
+	FeedJSON FeedFormat = "json"
+)
+
+// ContentType returns the media type served for the format.
+func (f FeedFormat) ContentType() string {
+	switch f {
+	case FeedRSS:
+		return "application/rss+xml; charset=utf-8"
+	case FeedJSON:
+		return "application/feed+json; charset=utf-8"
+	default:
+		return "application/atom+xml; charset=utf-8"
+	}
+}
+
+// feedMaxEntries caps how many of the most recently updated posts a feed carries.
+const feedMaxEntries = 50
+
+// FeedService builds subscription feeds over published posts. Feeds never include unpublished
+// posts, even for authenticated callers, because responses are cacheable by feed readers.
+type FeedService struct {
+	posts   *PostService
+	baseURL string
+	title   string
+}
+
+func NewFeedService(posts *PostService, baseURL, title string) *FeedService {
+	return &FeedService{posts: posts, baseURL: strings.TrimRight(baseURL, "/"), title: title}
+}
+
+// Feed is the format-independent feed model. Entries are sorted by Updated, newest first.
+// Content is rendered on demand by Encode so validators can be checked without rendering.
+type Feed struct {
+	Title   string
+	Link    string // site or tag page the feed describes
+	Self    string // absolute URL of the feed itself
+	Updated time.Time
+	Posts   []*storage.Post
+
+	service *FeedService
+}
+
+// Feed collects the published posts for the site feed, or for tag when it is non-empty.
+// selfPath is the request path of the feed, used for its self link.
+func (s *FeedService) Feed(ctx context.Context, tag, selfPath string) (*Feed, error) {
+	var posts []*storage.Post
+	title, link := s.title, s.baseURL+"/"
+	if tag == "" {
+		all, err := s.posts.GetPosts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range all {
+			posts = append(posts, p)
+		}
+	} else {
+		byTag, err := s.posts.GetPostsByTag(tag, 0, ctx)
+		if err != nil {
+			return nil, err
+		}
+		posts = byTag
+		title = fmt.Sprintf("%s: %s", s.title, tag)
+		link = s.baseURL + "/?tags=" + url.QueryEscape(tag)
+	}
+	published := posts[:0]
+	for _, p := range posts {
+		if p.Meta.Published {
+			published = append(published, p)
+		}
+	}
+	sort.Slice(published, func(i, j int) bool {
+		if published[i].Meta.Updated.Equal(published[j].Meta.Updated) {
+			return published[i].Meta.Slug < published[j].Meta.Slug
+		}
+		return published[i].Meta.Updated.After(published[j].Meta.Updated)
+	})
+	if len(published) > feedMaxEntries {
+		published = published[:feedMaxEntries]
+	}
+	feed := &Feed{Title: title, Link: link, Self: s.baseURL + selfPath, Posts: published, service: s}
+	if len(published) > 0 {
+		feed.Updated = published[0].Meta.Updated
+	}
+	return feed, nil
+}
+
+// LastModified is the newest entry update, truncated to the second precision of HTTP dates.
+func (f *Feed) LastModified() time.Time {
+	return f.Updated.UTC().Truncate(time.Second)
+}
+
+// ETag is a strong validator over everything that ends up in the encoded feed. Entry bodies
+// enter as rendered HTML, since embeds, dataview results, wikilinks and attachments change them
+// without touching the post; the renders come from the render cache, so a conditional poll
+// costs no markdown conversion once the posts are cached.
+func (f *Feed) ETag(ctx context.Context, format FeedFormat) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", format, f.Title, f.Self)
+	for _, p := range f.Posts {
+		html, err := f.renderHTML(ctx, p)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\x00%d\x00%s\x00%s\x00", p.Meta.Slug, p.Meta.Name, p.Meta.Lead,
+			p.Meta.Created.Unix(), p.Meta.Updated.UnixNano(), strings.Join(p.Meta.Tags, ","), html)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`, nil
+}
+
+func (f *Feed) postURL(p *storage.Post) string {
+	return f.service.baseURL + "/posts/" + url.PathEscape(p.Meta.Slug)
+}
+
+// renderHTML renders a post body the same way the post page does. Relative links inside the
+// HTML are resolved by readers against the entry link or xml:base.
+func (f *Feed) renderHTML(ctx context.Context, p *storage.Post) (string, error) {
+	html, err := f.service.posts.RenderPost(p, ctx)
+	if err != nil {
+		return "", fmt.Errorf("render %s: %w", p.Meta.Slug, err)
+	}
+	return string(html), nil
+}
+
+// Encode renders the feed in format.
+func (f *Feed) Encode(ctx context.Context, format FeedFormat) ([]byte, error) {
+	switch format {
+	case FeedRSS:
+		return f.encodeRSS(ctx)
+	case FeedJSON:
+		return f.encodeJSON(ctx)
+	default:
+		return f.encodeAtom(ctx)
+	}
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Base string `xml:"xml:base,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+func (f *Feed) encodeAtom(ctx context.Context) ([]byte, error) {
+	out := atomFeed{
+		Title:   f.Title,
+		ID:      f.Self,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, p := range f.Posts {
+		link := f.postURL(p)
+		body, err := f.renderHTML(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		entry := atomEntry{
+			Title:     p.Meta.Name,
+			ID:        link,
+			Link:      atomLink{Href: link, Rel: "alternate", Type: "text/html"},
+			Published: p.Meta.Created.UTC().Format(time.RFC3339),
+			Updated:   p.Meta.Updated.UTC().Format(time.RFC3339),
+			Summary:   p.Meta.Lead,
+			Content:   atomContent{Type: "html", Base: link, Body: body},
+		}
+		for _, t := range p.Meta.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: t})
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+	return marshalXML(out)
+}
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          rssSelf   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssSelf struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description"`
+	Categories  []string `xml:"category"`
+}
+
+func (f *Feed) encodeRSS(ctx context.Context) ([]byte, error) {
+	out := rssDoc{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Title,
+			Self:        rssSelf{Href: f.Self, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if !f.Updated.IsZero() {
+		out.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, p := range f.Posts {
+		link := f.postURL(p)
+		body, err := f.renderHTML(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		out.Channel.Items = append(out.Channel.Items, rssItem{
+			Title:       p.Meta.Name,
+			Link:        link,
+			GUID:        link,
+			PubDate:     p.Meta.Updated.UTC().Format(time.RFC1123Z),
+			Description: body,
+			Categories:  p.Meta.Tags,
+		})
+	}
+	return marshalXML(out)
+}
+
+func marshalXML(v any) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
+
+// jsonFeed follows JSON Feed 1.1 (https://jsonfeed.org/version/1.1).
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	Summary       string   `json:"summary,omitempty"`
+	ContentHTML   string   `json:"content_html"`
+	DatePublished string   `json:"date_published"`
+	DateModified  string   `json:"date_modified"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+func (f *Feed) encodeJSON(ctx context.Context) ([]byte, error) {
+	out := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.Self,
+		Items:       []jsonFeedItem{},
+	}
+	for _, p := range f.Posts {
+		link := f.postURL(p)
+		body, err := f.renderHTML(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, jsonFeedItem{
+			ID:            link,
+			URL:           link,
+			Title:         p.Meta.Name,
+			Summary:       p.Meta.Lead,
+			ContentHTML:   body,
+			DatePublished: p.Meta.Created.UTC().Format(time.RFC3339),
+			DateModified:  p.Meta.Updated.UTC().Format(time.RFC3339),
+			Tags:          p.Meta.Tags,
+		})
+	}
+	body, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
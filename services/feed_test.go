@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	firebaseauth "firebase.google.com/go/v4/auth"
+
+	"github.com/soockee/cybersocke.com/session"
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// newTestPostService serves notes, keyed by file name, from an FSStore over a temporary vault.
+func newTestPostService(t *testing.T, notes map[string]string) *PostService {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range notes {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	store, err := storage.NewFSStore(ctx, slog.Default(), dir)
+	if err != nil {
+		t.Fatalf("NewFSStore: %v", err)
+	}
+	return NewPostService(store, nil)
+}
+
+func newFeedTestService(t *testing.T, notes map[string]string) *FeedService {
+	t.Helper()
+	return NewFeedService(newTestPostService(t, notes), "https://example.com/", "example.com")
+}
+
+// testNote renders a note with frontmatter and body.
+func testNote(name, updated string, published bool, body string, tags ...string) string {
+	pub := "false"
+	if published {
+		pub = "true"
+	}
+	return "---\nname: " + name + "\nlead: about " + name + "\ncreated: 2024-01-01\nupdated: " + updated +
+		"\npublished: " + pub + "\ntags: [" + strings.Join(tags, ", ") + "]\n---\n\n" + body + "\n"
+}
+
+func TestFeedContentsAndValidators(t *testing.T) {
+	fs := newFeedTestService(t, map[string]string{
+		"old.md":   testNote("Old", "2024-01-02", true, "Hello **Old**", "type/note", "theme/go"),
+		"new.md":   testNote("New", "2024-03-01", true, "Hello **New**", "type/note", "theme/k8s"),
+		"draft.md": testNote("Draft", "2024-04-01", false, "Hello **Draft**", "type/note", "theme/go"),
+	})
+	ctx := context.Background()
+	feed, err := fs.Feed(ctx, "", "/feed.xml")
+	if err != nil {
+		t.Fatalf("Feed: %v", err)
+	}
+	if len(feed.Posts) != 2 || feed.Posts[0].Meta.Slug != "new.md" {
+		t.Fatalf("expected published posts newest first, got %d", len(feed.Posts))
+	}
+	if got := feed.LastModified().Format("2006-01-02"); got != "2024-03-01" {
+		t.Fatalf("LastModified = %s", got)
+	}
+	if feed.Self != "https://example.com/feed.xml" {
+		t.Fatalf("Self = %s", feed.Self)
+	}
+	atomTag, err := feed.ETag(ctx, FeedAtom)
+	if err != nil {
+		t.Fatalf("ETag: %v", err)
+	}
+	if rssTag, _ := feed.ETag(ctx, FeedRSS); atomTag == rssTag {
+		t.Fatalf("ETag must differ per format")
+	}
+	again, _ := fs.Feed(ctx, "", "/feed.xml")
+	if againTag, _ := again.ETag(ctx, FeedAtom); atomTag != againTag {
+		t.Fatalf("ETag not stable across builds")
+	}
+
+	atom, err := feed.Encode(ctx, FeedAtom)
+	if err != nil {
+		t.Fatalf("Encode atom: %v", err)
+	}
+	var parsed atomFeed
+	if err := xml.Unmarshal(atom, &parsed); err != nil {
+		t.Fatalf("atom is not valid XML: %v", err)
+	}
+	if len(parsed.Entries) != 2 || !strings.Contains(parsed.Entries[0].Content.Body, "<strong>New</strong>") {
+		t.Fatalf("unexpected atom entries: %+v", parsed.Entries)
+	}
+	if parsed.Entries[0].ID != "https://example.com/posts/new.md" {
+		t.Fatalf("entry id = %s", parsed.Entries[0].ID)
+	}
+
+	rss, err := feed.Encode(ctx, FeedRSS)
+	if err != nil || !strings.Contains(string(rss), "<pubDate>Fri, 01 Mar 2024") {
+		t.Fatalf("unexpected rss (%v): %s", err, rss)
+	}
+
+	raw, err := feed.Encode(ctx, FeedJSON)
+	if err != nil {
+		t.Fatalf("Encode json: %v", err)
+	}
+	var jf jsonFeed
+	if err := json.Unmarshal(raw, &jf); err != nil || len(jf.Items) != 2 || jf.Items[1].Title != "Old" {
+		t.Fatalf("unexpected json feed (%v): %s", err, raw)
+	}
+
+	tagged, err := fs.Feed(ctx, "theme/go", "/tags/theme%2Fgo/feed.xml")
+	if err != nil {
+		t.Fatalf("tag Feed: %v", err)
+	}
+	if len(tagged.Posts) != 1 || tagged.Posts[0].Meta.Slug != "old.md" {
+		t.Fatalf("tag feed must only carry published posts with the tag, got %d", len(tagged.Posts))
+	}
+}
+
+func TestFeedETagFollowsEmbeds(t *testing.T) {
+	fs := newFeedTestService(t, map[string]string{
+		"host.md":  testNote("Host", "2024-03-01", true, "Hello **Host**", "type/note", "theme/k8s") + "\n![[Guide]]\n",
+		"guide.md": testNote("Guide", "2024-01-02", true, "Hello **Guide**", "type/note", "theme/go"),
+	})
+	ctx := context.Background()
+	feed, _ := fs.Feed(ctx, "theme/k8s", "/tags/theme%2Fk8s/feed.xml")
+	before, err := feed.ETag(ctx, FeedAtom)
+	if err != nil {
+		t.Fatalf("ETag: %v", err)
+	}
+	// The embedded note is not in the tag feed, but its content is.
+	writer := context.WithValue(ctx, session.IdTokenKey, &firebaseauth.Token{UID: "writer"})
+	if _, _, err := fs.posts.UpdatePost("guide.md", []byte(testNote("Guide", "2024-01-02", true, "Hello **Guide**", "type/note", "theme/go")+"\nmore\n"), storage.WriteOptions{}, writer); err != nil {
+		t.Fatalf("UpdatePost: %v", err)
+	}
+	feed, _ = fs.Feed(ctx, "theme/k8s", "/tags/theme%2Fk8s/feed.xml")
+	if after, _ := feed.ETag(ctx, FeedAtom); after == before {
+		t.Fatalf("ETag unchanged after the embedded note changed")
+	}
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // GIF dimensions for width/height attributes
+	"image/jpeg"
+	"image/png"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// Responsive images: PNG and JPEG attachments are served in the VariantWidths narrower than
+// the original as well, re-encoded by the standard library (which drops EXIF and other
+// metadata). Rendered images carry width/height and a srcset over those variants.
+
+// VariantWidths are the widths image variants are generated at.
+var VariantWidths = []int{480, 960, 1920}
+
+// maxImagePixels bounds the images variants are generated from; decoding one takes four
+// bytes per pixel.
+const maxImagePixels = 36_000_000
+
+// contentWidth is the widest the post column gets (see .explorer-main in site.css).
+const contentWidth = 820
+
+// ErrNoVariant is returned for variant widths that are not generated for an attachment.
+var ErrNoVariant = errors.New("no such image variant")
+
+// ImageInfo describes an image attachment. Variants lists the widths it is also served at,
+// narrowest first; it is empty for formats that are not resized.
+type ImageInfo struct {
+	Width    int
+	Height   int
+	Variants []int
+}
+
+// ImageFunc returns the dimensions of the image attachment at path (as resolved by the
+// document's AttachmentFunc). ok is false for other attachments.
+type ImageFunc func(path string) (info ImageInfo, ok bool)
+
+var imageFuncKey = parser.NewContextKey()
+
+// VariantURL is the URL of the width pixels wide variant of the attachment at path.
+func VariantURL(path string, width int) string {
+	return "/variants/" + strconv.Itoa(width) + "/" + path
+}
+
+// attachmentImage returns what the document's ImageFunc knows about the attachment href
+// points to, or nil.
+func attachmentImage(pc parser.Context, href string) *ImageInfo {
+	fn, _ := pc.Get(imageFuncKey).(ImageFunc)
+	if fn == nil || !strings.HasPrefix(href, "/attachments/") {
+		return nil
+	}
+	info, ok := fn(strings.TrimPrefix(href, "/attachments/"))
+	if !ok || info.Width <= 0 || info.Height <= 0 {
+		return nil
+	}
+	return &info
+}
+
+// imageAttrs are the attributes that size an image and offer its variants.
+type imageAttrs struct {
+	width, height int
+	srcset, sizes string
+}
+
+// responsiveAttrs sizes the image at href. width and height are the size requested in the
+// document (0 if none); a missing one follows from the aspect ratio.
+func responsiveAttrs(href string, info *ImageInfo, width, height int) imageAttrs {
+	a := imageAttrs{width: width, height: height}
+	switch {
+	case width == 0 && height == 0:
+		a.width, a.height = info.Width, info.Height
+	case height == 0:
+		a.height = max(1, (width*info.Height+info.Width/2)/info.Width)
+	case width == 0:
+		a.width = max(1, (height*info.Width+info.Height/2)/info.Height)
+	}
+	if len(info.Variants) == 0 {
+		return a
+	}
+	path := strings.TrimPrefix(href, "/attachments/")
+	candidates := make([]string, 0, len(info.Variants)+1)
+	for _, w := range info.Variants {
+		candidates = append(candidates, srcsetURL(VariantURL(path, w))+" "+strconv.Itoa(w)+"w")
+	}
+	candidates = append(candidates, srcsetURL(href)+" "+strconv.Itoa(info.Width)+"w")
+	a.srcset = strings.Join(candidates, ", ")
+	if width != 0 || height != 0 || a.width < contentWidth {
+		a.sizes = fmt.Sprintf("(max-width: %dpx) 100vw, %dpx", a.width, a.width)
+	} else {
+		a.sizes = fmt.Sprintf("(max-width: %dpx) 100vw, %dpx", contentWidth, contentWidth)
+	}
+	return a
+}
+
+// srcsetURL escapes the characters srcset uses as separators.
+func srcsetURL(u string) string {
+	return strings.NewReplacer(" ", "%20", ",", "%2C").Replace(u)
+}
+
+// setImageAttrs applies a to a markdown image.
+func setImageAttrs(n *ast.Image, a imageAttrs) {
+	n.SetAttributeString("width", []byte(strconv.Itoa(a.width)))
+	n.SetAttributeString("height", []byte(strconv.Itoa(a.height)))
+	if a.srcset != "" {
+		n.SetAttributeString("srcset", []byte(a.srcset))
+		n.SetAttributeString("sizes", []byte(a.sizes))
+	}
+	n.SetAttributeString("loading", []byte("lazy"))
+}
+
+// imageCache remembers the dimensions of image attachments by path and version, so each image
+// is only inspected once.
+type imageCache struct {
+	mu    sync.Mutex
+	infos map[string]imageCacheEntry
+}
+
+type imageCacheEntry struct {
+	version string
+	info    ImageInfo
+	ok      bool
+}
+
+// resizable reports whether variants are generated for attachments of contentType.
+func resizable(contentType string) bool {
+	return contentType == "image/png" || contentType == "image/jpeg"
+}
+
+// imageInfo returns the dimensions and variant widths of an image attachment. Formats the
+// standard library cannot read (WebP, SVG) report ok false.
+func (s *PostService) imageInfo(att *storage.Attachment, ctx context.Context) (ImageInfo, bool) {
+	if att.ContentType != "image/gif" && !resizable(att.ContentType) {
+		return ImageInfo{}, false
+	}
+	version := att.Version()
+	s.images.mu.Lock()
+	e, cached := s.images.infos[att.Path]
+	s.images.mu.Unlock()
+	if cached && e.version == version {
+		return e.info, e.ok
+	}
+	e = imageCacheEntry{version: version}
+	if _, data, err := s.GetAttachment(att.Path, ctx); err == nil {
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			e.info, e.ok = ImageInfo{Width: cfg.Width, Height: cfg.Height}, true
+			if resizable(att.ContentType) && cfg.Width*cfg.Height <= maxImagePixels {
+				for _, w := range VariantWidths {
+					if w < cfg.Width {
+						e.info.Variants = append(e.info.Variants, w)
+					}
+				}
+			}
+		}
+	} else if ctx.Err() != nil {
+		return ImageInfo{}, false // not cached: a later request may succeed
+	}
+	s.images.mu.Lock()
+	if s.images.infos == nil {
+		s.images.infos = make(map[string]imageCacheEntry)
+	}
+	s.images.infos[att.Path] = e
+	s.images.mu.Unlock()
+	return e.info, e.ok
+}
+
+// ImageVariant returns the attachment at path resized to width, one of VariantWidths narrower
+// than the original. Variants are generated on first request and cached by backends that
+// implement storage.VariantStore.
+func (s *PostService) ImageVariant(path string, width int, ctx context.Context) (*storage.Attachment, []byte, error) {
+	if !slices.Contains(VariantWidths, width) {
+		return nil, nil, fmt.Errorf("%w: width %d", ErrNoVariant, width)
+	}
+	att, data, err := s.GetAttachment(path, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !resizable(att.ContentType) {
+		return nil, nil, fmt.Errorf("%w: %s is not resized", ErrNoVariant, att.ContentType)
+	}
+	cache, _ := s.store.(storage.VariantStore)
+	if cache != nil {
+		if cached, err := cache.GetVariant(ctx, att.Path, width, att.Version()); err == nil {
+			return att, cached, nil
+		}
+	}
+	variant, err := resizeEncoded(data, att.ContentType, width)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cache != nil {
+		// A failed cache write only costs the next request another resize.
+		_ = cache.PutVariant(ctx, att.Path, width, att.Version(), variant)
+	}
+	return att, variant, nil
+}
+
+// resizeEncoded decodes a PNG or JPEG, scales it to width and encodes it in the same format.
+// Re-encoding keeps the pixels only, so EXIF data (camera, location) is gone.
+func resizeEncoded(data []byte, contentType string, width int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	if width >= cfg.Width || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("%w: width %d of %dx%d image", ErrNoVariant, width, cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	dst := resizeImage(src, width)
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// resizeImage scales src down to width pixels, keeping the aspect ratio. Each target pixel
+// averages the source pixels it covers (a box filter), which keeps downscaled screenshots and
+// photos free of aliasing.
+func resizeImage(src image.Image, width int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	height := max(1, (sh*width+sw/2)/sw)
+	rgba := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * sh / height
+		y1 := max((y+1)*sh/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := x * sw / width
+			x1 := max((x+1)*sw/width, x0+1)
+			var sum [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride+x0*4 : sy*rgba.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += uint64(row[i])
+					sum[1] += uint64(row[i+1])
+					sum[2] += uint64(row[i+2])
+					sum[3] += uint64(row[i+3])
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			off := y*dst.Stride + x*4
+			for c := range sum {
+				dst.Pix[off+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+// CoverImages returns the card thumbnail URL of each post with a cover, keyed by slug. Covers
+// naming attachments resolve like ![[embeds]] of the post and use the narrowest variant;
+// missing attachments are left out. URLs are used as given.
+func (s *PostService) CoverImages(posts map[string]*storage.Post, ctx context.Context) map[string]string {
+	covers := map[string]string{}
+	var idx *attachmentIndex
+	for slug, post := range posts {
+		cover := storage.CoverTarget(post.Meta.Cover)
+		switch {
+		case cover == "":
+			continue
+		case strings.HasPrefix(cover, "/") || strings.Contains(cover, "://"):
+			covers[slug] = cover
+			continue
+		}
+		if idx == nil {
+			if idx = s.attachmentIndex(ctx); idx == nil {
+				return covers
+			}
+		}
+		path, ok := idx.resolve(post, cover)
+		if !ok {
+			continue
+		}
+		covers[slug] = "/attachments/" + path
+		if info, ok := s.imageInfo(idx.paths[path], ctx); ok && len(info.Variants) > 0 {
+			covers[slug] = VariantURL(path, info.Variants[0])
+		}
+	}
+	return covers
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// testPNG encodes a w x h image, left half black and right half white.
+func testPNG(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < w/2 {
+				img.Set(x, y, color.Black)
+			} else {
+				img.Set(x, y, color.White)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestResizeImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		src.Set(x, 0, color.RGBA{R: 200, A: 255})
+		src.Set(x, 1, color.RGBA{B: 100, A: 255})
+	}
+	dst := resizeImage(src, 2)
+	if dst.Bounds().Dx() != 2 || dst.Bounds().Dy() != 1 {
+		t.Fatalf("unexpected size %v", dst.Bounds())
+	}
+	if got := dst.RGBAAt(0, 0); got != (color.RGBA{R: 100, B: 50, A: 255}) {
+		t.Fatalf("pixels not averaged: %v", got)
+	}
+}
+
+func TestImageVariant(t *testing.T) {
+	ps := newTestPostService(t, map[string]string{"photo.png": testPNG(t, 1200, 600)})
+	ctx := context.Background()
+
+	att, data, err := ps.ImageVariant("photo.png", 480, ctx)
+	if err != nil {
+		t.Fatalf("ImageVariant: %v", err)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != "png" || cfg.Width != 480 || cfg.Height != 240 || att.ContentType != "image/png" {
+		t.Fatalf("variant = %s %dx%d, %v", format, cfg.Width, cfg.Height, err)
+	}
+	cache := ps.store.(storage.VariantStore)
+	if cached, err := cache.GetVariant(ctx, "photo.png", 480, att.Version()); err != nil || !bytes.Equal(cached, data) {
+		t.Fatalf("variant not cached: %v", err)
+	}
+	for _, width := range []int{1920, 500} {
+		if _, _, err := ps.ImageVariant("photo.png", width, ctx); !errors.Is(err, ErrNoVariant) {
+			t.Fatalf("width %d: expected ErrNoVariant, got %v", width, err)
+		}
+	}
+}
+
+func TestResizeEncodedJPEGDropsMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1000, 500)), nil); err != nil {
+		t.Fatal(err)
+	}
+	// Insert an APP1 (EXIF) segment after the SOI marker.
+	exif := append([]byte{0xff, 0xe1, 0x00, 0x10}, []byte("Exif\x00\x00secret!!")...)
+	src := append(append(append([]byte{}, buf.Bytes()[:2]...), exif...), buf.Bytes()[2:]...)
+	out, err := resizeEncoded(src, "image/jpeg", 480)
+	if err != nil {
+		t.Fatalf("resizeEncoded: %v", err)
+	}
+	if bytes.Contains(out, []byte("secret")) {
+		t.Fatalf("EXIF data kept in variant")
+	}
+	if cfg, format, err := image.DecodeConfig(bytes.NewReader(out)); err != nil || format != "jpeg" || cfg.Width != 480 || cfg.Height != 240 {
+		t.Fatalf("variant = %s %dx%d, %v", format, cfg.Width, cfg.Height, err)
+	}
+}
+
+func TestRenderResponsiveImages(t *testing.T) {
+	ps := newTestPostService(t, map[string]string{
+		"host.md":   testNote("Host", "2024-01-02", true, "![[photo.png]]\n\n![[photo.png|300]]\n\n![small](small.png)", "type/note"),
+		"photo.png": testPNG(t, 1200, 600),
+		"small.png": testPNG(t, 200, 100),
+	})
+	out := renderSlug(t, ps, "host.md", context.Background())
+	for _, want := range []string{
+		`<img class="attachment" src="/attachments/photo.png" alt="photo.png" width="1200" height="600" srcset="/variants/480/photo.png 480w, /variants/960/photo.png 960w, /attachments/photo.png 1200w" sizes="(max-width: 820px) 100vw, 820px" loading="lazy" />`,
+		`<img class="attachment" src="/attachments/photo.png" alt="photo.png" width="300" height="150" srcset="/variants/480/photo.png 480w, /variants/960/photo.png 960w, /attachments/photo.png 1200w" sizes="(max-width: 300px) 100vw, 300px" loading="lazy" />`,
+		`<img src="/attachments/small.png" alt="small" width="200" height="100" loading="lazy" />`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("render missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestCoverImages(t *testing.T) {
+	withCover := func(name, cover string) string {
+		return strings.Replace(testNote(name, "2024-01-02", true, "body", "type/note"), "tags:", "cover: \""+cover+"\"\ntags:", 1)
+	}
+	ps := newTestPostService(t, map[string]string{
+		"big.md":     withCover("Big", "[[photo.png]]"),
+		"small.md":   withCover("Small", "small.png"),
+		"remote.md":  withCover("Remote", "https://example.com/cover.jpg"),
+		"missing.md": withCover("Missing", "none.png"),
+		"plain.md":   testNote("Plain", "2024-01-02", true, "body", "type/note"),
+		"photo.png":  testPNG(t, 1200, 600),
+		"small.png":  testPNG(t, 200, 100),
+	})
+	ctx := context.Background()
+	posts, err := ps.GetPosts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	covers := ps.CoverImages(posts, ctx)
+	want := map[string]string{
+		"big.md":    "/variants/480/photo.png",
+		"small.md":  "/attachments/small.png",
+		"remote.md": "https://example.com/cover.jpg",
+	}
+	if len(covers) != len(want) {
+		t.Fatalf("CoverImages = %v, want %v", covers, want)
+	}
+	for slug, url := range want {
+		if covers[slug] != url {
+			t.Fatalf("CoverImages = %v, want %v", covers, want)
+		}
+	}
+}
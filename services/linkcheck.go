@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"io/fs"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// Internal link checking: wikilinks, markdown links to /posts/..., /assets/... and
+// /attachments/..., and images are checked against the post set, the public assets and the
+// stored attachments. External links and relative links to anything but attachments are not
+// followed.
+
+// Link kinds reported in LinkIssue.Kind.
+const (
+	LinkKindWiki  = "wikilink"
+	LinkKindLink  = "link"
+	LinkKindImage = "image"
+)
+
+// Reasons reported in LinkIssue.Reason.
+const (
+	LinkMissing        = "missing"         // no such post or asset
+	LinkUnpublished    = "unpublished"     // the target post is a draft
+	LinkMissingHeading = "missing-heading" // the post exists but has no such heading or block
+)
+
+// LinkIssue is a broken internal link. Line is 1-based within the checked document.
+type LinkIssue struct {
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// BrokenLinks lists the broken internal links of one post.
+type BrokenLinks struct {
+	Slug      string      `json:"slug"`
+	Name      string      `json:"name"`
+	Published bool        `json:"published"`
+	Issues    []LinkIssue `json:"issues"`
+}
+
+var (
+	wikiLinkLineRe = regexp.MustCompile(`(!?)\[\[([^\[\]\n]+)\]\]`)
+	linkDestRe     = regexp.MustCompile(`\[[^\]\n]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	imageDestRe    = regexp.MustCompile(`!` + linkDestRe.String())
+	codeSpanRe     = regexp.MustCompile("`[^`\n]*`")
+	postsPathPref  = "/posts/"
+	assetPathPref  = "/assets/"
+	attachPathPref = "/attachments/"
+)
+
+// linkChecker checks documents against one snapshot of the posts, unpublished ones included so
+// that drafts can be told apart from missing posts.
+type linkChecker struct {
+	posts       map[string]*storage.Post
+	resolve     func(target string) (string, bool)
+	assets      fs.FS            // public assets as served under /assets/; nil skips asset checks
+	attachments *attachmentIndex // nil skips attachment checks
+}
+
+func newLinkChecker(posts map[string]*storage.Post, assets fs.FS, attachments *attachmentIndex) *linkChecker {
+	return &linkChecker{posts: posts, resolve: storage.NameResolver(posts), assets: assets, attachments: attachments}
+}
+
+// check returns the broken links of post in document order. Links to drafts only count as
+// broken from published posts.
+func (c *linkChecker) check(post *storage.Post) []LinkIssue {
+	var issues []LinkIssue
+	inFence := false
+	for i, line := range strings.Split(string(post.Content), "\n") {
+		if fenceRe.MatchString(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		line = codeSpanRe.ReplaceAllString(line, "")
+		for _, m := range wikiLinkLineRe.FindAllStringSubmatch(line, -1) {
+			kind, reason := c.checkWikiLink(post, storage.ParseWikiLink(m[2]), m[1] == "!")
+			if reason != "" {
+				issues = append(issues, LinkIssue{Kind: kind, Target: m[2], Line: i + 1, Reason: reason})
+			}
+		}
+		line = wikiLinkLineRe.ReplaceAllString(line, "")
+		// Images first, so that a linked image [![alt](src)](href) leaves a plain link behind.
+		for _, m := range imageDestRe.FindAllStringSubmatch(line, -1) {
+			if reason := c.checkURL(post, m[1]); reason != "" {
+				issues = append(issues, LinkIssue{Kind: LinkKindImage, Target: m[1], Line: i + 1, Reason: reason})
+			}
+		}
+		line = imageDestRe.ReplaceAllString(line, "image")
+		for _, m := range linkDestRe.FindAllStringSubmatch(line, -1) {
+			if reason := c.checkURL(post, m[1]); reason != "" {
+				issues = append(issues, LinkIssue{Kind: LinkKindLink, Target: m[1], Line: i + 1, Reason: reason})
+			}
+		}
+	}
+	return issues
+}
+
+func (c *linkChecker) checkWikiLink(post *storage.Post, link storage.WikiLink, embed bool) (kind, reason string) {
+	kind = LinkKindWiki
+	if ct, ok := storage.AttachmentContentType(link.Target); ok {
+		if embed && strings.HasPrefix(ct, "image/") {
+			kind = LinkKindImage
+		}
+		return kind, c.checkAttachment(post, link.Target)
+	}
+	target := post
+	if link.Target != "" {
+		slug, ok := c.resolve(link.Target)
+		if !ok {
+			return kind, LinkMissing
+		}
+		target = c.posts[slug]
+	}
+	return kind, c.checkPost(post, target, link.Heading)
+}
+
+// checkURL checks the destination of a markdown link or image.
+func (c *linkChecker) checkURL(post *storage.Post, dest string) string {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return ""
+	}
+	switch {
+	case u.Path == "" && u.Fragment != "":
+		return c.checkPost(post, post, u.Fragment)
+	case strings.HasPrefix(u.Path, postsPathPref):
+		slug, _, _ := strings.Cut(strings.TrimPrefix(u.Path, postsPathPref), "/")
+		target, ok := c.posts[slug]
+		if !ok {
+			target, ok = c.posts[slug+".md"]
+		}
+		if !ok {
+			return LinkMissing
+		}
+		return c.checkPost(post, target, u.Fragment)
+	case strings.HasPrefix(u.Path, assetPathPref):
+		return c.checkAsset(strings.TrimPrefix(u.Path, assetPathPref))
+	case strings.HasPrefix(u.Path, attachPathPref):
+		if c.attachments == nil {
+			return ""
+		}
+		if _, ok := c.attachments.paths[strings.TrimPrefix(u.Path, attachPathPref)]; !ok {
+			return LinkMissing
+		}
+	case !strings.HasPrefix(u.Path, "/") && isAttachmentRef(u.Path):
+		return c.checkAttachment(post, u.Path)
+	}
+	return ""
+}
+
+// checkPost checks a link from post to target, optionally pointing at heading.
+func (c *linkChecker) checkPost(post, target *storage.Post, heading string) string {
+	if target != post && !target.Meta.Published && post.Meta.Published {
+		return LinkUnpublished
+	}
+	if heading != "" {
+		if _, ok := ExtractSection(target.Content, heading); !ok {
+			return LinkMissingHeading
+		}
+	}
+	return ""
+}
+
+// checkAttachment resolves an attachment reference the way rendering does.
+func (c *linkChecker) checkAttachment(post *storage.Post, ref string) string {
+	if c.attachments == nil {
+		return ""
+	}
+	if _, ok := c.attachments.resolve(post, ref); !ok {
+		return LinkMissing
+	}
+	return ""
+}
+
+func (c *linkChecker) checkAsset(name string) string {
+	if c.assets == nil {
+		return ""
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if _, err := fs.Stat(c.assets, name); err != nil {
+		return LinkMissing
+	}
+	return ""
+}
+
+// report returns the posts with broken links, sorted by slug.
+func (c *linkChecker) report() []BrokenLinks {
+	out := []BrokenLinks{}
+	for _, p := range c.posts {
+		if issues := c.check(p); len(issues) > 0 {
+			out = append(out, BrokenLinks{Slug: p.Meta.Slug, Name: p.Meta.Name, Published: p.Meta.Published, Issues: issues})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Slug < out[j].Slug })
+	return out
+}
+
+// linkReport caches the broken-link report until the corpus changes.
+type linkReport struct {
+	mu     sync.Mutex
+	report []BrokenLinks // nil while stale
+}
+
+func (r *linkReport) get(build func() ([]BrokenLinks, error)) ([]BrokenLinks, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.report != nil {
+		return r.report, nil
+	}
+	report, err := build()
+	if err != nil {
+		return nil, err
+	}
+	r.report = report
+	return report, nil
+}
+
+func (r *linkReport) reset() {
+	r.mu.Lock()
+	r.report = nil
+	r.mu.Unlock()
+}
+
+// bodyLineOffset is the number of lines before body in the uploaded document data, so issues
+// point at lines of the file the author sent rather than of the stored body.
+func bodyLineOffset(data, body []byte) int {
+	if !bytes.HasSuffix(data, body) {
+		return 0
+	}
+	return bytes.Count(data[:len(data)-len(body)], []byte("\n"))
+}
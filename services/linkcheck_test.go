@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	firebaseauth "firebase.google.com/go/v4/auth"
+
+	"github.com/soockee/cybersocke.com/session"
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+func TestCheckLinks(t *testing.T) {
+	ps := newTestPostService(t, map[string]string{
+		"guide.md": testNote("Guide", "2024-01-02", true, "# Guide\n\n## Install\n\nsteps", "type/note"),
+		"draft.md": testNote("Draft", "2024-01-02", false, "secret", "type/note"),
+		"logo.png": "\x89PNG",
+	})
+	ps.SetAssets(fstest.MapFS{"img/logo.png": {Data: []byte("png")}})
+	doc := testNote("Host", "2024-01-02", true, "## Intro\n\n"+
+		"[[Guide]] [[Guide#Install]] [[Guide#Nope]] [[Draft]] [[Nowhere]] [[#Intro]] [[#Outro]]\n"+
+		"[ok](/posts/guide.md#install) [gone](/posts/gone) [ext](https://example.com/posts/x) [[Host]]\n"+
+		"![logo](/assets/img/logo.png) ![missing](/assets/img/none.png) [![logo](/assets/img/logo.png)](/posts/draft.md)\n"+
+		"![[logo.png|100]] ![[img/chart.svg]] ![logo](logo.png) [deck](/attachments/deck.pdf) `[[Nowhere]]`\n\n```\n[[Nowhere]]\n```", "type/note")
+	issues, err := ps.CheckLinks([]byte(doc), "host.md", context.Background())
+	if err != nil {
+		t.Fatalf("CheckLinks: %v", err)
+	}
+	// The frontmatter and blank line take 9 lines, so the first link line is line 12.
+	want := []LinkIssue{
+		{Kind: LinkKindWiki, Target: "Guide#Nope", Line: 12, Reason: LinkMissingHeading},
+		{Kind: LinkKindWiki, Target: "Draft", Line: 12, Reason: LinkUnpublished},
+		{Kind: LinkKindWiki, Target: "Nowhere", Line: 12, Reason: LinkMissing},
+		{Kind: LinkKindWiki, Target: "#Outro", Line: 12, Reason: LinkMissingHeading},
+		{Kind: LinkKindLink, Target: "/posts/gone", Line: 13, Reason: LinkMissing},
+		{Kind: LinkKindImage, Target: "/assets/img/none.png", Line: 14, Reason: LinkMissing},
+		{Kind: LinkKindLink, Target: "/posts/draft.md", Line: 14, Reason: LinkUnpublished},
+		{Kind: LinkKindImage, Target: "img/chart.svg", Line: 15, Reason: LinkMissing},
+		{Kind: LinkKindLink, Target: "/attachments/deck.pdf", Line: 15, Reason: LinkMissing},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Fatalf("CheckLinks =\n%+v\nwant\n%+v", issues, want)
+	}
+
+	// Drafts may link to drafts.
+	issues, err = ps.CheckLinks([]byte(testNote("Other draft", "2024-01-02", false, "[[Draft]]", "type/note")), "other.md", context.Background())
+	if err != nil || len(issues) != 0 {
+		t.Fatalf("draft to draft link reported: %+v %v", issues, err)
+	}
+}
+
+func TestBrokenLinkReport(t *testing.T) {
+	ps := newTestPostService(t, map[string]string{
+		"alpha.md": testNote("Alpha", "2024-01-02", true, "[[Beta]] and [[Draft]]", "type/note"),
+		"bravo.md": testNote("Bravo", "2024-01-02", true, "[[Alpha]]", "type/note"),
+		"draft.md": testNote("Draft", "2024-01-02", false, "[[Nowhere]]", "type/note"),
+	})
+	ctx := context.Background()
+	report, err := ps.BrokenLinks(ctx)
+	if err != nil {
+		t.Fatalf("BrokenLinks: %v", err)
+	}
+	if len(report) != 2 || report[0].Slug != "alpha.md" || len(report[0].Issues) != 2 || report[1].Slug != "draft.md" || report[1].Published {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	// Adding the missing post fixes Alpha's link on the next report.
+	authed := context.WithValue(ctx, session.IdTokenKey, &firebaseauth.Token{UID: "u1"})
+	upload := strings.Replace(testNote("Beta", "2024-01-02", true, "[[Gamma]]", "type/note"), "tags: [type/note]", "tags: [type/note, theme/go]", 1)
+	_, issues, err := ps.CreatePost([]byte(upload), "beta.md", storage.WriteOptions{}, authed)
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Target != "Gamma" || issues[0].Line != 10 {
+		t.Fatalf("upload issues = %+v", issues)
+	}
+	report, _ = ps.BrokenLinks(ctx)
+	if len(report) != 3 || report[0].Slug != "alpha.md" || len(report[0].Issues) != 1 || report[1].Slug != "beta.md" {
+		t.Fatalf("report not recomputed after upload: %+v", report)
+	}
+}
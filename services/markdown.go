@@ -2,17 +2,26 @@ package services
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"strings"
 
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/soockee/cybersocke.com/storage"
 )
 
 // StripDataview removes dataview-style frontmatter/meta directives from the source markdown.
 // It filters out lines beginning with known prefixes and also sanitizes certain patterns
-// (e.g., Obsidian-style block IDs like "^id" and embedded HTML whitespace markers).
+// (e.g., embedded HTML whitespace markers). Obsidian block IDs ("^id") are kept; the Obsidian
+// extension turns them into anchors.
 // The function returns the cleaned markdown content as a byte slice ready for rendering.
 func StripDataview(source []byte) []byte {
 	if len(source) == 0 {
@@ -38,10 +47,6 @@ func StripDataview(source []byte) []byte {
 			strings.HasPrefix(line, "update_time:") {
 			continue
 		}
-		// Remove obsidian block id anchors like ^blockId
-		if strings.HasPrefix(line, "^") {
-			continue
-		}
 		// Strip embedded html whitespace markers
 		line = strings.ReplaceAll(line, "<html>", "")
 		line = strings.ReplaceAll(line, "</html>", "")
@@ -51,9 +56,12 @@ func StripDataview(source []byte) []byte {
 	return []byte(strings.Join(out, "\n"))
 }
 
-func RenderMD(source []byte) bytes.Buffer {
-	md := goldmark.New(
-		goldmark.WithExtensions(extension.GFM),
+// newMarkdown builds the goldmark pipeline shared by every render. Wikilink resolution is
+// per-document and travels in the parser context (see convertMarkdown), so one instance
+// serves all posts.
+func newMarkdown() goldmark.Markdown {
+	return goldmark.New(
+		goldmark.WithExtensions(extension.GFM, WikiLinks(nil), Obsidian, Transclusion, Dataview, TableOfContents, Attachments),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(),
 		),
@@ -63,9 +71,199 @@ func RenderMD(source []byte) bytes.Buffer {
 			html.WithUnsafe(),
 		),
 	)
-	var buf bytes.Buffer
-	if err := md.Convert(source, &buf); err != nil {
-		panic(err)
+}
+
+// convertMarkdown renders doc.Source and returns its heading tree. The document's wikilink
+// resolver, embed, dataview, attachment and image functions (any of which may be nil) reach the
+// extensions through the parser context. Slug, Trusted and Variant are left to the caller.
+func convertMarkdown(md goldmark.Markdown, doc RenderDoc, w io.Writer) ([]*Heading, error) {
+	var headings []*Heading
+	pc := parser.NewContext(parser.WithIDs(newHeadingIDs()))
+	pc.Set(headingsKey, &headings)
+	if doc.Resolve != nil {
+		pc.Set(wikiLinkResolverKey, doc.Resolve)
+	}
+	if doc.Embed != nil {
+		pc.Set(embedFuncKey, doc.Embed)
+	}
+	if doc.Dataview != nil {
+		pc.Set(dataviewFuncKey, doc.Dataview)
+	}
+	if doc.Attachment != nil {
+		pc.Set(attachmentFuncKey, doc.Attachment)
+	}
+	if doc.Image != nil {
+		pc.Set(imageFuncKey, doc.Image)
+	}
+	if err := md.Convert(doc.Source, w, parser.WithContext(pc)); err != nil {
+		return nil, fmt.Errorf("render markdown: %w", err)
+	}
+	return headings, nil
+}
+
+// WikiLinkResolver maps a wikilink target (the part before # and |) to a post slug.
+type WikiLinkResolver func(target string) (slug string, ok bool)
+
+// KindWikiLink is the AST node kind of [[target#heading|label]] links.
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// WikiLinkNode is an inline [[...]] or ![[...]] occurrence. Href is set at parse time when the
+// target resolves; an empty Href renders as a missing link.
+type WikiLinkNode struct {
+	ast.BaseInline
+	Link storage.WikiLink
+	Href string
+}
+
+func (n *WikiLinkNode) Kind() ast.NodeKind { return KindWikiLink }
+
+func (n *WikiLinkNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Link.Target, "Heading": n.Link.Heading, "Label": n.Link.Label}, nil)
+}
+
+var wikiLinkResolverKey = parser.NewContextKey()
+
+type wikiLinkParser struct {
+	resolve WikiLinkResolver // fallback when the parser context carries no resolver
+}
+
+func (p *wikiLinkParser) Trigger() []byte { return []byte{'!', '['} }
+
+// Parse consumes [[...]] (or ![[...]]) on a single line; anything else is left to the regular
+// link and image parsers.
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	open := 0
+	if bytes.HasPrefix(line, []byte("![[")) {
+		open = 1
+	}
+	if !bytes.HasPrefix(line[open:], []byte("[[")) {
+		return nil
+	}
+	rest := line[open+2:]
+	end := bytes.Index(rest, []byte("]]"))
+	if end <= 0 || bytes.ContainsAny(rest[:end], "[]") {
+		return nil
+	}
+	link := storage.ParseWikiLink(string(rest[:end]))
+	if link.Target == "" && link.Heading == "" {
+		return nil
+	}
+	link.Embed = open == 1
+	block.Advance(open + 2 + end + 2)
+	if isAttachmentRef(link.Target) {
+		href := attachmentHref(pc, link.Target)
+		if link.Embed {
+			node := &AttachmentNode{Link: link, Href: href}
+			if href != "" {
+				node.Image = attachmentImage(pc, href)
+			}
+			return node
+		}
+		return &WikiLinkNode{Link: link, Href: href}
 	}
-	return buf
+	resolve := p.resolve
+	if r, ok := pc.Get(wikiLinkResolverKey).(WikiLinkResolver); ok {
+		resolve = r
+	}
+	return &WikiLinkNode{Link: link, Href: wikiLinkHref(link, resolve)}
+}
+
+// wikiLinkHref returns the URL a wikilink points to, or "" when its target does not resolve.
+func wikiLinkHref(link storage.WikiLink, resolve WikiLinkResolver) string {
+	if link.Target == "" {
+		return "#" + headingAnchor(link.Heading)
+	}
+	if resolve == nil {
+		return ""
+	}
+	slug, ok := resolve(link.Target)
+	if !ok {
+		return ""
+	}
+	href := "/posts/" + slug
+	if link.Heading != "" {
+		href += "#" + headingAnchor(link.Heading)
+	}
+	return href
+}
+
+type wikiLinkRenderer struct{}
+
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikiLink, r.render)
+}
+
+func (r *wikiLinkRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	node := n.(*WikiLinkNode)
+	href, label := node.Href, wikiLinkLabel(node.Link)
+	if href == "" {
+		_, _ = w.WriteString(`<span class="wikilink wikilink-missing" title="Note not found">`)
+		_, _ = w.Write(util.EscapeHTML([]byte(label)))
+		_, _ = w.WriteString(`</span>`)
+		return ast.WalkSkipChildren, nil
+	}
+	_, _ = w.WriteString(`<a class="wikilink" href="`)
+	_, _ = w.Write(util.EscapeHTML(util.URLEscape([]byte(href), false)))
+	_, _ = w.WriteString(`">`)
+	_, _ = w.Write(util.EscapeHTML([]byte(label)))
+	_, _ = w.WriteString(`</a>`)
+	return ast.WalkSkipChildren, nil
+}
+
+// wikiLinkLabel is the text shown for link: its alias, or the target and heading it points to.
+func wikiLinkLabel(link storage.WikiLink) string {
+	switch {
+	case link.Label != "":
+		return link.Label
+	case link.Target == "":
+		return link.Heading
+	case link.Heading != "":
+		return link.Target + " > " + link.Heading
+	default:
+		return link.Target
+	}
+}
+
+// headingAnchor reproduces goldmark's auto heading IDs (ASCII alphanumerics lowercased, spaces,
+// '-' and '_' become '-') so [[note#Some Heading]] lands on the rendered heading.
+// Block references (^id) are kept verbatim.
+func headingAnchor(heading string) string {
+	if strings.HasPrefix(heading, "^") {
+		return heading
+	}
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(heading) {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			b.WriteRune(r + 'a' - 'A')
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '_' || r == '\t':
+			b.WriteByte('-')
+		}
+	}
+	if b.Len() == 0 {
+		return "heading"
+	}
+	return b.String()
+}
+
+type wikiLinks struct {
+	resolve WikiLinkResolver
+}
+
+// WikiLinks returns a goldmark extension rendering Obsidian [[wikilinks]] as links to /posts/{slug}.
+// resolve is used for documents converted without a per-document resolver in their context.
+func WikiLinks(resolve WikiLinkResolver) goldmark.Extender {
+	return &wikiLinks{resolve: resolve}
+}
+
+func (e *wikiLinks) Extend(m goldmark.Markdown) {
+	// Ahead of the standard link parser (priority 200) so "[[" is not read as a link label.
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(&wikiLinkParser{resolve: e.resolve}, 199)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&wikiLinkRenderer{}, 199)))
 }
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// renderMD renders source with the shared pipeline and no sanitizing, cache or embeds; the
+// tests use it to check the extensions' raw output.
+func renderMD(source []byte, resolve WikiLinkResolver) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	_, err := convertMarkdown(newMarkdown(), RenderDoc{Source: source, Resolve: resolve}, &buf)
+	return buf, err
+}
+
+func TestRenderWikiLinks(t *testing.T) {
+	resolve := func(target string) (string, bool) {
+		if strings.EqualFold(target, "Alpha Note") {
+			return "alpha-note.md", true
+		}
+		return "", false
+	}
+	out, err := renderMD([]byte("See [[Alpha Note#Getting Started|the intro]], [[Missing]] and [a](https://example.com) ![img](x.png)."), resolve)
+	if err != nil {
+		t.Fatalf("renderMD: %v", err)
+	}
+	html := out.String()
+	for _, want := range []string{
+		`<a class="wikilink" href="/posts/alpha-note.md#getting-started">the intro</a>`,
+		`<span class="wikilink wikilink-missing" title="Note not found">Missing</span>`,
+		`<a href="https://example.com">a</a>`,
+		`<img src="/attachments/x.png" alt="img" />`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("missing %q in %s", want, html)
+		}
+	}
+	// Without a resolver links degrade to text instead of leaking [[...]].
+	if plain, _ := renderMD([]byte("[[Alpha Note]]"), nil); strings.Contains(plain.String(), "[[") {
+		t.Fatalf("raw wikilink rendered: %s", plain.String())
+	}
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Obsidian-flavoured markdown: > [!type] callouts, ==highlights==, %%comments%% and ^block-id
+// anchors. Block references ([[note#^id]]) are handled by the wikilink extension and land on
+// the ids assigned here.
+
+var (
+	// KindCallout is the AST node kind of > [!type] Title blocks.
+	KindCallout = ast.NewNodeKind("Callout")
+	// KindHighlight is the AST node kind of ==highlighted== text.
+	KindHighlight = ast.NewNodeKind("Highlight")
+	// KindComment and KindCommentBlock are the AST node kinds of inline and line-starting
+	// %%comments%%; neither renders.
+	KindComment      = ast.NewNodeKind("Comment")
+	KindCommentBlock = ast.NewNodeKind("CommentBlock")
+)
+
+// CalloutNode is a blockquote opened with [!type]. A "-" or "+" after the type makes it
+// foldable, collapsed or expanded by default.
+type CalloutNode struct {
+	ast.BaseBlock
+	Callout  string // the type, e.g. "note" or "warning"
+	Title    string
+	Foldable bool
+	Open     bool
+}
+
+func (n *CalloutNode) Kind() ast.NodeKind { return KindCallout }
+
+func (n *CalloutNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Callout": n.Callout, "Title": n.Title}, nil)
+}
+
+// HighlightNode wraps ==highlighted== inline content.
+type HighlightNode struct {
+	ast.BaseInline
+}
+
+func (n *HighlightNode) Kind() ast.NodeKind { return KindHighlight }
+
+func (n *HighlightNode) Dump(source []byte, level int) { ast.DumpHelper(n, source, level, nil, nil) }
+
+// CommentNode is an inline %%comment%%, possibly spanning lines of one paragraph.
+type CommentNode struct {
+	ast.BaseInline
+}
+
+func (n *CommentNode) Kind() ast.NodeKind { return KindComment }
+
+func (n *CommentNode) Dump(source []byte, level int) { ast.DumpHelper(n, source, level, nil, nil) }
+
+// CommentBlockNode is a %%comment%% starting a line, possibly spanning several blocks.
+type CommentBlockNode struct {
+	ast.BaseBlock
+	closed bool
+}
+
+func (n *CommentBlockNode) Kind() ast.NodeKind { return KindCommentBlock }
+
+func (n *CommentBlockNode) Dump(source []byte, level int) { ast.DumpHelper(n, source, level, nil, nil) }
+
+func (n *CommentBlockNode) IsRaw() bool { return true }
+
+var calloutRe = regexp.MustCompile(`^\[!([A-Za-z0-9_-]+)\]([+-]?)[ \t]*(.*?)\s*$`)
+
+type calloutParser struct{}
+
+func (p *calloutParser) Trigger() []byte { return []byte{'>'} }
+
+func (p *calloutParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	w, pos := util.IndentWidth(line, reader.LineOffset())
+	if w > 3 || pos >= len(line) || line[pos] != '>' {
+		return nil, parser.NoChildren
+	}
+	m := calloutRe.FindSubmatch(bytes.TrimLeft(line[pos+1:], " \t"))
+	if m == nil {
+		return nil, parser.NoChildren
+	}
+	node := &CalloutNode{
+		Callout:  strings.ToLower(string(m[1])),
+		Title:    string(m[3]),
+		Foldable: len(m[2]) > 0,
+		Open:     string(m[2]) == "+",
+	}
+	reader.Advance(len(bytes.TrimRight(line, "\r\n")))
+	return node, parser.HasChildren
+}
+
+// Continue follows goldmark's blockquote rules: every line of the callout starts with '>'.
+func (p *calloutParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, _ := reader.PeekLine()
+	w, pos := util.IndentWidth(line, reader.LineOffset())
+	if w > 3 || pos >= len(line) || line[pos] != '>' {
+		return parser.Close
+	}
+	pos++
+	if pos >= len(line) || line[pos] == '\n' {
+		reader.Advance(pos)
+		return parser.Continue | parser.HasChildren
+	}
+	reader.Advance(pos)
+	if line[pos] == ' ' || line[pos] == '\t' {
+		padding := 0
+		if line[pos] == '\t' {
+			padding = util.TabWidth(reader.LineOffset()) - 1
+		}
+		reader.AdvanceAndSetPadding(1, padding)
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+func (p *calloutParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *calloutParser) CanInterruptParagraph() bool { return true }
+
+func (p *calloutParser) CanAcceptIndentedLine() bool { return false }
+
+var commentMarker = []byte("%%")
+
+type commentBlockParser struct{}
+
+func (p *commentBlockParser) Trigger() []byte { return []byte{'%'} }
+
+// Open claims lines starting with %% unless the comment closes on the same line and text follows
+// it; those are left to the inline comment parser.
+func (p *commentBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	w, pos := util.IndentWidth(line, reader.LineOffset())
+	if w > 3 || !bytes.HasPrefix(line[pos:], commentMarker) {
+		return nil, parser.NoChildren
+	}
+	rest := line[pos+2:]
+	node := &CommentBlockNode{}
+	if i := bytes.Index(rest, commentMarker); i >= 0 {
+		if len(bytes.TrimSpace(rest[i+2:])) > 0 {
+			return nil, parser.NoChildren
+		}
+		node.closed = true
+	}
+	reader.Advance(len(bytes.TrimRight(line, "\r\n")))
+	return node, parser.NoChildren
+}
+
+func (p *commentBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*CommentBlockNode)
+	if n.closed {
+		return parser.Close
+	}
+	line, _ := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+	n.closed = bytes.Contains(line, commentMarker)
+	reader.Advance(len(bytes.TrimRight(line, "\r\n")))
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *commentBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *commentBlockParser) CanInterruptParagraph() bool { return true }
+
+func (p *commentBlockParser) CanAcceptIndentedLine() bool { return false }
+
+type commentParser struct{}
+
+func (p *commentParser) Trigger() []byte { return []byte{'%'} }
+
+// Parse consumes %%...%% up to the closing marker, which may be on a later line of the same
+// paragraph. An unclosed marker is left as text.
+func (p *commentParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if !bytes.HasPrefix(line, commentMarker) {
+		return nil
+	}
+	startLine, startPos := block.Position()
+	skip := 2
+	for {
+		if i := bytes.Index(line[skip:], commentMarker); i >= 0 {
+			block.Advance(skip + i + 2)
+			return &CommentNode{}
+		}
+		block.AdvanceLine()
+		line, _ = block.PeekLine()
+		if line == nil {
+			block.SetPosition(startLine, startPos)
+			return nil
+		}
+		skip = 0
+	}
+}
+
+type highlightDelimiterProcessor struct{}
+
+func (p *highlightDelimiterProcessor) IsDelimiter(b byte) bool { return b == '=' }
+
+func (p *highlightDelimiterProcessor) CanOpenCloser(opener, closer *parser.Delimiter) bool {
+	return opener.Char == closer.Char
+}
+
+func (p *highlightDelimiterProcessor) OnMatch(consumes int) ast.Node { return &HighlightNode{} }
+
+type highlightParser struct{}
+
+func (p *highlightParser) Trigger() []byte { return []byte{'='} }
+
+// Parse accepts exactly two '=' as delimiter, like goldmark's ~~strikethrough~~.
+func (p *highlightParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	before := block.PrecendingCharacter()
+	line, segment := block.PeekLine()
+	node := parser.ScanDelimiter(line, before, 1, &highlightDelimiterProcessor{})
+	if node == nil || node.OriginalLength != 2 || before == '=' {
+		return nil
+	}
+	node.Segment = segment.WithStop(segment.Start + node.OriginalLength)
+	block.Advance(node.OriginalLength)
+	pc.PushDelimiter(node)
+	return node
+}
+
+// blockIDRe matches a trailing Obsidian block ID ("text ^abc-1" or a line of just "^abc-1").
+var blockIDRe = regexp.MustCompile(`(?:^|[ \t])\^([A-Za-z0-9-]+)[ \t]*$`)
+
+// blockIDTransformer turns ^id markers into id="^id" attributes, so [[note#^id]] (rendered as
+// /posts/note#^id) scrolls to the block. A marker at the end of a paragraph or list item names
+// that block; a paragraph holding only the marker names the block before it.
+type blockIDTransformer struct{}
+
+func (t *blockIDTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	var blocks []ast.Node
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering && (n.Kind() == ast.KindParagraph || n.Kind() == ast.KindTextBlock) {
+			blocks = append(blocks, n)
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, b := range blocks {
+		applyBlockID(b, reader.Source())
+	}
+}
+
+func applyBlockID(block ast.Node, source []byte) {
+	last, ok := block.LastChild().(*ast.Text)
+	if !ok {
+		return
+	}
+	value := last.Segment.Value(source)
+	m := blockIDRe.FindSubmatchIndex(value)
+	if m == nil {
+		return
+	}
+	id := "^" + string(value[m[2]:m[3]])
+	last.Segment = last.Segment.WithStop(last.Segment.Start + m[0])
+	if last.Segment.Len() == 0 {
+		prev := last.PreviousSibling()
+		block.RemoveChild(block, last)
+		if t, ok := prev.(*ast.Text); ok {
+			t.SetSoftLineBreak(false)
+			t.SetHardLineBreak(false)
+		}
+	}
+	target := block
+	if block.ChildCount() == 0 {
+		target = block.PreviousSibling()
+		block.Parent().RemoveChild(block.Parent(), block)
+		if target == nil {
+			return
+		}
+	} else if parent := block.Parent(); parent != nil && parent.Kind() == ast.KindListItem {
+		target = parent
+	}
+	target.SetAttributeString("id", []byte(id))
+}
+
+type obsidianRenderer struct{}
+
+func (r *obsidianRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindCallout, r.renderCallout)
+	reg.Register(KindHighlight, r.renderHighlight)
+	reg.Register(KindComment, r.renderNothing)
+	reg.Register(KindCommentBlock, r.renderNothing)
+}
+
+// renderCallout writes a <div class="callout callout-{type}">, or a <details> element when the
+// callout is foldable.
+func (r *obsidianRenderer) renderCallout(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	c := n.(*CalloutNode)
+	outer, title := "div", "div"
+	if c.Foldable {
+		outer, title = "details", "summary"
+	}
+	if !entering {
+		_, _ = w.WriteString("</div>\n</" + outer + ">\n")
+		return ast.WalkContinue, nil
+	}
+	kind := calloutClass(c.Callout)
+	_, _ = w.WriteString("<" + outer + ` class="callout callout-` + kind + `"`)
+	if c.Attributes() != nil {
+		html.RenderAttributes(w, c, html.GlobalAttributeFilter)
+	}
+	if c.Foldable && c.Open {
+		_, _ = w.WriteString(` open=""`)
+	}
+	_, _ = w.WriteString(">\n<" + title + ` class="callout-title">`)
+	label := c.Title
+	if label == "" {
+		label = strings.ToUpper(kind[:1]) + kind[1:]
+	}
+	_, _ = w.Write(util.EscapeHTML([]byte(label)))
+	_, _ = w.WriteString("</" + title + ">\n" + `<div class="callout-content">` + "\n")
+	return ast.WalkContinue, nil
+}
+
+// calloutClass reduces a callout type to a class-safe name.
+func calloutClass(kind string) string {
+	var b strings.Builder
+	for _, r := range kind {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "note"
+	}
+	return b.String()
+}
+
+func (r *obsidianRenderer) renderHighlight(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString("<mark>")
+	} else {
+		_, _ = w.WriteString("</mark>")
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *obsidianRenderer) renderNothing(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	return ast.WalkSkipChildren, nil
+}
+
+type obsidian struct{}
+
+// Obsidian is a goldmark extension for callouts, highlights, comments and block IDs.
+var Obsidian goldmark.Extender = &obsidian{}
+
+func (e *obsidian) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		// Ahead of the blockquote (800) and paragraph (1000) parsers.
+		parser.WithBlockParsers(
+			util.Prioritized(&calloutParser{}, 799),
+			util.Prioritized(&commentBlockParser{}, 750),
+		),
+		parser.WithInlineParsers(
+			util.Prioritized(&commentParser{}, 198),
+			util.Prioritized(&highlightParser{}, 501),
+		),
+		parser.WithASTTransformers(util.Prioritized(&blockIDTransformer{}, 100)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&obsidianRenderer{}, 199)))
+}
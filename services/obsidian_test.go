@@ -0,0 +1,76 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderObsidianSyntax(t *testing.T) {
+	src := strings.Join([]string{
+		"> [!warning] Mind the gap",
+		"> Body with ==marked **bold**== text.",
+		"",
+		"> [!tip]- Folded",
+		"> hidden",
+		"",
+		"> [!info]+",
+		"> shown",
+		"",
+		"> plain quote",
+		"",
+		"Visible %%inline secret%% text and a == b.",
+		"",
+		"%%",
+		"block secret",
+		"",
+		"still secret",
+		"%%",
+		"",
+		"Paragraph with an id ^para-1",
+		"",
+		"- item one ^item-1",
+		"- item two",
+		"",
+		"| a |",
+		"|---|",
+		"| 1 |",
+		"",
+		"^table-1",
+		"",
+		"See [[Alpha#^para-1|the paragraph]] and [[#^item-1]].",
+	}, "\n")
+	resolve := func(target string) (string, bool) { return "alpha.md", target == "Alpha" }
+	out, err := renderMD([]byte(src), resolve)
+	if err != nil {
+		t.Fatalf("renderMD: %v", err)
+	}
+	html := out.String()
+	for _, want := range []string{
+		`<div class="callout callout-warning">` + "\n" + `<div class="callout-title">Mind the gap</div>`,
+		`<mark>marked <strong>bold</strong></mark>`,
+		`<details class="callout callout-tip">` + "\n" + `<summary class="callout-title">Folded</summary>`,
+		`<details class="callout callout-info" open="">` + "\n" + `<summary class="callout-title">Info</summary>`,
+		"<blockquote>\n<p>plain quote</p>",
+		`<p>Visible  text and a == b.</p>`,
+		`<p id="^para-1">Paragraph with an id</p>`,
+		`<li id="^item-1">item one</li>`,
+		`<table id="^table-1">`,
+		`href="/posts/alpha.md#%5Epara-1"`,
+		`href="#%5Eitem-1"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("missing %q in:\n%s", want, html)
+		}
+	}
+	for _, leak := range []string{"secret", "[!", "^table-1</p>", "%%"} {
+		if strings.Contains(html, leak) {
+			t.Errorf("output contains %q:\n%s", leak, html)
+		}
+	}
+
+	// The default sanitization policy keeps the generated markup.
+	clean, removed := ActiveSanitizePolicy().Sanitize(out.Bytes())
+	if len(removed) != 0 {
+		t.Fatalf("sanitizer stripped Obsidian markup: %v\n%s", removed, clean)
+	}
+}
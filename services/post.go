@@ -2,10 +2,14 @@ package services
 
 import (
 	"context"
+	"io/fs"
+	"maps"
 	"sort"
+	"strings"
 
 	firebaseauth "firebase.google.com/go/v4/auth"
 
+	"github.com/soockee/cybersocke.com/parser/frontmatter"
 	"github.com/soockee/cybersocke.com/session"
 	"github.com/soockee/cybersocke.com/storage"
 )
@@ -13,12 +17,46 @@ import (
 type PostService struct {
 	authService *AuthService
 	store       storage.Storage
+	search      *SearchIndex // built lazily on first query, then kept current by CreatePost
+	render      *RenderService
+	assets      fs.FS      // public assets checked by the link checker; nil skips them
+	links       linkReport // broken-link report, dropped on every change
+	images      imageCache // dimensions of image attachments
 }
 
 func NewPostService(store storage.Storage, authService *AuthService) *PostService {
-	return &PostService{
+	s := &PostService{
 		authService: authService,
 		store:       store,
+		search:      NewSearchIndex(),
+		render:      NewRenderService(DefaultRenderCacheSize, nil),
+	}
+	// Backends that observe out-of-band edits (bucket sync, vault watcher) push them to the index.
+	if n, ok := store.(storage.ChangeNotifier); ok {
+		n.Subscribe(s.applyChange)
+	}
+	return s
+}
+
+// SetAssets sets the files served under /assets/, against which links to assets are checked.
+func (s *PostService) SetAssets(public fs.FS) {
+	s.assets = public
+}
+
+// applyChange keeps the search index in step with a storage change. Before the first query the
+// index is not built yet and the change is picked up by the initial build instead.
+func (s *PostService) applyChange(c storage.PostChange) {
+	s.render.Invalidate(c.Slug)
+	s.links.reset()
+	if !s.search.Ready() {
+		return
+	}
+	if c.Deleted {
+		s.search.Remove(c.Slug)
+		return
+	}
+	if post, err := s.store.GetPost(c.Slug, context.Background()); err == nil && post != nil {
+		s.search.Update(post)
 	}
 }
 
@@ -137,12 +175,278 @@ func (s *PostService) GetRelatedPosts(slug string, limit int, ctx context.Contex
 	return filtered, nil
 }
 
-func (s *PostService) SearchPost(slug string, ctx context.Context) []string {
-	return []string{}
+// GetPostLinks returns the wikilinks of slug and its backlinks. Unauthenticated callers see
+// neither backlinks from nor resolved links to unpublished posts.
+func (s *PostService) GetPostLinks(slug string, ctx context.Context) (*storage.PostLinks, error) {
+	links, err := s.store.GetPostLinks(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Value(session.IdTokenKey) != nil {
+		return links, nil
+	}
+	all, err := s.store.GetPosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	published := func(slug string) bool {
+		p, ok := all[slug]
+		return ok && p.Meta.Published
+	}
+	filtered := &storage.PostLinks{Slug: links.Slug, Outgoing: make([]storage.LinkRef, 0, len(links.Outgoing)), Incoming: []string{}}
+	for _, ref := range links.Outgoing {
+		if ref.Slug != "" && !published(ref.Slug) {
+			ref.Slug = ""
+		}
+		filtered.Outgoing = append(filtered.Outgoing, ref)
+	}
+	for _, from := range links.Incoming {
+		if published(from) {
+			filtered.Incoming = append(filtered.Incoming, from)
+		}
+	}
+	return filtered, nil
+}
+
+// GetBacklinks returns the posts linking to slug, sorted by date desc (slug asc tie-breaker).
+func (s *PostService) GetBacklinks(slug string, ctx context.Context) ([]*storage.Post, error) {
+	links, err := s.GetPostLinks(slug, ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*storage.Post, 0, len(links.Incoming))
+	for _, from := range links.Incoming {
+		if p, err := s.store.GetPost(from, ctx); err == nil && p != nil {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Meta.Updated.Equal(out[j].Meta.Updated) {
+			return out[i].Meta.Slug < out[j].Meta.Slug
+		}
+		return out[i].Meta.Updated.After(out[j].Meta.Updated)
+	})
+	return out, nil
+}
+
+// LinkResolver returns a resolver for the wikilinks in slug's content, for RenderDoc.Resolve.
+// On lookup failure every link renders as unresolved rather than failing the page.
+func (s *PostService) LinkResolver(slug string, ctx context.Context) WikiLinkResolver {
+	links, err := s.GetPostLinks(slug, ctx)
+	if err != nil {
+		return nil
+	}
+	return links.Resolve
+}
+
+// RenderPost returns the HTML body of post as seen by the caller in ctx: dataview directives are
+// stripped, wikilinks resolve only to posts the caller may read and raw HTML is sanitized unless
+// the post is marked trusted_html. Embedded notes are inlined from posts the caller may read, up
+// to MaxEmbedDepth levels. Renders are cached.
+func (s *PostService) RenderPost(post *storage.Post, ctx context.Context) ([]byte, error) {
+	return s.renderSource(post, StripDataview(post.Content), ctx)
+}
+
+// RenderSection renders the part of post under heading (or the block "^id"), like RenderPost.
+// ok is false when the post has no such section.
+func (s *PostService) RenderSection(post *storage.Post, heading string, ctx context.Context) (html []byte, ok bool, err error) {
+	section, ok := ExtractSection(StripDataview(post.Content), heading)
+	if !ok {
+		return nil, false, nil
+	}
+	html, err = s.renderSource(post, section, ctx)
+	return html, err == nil, err
+}
+
+// TableOfContents returns the heading tree of post as RenderPost renders it. It shares the
+// render cache, so asking for both costs one conversion.
+func (s *PostService) TableOfContents(post *storage.Post, ctx context.Context) ([]*Heading, error) {
+	return s.render.Headings(s.renderDoc(post, StripDataview(post.Content), ctx))
+}
+
+func (s *PostService) renderSource(post *storage.Post, source []byte, ctx context.Context) ([]byte, error) {
+	return s.render.Render(s.renderDoc(post, source, ctx))
+}
+
+func (s *PostService) renderDoc(post *storage.Post, source []byte, ctx context.Context) RenderDoc {
+	return s.embedderDoc(post, source, s.newEmbedder(post.Meta.Slug, ctx), ctx)
+}
+
+// embedderDoc is renderDoc with the wikilinks and embeds of source resolved by emb.
+func (s *PostService) embedderDoc(post *storage.Post, source []byte, emb *embedder, ctx context.Context) RenderDoc {
+	attachment, image := s.attachmentResolver(post, ctx)
+	return RenderDoc{
+		Slug:       post.Meta.Slug,
+		Source:     source,
+		Resolve:    emb.resolve,
+		Embed:      emb.embed,
+		Dataview:   emb.dataview,
+		Attachment: attachment,
+		Image:      image,
+		Trusted:    post.Meta.TrustedHTML,
+		Variant:    renderVariant(ctx),
+	}
+}
+
+// QueryDataview runs a dataview query over the posts the caller in ctx may read.
+func (s *PostService) QueryDataview(query string, ctx context.Context) (*DataviewResult, error) {
+	q, err := ParseDataviewQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := s.GetPosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*storage.Post, 0, len(posts))
+	for _, p := range posts {
+		list = append(list, p)
+	}
+	return q.Run(list), nil
+}
+
+// ContentWarnings lists what sanitization strips from slug's rendered HTML, so authors learn at
+// upload time that part of their markup will not be shown. Trusted posts have no warnings.
+func (s *PostService) ContentWarnings(slug string, ctx context.Context) ([]string, error) {
+	post, err := s.store.GetPost(slug, ctx)
+	if err != nil || post == nil || post.Meta.TrustedHTML {
+		return nil, err
+	}
+	return s.render.Removals(StripDataview(post.Content))
+}
+
+// RenderStats reports the render cache counters.
+func (s *PostService) RenderStats() RenderStats {
+	return s.render.Stats()
+}
+
+// SearchPosts runs a full-text query over all posts, ranked by BM25. Unpublished posts are only
+// returned to authenticated callers. limit <= 0 means no cap.
+func (s *PostService) SearchPosts(query string, limit int, ctx context.Context) ([]SearchHit, error) {
+	if !s.search.Ready() {
+		all, err := s.store.GetPosts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.search.Rebuild(all)
+	}
+	authed := ctx.Value(session.IdTokenKey) != nil
+	return s.search.Search(query, limit, func(p *storage.Post) bool {
+		return authed || p.Meta.Published
+	}), nil
+}
+
+// CreatePost stores an uploaded post under the preconditions of opts and returns the version
+// written and its broken internal links (see CheckLinks), with line numbers counted in data.
+func (s *PostService) CreatePost(data []byte, originalFilename string, opts storage.WriteOptions, ctx context.Context) (string, []LinkIssue, error) {
+	version, err := s.store.CreatePost(data, originalFilename, opts, ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	slug := storage.SanitizeFilename(originalFilename)
+	s.render.Invalidate(slug)
+	s.links.reset()
+	// Keep the search index current; if it has not been built yet the first query loads everything.
+	if s.search.Ready() {
+		if post, err := s.store.GetPost(slug, ctx); err == nil && post != nil {
+			s.search.Update(post)
+		}
+	}
+	return version, s.storedLinkIssues(slug, data, ctx), nil
+}
+
+// UpdatePost replaces an existing post, refreshes its search index entry and cached render and
+// returns the version written and its broken internal links like CreatePost.
+func (s *PostService) UpdatePost(slug string, data []byte, opts storage.WriteOptions, ctx context.Context) (string, []LinkIssue, error) {
+	version, err := s.store.UpdatePost(slug, data, opts, ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if !strings.HasSuffix(slug, ".md") {
+		slug = slug + ".md"
+	}
+	s.render.Invalidate(slug)
+	s.links.reset()
+	if s.search.Ready() {
+		if post, err := s.store.GetPost(slug, ctx); err == nil && post != nil {
+			s.search.Update(post)
+		}
+	}
+	return version, s.storedLinkIssues(slug, data, ctx), nil
+}
+
+// storedLinkIssues checks the links of a post just written from data. Errors only cost the
+// issues, never the write that already succeeded.
+func (s *PostService) storedLinkIssues(slug string, data []byte, ctx context.Context) []LinkIssue {
+	issues, err := s.CheckLinks(data, slug, ctx)
+	if err != nil {
+		return nil
+	}
+	return issues
 }
 
-func (s *PostService) CreatePost(data []byte, originalFilename string, ctx context.Context) error {
-	return s.store.CreatePost(data, originalFilename, ctx)
+// CheckLinks lints a document without storing it; its metadata must be valid. Wikilinks, links to /posts/... and /assets/...
+// and images are checked against all posts and the public assets. The document is treated as
+// the post originalFilename would become, so links to itself and its headings resolve. Links
+// to drafts are only reported from published documents.
+func (s *PostService) CheckLinks(data []byte, originalFilename string, ctx context.Context) ([]LinkIssue, error) {
+	meta := storage.PostMeta{}
+	body, err := frontmatter.Parse(strings.NewReader(string(data)), &meta)
+	if err != nil {
+		return nil, err
+	}
+	meta.Slug = storage.SanitizeFilename(originalFilename)
+	if err := meta.Validate(); err != nil {
+		return nil, err
+	}
+	all, err := s.store.GetPosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	posts := maps.Clone(all)
+	if posts == nil {
+		posts = map[string]*storage.Post{}
+	}
+	post := &storage.Post{Meta: meta, Content: body}
+	posts[meta.Slug] = post
+	return s.checkPostLinks(post, posts, data, ctx), nil
+}
+
+// checkPostLinks checks the links of post, parsed from data, against posts (which include it)
+// and reports line numbers counted in data.
+func (s *PostService) checkPostLinks(post *storage.Post, posts map[string]*storage.Post, data []byte, ctx context.Context) []LinkIssue {
+	issues := newLinkChecker(posts, s.assets, s.attachmentIndex(ctx)).check(post)
+	offset := bodyLineOffset(data, post.Content)
+	for i := range issues {
+		issues[i].Line += offset
+	}
+	return issues
+}
+
+// BrokenLinks reports every post with broken internal links, sorted by slug. The report is
+// kept until a post changes.
+func (s *PostService) BrokenLinks(ctx context.Context) ([]BrokenLinks, error) {
+	return s.links.get(func() ([]BrokenLinks, error) {
+		posts, err := s.store.GetPosts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newLinkChecker(posts, s.assets, s.attachmentIndex(ctx)).report(), nil
+	})
+}
+
+// DeletePost removes a post and drops it from the search index and render cache.
+func (s *PostService) DeletePost(slug string, ctx context.Context) error {
+	if err := s.store.DeletePost(slug, ctx); err != nil {
+		return err
+	}
+	if !strings.HasSuffix(slug, ".md") {
+		slug = slug + ".md"
+	}
+	s.search.Remove(slug)
+	s.render.Invalidate(slug)
+	s.links.reset()
+	return nil
 }
 
 // ChooseStartingPost selects the newest post (date desc; slug asc tie-breaker) from a map.
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"reflect"
+	"time"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// UploadPreview is the outcome of a dry-run upload (see PreviewUpload).
+type UploadPreview struct {
+	Slug        string                   `json:"slug"`
+	Valid       bool                     `json:"valid"`             // the upload would be accepted
+	Tags        []string                 `json:"tags"`              // normalized when valid
+	Overwrites  bool                     `json:"overwrites"`        // a post with the slug exists
+	Version     string                   `json:"version,omitempty"` // of the post overwritten, to upload with If-Match
+	Changes     []FieldChange            `json:"changes,omitempty"`
+	Issues      storage.ValidationErrors `json:"issues"`
+	Warnings    []string                 `json:"warnings,omitempty"` // markup sanitization strips
+	BrokenLinks []LinkIssue              `json:"brokenLinks,omitempty"`
+	HTML        string                   `json:"html,omitempty"`
+}
+
+// FieldChange is a frontmatter field an upload changes on the post it overwrites.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// PreviewUpload runs the upload pipeline on data without storing it (see storage.CheckUpload)
+// and reports the slug, normalized tags and issues, whether an existing post would be
+// overwritten, at which version, and which of its fields change, the broken links and the
+// rendered HTML. Links and embeds resolve against every post, as they will once the upload is
+// stored. Renders are not cached.
+func (s *PostService) PreviewUpload(data []byte, originalFilename string, ctx context.Context) (*UploadPreview, error) {
+	post, issues := storage.CheckUpload(data, originalFilename, ctx)
+	preview := &UploadPreview{
+		Slug:   storage.SanitizeFilename(originalFilename),
+		Valid:  !issues.HasErrors(),
+		Issues: issues,
+	}
+	if preview.Issues == nil {
+		preview.Issues = storage.ValidationErrors{}
+	}
+	existing, err := s.store.GetPost(preview.Slug, ctx)
+	switch {
+	case err == nil && existing != nil:
+		preview.Overwrites, preview.Version = true, existing.Version
+	case err != nil && !errors.Is(err, storage.ErrPostNotFound):
+		return nil, err
+	}
+	if post == nil {
+		return preview, nil
+	}
+	preview.Tags = post.Meta.Tags
+	if preview.Overwrites {
+		preview.Changes = metaChanges(existing.Meta, post.Meta)
+	}
+
+	all, err := s.store.GetPosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	posts := maps.Clone(all)
+	if posts == nil {
+		posts = map[string]*storage.Post{}
+	}
+	posts[post.Meta.Slug] = post
+	preview.BrokenLinks = s.checkPostLinks(post, posts, data, ctx)
+
+	emb := &embedder{posts: s, ctx: ctx, resolve: storage.NameResolver(posts), chain: []string{embedKey(post.Meta.Slug, "")}}
+	html, removed, err := s.render.Preview(s.embedderDoc(post, StripDataview(post.Content), emb, ctx))
+	if err != nil {
+		return nil, err
+	}
+	preview.HTML, preview.Warnings = string(html), removed
+	return preview, nil
+}
+
+// metaChanges lists the frontmatter fields that differ between old and new, dates formatted
+// as they are written.
+func metaChanges(old, new storage.PostMeta) []FieldChange {
+	fields := []FieldChange{
+		{"name", old.Name, new.Name},
+		{"tags", nonNil(old.Tags), nonNil(new.Tags)},
+		{"aliases", nonNil(old.Aliases), nonNil(new.Aliases)},
+		{"lead", old.Lead, new.Lead},
+		{"created", formatTime(old.Created, time.DateOnly), formatTime(new.Created, time.DateOnly)},
+		{"updated", formatTime(old.Updated, time.RFC3339), formatTime(new.Updated, time.RFC3339)},
+		{"published", old.Published, new.Published},
+		{"trusted_html", old.TrustedHTML, new.TrustedHTML},
+		{"cover", old.Cover, new.Cover},
+	}
+	var changes []FieldChange
+	for _, f := range fields {
+		if !reflect.DeepEqual(f.Old, f.New) {
+			changes = append(changes, f)
+		}
+	}
+	return changes
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func formatTime(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(layout)
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	firebaseauth "firebase.google.com/go/v4/auth"
+
+	"github.com/soockee/cybersocke.com/session"
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+func TestPreviewUpload(t *testing.T) {
+	ps := newTestPostService(t, map[string]string{
+		"k8s.md":   testNote("K8s", "2024-01-02", true, "old body", "type/note"),
+		"other.md": testNote("Other", "2024-01-02", true, "other body", "type/note"),
+	})
+	ctx := context.WithValue(context.Background(), session.IdTokenKey, &firebaseauth.Token{UID: "u1"})
+	doc := "---\nname: K8s\nlead: new lead\ncreated: 2024-01-01\nupdated: 2024-01-03\npublished: true\n" +
+		"tags: [type/note, theme/kubernetes, type/note]\n---\n\nSee [[other]] and [[nowhere]].\n\n<span onclick=\"x()\">hi</span>\n"
+
+	preview, err := ps.PreviewUpload([]byte(doc), "K8s.md", ctx)
+	if err != nil {
+		t.Fatalf("PreviewUpload: %v", err)
+	}
+	if preview.Slug != "k8s.md" || !preview.Valid || !preview.Overwrites {
+		t.Fatalf("unexpected preview %+v", preview)
+	}
+	if want := []string{"type/note", "theme/kubernetes"}; !reflect.DeepEqual(preview.Tags, want) {
+		t.Fatalf("tags = %v, want %v", preview.Tags, want)
+	}
+	if len(preview.Issues) != 1 || preview.Issues[0].Field != "tags[2]" || preview.Issues[0].Line != 7 {
+		t.Fatalf("issues = %+v", []storage.ValidationIssue(preview.Issues))
+	}
+	wantChanges := []FieldChange{
+		{"tags", []string{"type/note"}, []string{"type/note", "theme/kubernetes"}},
+		{"lead", "about K8s", "new lead"},
+		{"updated", "2024-01-02T00:00:00Z", "2024-01-03T00:00:00Z"},
+	}
+	if !reflect.DeepEqual(preview.Changes, wantChanges) {
+		t.Fatalf("changes = %+v, want %+v", preview.Changes, wantChanges)
+	}
+	if len(preview.BrokenLinks) != 1 || preview.BrokenLinks[0].Target != "nowhere" || preview.BrokenLinks[0].Line != 10 {
+		t.Fatalf("broken links = %+v", preview.BrokenLinks)
+	}
+	if !strings.Contains(preview.HTML, `href="/posts/other.md"`) || strings.Contains(preview.HTML, "onclick") {
+		t.Fatalf("unexpected preview HTML:\n%s", preview.HTML)
+	}
+	if len(preview.Warnings) == 0 {
+		t.Fatalf("expected sanitizer warnings")
+	}
+	if post, _ := ps.GetPost("k8s.md", ctx); post == nil || post.Meta.Lead != "about K8s" {
+		t.Fatalf("dry run modified the stored post: %+v", post)
+	}
+
+	preview, err = ps.PreviewUpload([]byte("---\nname: New\ntrusted_html: true\n---\nBody\n"), "new.md", ctx)
+	if err != nil {
+		t.Fatalf("PreviewUpload: %v", err)
+	}
+	if preview.Valid || preview.Overwrites || preview.Changes != nil {
+		t.Fatalf("unexpected preview %+v", preview)
+	}
+	fields := map[string]bool{}
+	for _, issue := range preview.Issues {
+		fields[issue.Field] = true
+	}
+	for _, field := range []string{"lead", "created", "updated", "tags", "trusted_html"} {
+		if !fields[field] {
+			t.Fatalf("no issue for %s: %+v", field, []storage.ValidationIssue(preview.Issues))
+		}
+	}
+}
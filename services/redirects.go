@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// ErrRedirectsUnsupported is returned when the storage backend keeps no redirects.
+var ErrRedirectsUnsupported = errors.New("redirects are not supported by this storage backend")
+
+// redirectStore returns the backend's redirect store, if it has one.
+func (s *PostService) redirectStore() (storage.RedirectStore, error) {
+	rs, ok := s.store.(storage.RedirectStore)
+	if !ok {
+		return nil, ErrRedirectsUnsupported
+	}
+	return rs, nil
+}
+
+// PostRedirect returns where a request for post id that matched no post should go: the target
+// of a redirect from its post path, else the post id is an alias of or spells differently
+// (/posts/Some Note for some-note.md). Targets that are posts must be visible to the caller.
+// Returns storage.ErrRedirectNotFound (wrapped) when there is nowhere to go.
+func (s *PostService) PostRedirect(id string, ctx context.Context) (string, error) {
+	rs, err := s.redirectStore()
+	if err != nil {
+		return "", err
+	}
+	if r, err := rs.LookupRedirect(ctx, storage.PostPath(id)); err == nil {
+		return s.visibleTarget(r.To, ctx)
+	}
+	slug, err := rs.ResolveAlias(ctx, id)
+	if err != nil || slug == id || slug == id+".md" {
+		return "", fmt.Errorf("%w: %s", storage.ErrRedirectNotFound, id)
+	}
+	return s.visibleTarget(storage.PostPath(slug), ctx)
+}
+
+// PathRedirect returns the target of the redirect from a request path outside /posts/, like
+// PostRedirect.
+func (s *PostService) PathRedirect(path string, ctx context.Context) (string, error) {
+	rs, err := s.redirectStore()
+	if err != nil {
+		return "", err
+	}
+	r, err := rs.LookupRedirect(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	return s.visibleTarget(r.To, ctx)
+}
+
+// visibleTarget passes a redirect target through unless it is a post the caller may not see,
+// so redirects do not reveal drafts.
+func (s *PostService) visibleTarget(to string, ctx context.Context) (string, error) {
+	slug, ok := strings.CutPrefix(to, "/posts/")
+	if !ok {
+		return to, nil
+	}
+	if i := strings.IndexAny(slug, "/?#"); i >= 0 {
+		slug = slug[:i]
+	}
+	if post, err := s.GetPost(slug, ctx); err != nil || post == nil {
+		return "", fmt.Errorf("%w: target %s not found", storage.ErrRedirectNotFound, to)
+	}
+	return to, nil
+}
+
+// Redirects returns the redirect table, sorted by source path.
+func (s *PostService) Redirects(ctx context.Context) ([]storage.Redirect, error) {
+	rs, err := s.redirectStore()
+	if err != nil {
+		return nil, err
+	}
+	return rs.ListRedirects(ctx)
+}
+
+// PutRedirect adds or replaces the manual redirect from -> to.
+func (s *PostService) PutRedirect(from, to string, ctx context.Context) (*storage.Redirect, error) {
+	rs, err := s.redirectStore()
+	if err != nil {
+		return nil, err
+	}
+	return rs.PutRedirect(ctx, from, to)
+}
+
+// DeleteRedirect removes the redirect from path.
+func (s *PostService) DeleteRedirect(from string, ctx context.Context) error {
+	rs, err := s.redirectStore()
+	if err != nil {
+		return err
+	}
+	return rs.DeleteRedirect(ctx, from)
+}
+
+// AliasConflicts lists the aliases claimed by more than one post.
+func (s *PostService) AliasConflicts(ctx context.Context) ([]storage.AliasConflict, error) {
+	rs, err := s.redirectStore()
+	if err != nil {
+		return nil, err
+	}
+	return rs.AliasConflicts(ctx)
+}
+
+// RenamePost moves post slug to the slug newName sanitizes to, recording a redirect from the old
+// URL, and returns the new slug and the version of the renamed post.
+func (s *PostService) RenamePost(slug, newName string, opts storage.WriteOptions, ctx context.Context) (string, string, error) {
+	rs, err := s.redirectStore()
+	if err != nil {
+		return "", "", err
+	}
+	version, err := rs.RenamePost(ctx, slug, newName, opts)
+	if err != nil {
+		return "", "", err
+	}
+	if !strings.HasSuffix(slug, ".md") {
+		slug = slug + ".md"
+	}
+	newSlug := storage.SanitizeFilename(newName)
+	s.search.Remove(slug)
+	s.render.Invalidate(slug)
+	s.render.Invalidate(newSlug)
+	s.links.reset()
+	if s.search.Ready() {
+		if post, err := s.store.GetPost(newSlug, ctx); err == nil && post != nil {
+			s.search.Update(post)
+		}
+	}
+	return newSlug, version, nil
+}
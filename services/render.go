@@ -0,0 +1,259 @@
+package services
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"sync"
+
+	"github.com/yuin/goldmark"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// DefaultRenderCacheSize bounds the rendered-HTML cache when NewRenderService is given no size.
+const DefaultRenderCacheSize = 512
+
+// RenderStats reports render cache activity since startup.
+type RenderStats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	Errors        uint64 `json:"errors"`
+	Entries       int    `json:"entries"`
+	Capacity      int    `json:"capacity"`
+}
+
+// RenderService converts markdown with one goldmark pipeline, sanitizes the HTML and keeps the
+// result in a bounded LRU cache. Entries are keyed by a hash of the source and of how each
+// wikilink in it resolves, so an edit to the post or to the set of posts it links to yields a
+// fresh render. Content pulled in by ![[embeds]] and dataview queries is not part of the key;
+// instead each entry remembers the posts it embeds, or that it queried all of them, and
+// Invalidate drops it together with them.
+type RenderService struct {
+	md       goldmark.Markdown
+	policy   *SanitizePolicy
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front = most recently used
+	stats   RenderStats
+}
+
+type renderEntry struct {
+	key  string
+	slug string
+	deps []string // slugs of embedded posts; anyPostDep when every change matters, attachmentsDep when attachments resolved
+	html []byte
+	toc  []*Heading
+}
+
+// RenderDoc is one document to render.
+type RenderDoc struct {
+	Slug     string           // names the document so Invalidate can drop its entries
+	Source   []byte           // markdown, dataview directives already stripped
+	Resolve  WikiLinkResolver // nil renders every wikilink as missing
+	Embed    EmbedFunc        // nil renders ![[embeds]] as links
+	Dataview DataviewFunc     // nil renders ```dataview blocks as code
+	// Attachment resolves ![[image.png]] and relative attachment links; nil maps them to
+	// /attachments/ unchecked.
+	Attachment AttachmentFunc
+	// Image sizes resolved image attachments and lists their variants; nil renders them
+	// without width, height and srcset.
+	Image   ImageFunc
+	Trusted bool // skip sanitization
+	// Variant separates renders of the same source that differ by viewer, such as embedded
+	// drafts that only signed-in users may see.
+	Variant string
+}
+
+// NewRenderService returns a render service caching up to capacity documents
+// (DefaultRenderCacheSize if capacity <= 0) and sanitizing with policy (the active policy if nil).
+func NewRenderService(capacity int, policy *SanitizePolicy) *RenderService {
+	if capacity <= 0 {
+		capacity = DefaultRenderCacheSize
+	}
+	if policy == nil {
+		policy = ActiveSanitizePolicy()
+	}
+	return &RenderService{
+		md:       newMarkdown(),
+		policy:   policy,
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Render returns the HTML for doc. The output is sanitized unless doc.Trusted is set; the
+// returned slice must not be modified.
+func (s *RenderService) Render(doc RenderDoc) ([]byte, error) {
+	e, err := s.entry(doc)
+	if err != nil {
+		return nil, err
+	}
+	return e.html, nil
+}
+
+// Headings returns the heading tree of doc, rendering it if it is not cached yet. The tree is
+// shared with the cache and must not be modified.
+func (s *RenderService) Headings(doc RenderDoc) ([]*Heading, error) {
+	e, err := s.entry(doc)
+	if err != nil {
+		return nil, err
+	}
+	return e.toc, nil
+}
+
+// entry returns the cache entry for doc, rendering and storing it on a miss.
+func (s *RenderService) entry(doc RenderDoc) (*renderEntry, error) {
+	key := renderKey(doc)
+	s.mu.Lock()
+	if el, ok := s.entries[key]; ok {
+		s.order.MoveToFront(el)
+		s.stats.Hits++
+		e := el.Value.(*renderEntry)
+		s.mu.Unlock()
+		return e, nil
+	}
+	s.stats.Misses++
+	s.mu.Unlock()
+
+	var deps []string
+	hooked := doc
+	if doc.Embed != nil {
+		hooked.Embed = func(link storage.WikiLink) Embed {
+			e := doc.Embed(link)
+			deps = append(deps, e.Deps...)
+			return e
+		}
+	}
+	if doc.Dataview != nil {
+		hooked.Dataview = func(query string) (*DataviewResult, error) {
+			deps = append(deps, anyPostDep)
+			return doc.Dataview(query)
+		}
+	}
+	if doc.Attachment != nil {
+		hooked.Attachment = func(ref string) (string, bool) {
+			deps = append(deps, attachmentsDep)
+			return doc.Attachment(ref)
+		}
+	}
+	var buf bytes.Buffer
+	toc, err := convertMarkdown(s.md, hooked, &buf)
+	if err != nil {
+		s.mu.Lock()
+		s.stats.Errors++
+		s.mu.Unlock()
+		return nil, err
+	}
+	html := buf.Bytes()
+	if !doc.Trusted {
+		html, _ = s.policy.Sanitize(html)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok { // rendered concurrently
+		s.order.MoveToFront(el)
+		return el.Value.(*renderEntry), nil
+	}
+	e := &renderEntry{key: key, slug: doc.Slug, deps: deps, html: html, toc: toc}
+	s.entries[key] = s.order.PushFront(e)
+	for s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+		s.stats.Evictions++
+	}
+	return e, nil
+}
+
+// Preview renders doc like Render but bypasses the cache, for documents that are not stored.
+// removed lists what sanitization stripped.
+func (s *RenderService) Preview(doc RenderDoc) (html []byte, removed []string, err error) {
+	var buf bytes.Buffer
+	if _, err := convertMarkdown(s.md, doc, &buf); err != nil {
+		return nil, nil, err
+	}
+	if doc.Trusted {
+		return buf.Bytes(), nil, nil
+	}
+	html, removed = s.policy.Sanitize(buf.Bytes())
+	return html, removed, nil
+}
+
+// Removals reports what sanitization would strip from source, for warning authors at upload time.
+func (s *RenderService) Removals(source []byte) ([]string, error) {
+	var buf bytes.Buffer
+	if _, err := convertMarkdown(s.md, RenderDoc{Source: source}, &buf); err != nil {
+		return nil, err
+	}
+	_, removed := s.policy.Sanitize(buf.Bytes())
+	return removed, nil
+}
+
+// anyPostDep marks renders that depend on every post, such as dataview query results.
+const anyPostDep = "*"
+
+// Invalidate drops every cached render of slug, of the documents embedding it and of those
+// running dataview queries.
+func (s *RenderService) Invalidate(slug string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for el := s.order.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*renderEntry); e.slug == slug || slices.Contains(e.deps, slug) || slices.Contains(e.deps, anyPostDep) {
+			s.remove(el)
+			s.stats.Invalidations++
+		}
+		el = next
+	}
+}
+
+// Stats returns a snapshot of the cache counters.
+func (s *RenderService) Stats() RenderStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.Entries = s.order.Len()
+	stats.Capacity = s.capacity
+	return stats
+}
+
+func (s *RenderService) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*renderEntry).key)
+}
+
+// renderKey hashes the source, trust flag and variant together with the resolution of each
+// wikilink target, since the same markdown renders differently once a linked post appears,
+// disappears or is unpublished.
+func renderKey(doc RenderDoc) string {
+	h := sha256.New()
+	if doc.Trusted {
+		h.Write([]byte("trusted\x00"))
+	}
+	if doc.Variant != "" {
+		h.Write([]byte("variant\x00" + doc.Variant + "\x00"))
+	}
+	h.Write(doc.Source)
+	if resolve := doc.Resolve; resolve != nil {
+		for _, link := range storage.ExtractWikiLinks(doc.Source) {
+			if link.Target == "" {
+				continue
+			}
+			slug, ok := resolve(link.Target)
+			h.Write([]byte{0})
+			h.Write([]byte(link.Target))
+			if ok {
+				h.Write([]byte{1})
+				h.Write([]byte(slug))
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
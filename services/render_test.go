@@ -0,0 +1,66 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+)
+
+func TestRenderServiceCache(t *testing.T) {
+	s := NewRenderService(2, nil)
+	resolve := func(target string) (string, bool) { return "alpha.md", target == "Alpha" }
+
+	first, err := s.Render(RenderDoc{Slug: "a.md", Source: []byte("See [[Alpha]]"), Resolve: resolve})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !strings.Contains(string(first), `href="/posts/alpha.md"`) {
+		t.Fatalf("wikilink not resolved: %s", first)
+	}
+	if _, err := s.Render(RenderDoc{Slug: "a.md", Source: []byte("See [[Alpha]]"), Resolve: resolve}); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if st := s.Stats(); st.Hits != 1 || st.Misses != 1 || st.Entries != 1 {
+		t.Fatalf("unexpected stats after repeat: %+v", st)
+	}
+
+	// A change in how a link resolves is a different document.
+	missing, _ := s.Render(RenderDoc{Slug: "a.md", Source: []byte("See [[Alpha]]"), Resolve: func(string) (string, bool) { return "", false }})
+	if !strings.Contains(string(missing), "wikilink-missing") {
+		t.Fatalf("stale render served after link target disappeared: %s", missing)
+	}
+
+	// Capacity 2: a third document evicts the least recently used one.
+	if _, err := s.Render(RenderDoc{Slug: "b.md", Source: []byte("# B")}); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if st := s.Stats(); st.Evictions != 1 || st.Entries != 2 || st.Capacity != 2 {
+		t.Fatalf("unexpected stats after eviction: %+v", st)
+	}
+
+	s.Invalidate("a.md")
+	if st := s.Stats(); st.Invalidations != 1 || st.Entries != 1 {
+		t.Fatalf("unexpected stats after invalidation: %+v", st)
+	}
+}
+
+type failingRenderer struct{}
+
+func (failingRenderer) Render(io.Writer, []byte, ast.Node) error { return errors.New("boom") }
+func (failingRenderer) AddOptions(...renderer.Option)            {}
+
+func TestRenderServiceReturnsErrors(t *testing.T) {
+	s := NewRenderService(2, nil)
+	s.md = goldmark.New(goldmark.WithRenderer(failingRenderer{}))
+	if _, err := s.Render(RenderDoc{Slug: "a.md", Source: []byte("text")}); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected conversion error, got %v", err)
+	}
+	if st := s.Stats(); st.Errors != 1 || st.Entries != 0 {
+		t.Fatalf("failed render was cached: %+v", st)
+	}
+}
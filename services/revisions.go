@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// ErrRevisionsUnsupported is returned when the storage backend keeps no revisions.
+var ErrRevisionsUnsupported = errors.New("revisions are not supported by this storage backend")
+
+// revisionStore returns the backend's revision store, if it has one.
+func (s *PostService) revisionStore() (storage.RevisionStore, error) {
+	rs, ok := s.store.(storage.RevisionStore)
+	if !ok {
+		return nil, ErrRevisionsUnsupported
+	}
+	return rs, nil
+}
+
+// Revisions lists the revisions of a post, newest first.
+func (s *PostService) Revisions(slug string, ctx context.Context) ([]storage.Revision, error) {
+	rs, err := s.revisionStore()
+	if err != nil {
+		return nil, err
+	}
+	return rs.ListRevisions(ctx, slug)
+}
+
+// Revision returns one revision of a post and its document.
+func (s *PostService) Revision(slug, id string, ctx context.Context) (*storage.Revision, []byte, error) {
+	rs, err := s.revisionStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	return rs.GetRevision(ctx, slug, id)
+}
+
+// CompareRevisions returns the line diff from revision from to revision to of a post.
+func (s *PostService) CompareRevisions(slug, from, to string, ctx context.Context) ([]DiffLine, error) {
+	_, old, err := s.Revision(slug, from, ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, new, err := s.Revision(slug, to, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return DiffLines(old, new), nil
+}
+
+// RestoreRevision writes the document of a revision back as the post, a new revision that
+// passes the upload checks like any other write, and returns what UpdatePost returns. A deleted
+// post is created again; opts apply either way.
+func (s *PostService) RestoreRevision(slug, id string, opts storage.WriteOptions, ctx context.Context) (string, []LinkIssue, error) {
+	_, data, err := s.Revision(slug, id, ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	_, err = s.store.GetPost(slug, ctx)
+	switch {
+	case errors.Is(err, storage.ErrPostNotFound):
+		return s.CreatePost(data, slug, opts, ctx)
+	case err != nil:
+		return "", nil, err
+	}
+	return s.UpdatePost(slug, data, opts, ctx)
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v2"
+)
+
+//go:embed sanitize_policy.yaml
+var defaultSanitizePolicy []byte
+
+// SanitizePolicy is the allow-list applied to rendered post HTML. It is loaded from a YAML or
+// JSON file and is read-only once loaded.
+type SanitizePolicy struct {
+	Elements         map[string][]string `yaml:"elements" json:"elements"`
+	GlobalAttributes []string            `yaml:"globalAttributes" json:"globalAttributes,omitempty"`
+	URLSchemes       []string            `yaml:"urlSchemes" json:"urlSchemes,omitempty"`
+	IframeHosts      []string            `yaml:"iframeHosts" json:"iframeHosts,omitempty"`
+
+	attrs   map[string]map[string]bool // element -> allowed attributes, globals included
+	schemes map[string]bool
+	iframes map[string]bool
+}
+
+// neverAllowed elements cannot be enabled by a policy file.
+var neverAllowed = map[string]bool{"script": true, "style": true}
+
+// dropContent elements are removed together with everything inside them when not allowed;
+// other disallowed elements are unwrapped and keep their text.
+var dropContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "applet": true,
+	"template": true, "noscript": true, "noembed": true, "noframes": true, "textarea": true,
+	"title": true, "select": true, "svg": true, "math": true, "xmp": true, "plaintext": true,
+}
+
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+var urlAttributes = map[string]bool{"href": true, "src": true, "cite": true, "poster": true, "action": true, "formaction": true}
+
+var activeSanitizePolicy atomic.Pointer[SanitizePolicy]
+
+func init() {
+	p, err := ParseSanitizePolicy(defaultSanitizePolicy, "yaml")
+	if err != nil {
+		panic(fmt.Sprintf("embedded sanitize policy: %v", err))
+	}
+	activeSanitizePolicy.Store(p)
+}
+
+// ActiveSanitizePolicy returns the policy render services use when none is given explicitly.
+func ActiveSanitizePolicy() *SanitizePolicy { return activeSanitizePolicy.Load() }
+
+// SetSanitizePolicy replaces the active policy. Render services created earlier keep theirs,
+// so call it before constructing services.
+func SetSanitizePolicy(p *SanitizePolicy) {
+	if p != nil {
+		activeSanitizePolicy.Store(p)
+	}
+}
+
+// LoadSanitizePolicyFile reads a policy from path; ".json" files are decoded as JSON, anything
+// else as YAML.
+func LoadSanitizePolicyFile(path string) (*SanitizePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read sanitize policy: %w", err)
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+	return ParseSanitizePolicy(data, format)
+}
+
+// ParseSanitizePolicy decodes and checks a policy document in the given format ("yaml" or "json").
+func ParseSanitizePolicy(data []byte, format string) (*SanitizePolicy, error) {
+	p := &SanitizePolicy{}
+	var err error
+	switch format {
+	case "json":
+		err = json.Unmarshal(data, p)
+	case "yaml":
+		err = yaml.UnmarshalStrict(data, p)
+	default:
+		return nil, fmt.Errorf("unknown sanitize policy format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode sanitize policy: %w", err)
+	}
+	if err := p.init(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *SanitizePolicy) init() error {
+	checkAttr := func(el, attr string) error {
+		if strings.HasPrefix(attr, "on") {
+			return fmt.Errorf("sanitize policy: event handler attribute %q cannot be allowed on %s", attr, el)
+		}
+		return nil
+	}
+	p.attrs = make(map[string]map[string]bool, len(p.Elements))
+	for el, attrs := range p.Elements {
+		el = strings.ToLower(el)
+		if neverAllowed[el] {
+			return fmt.Errorf("sanitize policy: element %q cannot be allowed", el)
+		}
+		set := map[string]bool{}
+		for _, a := range append(append([]string{}, attrs...), p.GlobalAttributes...) {
+			a = strings.ToLower(a)
+			if err := checkAttr(el, a); err != nil {
+				return err
+			}
+			set[a] = true
+		}
+		p.attrs[el] = set
+	}
+	p.schemes = map[string]bool{}
+	for _, s := range p.URLSchemes {
+		p.schemes[strings.ToLower(strings.TrimSuffix(s, ":"))] = true
+	}
+	p.iframes = map[string]bool{}
+	for _, h := range p.IframeHosts {
+		p.iframes[strings.ToLower(h)] = true
+	}
+	return nil
+}
+
+// Sanitize filters html through the policy and returns the cleaned markup together with a
+// description of everything it removed, in document order without duplicates.
+func (p *SanitizePolicy) Sanitize(src []byte) ([]byte, []string) {
+	s := sanitizer{policy: p, seen: map[string]bool{}}
+	z := html.NewTokenizer(bytes.NewReader(src))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			// End of input (a bytes.Reader cannot fail otherwise). Close what the document left
+			// open so it cannot swallow the surrounding page.
+			for i := len(s.open) - 1; i >= 0; i-- {
+				s.out.WriteString("</" + s.open[i] + ">")
+			}
+			return s.out.Bytes(), s.removed
+		case html.TextToken:
+			if s.skip == 0 {
+				s.out.WriteString(escapeText(string(z.Text())))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			s.start(tok)
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			s.end(string(name))
+		case html.CommentToken, html.DoctypeToken:
+			// dropped silently: neither renders
+		}
+	}
+}
+
+type sanitizer struct {
+	policy  *SanitizePolicy
+	out     bytes.Buffer
+	open    []string // allowed elements awaiting their end tag
+	skip    int      // depth inside a dropped element
+	skipTag string
+	removed []string
+	seen    map[string]bool
+}
+
+func (s *sanitizer) note(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if !s.seen[msg] {
+		s.seen[msg] = true
+		s.removed = append(s.removed, msg)
+	}
+}
+
+func (s *sanitizer) start(tok html.Token) {
+	name := tok.Data
+	void := voidElements[name]
+	if s.skip > 0 {
+		if name == s.skipTag && !void {
+			s.skip++
+		}
+		return
+	}
+	allowed, ok := s.policy.attrs[name]
+	switch {
+	case !ok:
+		s.note("removed <%s> element", name)
+	case name == "iframe" && !s.policy.iframeAllowed(tok.Attr):
+		s.note("removed <iframe> with a source outside the allowed hosts")
+		ok = false
+	case name == "input" && !isCheckbox(tok.Attr):
+		s.note("removed <input> other than a checkbox")
+		ok = false
+	}
+	if !ok {
+		if dropContent[name] && !void {
+			s.skip, s.skipTag = 1, name
+		}
+		return
+	}
+	s.out.WriteString("<" + name)
+	for _, a := range tok.Attr {
+		key := a.Key
+		if a.Namespace != "" {
+			key = a.Namespace + ":" + a.Key
+		}
+		switch {
+		case !allowed[key]:
+			s.note("removed %s attribute on <%s>", key, name)
+			continue
+		case urlAttributes[key] && !s.policy.urlAllowed(a.Val):
+			s.note("removed unsafe %s URL on <%s>", key, name)
+			continue
+		case key == "srcset" && !s.policy.srcsetAllowed(a.Val):
+			s.note("removed unsafe srcset URL on <%s>", name)
+			continue
+		}
+		s.out.WriteString(" " + key + `="` + html.EscapeString(a.Val) + `"`)
+	}
+	if void {
+		s.out.WriteString(" />")
+		return
+	}
+	s.out.WriteString(">")
+	s.open = append(s.open, name)
+}
+
+func (s *sanitizer) end(name string) {
+	if s.skip > 0 {
+		if name == s.skipTag {
+			s.skip--
+		}
+		return
+	}
+	// Close up to the matching open element; stray end tags are dropped.
+	for i := len(s.open) - 1; i >= 0; i-- {
+		if s.open[i] != name {
+			continue
+		}
+		for j := len(s.open) - 1; j >= i; j-- {
+			s.out.WriteString("</" + s.open[j] + ">")
+		}
+		s.open = s.open[:i]
+		return
+	}
+}
+
+// urlAllowed accepts relative URLs, fragments and absolute URLs with an allowed scheme.
+func (p *SanitizePolicy) urlAllowed(raw string) bool {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" {
+		// "//host/path" inherits the page scheme; treat it like https.
+		return u.Host == "" || p.schemes["https"]
+	}
+	return p.schemes[strings.ToLower(u.Scheme)]
+}
+
+func (p *SanitizePolicy) srcsetAllowed(raw string) bool {
+	for _, candidate := range strings.Split(raw, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) > 0 && !p.urlAllowed(fields[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *SanitizePolicy) iframeAllowed(attrs []html.Attribute) bool {
+	for _, a := range attrs {
+		if a.Key != "src" {
+			continue
+		}
+		u, err := url.Parse(strings.TrimSpace(a.Val))
+		return err == nil && u.Scheme == "https" && p.iframes[strings.ToLower(u.Hostname())]
+	}
+	return false
+}
+
+func isCheckbox(attrs []html.Attribute) bool {
+	for _, a := range attrs {
+		if a.Key == "type" {
+			return strings.EqualFold(a.Val, "checkbox")
+		}
+	}
+	return false
+}
+
+// textEscaper escapes text content. Quotes are left alone so rendered prose stays readable.
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func escapeText(s string) string { return textEscaper.Replace(s) }
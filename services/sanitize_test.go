@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeDefaultPolicy(t *testing.T) {
+	in := `<h1 id="intro">Intro</h1>
+<p onclick="steal()">Hi <a href="javascript:alert(1)">x</a> <a href="/posts/a.md#top" title="A">a</a></p>
+<script>alert(1)</script><style>body{}</style>
+<div class="note"><object data="x"><p>fallback</p></object><blink>kept text</blink></div>
+<iframe src="https://www.youtube-nocookie.com/embed/abc" allowfullscreen=""></iframe>
+<iframe src="https://evil.example/embed"><p>gone</p></iframe>
+<ul><li><input checked="" disabled="" type="checkbox" /> done</li></ul>
+<input type="text" name="q" />
+<img src="data:image/png;base64,AAAA" alt="d" /><img src="/assets/a.png" srcset="/a-1x.png 1x, /a-2x.png 2x" alt="ok" />
+<p>1 &lt; 2 &amp; "quoted"</p>
+<table><tr><td align="right" style="color:red">1</td></tr></table>
+<div><em>unclosed`
+	out, removed := ActiveSanitizePolicy().Sanitize([]byte(in))
+	html := string(out)
+	for _, want := range []string{
+		`<h1 id="intro">Intro</h1>`,
+		`<p>Hi <a>x</a> <a href="/posts/a.md#top" title="A">a</a></p>`,
+		`<div class="note">kept text</div>`,
+		`<iframe src="https://www.youtube-nocookie.com/embed/abc" allowfullscreen=""></iframe>`,
+		`<li><input checked="" disabled="" type="checkbox" /> done</li>`,
+		`<img alt="d" />`,
+		`<img src="/assets/a.png" srcset="/a-1x.png 1x, /a-2x.png 2x" alt="ok" />`,
+		`<p>1 &lt; 2 &amp; "quoted"</p>`,
+		`<td align="right">1</td>`,
+		`<div><em>unclosed</em></div>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("missing %s in:\n%s", want, html)
+		}
+	}
+	for _, leak := range []string{"script", "alert", "steal", "body{}", "fallback", "evil", "gone", `type="text"`, "data:", "color:red"} {
+		if strings.Contains(html, leak) {
+			t.Errorf("sanitized output still contains %q:\n%s", leak, html)
+		}
+	}
+	for _, want := range []string{
+		"removed onclick attribute on <p>",
+		"removed unsafe href URL on <a>",
+		"removed <script> element",
+		"removed <iframe> with a source outside the allowed hosts",
+		"removed <input> other than a checkbox",
+		"removed style attribute on <td>",
+	} {
+		found := false
+		for _, r := range removed {
+			found = found || r == want
+		}
+		if !found {
+			t.Errorf("removal %q not reported: %v", want, removed)
+		}
+	}
+}
+
+func TestRenderPostSanitizesUntrusted(t *testing.T) {
+	fs := newFeedTestService(t, map[string]string{
+		"plain.md":   testNote("Plain", "2024-03-01", true, "Hello **Plain**", "type/note", "theme/go") + "\n<script>alert(1)</script><b onclick=\"x()\">bold</b>\n",
+		"trusted.md": strings.Replace(testNote("Trusted", "2024-03-01", true, "Hello **Trusted**", "type/note", "theme/go"), "published: true", "published: true\ntrusted_html: true", 1) + "\n<script>widget()</script>\n",
+	})
+	ctx := context.Background()
+	posts, _ := fs.posts.store.GetPosts(ctx)
+
+	plain, err := fs.posts.RenderPost(posts["plain.md"], ctx)
+	if err != nil {
+		t.Fatalf("RenderPost: %v", err)
+	}
+	if strings.Contains(string(plain), "alert") || !strings.Contains(string(plain), "<b>bold</b>") {
+		t.Fatalf("untrusted post not sanitized: %s", plain)
+	}
+	warnings, err := fs.posts.ContentWarnings("plain.md", ctx)
+	if err != nil || len(warnings) != 2 {
+		t.Fatalf("expected two warnings, got %v (%v)", warnings, err)
+	}
+
+	trusted, err := fs.posts.RenderPost(posts["trusted.md"], ctx)
+	if err != nil {
+		t.Fatalf("RenderPost: %v", err)
+	}
+	if !strings.Contains(string(trusted), "<script>widget()</script>") {
+		t.Fatalf("trusted post was sanitized: %s", trusted)
+	}
+	if w, _ := fs.posts.ContentWarnings("trusted.md", ctx); len(w) != 0 {
+		t.Fatalf("trusted post reported warnings: %v", w)
+	}
+}
+
+func TestLoadSanitizePolicyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(path, []byte(`{"elements": {"p": [], "a": ["href"]}, "urlSchemes": ["https"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadSanitizePolicyFile(path)
+	if err != nil {
+		t.Fatalf("LoadSanitizePolicyFile: %v", err)
+	}
+	out, _ := p.Sanitize([]byte(`<p><a href="http://x.test">x</a><a href="mailto:a@b">m</a></p><h1>t</h1>`))
+	if string(out) != `<p><a>x</a><a>m</a></p>t` {
+		t.Fatalf("unexpected output: %s", out)
+	}
+
+	for _, bad := range []string{
+		"elements:\n  script: []\n",
+		"elements:\n  a: [onclick]\n",
+		"elements: {}\nunknown: true\n",
+	} {
+		if _, err := ParseSanitizePolicy([]byte(bad), "yaml"); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package services
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// BM25 tuning constants. Field boosts weight a term hit by where it occurred so a match in the
+// title outranks the same word buried in the body.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	boostName    = 3.0
+	boostAliases = 2.5
+	boostTags    = 2.0
+	boostLead    = 1.5
+	boostBody    = 1.0
+
+	// prefixDiscount scales matches where the last query term is only a prefix of the indexed
+	// term, so search-as-you-type works while exact hits still win.
+	prefixDiscount = 0.6
+
+	snippetTokens = 30
+)
+
+// SearchHit is a ranked search result. Snippet is an excerpt of the post body split into
+// segments; Match marks segments that matched a query term.
+type SearchHit struct {
+	Post    *storage.Post
+	Score   float64
+	Snippet []SnippetSegment
+}
+
+type SnippetSegment struct {
+	Text  string
+	Match bool
+}
+
+// SnippetHTML renders the snippet as escaped HTML with matches wrapped in <mark>.
+func (h SearchHit) SnippetHTML() string {
+	var b strings.Builder
+	for _, seg := range h.Snippet {
+		if seg.Match {
+			b.WriteString("<mark>")
+			b.WriteString(html.EscapeString(seg.Text))
+			b.WriteString("</mark>")
+			continue
+		}
+		b.WriteString(html.EscapeString(seg.Text))
+	}
+	return b.String()
+}
+
+type searchDoc struct {
+	post   *storage.Post
+	length float64  // boosted token count across fields
+	terms  []string // distinct terms, used to drop postings on update
+	text   string   // plain body text for snippets
+}
+
+// SearchIndex is an in-memory inverted index over post name, lead, aliases, tags and body.
+// It is safe for concurrent use; Update and Remove keep it current without a full rebuild.
+type SearchIndex struct {
+	mu       sync.RWMutex
+	docs     map[string]*searchDoc
+	postings map[string]map[string]float64 // term -> slug -> boosted term frequency
+	totalLen float64
+	ready    bool
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		docs:     map[string]*searchDoc{},
+		postings: map[string]map[string]float64{},
+	}
+}
+
+// Ready reports whether the index has been populated by Rebuild.
+func (idx *SearchIndex) Ready() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.ready
+}
+
+// Rebuild replaces the index contents with posts.
+func (idx *SearchIndex) Rebuild(posts map[string]*storage.Post) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.docs = make(map[string]*searchDoc, len(posts))
+	idx.postings = map[string]map[string]float64{}
+	idx.totalLen = 0
+	for _, p := range posts {
+		idx.addLocked(p)
+	}
+	idx.ready = true
+}
+
+// Update indexes post, replacing any previous version with the same slug.
+func (idx *SearchIndex) Update(post *storage.Post) {
+	if post == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(post.Meta.Slug)
+	idx.addLocked(post)
+}
+
+// Remove drops slug from the index.
+func (idx *SearchIndex) Remove(slug string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(slug)
+}
+
+func (idx *SearchIndex) addLocked(p *storage.Post) {
+	slug := p.Meta.Slug
+	text := searchPlainText(p.Content)
+	tf := map[string]float64{}
+	length := 0.0
+	addField := func(s string, boost float64) {
+		for _, tok := range tokenize(s) {
+			tf[tok.term] += boost
+			length += boost
+		}
+	}
+	addField(p.Meta.Name, boostName)
+	addField(strings.Join(p.Meta.Aliases, " "), boostAliases)
+	addField(strings.Join(p.Meta.Tags, " "), boostTags)
+	addField(p.Meta.Lead, boostLead)
+	addField(text, boostBody)
+
+	doc := &searchDoc{post: p, length: length, text: text, terms: make([]string, 0, len(tf))}
+	for term, freq := range tf {
+		list, ok := idx.postings[term]
+		if !ok {
+			list = map[string]float64{}
+			idx.postings[term] = list
+		}
+		list[slug] = freq
+		doc.terms = append(doc.terms, term)
+	}
+	idx.docs[slug] = doc
+	idx.totalLen += length
+}
+
+func (idx *SearchIndex) removeLocked(slug string) {
+	doc, ok := idx.docs[slug]
+	if !ok {
+		return
+	}
+	for _, term := range doc.terms {
+		if list, ok := idx.postings[term]; ok {
+			delete(list, slug)
+			if len(list) == 0 {
+				delete(idx.postings, term)
+			}
+		}
+	}
+	idx.totalLen -= doc.length
+	delete(idx.docs, slug)
+}
+
+// Search ranks posts against query with BM25. Only posts accepted by keep are returned; the
+// filter runs before limit so hidden posts never take a result slot. limit <= 0 means no cap.
+func (idx *SearchIndex) Search(query string, limit int, keep func(*storage.Post) bool) []SearchHit {
+	qterms := uniqueTerms(tokenize(query))
+	if len(qterms) == 0 {
+		return []SearchHit{}
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	n := float64(len(idx.docs))
+	if n == 0 {
+		return []SearchHit{}
+	}
+	avgLen := idx.totalLen / n
+
+	scores := map[string]float64{}
+	matched := map[string]bool{} // indexed terms that contributed, used for highlighting
+	for i, q := range qterms {
+		expansions := map[string]float64{}
+		if _, ok := idx.postings[q]; ok {
+			expansions[q] = 1
+		}
+		// The last term may still be being typed; also match indexed terms it prefixes.
+		if i == len(qterms)-1 {
+			for term := range idx.postings {
+				if term != q && strings.HasPrefix(term, q) {
+					expansions[term] = prefixDiscount
+				}
+			}
+		}
+		for term, weight := range expansions {
+			list := idx.postings[term]
+			df := float64(len(list))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			for slug, tf := range list {
+				doc := idx.docs[slug]
+				norm := tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*doc.length/avgLen))
+				scores[slug] += weight * idf * norm
+			}
+			matched[term] = true
+		}
+	}
+
+	hits := make([]SearchHit, 0, len(scores))
+	for slug, score := range scores {
+		doc := idx.docs[slug]
+		if keep != nil && !keep(doc.post) {
+			continue
+		}
+		hits = append(hits, SearchHit{Post: doc.post, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Post.Meta.Slug < hits[j].Post.Meta.Slug
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	for i := range hits {
+		hits[i].Snippet = buildSnippet(idx.docs[hits[i].Post.Meta.Slug].text, matched)
+	}
+	return hits
+}
+
+type token struct {
+	term       string
+	start, end int // byte offsets into the source string
+}
+
+// tokenize splits s into lowercase letter/digit runs with their byte offsets.
+func tokenize(s string) []token {
+	var out []token
+	start := -1
+	for i, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			out = append(out, token{term: strings.ToLower(s[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		out = append(out, token{term: strings.ToLower(s[start:]), start: start, end: len(s)})
+	}
+	return out
+}
+
+func uniqueTerms(tokens []token) []string {
+	seen := map[string]struct{}{}
+	out := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if _, ok := seen[t.term]; ok {
+			continue
+		}
+		seen[t.term] = struct{}{}
+		out = append(out, t.term)
+	}
+	return out
+}
+
+// buildSnippet picks the window of snippetTokens tokens covering the most distinct matched
+// terms and splits it into highlighted segments. Without body matches the text start is used.
+func buildSnippet(text string, matched map[string]bool) []SnippetSegment {
+	tokens := tokenize(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+	bestStart, bestCount := 0, 0
+	for i, t := range tokens {
+		if !matched[t.term] {
+			continue
+		}
+		distinct := map[string]struct{}{}
+		for j := i; j < len(tokens) && j < i+snippetTokens; j++ {
+			if matched[tokens[j].term] {
+				distinct[tokens[j].term] = struct{}{}
+			}
+		}
+		if len(distinct) > bestCount {
+			bestStart, bestCount = i, len(distinct)
+		}
+	}
+	// Give the first match a little leading context.
+	if bestCount > 0 {
+		bestStart = max(0, bestStart-5)
+	}
+	end := min(len(tokens), bestStart+snippetTokens)
+
+	var segs []SnippetSegment
+	cursor := tokens[bestStart].start
+	appendText := func(s string, match bool) {
+		if s == "" {
+			return
+		}
+		if n := len(segs); n > 0 && segs[n-1].Match == match {
+			segs[n-1].Text += s
+			return
+		}
+		segs = append(segs, SnippetSegment{Text: s, Match: match})
+	}
+	if bestStart > 0 {
+		appendText("… ", false)
+	}
+	for _, t := range tokens[bestStart:end] {
+		gap, match := text[cursor:t.start], matched[t.term]
+		// Adjacent matches separated only by whitespace share one highlight.
+		if n := len(segs); match && n > 0 && segs[n-1].Match && strings.TrimSpace(gap) == "" {
+			appendText(gap, true)
+		} else {
+			appendText(gap, false)
+		}
+		appendText(text[t.start:t.end], match)
+		cursor = t.end
+	}
+	if end < len(tokens) {
+		appendText(" …", false)
+	} else {
+		appendText(strings.TrimRightFunc(text[cursor:], unicode.IsSpace), false)
+	}
+	return segs
+}
+
+var (
+	mdFenceRe    = regexp.MustCompile("(?m)^\\s*(```|~~~).*$")
+	mdImageRe    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLinkRe     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdWikiLinkRe = regexp.MustCompile(`!?\[\[(?:[^\]|]*\|)?([^\]]*)\]\]`)
+	mdTagRe      = regexp.MustCompile(`<[^>]+>`)
+	mdCommentRe  = regexp.MustCompile(`(?s)%%.*?%%`)
+	mdCalloutRe  = regexp.MustCompile(`\[![A-Za-z0-9_-]+\][+-]?`)
+	mdBlockIDRe  = regexp.MustCompile(`(?m)(^|[ \t])\^[A-Za-z0-9-]+[ \t]*$`)
+	mdMarkupRe   = regexp.MustCompile("(?m)^\\s*(#{1,6}|>|[-*+]|\\d+\\.)\\s+|[*_`~=]+")
+	spaceRe      = regexp.MustCompile(`\s+`)
+)
+
+// searchPlainText reduces markdown to readable text for indexing and snippets: dataview lines
+// are dropped via StripDataview, Obsidian comments, callout markers and block IDs go, and
+// link/emphasis markup is removed.
+func searchPlainText(content []byte) string {
+	s := string(StripDataview(content))
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "")
+	}
+	s = mdCommentRe.ReplaceAllString(s, " ")
+	s = mdCalloutRe.ReplaceAllString(s, "")
+	s = mdBlockIDRe.ReplaceAllString(s, "$1")
+	s = mdFenceRe.ReplaceAllString(s, "")
+	s = mdImageRe.ReplaceAllString(s, "$1")
+	s = mdLinkRe.ReplaceAllString(s, "$1")
+	s = mdWikiLinkRe.ReplaceAllString(s, "$1")
+	s = mdTagRe.ReplaceAllString(s, " ")
+	s = mdMarkupRe.ReplaceAllString(s, "")
+	return strings.TrimSpace(spaceRe.ReplaceAllString(s, " "))
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+func searchPost(slug, name, lead string, published bool, tags []string, body string) *storage.Post {
+	return &storage.Post{
+		Meta:    storage.PostMeta{Slug: slug, Name: name, Lead: lead, Published: published, Tags: tags},
+		Content: []byte(body),
+	}
+}
+
+func seedSearchIndex() *SearchIndex {
+	idx := NewSearchIndex()
+	idx.Rebuild(map[string]*storage.Post{
+		"k8s.md":   searchPost("k8s.md", "Kubernetes Basics", "Pods and nodes", true, []string{"theme/kubernetes"}, "A pod is the smallest unit. Scheduling places pods on nodes."),
+		"cost.md":  searchPost("cost.md", "Cutting Cloud Cost", "FinOps notes", true, []string{"theme/cost-optimization"}, "Rightsizing [kubernetes](https://k8s.io) workloads saves money.\ndataview: hidden"),
+		"draft.md": searchPost("draft.md", "Secret Kubernetes Draft", "", false, nil, "unfinished"),
+	})
+	return idx
+}
+
+func hitSlugs(hits []SearchHit) []string {
+	out := make([]string, 0, len(hits))
+	for _, h := range hits {
+		out = append(out, h.Post.Meta.Slug)
+	}
+	return out
+}
+
+func TestSearchIndexRanking(t *testing.T) {
+	idx := seedSearchIndex()
+	published := func(p *storage.Post) bool { return p.Meta.Published }
+
+	hits := idx.Search("kubernetes", 0, published)
+	if got := hitSlugs(hits); len(got) != 2 || got[0] != "k8s.md" || got[1] != "cost.md" {
+		t.Fatalf("expected title match first and draft filtered, got %v", got)
+	}
+	if all := idx.Search("kubernetes", 0, nil); len(all) != 3 {
+		t.Fatalf("expected draft when unfiltered, got %v", hitSlugs(all))
+	}
+	if limited := idx.Search("kubernetes", 1, published); len(limited) != 1 {
+		t.Fatalf("limit not applied: %v", hitSlugs(limited))
+	}
+	// Prefix match on the last term for search-as-you-type.
+	if got := hitSlugs(idx.Search("righ", 0, nil)); len(got) != 1 || got[0] != "cost.md" {
+		t.Fatalf("prefix search = %v", got)
+	}
+	// StripDataview lines are not indexed.
+	if got := idx.Search("hidden", 0, nil); len(got) != 0 {
+		t.Fatalf("dataview line indexed: %v", hitSlugs(got))
+	}
+	if got := idx.Search("  ", 0, nil); len(got) != 0 {
+		t.Fatalf("blank query returned %v", hitSlugs(got))
+	}
+}
+
+func TestSearchIndexSnippetAndUpdate(t *testing.T) {
+	idx := seedSearchIndex()
+	hits := idx.Search("kubernetes workloads", 0, nil)
+	var cost SearchHit
+	for _, h := range hits {
+		if h.Post.Meta.Slug == "cost.md" {
+			cost = h
+		}
+	}
+	html := cost.SnippetHTML()
+	if !strings.Contains(html, "<mark>kubernetes workloads</mark>") || strings.Contains(html, "https://") {
+		t.Fatalf("unexpected snippet %q", html)
+	}
+
+	idx.Update(searchPost("cost.md", "Cutting Cloud Cost", "", true, nil, "Spot instances."))
+	if got := idx.Search("workloads", 0, nil); len(got) != 0 {
+		t.Fatalf("stale postings after update: %v", hitSlugs(got))
+	}
+	if got := hitSlugs(idx.Search("spot", 0, nil)); len(got) != 1 || got[0] != "cost.md" {
+		t.Fatalf("updated content not searchable: %v", got)
+	}
+	idx.Remove("cost.md")
+	if got := idx.Search("cost", 0, nil); len(got) != 0 {
+		t.Fatalf("removed post still found: %v", hitSlugs(got))
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// PageMeta carries the head metadata of a rendered page: canonical URL, OpenGraph/Twitter cards
+// and, for posts, JSON-LD BlogPosting data. The zero value renders only the title.
+type PageMeta struct {
+	Title       string
+	Description string
+	Canonical   string // absolute URL
+	SiteName    string
+	Type        string // OpenGraph type: "website" or "article"
+	Published   time.Time
+	Modified    time.Time
+	Tags        []string
+	NoIndex     bool // keep the page out of search engines (drafts, search results)
+}
+
+// IsArticle reports whether the page describes a single post.
+func (m PageMeta) IsArticle() bool { return m.Type == "article" }
+
+// JSONLD returns schema.org BlogPosting data for article pages, or "" otherwise. The encoder
+// escapes <, > and & so the result is safe inside a <script> element.
+func (m PageMeta) JSONLD() string {
+	if !m.IsArticle() || m.NoIndex {
+		return ""
+	}
+	doc := map[string]any{
+		"@context":         "https://schema.org",
+		"@type":            "BlogPosting",
+		"headline":         m.Title,
+		"url":              m.Canonical,
+		"mainEntityOfPage": m.Canonical,
+		"datePublished":    m.Published.UTC().Format(time.RFC3339),
+		"dateModified":     m.Modified.UTC().Format(time.RFC3339),
+	}
+	if m.Description != "" {
+		doc["description"] = m.Description
+	}
+	if len(m.Tags) > 0 {
+		doc["keywords"] = m.Tags
+	}
+	if m.SiteName != "" {
+		doc["publisher"] = map[string]string{"@type": "Organization", "name": m.SiteName}
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// SiteService builds absolute URLs, page metadata, the sitemap and robots.txt. Like feeds, the
+// sitemap only ever lists published posts, whoever asks.
+type SiteService struct {
+	posts    *PostService
+	baseURL  string
+	name     string
+	disallow []string
+}
+
+func NewSiteService(posts *PostService, baseURL, name string, robotsDisallow []string) *SiteService {
+	return &SiteService{posts: posts, baseURL: strings.TrimRight(baseURL, "/"), name: name, disallow: robotsDisallow}
+}
+
+// URL makes path absolute against the configured base URL.
+func (s *SiteService) URL(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return s.baseURL + path
+}
+
+// PageMeta returns metadata for a non-post page at path.
+func (s *SiteService) PageMeta(title, description, path string) PageMeta {
+	return PageMeta{Title: title, Description: description, Canonical: s.URL(path), SiteName: s.name, Type: "website"}
+}
+
+// PostPageMeta returns article metadata for post. Unpublished posts (visible to authenticated
+// editors only) are marked noindex and carry no OpenGraph or structured data.
+func (s *SiteService) PostPageMeta(p *storage.Post) PageMeta {
+	meta := PageMeta{
+		Title:     p.Meta.Name,
+		Canonical: s.URL("/posts/" + url.PathEscape(p.Meta.Slug)),
+		SiteName:  s.name,
+		Type:      "article",
+	}
+	if !p.Meta.Published {
+		meta.NoIndex = true
+		return meta
+	}
+	meta.Description = p.Meta.Lead
+	meta.Published = p.Meta.Created
+	meta.Modified = p.Meta.Updated
+	meta.Tags = p.Meta.Tags
+	return meta
+}
+
+// TagPageMeta returns metadata for the tag view of the given tags.
+func (s *SiteService) TagPageMeta(tags []string) PageMeta {
+	return s.PageMeta(strings.Join(tags, ", "), "Notes tagged "+strings.Join(tags, ", "), "/?tags="+url.QueryEscape(strings.Join(tags, ",")))
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Sitemap renders sitemap.xml: the home and graph pages, every published post with its update
+// time and one tag page per tag used by a published post.
+func (s *SiteService) Sitemap(ctx context.Context) ([]byte, error) {
+	all, err := s.posts.GetPosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var newest time.Time
+	tagUpdated := map[string]time.Time{}
+	posts := make([]*storage.Post, 0, len(all))
+	for _, p := range all {
+		if !p.Meta.Published {
+			continue
+		}
+		posts = append(posts, p)
+		if p.Meta.Updated.After(newest) {
+			newest = p.Meta.Updated
+		}
+		for _, t := range p.Meta.Tags {
+			if p.Meta.Updated.After(tagUpdated[t]) {
+				tagUpdated[t] = p.Meta.Updated
+			}
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].Meta.Slug < posts[j].Meta.Slug })
+	lastmod := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	set := sitemapURLSet{URLs: []sitemapURL{
+		{Loc: s.URL("/"), LastMod: lastmod(newest)},
+		{Loc: s.URL("/graph"), LastMod: lastmod(newest)},
+	}}
+	for _, p := range posts {
+		set.URLs = append(set.URLs, sitemapURL{Loc: s.URL("/posts/" + url.PathEscape(p.Meta.Slug)), LastMod: lastmod(p.Meta.Updated)})
+	}
+	tags := make([]string, 0, len(tagUpdated))
+	for t := range tagUpdated {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	for _, t := range tags {
+		set.URLs = append(set.URLs, sitemapURL{Loc: s.URL("/?tags=" + url.QueryEscape(t)), LastMod: lastmod(tagUpdated[t])})
+	}
+	return marshalXML(set)
+}
+
+// Robots renders robots.txt: the configured disallowed prefixes and the sitemap location.
+func (s *SiteService) Robots() []byte {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if len(s.disallow) == 0 {
+		b.WriteString("Disallow:\n")
+	}
+	for _, path := range s.disallow {
+		b.WriteString("Disallow: " + path + "\n")
+	}
+	b.WriteString("\nSitemap: " + s.URL("/sitemap.xml") + "\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSitemapAndPageMetaSkipUnpublished(t *testing.T) {
+	fs := newFeedTestService(t, map[string]string{
+		"public.md": testNote("Public", "2024-03-01", true, "Hello **Public**", "type/note", "theme/go"),
+		"draft.md":  testNote("Draft", "2024-04-01", false, "Hello **Draft**", "type/note", "theme/secret"),
+	})
+	site := NewSiteService(fs.posts, "https://example.com", "example.com", []string{"/admin"})
+	ctx := context.Background()
+
+	raw, err := site.Sitemap(ctx)
+	if err != nil {
+		t.Fatalf("Sitemap: %v", err)
+	}
+	sitemap := string(raw)
+	for _, want := range []string{
+		"<loc>https://example.com/posts/public.md</loc>",
+		"<lastmod>2024-03-01T00:00:00Z</lastmod>",
+		"<loc>https://example.com/graph</loc>",
+		"<loc>https://example.com/?tags=theme%2Fgo</loc>",
+	} {
+		if !strings.Contains(sitemap, want) {
+			t.Errorf("sitemap missing %s:\n%s", want, sitemap)
+		}
+	}
+	for _, leak := range []string{"draft.md", "theme%2Fsecret"} {
+		if strings.Contains(sitemap, leak) {
+			t.Errorf("sitemap leaks unpublished %s", leak)
+		}
+	}
+
+	robots := string(site.Robots())
+	if !strings.Contains(robots, "Disallow: /admin\n") || !strings.Contains(robots, "Sitemap: https://example.com/sitemap.xml") {
+		t.Fatalf("unexpected robots.txt:\n%s", robots)
+	}
+
+	posts, _ := fs.posts.store.GetPosts(ctx)
+	meta := site.PostPageMeta(posts["public.md"])
+	if meta.Canonical != "https://example.com/posts/public.md" || meta.Description != "about Public" {
+		t.Fatalf("unexpected meta: %+v", meta)
+	}
+	var ld map[string]any
+	if err := json.Unmarshal([]byte(meta.JSONLD()), &ld); err != nil {
+		t.Fatalf("JSON-LD: %v", err)
+	}
+	if ld["@type"] != "BlogPosting" || ld["headline"] != "Public" || ld["datePublished"] != "2024-01-01T00:00:00Z" {
+		t.Fatalf("unexpected JSON-LD: %v", ld)
+	}
+
+	draft := site.PostPageMeta(posts["draft.md"])
+	if !draft.NoIndex || draft.Description != "" || draft.JSONLD() != "" || len(draft.Tags) != 0 {
+		t.Fatalf("draft metadata leaks details: %+v", draft)
+	}
+}
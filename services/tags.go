@@ -7,7 +7,10 @@ import (
 	"github.com/soockee/cybersocke.com/storage"
 )
 
-type TagService struct{}
+// TagService answers tag queries against the configured taxonomy.
+type TagService struct {
+	taxonomy *storage.Taxonomy
+}
 
 type TagSummary struct {
 	TagCounts map[string]int
@@ -15,7 +18,16 @@ type TagSummary struct {
 	Suggested []string
 }
 
-func NewTagService() *TagService { return &TagService{} }
+// NewTagService uses tax for family lookups; nil selects storage.ActiveTaxonomy.
+func NewTagService(tax *storage.Taxonomy) *TagService {
+	if tax == nil {
+		tax = storage.ActiveTaxonomy()
+	}
+	return &TagService{taxonomy: tax}
+}
+
+// Taxonomy returns the tag families and rules uploads are validated against.
+func (ts *TagService) Taxonomy() *storage.Taxonomy { return ts.taxonomy }
 
 // ParseSelectedTags parses a raw comma-separated tag query string.
 func (ts *TagService) ParseSelectedTags(raw string) []string {
@@ -107,14 +119,19 @@ func includesAll(tags []string, selected []string) bool {
 
 // ListFamilyTags returns the unique tags under a given family prefix (e.g. "theme").
 // For family "theme" this will return tags like: theme/observability, theme/platform
-// Ordering is lexicographic for stable UI grouping.
+// Values declared in the taxonomy are listed even before a post uses them; families the
+// taxonomy does not know yield nothing. Ordering is lexicographic for stable UI grouping.
 func (ts *TagService) ListFamilyTags(posts map[string]*storage.Post, family string) []string {
 	family = strings.TrimSpace(family)
-	if family == "" {
+	f, ok := ts.taxonomy.Family(family)
+	if !ok {
 		return []string{}
 	}
 	prefix := family + "/"
 	uniq := map[string]struct{}{}
+	for _, v := range f.Values {
+		uniq[prefix+v] = struct{}{}
+	}
 	for _, p := range posts {
 		for _, t := range p.Meta.Tags {
 			if strings.HasPrefix(t, prefix) {
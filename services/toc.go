@@ -0,0 +1,119 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Heading is an entry of a post's table of contents. ID is the anchor goldmark assigned to the
+// rendered heading; Children are the headings nested below it.
+type Heading struct {
+	Level    int        `json:"level"`
+	Text     string     `json:"text"`
+	ID       string     `json:"id"`
+	Children []*Heading `json:"children,omitempty"`
+}
+
+var headingsKey = parser.NewContextKey()
+
+var headingCommentRe = regexp.MustCompile(`%%.*?%%`)
+
+// headingIDs is goldmark's ID generator minus %%comments%%, which never render and must not
+// leak into anchors either.
+type headingIDs struct {
+	parser.IDs
+}
+
+func newHeadingIDs() parser.IDs {
+	return headingIDs{IDs: parser.NewContext().IDs()}
+}
+
+func (ids headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	return ids.IDs.Generate(headingCommentRe.ReplaceAll(value, nil), kind)
+}
+
+// tocTransformer records the document's top-level headings in the *[]*Heading placed in the
+// parser context by convertMarkdown. Headings inside callouts, lists and embeds are left out,
+// as in Obsidian's outline.
+type tocTransformer struct{}
+
+func (t *tocTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	out, _ := pc.Get(headingsKey).(*[]*Heading)
+	if out == nil {
+		return
+	}
+	var flat []*Heading
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		h, ok := n.(*ast.Heading)
+		if !ok {
+			continue
+		}
+		id, _ := h.AttributeString("id")
+		idBytes, _ := id.([]byte)
+		flat = append(flat, &Heading{Level: h.Level, Text: headingText(h, reader.Source()), ID: string(idBytes)})
+	}
+	*out = nestHeadings(flat)
+}
+
+// headingText is the plain text of a heading as rendered: wikilinks contribute their label,
+// comments nothing.
+func headingText(h *ast.Heading, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(h, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			b.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(n.Value)
+		case *WikiLinkNode:
+			b.WriteString(wikiLinkLabel(n.Link))
+			return ast.WalkSkipChildren, nil
+		case *CommentNode, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// nestHeadings turns headings in document order into a tree: each heading becomes a child of
+// the closest preceding heading with a lower level.
+func nestHeadings(flat []*Heading) []*Heading {
+	var roots, stack []*Heading
+	for _, h := range flat {
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, h)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, h)
+		}
+		stack = append(stack, h)
+	}
+	return roots
+}
+
+type tableOfContents struct{}
+
+// TableOfContents is a goldmark extension collecting each document's heading tree (see
+// convertMarkdown). It relies on parser.WithAutoHeadingID for the anchors.
+var TableOfContents goldmark.Extender = &tableOfContents{}
+
+func (e *tableOfContents) Extend(m goldmark.Markdown) {
+	// After the embed and dataview transformers, which may replace top-level blocks.
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(&tocTransformer{}, 50)))
+}
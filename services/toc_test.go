@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHeadingTree(t *testing.T) {
+	src := "# Title\n\n## Setup `kind`\n\n### Install [[Guide|the guide]]\n\n#### Deep\n\n## Setup `kind`\n\n> [!note]\n> ## Inside a callout\n\n- ## In a list\n\n# Second %%hidden%%\n"
+	var buf strings.Builder
+	toc, err := convertMarkdown(newMarkdown(), RenderDoc{Source: []byte(src)}, &buf)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	var dump func(hs []*Heading, depth int) string
+	dump = func(hs []*Heading, depth int) string {
+		var out string
+		for _, h := range hs {
+			out += strings.Repeat("  ", depth) + h.Text + " #" + h.ID + "\n" + dump(h.Children, depth+1)
+		}
+		return out
+	}
+	want := "Title #title\n  Setup kind #setup-kind\n    Install the guide #install-guidethe-guide\n      Deep #deep\n  Setup kind #setup-kind-1\nSecond #second\n"
+	if got := dump(toc, 0); got != want {
+		t.Fatalf("heading tree:\n%s\nwant:\n%s", got, want)
+	}
+	for _, id := range []string{`id="setup-kind"`, `id="setup-kind-1"`, `id="deep"`} {
+		if !strings.Contains(buf.String(), id) {
+			t.Errorf("rendered HTML lacks %s:\n%s", id, buf.String())
+		}
+	}
+}
+
+func TestTableOfContentsSharesRenderCache(t *testing.T) {
+	ps := newTestPostService(t, map[string]string{
+		"guide.md": testNote("Guide", "2024-01-02", true, "## Install\n\ntext\n\n### Linux\n\nmore", "type/note"),
+	})
+	ctx := context.Background()
+	post, _ := ps.GetPost("guide.md", ctx)
+	if _, err := ps.RenderPost(post, ctx); err != nil {
+		t.Fatalf("RenderPost: %v", err)
+	}
+	toc, err := ps.TableOfContents(post, ctx)
+	if err != nil {
+		t.Fatalf("TableOfContents: %v", err)
+	}
+	if len(toc) != 1 || toc[0].ID != "install" || len(toc[0].Children) != 1 || toc[0].Children[0].Text != "Linux" {
+		t.Fatalf("unexpected toc: %+v", toc)
+	}
+	if st := ps.RenderStats(); st.Misses != 1 || st.Hits != 1 {
+		t.Fatalf("expected the toc to come from the cached render: %+v", st)
+	}
+}
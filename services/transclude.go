@@ -0,0 +1,345 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+
+	firebaseauth "firebase.google.com/go/v4/auth"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/soockee/cybersocke.com/session"
+	"github.com/soockee/cybersocke.com/storage"
+)
+
+// Note transclusion: a paragraph consisting of nothing but ![[note]], ![[note#Heading]] or
+// ![[note#^block]] is replaced by the referenced content. Embeds inside running text stay
+// links, as do all embeds when the document is rendered without an EmbedFunc.
+
+// MaxEmbedDepth bounds how deeply embeds nest. Deeper embeds render as a notice instead.
+const MaxEmbedDepth = 3
+
+// KindEmbed is the AST node kind of a transcluded ![[embed]].
+var KindEmbed = ast.NewNodeKind("Embed")
+
+// EmbedFunc resolves an ![[embed]] of the document being rendered.
+type EmbedFunc func(link storage.WikiLink) Embed
+
+// Embed is the outcome of resolving an ![[embed]].
+type Embed struct {
+	Href  string   // link to the embedded note; empty when the target does not resolve
+	HTML  []byte   // rendered content, nil when the embed cannot be shown
+	Error string   // why HTML is nil
+	Deps  []string // slugs of every post whose content HTML depends on
+}
+
+// EmbedNode is a block-level embed, resolved at parse time.
+type EmbedNode struct {
+	ast.BaseBlock
+	Link  storage.WikiLink
+	Embed Embed
+}
+
+func (n *EmbedNode) Kind() ast.NodeKind { return KindEmbed }
+
+func (n *EmbedNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Link.Target, "Heading": n.Link.Heading, "Error": n.Embed.Error}, nil)
+}
+
+var embedFuncKey = parser.NewContextKey()
+
+// embedTransformer replaces paragraphs holding a single embed with EmbedNodes. It runs after
+// blockIDTransformer so a trailing ^id has already moved into the paragraph's attributes.
+type embedTransformer struct{}
+
+func (t *embedTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	embed, _ := pc.Get(embedFuncKey).(EmbedFunc)
+	if embed == nil {
+		return
+	}
+	source := reader.Source()
+	var blocks []ast.Node
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.Kind() {
+		case ast.KindParagraph, ast.KindTextBlock:
+			if soleEmbed(n, source) != nil {
+				blocks = append(blocks, n)
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, block := range blocks {
+		link := soleEmbed(block, source).Link
+		node := &EmbedNode{Link: link, Embed: embed(link)}
+		for _, attr := range block.Attributes() {
+			node.SetAttribute(attr.Name, attr.Value)
+		}
+		block.Parent().ReplaceChild(block.Parent(), block, node)
+	}
+}
+
+// soleEmbed returns the embed wikilink that is block's only content, ignoring blank text.
+func soleEmbed(block ast.Node, source []byte) *WikiLinkNode {
+	var found *WikiLinkNode
+	for c := block.FirstChild(); c != nil; c = c.NextSibling() {
+		if t, ok := c.(*ast.Text); ok && len(bytes.TrimSpace(t.Segment.Value(source))) == 0 {
+			continue
+		}
+		link, ok := c.(*WikiLinkNode)
+		if !ok || !link.Link.Embed || found != nil {
+			return nil
+		}
+		found = link
+	}
+	return found
+}
+
+type embedRenderer struct{}
+
+func (r *embedRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindEmbed, r.render)
+}
+
+func (r *embedRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	node := n.(*EmbedNode)
+	class := "embed"
+	if node.Embed.HTML == nil {
+		class += " embed-missing"
+	}
+	_, _ = w.WriteString(`<div class="` + class + `"`)
+	if node.Attributes() != nil {
+		html.RenderAttributes(w, node, html.GlobalAttributeFilter)
+	}
+	_, _ = w.WriteString(">\n" + `<div class="embed-title">`)
+	label := util.EscapeHTML([]byte(wikiLinkLabel(node.Link)))
+	if href := node.Embed.Href; href != "" {
+		_, _ = w.WriteString(`<a class="wikilink" href="`)
+		_, _ = w.Write(util.EscapeHTML(util.URLEscape([]byte(href), false)))
+		_, _ = w.WriteString(`">`)
+		_, _ = w.Write(label)
+		_, _ = w.WriteString(`</a>`)
+	} else {
+		_, _ = w.Write(label)
+	}
+	_, _ = w.WriteString("</div>\n")
+	if node.Embed.HTML == nil {
+		_, _ = w.WriteString(`<p class="embed-error">`)
+		_, _ = w.Write(util.EscapeHTML([]byte(node.Embed.Error)))
+		_, _ = w.WriteString("</p>\n</div>\n")
+		return ast.WalkSkipChildren, nil
+	}
+	_, _ = w.WriteString(`<div class="embed-content">` + "\n")
+	_, _ = w.Write(node.Embed.HTML)
+	_, _ = w.WriteString("</div>\n</div>\n")
+	return ast.WalkSkipChildren, nil
+}
+
+type transclusion struct{}
+
+// Transclusion is a goldmark extension rendering ![[embeds]] through the EmbedFunc in the
+// parser context (see convertMarkdown).
+var Transclusion goldmark.Extender = &transclusion{}
+
+func (e *transclusion) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(&embedTransformer{}, 90)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&embedRenderer{}, 199)))
+}
+
+// embedder resolves the embeds of one document for PostService. Nested documents get their own
+// embedder sharing the caller's context.
+type embedder struct {
+	posts   *PostService
+	ctx     context.Context
+	resolve WikiLinkResolver
+	chain   []string // embedKeys from the outermost document down to this one
+	deps    []string
+}
+
+func (s *PostService) newEmbedder(slug string, ctx context.Context) *embedder {
+	return &embedder{posts: s, ctx: ctx, resolve: s.LinkResolver(slug, ctx), chain: []string{embedKey(slug, "")}}
+}
+
+// embedKey identifies an embedded note or section for cycle detection.
+func embedKey(slug, heading string) string {
+	if heading == "" {
+		return slug
+	}
+	return slug + "#" + headingAnchor(heading)
+}
+
+func (e *embedder) embed(link storage.WikiLink) Embed {
+	out := e.lookup(link)
+	e.deps = append(e.deps, out.Deps...)
+	return out
+}
+
+func (e *embedder) lookup(link storage.WikiLink) Embed {
+	out := Embed{Href: wikiLinkHref(link, e.resolve)}
+	current := strings.SplitN(e.chain[len(e.chain)-1], "#", 2)[0]
+	slug, ok := current, true
+	if link.Target != "" {
+		if e.resolve == nil {
+			ok = false
+		} else {
+			slug, ok = e.resolve(link.Target)
+		}
+	}
+	if !ok {
+		out.Error = "Note not found."
+		return out
+	}
+	out.Deps = []string{slug}
+	key := embedKey(slug, link.Heading)
+	if slices.Contains(e.chain, key) {
+		out.Error = "Not embedded: the note would include itself."
+		return out
+	}
+	if len(e.chain) > MaxEmbedDepth {
+		out.Error = "Not embedded: embeds are nested too deeply."
+		return out
+	}
+	// GetPost applies the published check, so drafts never leak into public pages.
+	post, err := e.posts.GetPost(slug, e.ctx)
+	if err != nil || post == nil {
+		out.Error = "Note not found."
+		return out
+	}
+	source := StripDataview(post.Content)
+	if link.Heading != "" {
+		if source, ok = ExtractSection(source, link.Heading); !ok {
+			out.Error = "Section not found."
+			return out
+		}
+	}
+	inner := &embedder{posts: e.posts, ctx: e.ctx, resolve: e.posts.LinkResolver(slug, e.ctx), chain: append(slices.Clip(e.chain), key)}
+	var buf bytes.Buffer
+	attachment, image := inner.attachments(post)
+	doc := RenderDoc{Source: source, Resolve: inner.resolve, Embed: inner.embed, Dataview: inner.dataview, Attachment: attachment, Image: image}
+	if _, err := convertMarkdown(e.posts.render.md, doc, &buf); err != nil {
+		out.Error = "The note could not be rendered."
+		return out
+	}
+	out.Deps = append(out.Deps, inner.deps...)
+	out.HTML = buf.Bytes()
+	if !post.Meta.TrustedHTML {
+		// The embedding page may be trusted; untrusted content stays sanitized inside it.
+		out.HTML, _ = e.posts.render.policy.Sanitize(out.HTML)
+	}
+	return out
+}
+
+// attachments resolves the attachment references of the embedded post.
+func (e *embedder) attachments(post *storage.Post) (AttachmentFunc, ImageFunc) {
+	resolve, image := e.posts.attachmentResolver(post, e.ctx)
+	return func(ref string) (string, bool) {
+		e.deps = append(e.deps, attachmentsDep)
+		return resolve(ref)
+	}, image
+}
+
+// dataview runs a query for the document; its result changes with any post.
+func (e *embedder) dataview(query string) (*DataviewResult, error) {
+	e.deps = append(e.deps, anyPostDep)
+	return e.posts.QueryDataview(query, e.ctx)
+}
+
+// renderVariant tells signed-in and anonymous renders apart, since embeds of unpublished posts
+// are only resolved for signed-in users.
+func renderVariant(ctx context.Context) string {
+	if tok, _ := ctx.Value(session.IdTokenKey).(*firebaseauth.Token); tok != nil {
+		return "authed"
+	}
+	return ""
+}
+
+var (
+	atxHeadingRe = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	listItemRe   = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s`)
+	fenceRe      = regexp.MustCompile("^ {0,3}(```|~~~)")
+)
+
+// ExtractSection returns the part of markdown an Obsidian heading or block reference points to.
+// A heading selects the heading line and everything up to the next heading of the same or a
+// higher level; "^id" selects the block carrying that id (a single line for list items).
+// Headings match by their anchor, so "Some heading" finds "## Some Heading".
+func ExtractSection(markdown []byte, heading string) ([]byte, bool) {
+	lines := strings.SplitAfter(string(markdown), "\n")
+	if strings.HasPrefix(heading, "^") {
+		return extractBlock(lines, heading[1:])
+	}
+	want := headingAnchor(heading)
+	start, level := -1, 0
+	inFence := false
+	for i, line := range lines {
+		if fenceRe.MatchString(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		m := atxHeadingRe.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil {
+			continue
+		}
+		if start >= 0 && len(m[1]) <= level {
+			return []byte(strings.Join(lines[start:i], "")), true
+		}
+		if start < 0 && headingAnchor(m[2]) == want {
+			start, level = i, len(m[1])
+		}
+	}
+	if start < 0 {
+		return nil, false
+	}
+	return []byte(strings.Join(lines[start:], "")), true
+}
+
+// extractBlock finds the paragraph or list item ending in ^id. A marker on a line of its own
+// refers to the block before it.
+func extractBlock(lines []string, id string) ([]byte, bool) {
+	blank := func(i int) bool { return strings.TrimSpace(lines[i]) == "" }
+	for i, line := range lines {
+		m := blockIDRe.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil || m[1] != id {
+			continue
+		}
+		if strings.TrimSpace(line) == "^"+id {
+			end := i - 1
+			for end >= 0 && blank(end) {
+				end--
+			}
+			if end < 0 {
+				return nil, false
+			}
+			i = end
+		}
+		if listItemRe.MatchString(lines[i]) {
+			return []byte(strings.TrimLeft(lines[i], " \t")), true
+		}
+		start, end := i, i+1
+		for start > 0 && !blank(start-1) {
+			start--
+		}
+		for end < len(lines) && !blank(end) {
+			end++
+		}
+		return []byte(strings.Join(lines[start:end], "")), true
+	}
+	return nil, false
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	firebaseauth "firebase.google.com/go/v4/auth"
+
+	"github.com/soockee/cybersocke.com/session"
+)
+
+func renderSlug(t *testing.T, ps *PostService, slug string, ctx context.Context) string {
+	t.Helper()
+	post, err := ps.GetPost(slug, ctx)
+	if err != nil || post == nil {
+		t.Fatalf("GetPost(%s): %v", slug, err)
+	}
+	html, err := ps.RenderPost(post, ctx)
+	if err != nil {
+		t.Fatalf("RenderPost(%s): %v", slug, err)
+	}
+	return string(html)
+}
+
+func TestExtractSection(t *testing.T) {
+	md := "# Title\n\nintro\n\n## Setup\n\nsetup text ^setup-block\n\n### Details\n\n```\n# not a heading\n```\n\n## Usage\n\n- first\n- second ^item\n\n| a |\n|---|\n\n^table\n"
+	cases := []struct {
+		ref  string
+		want string
+	}{
+		{"Setup", "## Setup\n\nsetup text ^setup-block\n\n### Details\n\n```\n# not a heading\n```\n\n"},
+		{"details", "### Details\n\n```\n# not a heading\n```\n\n"},
+		{"Usage", "## Usage\n\n- first\n- second ^item\n\n| a |\n|---|\n\n^table\n"},
+		{"^setup-block", "setup text ^setup-block\n"},
+		{"^item", "- second ^item\n"},
+		{"^table", "| a |\n|---|\n"},
+	}
+	for _, c := range cases {
+		got, ok := ExtractSection([]byte(md), c.ref)
+		if !ok || string(got) != c.want {
+			t.Errorf("ExtractSection(%q) = %q, %v; want %q", c.ref, got, ok, c.want)
+		}
+	}
+	for _, ref := range []string{"not a heading", "Missing", "^nope"} {
+		if got, ok := ExtractSection([]byte(md), ref); ok {
+			t.Errorf("ExtractSection(%q) found %q", ref, got)
+		}
+	}
+}
+
+func TestTransclusion(t *testing.T) {
+	ps := newTestPostService(t, map[string]string{
+		"host.md":  testNote("Host", "2024-01-02", true, "Before\n\n![[Guide]]\n\n![[Guide#Install]]\n\n![[Draft]]\n\nInline ![[Guide]] stays a link.\n\n![[Nowhere]]", "type/note"),
+		"guide.md": testNote("Guide", "2024-01-02", true, "# Guide\n\nGuide intro\n\n## Install\n\nRun the installer\n\n## Other\n\nOther text", "type/note"),
+		"draft.md": testNote("Draft", "2024-01-02", false, "Secret draft", "type/note"),
+	})
+	ctx := context.Background()
+	out := renderSlug(t, ps, "host.md", ctx)
+	for _, want := range []string{
+		`<div class="embed">`,
+		`<a class="wikilink" href="/posts/guide.md">Guide</a>`,
+		"Guide intro",
+		`<a class="wikilink" href="/posts/guide.md#install">Guide &gt; Install</a>`,
+		`Inline <a class="wikilink" href="/posts/guide.md">Guide</a> stays a link.`,
+		`<div class="embed embed-missing">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "Guide intro") != 1 || strings.Count(out, "Run the installer") != 2 {
+		t.Errorf("unexpected embedded content:\n%s", out)
+	}
+	if strings.Contains(out, "Secret draft") {
+		t.Fatalf("unpublished note embedded for anonymous reader:\n%s", out)
+	}
+
+	authed := context.WithValue(ctx, session.IdTokenKey, &firebaseauth.Token{UID: "u1"})
+	if out := renderSlug(t, ps, "host.md", authed); !strings.Contains(out, "Secret draft") {
+		t.Fatalf("signed-in reader should see the embedded draft:\n%s", out)
+	}
+	if out := renderSlug(t, ps, "host.md", ctx); strings.Contains(out, "Secret draft") {
+		t.Fatalf("signed-in render served to anonymous reader:\n%s", out)
+	}
+
+	// Changing an embedded note drops the renders that show it.
+	before := ps.RenderStats().Entries
+	ps.render.Invalidate("guide.md")
+	if st := ps.RenderStats(); st.Entries != before-2 {
+		t.Fatalf("embedding renders not invalidated: %+v (had %d)", st, before)
+	}
+}
+
+func TestTransclusionCyclesAndDepth(t *testing.T) {
+	ps := newTestPostService(t, map[string]string{
+		"ping.md": testNote("Ping", "2024-01-02", true, "ping body\n\n![[Pong]]", "type/note"),
+		"pong.md": testNote("Pong", "2024-01-02", true, "pong body\n\n![[Ping]]", "type/note"),
+		"self.md": testNote("Self", "2024-01-02", true, "# Self\n\n## A\n\nsection a\n\n![[#B]]\n\n## B\n\nsection b\n\n![[#A]]", "type/note"),
+		"d1.md":   testNote("D1", "2024-01-02", true, "level one\n\n![[D2]]", "type/note"),
+		"d2.md":   testNote("D2", "2024-01-02", true, "level two\n\n![[D3]]", "type/note"),
+		"d3.md":   testNote("D3", "2024-01-02", true, "level three\n\n![[D4]]", "type/note"),
+		"d4.md":   testNote("D4", "2024-01-02", true, "level four\n\n![[D5]]", "type/note"),
+		"d5.md":   testNote("D5", "2024-01-02", true, "level five", "type/note"),
+	})
+	ctx := context.Background()
+
+	out := renderSlug(t, ps, "ping.md", ctx)
+	if strings.Count(out, "pong body") != 1 || strings.Count(out, "ping body") != 1 || !strings.Contains(out, "would include itself") {
+		t.Fatalf("cycle not cut after one round:\n%s", out)
+	}
+
+	out = renderSlug(t, ps, "self.md", ctx)
+	if !strings.Contains(out, "would include itself") || !strings.Contains(out, `href="#b">B</a>`) {
+		t.Fatalf("same-note section embeds not handled:\n%s", out)
+	}
+
+	out = renderSlug(t, ps, "d1.md", ctx)
+	if !strings.Contains(out, "level four") || strings.Contains(out, "level five") || !strings.Contains(out, "nested too deeply") {
+		t.Fatalf("depth limit not applied:\n%s", out)
+	}
+}
+
+func TestRenderSection(t *testing.T) {
+	ps := newTestPostService(t, map[string]string{
+		"guide.md": testNote("Guide", "2024-01-02", true, "# Guide\n\n## Install\n\nRun the installer\n\n## Other\n\nOther text", "type/note"),
+	})
+	ctx := context.Background()
+	post, _ := ps.GetPost("guide.md", ctx)
+	html, ok, err := ps.RenderSection(post, "install", ctx)
+	if err != nil || !ok {
+		t.Fatalf("RenderSection: %v %v", ok, err)
+	}
+	if !strings.Contains(string(html), "Run the installer") || strings.Contains(string(html), "Other text") {
+		t.Fatalf("unexpected section:\n%s", html)
+	}
+	if _, ok, err := ps.RenderSection(post, "Missing", ctx); ok || err != nil {
+		t.Fatalf("missing section: ok=%v err=%v", ok, err)
+	}
+}
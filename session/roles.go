@@ -0,0 +1,49 @@
+package session
+
+import firebaseauth "firebase.google.com/go/v4/auth"
+
+// HasRole reports whether tok carries the wanted role. Accepted claim patterns:
+//
+//	role: "writer" OR "admin"
+//	roles: ["writer", "admin"] slice
+//	boolean claims: writer=true OR admin=true
+//
+// admin implies writer, and writer implies user.
+func HasRole(tok *firebaseauth.Token, want string) bool {
+	if tok == nil {
+		return false
+	}
+	c := tok.Claims
+	roleStr, _ := c["role"].(string)
+	rolesSlice, _ := c["roles"].([]any)
+	claimTrue := func(name string) bool {
+		if b, ok := c[name].(bool); ok && b {
+			return true
+		}
+		return false
+	}
+	rolePresent := func(name string) bool {
+		if roleStr == name {
+			return true
+		}
+		for _, v := range rolesSlice {
+			if s, ok := v.(string); ok && s == name {
+				return true
+			}
+		}
+		return false
+	}
+	admin := rolePresent("admin") || claimTrue("admin")
+	writer := rolePresent("writer") || claimTrue("writer") || admin // admin supersets writer
+	user := rolePresent("user") || claimTrue("user") || writer      // writer/admin supersets user
+	switch want {
+	case "admin":
+		return admin
+	case "writer":
+		return writer
+	case "user":
+		return user
+	default:
+		return rolePresent(want) || claimTrue(want)
+	}
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxAttachmentSize bounds a single uploaded attachment.
+const MaxAttachmentSize = 10 << 20
+
+// Attachment is an image or document stored alongside the posts. Path is "/"-separated and
+// relative to the backend's attachment root; it is the part of the URL after /attachments/.
+type Attachment struct {
+	Path        string    `json:"path"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Modified    time.Time `json:"modified"`
+}
+
+// AttachmentStore is implemented by backends that keep attachments: GCS under attachments/,
+// the filesystem backend in the vault itself and SQLite in an attachments table.
+type AttachmentStore interface {
+	// PutAttachment stores data as filename next to the post slug, replacing an attachment of
+	// the same name. Returns ErrPostNotFound if slug does not exist.
+	PutAttachment(ctx context.Context, slug, filename string, data []byte) (*Attachment, error)
+	// GetAttachment returns the attachment at path and its content.
+	GetAttachment(ctx context.Context, path string) (*Attachment, []byte, error)
+	// ListAttachments returns every attachment, sorted by path.
+	ListAttachments(ctx context.Context) ([]*Attachment, error)
+	// DeleteAttachment removes the attachment at path.
+	DeleteAttachment(ctx context.Context, path string) error
+}
+
+var (
+	// ErrAttachmentNotFound is returned (wrapped) when no attachment exists at a path.
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrAttachmentTooLarge is returned when an upload exceeds MaxAttachmentSize.
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+	// ErrAttachmentType is returned when an upload is not a supported image or PDF, or its
+	// content does not match its extension.
+	ErrAttachmentType = errors.New("unsupported attachment type")
+	// ErrAttachmentName is returned for empty, hidden or otherwise unusable file names.
+	ErrAttachmentName = errors.New("invalid attachment name")
+)
+
+// attachmentTypes maps the supported extensions to the content type they are served with.
+var attachmentTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".svg":  "image/svg+xml",
+	".pdf":  "application/pdf",
+}
+
+// AttachmentContentType returns the content type attachments named name are served with, and
+// false when the extension is not a supported attachment type.
+func AttachmentContentType(name string) (string, bool) {
+	ct, ok := attachmentTypes[strings.ToLower(path.Ext(name))]
+	return ct, ok
+}
+
+// CleanAttachmentPath normalizes a requested attachment path. It returns false for paths that
+// are empty, leave the attachment root or pass through hidden files or directories.
+func CleanAttachmentPath(p string) (string, bool) {
+	p = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(p, "\\", "/")), "/")
+	if p == "" {
+		return "", false
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if isHidden(seg) {
+			return "", false
+		}
+	}
+	return p, true
+}
+
+// prepareAttachment validates an upload: the name must be a plain file name with a supported
+// extension, the size within MaxAttachmentSize and the sniffed content must match the
+// extension. The returned attachment carries the file name as Path; backends prefix it.
+func prepareAttachment(filename string, data []byte) (*Attachment, error) {
+	name := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if name == "." || name == "/" || isHidden(name) || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return nil, fmt.Errorf("%w: %q", ErrAttachmentName, filename)
+	}
+	ct, ok := AttachmentContentType(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentType, path.Ext(name))
+	}
+	if len(data) > MaxAttachmentSize {
+		return nil, fmt.Errorf("%w: %d bytes (limit %d)", ErrAttachmentTooLarge, len(data), MaxAttachmentSize)
+	}
+	if !contentMatches(ct, data) {
+		return nil, fmt.Errorf("%w: %s content does not look like %s", ErrAttachmentType, name, ct)
+	}
+	return &Attachment{Path: name, ContentType: ct, Size: int64(len(data)), Modified: time.Now().UTC()}, nil
+}
+
+// contentMatches sniffs data and compares it with the type its extension promises. SVG is
+// text and cannot be sniffed, so it only needs to contain an <svg element.
+func contentMatches(contentType string, data []byte) bool {
+	if contentType == "image/svg+xml" {
+		return bytes.Contains(bytes.ToLower(data[:min(len(data), 4096)]), []byte("<svg"))
+	}
+	sniffed, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	return sniffed == contentType
+}
+
+// attachmentDir is the directory uploads for slug go to in backends without folders.
+func attachmentDir(slug string) string {
+	return strings.TrimSuffix(canonicalSlug(slug), ".md")
+}
+
+func sortAttachments(list []*Attachment) []*Attachment {
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
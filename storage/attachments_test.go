@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var pngData = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestPrepareAttachment(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"diagram.png", pngData, nil},
+		{"../../Diagram.PNG", pngData, nil},
+		{"slides.pdf", []byte("%PDF-1.7\n"), nil},
+		{"icon.svg", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"/>`), nil},
+		{"fake.png", []byte("<html>not an image</html>"), ErrAttachmentType},
+		{"icon.svg", []byte("plain text"), ErrAttachmentType},
+		{"notes.txt", []byte("text"), ErrAttachmentType},
+		{".hidden.png", pngData, ErrAttachmentName},
+		{"big.png", append(append([]byte{}, pngData...), make([]byte, MaxAttachmentSize)...), ErrAttachmentTooLarge},
+	}
+	for _, c := range cases {
+		att, err := prepareAttachment(c.name, c.data)
+		if !errors.Is(err, c.err) {
+			t.Fatalf("prepareAttachment(%q) error = %v, want %v", c.name, err, c.err)
+		}
+		if err == nil && att.Path != filepath.Base(c.name) {
+			t.Fatalf("prepareAttachment(%q) path = %q", c.name, att.Path)
+		}
+	}
+}
+
+func TestCleanAttachmentPath(t *testing.T) {
+	cases := map[string]string{
+		"k8s/diagram.png":        "k8s/diagram.png",
+		"/k8s/./diagram.png":     "k8s/diagram.png",
+		"../../etc/passwd":       "etc/passwd",
+		`k8s\diagram.png`:        "k8s/diagram.png",
+		"":                       "",
+		".obsidian/logo.png":     "",
+		"notes/.trash/image.png": "",
+	}
+	for in, want := range cases {
+		got, ok := CleanAttachmentPath(in)
+		if got != want || ok != (want != "") {
+			t.Fatalf("CleanAttachmentPath(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+}
+
+func TestFSStoreAttachments(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, filepath.Join(dir, "notes", "k8s.md"), note("K8s", "type/note", "theme/kubernetes"))
+	writeNote(t, filepath.Join(dir, "img", "existing.png"), string(pngData))
+	s := newTestFSStore(t, dir)
+	ctx := writerContext()
+
+	list, _ := s.ListAttachments(ctx)
+	if len(list) != 1 || list[0].Path != "img/existing.png" || list[0].ContentType != "image/png" {
+		t.Fatalf("vault attachments not loaded: %+v", list)
+	}
+	if _, err := s.PutAttachment(context.Background(), "k8s.md", "diagram.png", pngData); err == nil {
+		t.Fatalf("expected unauthenticated upload to fail")
+	}
+	if _, err := s.PutAttachment(ctx, "nowhere.md", "diagram.png", pngData); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("upload for missing post: %v", err)
+	}
+	att, err := s.PutAttachment(ctx, "k8s.md", "diagram.png", pngData)
+	if err != nil {
+		t.Fatalf("PutAttachment: %v", err)
+	}
+	if att.Path != "notes/attachments/diagram.png" {
+		t.Fatalf("unexpected path %q", att.Path)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes", "attachments", "diagram.png")); err != nil {
+		t.Fatalf("expected file written: %v", err)
+	}
+	got, data, err := s.GetAttachment(ctx, att.Path)
+	if err != nil || string(data) != string(pngData) || got.Size != int64(len(pngData)) {
+		t.Fatalf("GetAttachment = %+v, %q, %v", got, data, err)
+	}
+	if err := s.DeleteAttachment(ctx, att.Path); err != nil {
+		t.Fatalf("DeleteAttachment: %v", err)
+	}
+	if _, _, err := s.GetAttachment(ctx, att.Path); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Fatalf("attachment still served after delete: %v", err)
+	}
+}
+
+func TestSQLiteStoreAttachments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.db")
+	s := seedSQLiteStore(t, path)
+	ctx := writerContext()
+
+	if _, err := s.PutAttachment(ctx, "nowhere.md", "diagram.png", pngData); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("upload for missing post: %v", err)
+	}
+	att, err := s.PutAttachment(ctx, "alpha.md", "diagram.png", pngData)
+	if err != nil {
+		t.Fatalf("PutAttachment: %v", err)
+	}
+	if att.Path != "alpha/diagram.png" {
+		t.Fatalf("unexpected path %q", att.Path)
+	}
+	if _, err := s.PutAttachment(ctx, "alpha.md", "diagram.png", pngData); err != nil {
+		t.Fatalf("replacing an attachment: %v", err)
+	}
+	list, err := s.ListAttachments(ctx)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListAttachments = %+v, %v", list, err)
+	}
+	got, data, err := s.GetAttachment(ctx, "alpha/diagram.png")
+	if err != nil || string(data) != string(pngData) || got.ContentType != "image/png" {
+		t.Fatalf("GetAttachment = %+v, %q, %v", got, data, err)
+	}
+	if err := s.DeleteAttachment(ctx, "alpha/diagram.png"); err != nil {
+		t.Fatalf("DeleteAttachment: %v", err)
+	}
+	if err := s.DeleteAttachment(ctx, "alpha/diagram.png"); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Fatalf("second delete: %v", err)
+	}
+}
+
+func TestVariantsDroppedWithAttachment(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, filepath.Join(dir, "k8s.md"), note("K8s", "type/note", "theme/kubernetes"))
+	stores := map[string]interface {
+		AttachmentStore
+		VariantStore
+	}{
+		"fs":     newTestFSStore(t, dir),
+		"sqlite": seedSQLiteStore(t, filepath.Join(t.TempDir(), "posts.db")),
+	}
+	slugs := map[string]string{"fs": "k8s.md", "sqlite": "alpha.md"}
+	ctx := writerContext()
+	for name, s := range stores {
+		att, err := s.PutAttachment(ctx, slugs[name], "photo.png", pngData)
+		if err != nil {
+			t.Fatalf("%s: PutAttachment: %v", name, err)
+		}
+		if _, err := s.GetVariant(ctx, att.Path, 480, att.Version()); !errors.Is(err, ErrVariantNotFound) {
+			t.Fatalf("%s: expected ErrVariantNotFound, got %v", name, err)
+		}
+		if err := s.PutVariant(ctx, att.Path, 480, att.Version(), []byte("small")); err != nil {
+			t.Fatalf("%s: PutVariant: %v", name, err)
+		}
+		if data, err := s.GetVariant(ctx, att.Path, 480, att.Version()); err != nil || string(data) != "small" {
+			t.Fatalf("%s: GetVariant = %q, %v", name, data, err)
+		}
+		if _, err := s.PutAttachment(ctx, slugs[name], "photo.png", pngData); err != nil {
+			t.Fatalf("%s: replacing attachment: %v", name, err)
+		}
+		if _, err := s.GetVariant(ctx, att.Path, 480, att.Version()); !errors.Is(err, ErrVariantNotFound) {
+			t.Fatalf("%s: variant survived replacing the attachment: %v", name, err)
+		}
+	}
+}
+
+func TestValidateCover(t *testing.T) {
+	cases := map[string]string{
+		"":                               "",
+		"diagram.png":                    "diagram.png",
+		"![[img/diagram.png|300]]":       "img/diagram.png",
+		"[[cover.JPG]]":                  "cover.JPG",
+		"https://example.com/a.webp?v=2": "https://example.com/a.webp?v=2",
+		"slides.pdf":                     "!",
+		"[[Some Note]]":                  "!",
+	}
+	for cover, want := range cases {
+		meta := PostMeta{Name: "X", Slug: "x.md", Lead: "l", CreatedRaw: "2024-01-01", UpdatedRaw: "2024-01-02", Cover: cover}
+		err := meta.Validate()
+		if want == "!" {
+			if err == nil {
+				t.Fatalf("cover %q accepted", cover)
+			}
+			continue
+		}
+		if err != nil || meta.Cover != want {
+			t.Fatalf("cover %q: got %q, %v; want %q", cover, meta.Cover, err, want)
+		}
+	}
+}
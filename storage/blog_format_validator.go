@@ -1,7 +1,6 @@
 package storage
 
 import (
-	"errors"
 	"regexp"
 	"strings"
 	"time"
@@ -10,8 +9,16 @@ import (
 // slugPattern now includes the .md extension (required) per new spec.
 var slugPattern = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*\.md$`)
 
-// Validate checks required fields; empty tags are allowed.
+// Validate checks required fields; empty tags are allowed. It fails with the ValidationErrors
+// of Check.
 func (p *PostMeta) Validate() error {
+	return p.Check().err()
+}
+
+// Check validates every field and returns all issues found. Like Validate it derives a missing
+// Name from the slug, parses Created, Updated and Published and normalizes Cover.
+func (p *PostMeta) Check() ValidationErrors {
+	var issues ValidationErrors
 	// Derive display Name from slug if missing.
 	if strings.TrimSpace(p.Name) == "" && strings.TrimSpace(p.Slug) != "" {
 		base := strings.TrimSuffix(p.Slug, ".md")
@@ -22,51 +29,75 @@ func (p *PostMeta) Validate() error {
 			}
 		}
 		p.Name = strings.Join(parts, " ")
+		issues.add(SeverityWarning, "name", "name missing; derived from the file name: "+p.Name)
 	}
 	if strings.TrimSpace(p.Name) == "" {
-		return errors.New("name is required")
+		issues.add(SeverityError, "name", "name is required")
 	}
 	if !slugPattern.MatchString(p.Slug) {
-		return errors.New("slug must be lowercase kebab-case and end with .md")
+		issues.add(SeverityError, "slug", "slug must be lowercase kebab-case and end with .md")
 	}
 	// Lead is now the required textual summary (description field removed from PostMeta).
 	if strings.TrimSpace(p.Lead) == "" {
-		return errors.New("lead is required")
+		issues.add(SeverityError, "lead", "lead is required")
 	}
 	// Tags optional; no validation beyond presence type.
 
 	// Created date required (strict YYYY-MM-DD)
 	createdTs := parseDate(p.CreatedRaw)
 	if createdTs.IsZero() {
-		return errors.New("created date required (YYYY-MM-DD)")
+		issues.add(SeverityError, "created", "created date required (YYYY-MM-DD)")
 	}
 	p.Created = createdTs
 
 	// Parse Updated from raw flexible string
 	upd := parseTimestamp(p.UpdatedRaw)
-	if upd.IsZero() {
-		return errors.New("updated timestamp required")
-	}
-	if upd.After(time.Now().Add(24 * time.Hour)) {
-		return errors.New("updated timestamp cannot be in the far future")
-	}
-	if createdTs.After(upd.Add(2 * time.Hour)) { // allow small skew
-		return errors.New("created timestamp after updated timestamp")
+	switch {
+	case upd.IsZero():
+		issues.add(SeverityError, "updated", "updated timestamp required")
+	case upd.After(time.Now().Add(24 * time.Hour)):
+		issues.add(SeverityError, "updated", "updated timestamp cannot be in the far future")
+	case !createdTs.IsZero() && createdTs.After(upd.Add(2*time.Hour)): // allow small skew
+		issues.add(SeverityError, "created", "created timestamp after updated timestamp")
 	}
 	p.Updated = upd
 
 	// Parse Published from raw flexible value; treat blank as false.
-	rawPub := strings.ToLower(strings.TrimSpace(p.PublishedRaw))
-	switch rawPub {
+	published, ok := parsePublished(p.PublishedRaw)
+	if !ok {
+		// If the original YAML provided a quoted value that isn't a recognized boolean keyword, surface an error.
+		issues.add(SeverityError, "published", "invalid published value")
+	}
+	p.Published = published
+
+	// Cover optional; normalized from ![[image.png]] to image.png.
+	if p.Cover = CoverTarget(p.Cover); p.Cover != "" {
+		name, _, _ := strings.Cut(p.Cover, "?")
+		if ct, ok := AttachmentContentType(name); !ok || !strings.HasPrefix(ct, "image/") {
+			issues.add(SeverityError, "cover", "cover must name a png, jpg, gif, webp or svg image")
+		}
+	}
+	return issues
+}
+
+// CoverTarget strips wikilink syntax and a |label from a cover value.
+func CoverTarget(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if strings.HasSuffix(raw, "]]") && (strings.HasPrefix(raw, "[[") || strings.HasPrefix(raw, "![[")) {
+		raw = ParseWikiLink(strings.TrimSuffix(strings.TrimLeft(raw, "!["), "]]")).Target
+	}
+	return raw
+}
+
+// parsePublished reads a published value; ok is false for values that are not boolean keywords.
+func parsePublished(raw string) (published, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
 	case "", "false", "no", "0", "off":
-		p.Published = false
+		return false, true
 	case "true", "yes", "1", "on":
-		p.Published = true
-	default:
-		// If the original YAML provided a quoted value that isn't a recognized boolean keyword, surface an error.
-		return errors.New("invalid published value")
+		return true, true
 	}
-	return nil
+	return false, false
 }
 
 // parseTimestamp tries several layouts to parse a string into time.Time
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// PostChange describes a post that was added, modified or removed by the backend, including
+// changes that did not go through this process (bucket edits, vault edits, other replicas).
+type PostChange struct {
+	Slug    string
+	Deleted bool
+}
+
+// ChangeNotifier is implemented by backends that can report post changes. Derived caches such as
+// the search index subscribe so they stay current without polling.
+type ChangeNotifier interface {
+	Subscribe(fn func(PostChange))
+}
+
+// Syncer is implemented by backends that mirror a remote source into a local cache and can be
+// asked to reconcile on demand.
+type Syncer interface {
+	Sync(ctx context.Context) (SyncResult, error)
+}
+
+// SyncResult summarizes one reconciliation pass.
+type SyncResult struct {
+	Added    int           `json:"added"`
+	Updated  int           `json:"updated"`
+	Removed  int           `json:"removed"`
+	Failed   int           `json:"failed"`
+	Duration time.Duration `json:"duration"`
+}
+
+// changeFeed fans out PostChange events to subscribers. Backends embed it and publish after
+// releasing their own locks so subscribers may call back into the store.
+type changeFeed struct {
+	subMu sync.RWMutex
+	subs  []func(PostChange)
+}
+
+// Subscribe registers fn to be called for every subsequent change.
+func (f *changeFeed) Subscribe(fn func(PostChange)) {
+	f.subMu.Lock()
+	defer f.subMu.Unlock()
+	f.subs = append(f.subs, fn)
+}
+
+func (f *changeFeed) publish(changes ...PostChange) {
+	if len(changes) == 0 {
+		return
+	}
+	f.subMu.RLock()
+	subs := f.subs
+	f.subMu.RUnlock()
+	for _, fn := range subs {
+		for _, c := range changes {
+			fn(c)
+		}
+	}
+}
@@ -66,7 +66,7 @@ func NewEmbedStore(postDir, publicDir string, assets embed.FS) (*EmbedStore, err
 			default:
 				postMeta.Published = false // ignore invalid
 			}
-			posts[postMeta.Slug] = Post{Meta: postMeta, Content: content}
+			posts[postMeta.Slug] = Post{Meta: postMeta, Content: content, Version: ContentVersion(f)}
 		}
 	}
 
@@ -110,6 +110,7 @@ func (s *EmbedStore) GetPost(id string, ctx context.Context) (*Post, error) {
 	p := &Post{
 		Meta:    orig.Meta,
 		Content: copyContent,
+		Version: orig.Version,
 	}
 	return p, nil
 }
@@ -123,13 +124,14 @@ func (s *EmbedStore) GetPosts(ctx context.Context) (map[string]*Post, error) {
 		result[id] = &Post{
 			Meta:    orig.Meta,
 			Content: copyContent,
+			Version: orig.Version,
 		}
 	}
 	return result, nil
 }
 
 func (s *EmbedStore) GetAbout() []byte {
-	f, err := s.assets.ReadFile("assets/content/about/about.md")
+	f, err := s.assets.ReadFile("content/about/about.md")
 	if err != nil {
 		return []byte{}
 	}
@@ -147,8 +149,18 @@ func (s *EmbedStore) GetAssets() http.Handler {
 
 // CreatePost implements the Storage interface but is not supported for the embedded store.
 // originalFilename is ignored. Returns an error to signal this backend is read-only.
-func (s *EmbedStore) CreatePost(_ []byte, _ string, _ context.Context) error {
-	return errors.New("create post not supported for embed store (read-only)")
+func (s *EmbedStore) CreatePost(_ []byte, _ string, _ WriteOptions, _ context.Context) (string, error) {
+	return "", errors.New("create post not supported for embed store (read-only)")
+}
+
+// UpdatePost is not supported for the embedded store.
+func (s *EmbedStore) UpdatePost(_ string, _ []byte, _ WriteOptions, _ context.Context) (string, error) {
+	return "", errors.New("update post not supported for embed store (read-only)")
+}
+
+// DeletePost is not supported for the embedded store.
+func (s *EmbedStore) DeletePost(_ string, _ context.Context) error {
+	return errors.New("delete post not supported for embed store (read-only)")
 }
 
 // GetPostsByTags implements tag filtering for embedded posts.
@@ -199,7 +211,7 @@ func (s *EmbedStore) GetPostsByTags(ctx context.Context, tags []string, matchAll
 		// copy content to comply with interface expectations (avoid mutation)
 		copyContent := make([]byte, len(p.Content))
 		copy(copyContent, p.Content)
-		cp := &Post{Meta: p.Meta, Content: copyContent}
+		cp := &Post{Meta: p.Meta, Content: copyContent, Version: p.Version}
 		result = append(result, cp)
 	}
 	return SortPostsByDate(result), nil
@@ -227,7 +239,7 @@ func (s *EmbedStore) GetRelatedPosts(ctx context.Context, slug string, limit int
 		p := s.posts[otherSlug]
 		copyContent := make([]byte, len(p.Content))
 		copy(copyContent, p.Content)
-		related = append(related, &Post{Meta: p.Meta, Content: copyContent})
+		related = append(related, &Post{Meta: p.Meta, Content: copyContent, Version: p.Version})
 	}
 	sort.Slice(related, func(i, j int) bool {
 		ci := sharedCounts[related[i].Meta.Slug]
@@ -246,6 +258,18 @@ func (s *EmbedStore) GetRelatedPosts(ctx context.Context, slug string, limit int
 	return related, nil
 }
 
+// GetPostLinks resolves wikilinks across the embedded posts.
+func (s *EmbedStore) GetPostLinks(ctx context.Context, slug string) (*PostLinks, error) {
+	if _, ok := s.posts[slug]; !ok {
+		return nil, errors.New("post not found")
+	}
+	posts := make(map[string]*Post, len(s.posts))
+	for k, p := range s.posts {
+		posts[k] = &p
+	}
+	return buildLinkIndex(posts).links(slug), nil
+}
+
 // hasTag checks membership
 func hasTag(tags []string, t string) bool {
 	for _, x := range tags {
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// fsAttachmentDir is the folder next to a note that uploads for it go to, matching Obsidian's
+// "in subfolder under current folder" attachment setting.
+const fsAttachmentDir = "attachments"
+
+// fsVariantDir caches generated image variants. Being hidden, it is neither loaded nor watched.
+const fsVariantDir = ".variants"
+
+// PutAttachment writes filename into the attachments folder next to the note backing slug.
+func (s *FSStore) PutAttachment(ctx context.Context, slug, filename string, data []byte) (*Attachment, error) {
+	if _, err := uploaderFromContext(ctx); err != nil {
+		return nil, err
+	}
+	slug = canonicalSlug(slug)
+	s.mu.RLock()
+	note, exists := s.slugPaths[slug]
+	s.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	att, err := prepareAttachment(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Join(folderOf(note), fsAttachmentDir)
+	att.Path = dir + "/" + att.Path
+	if err := os.MkdirAll(filepath.Join(s.root, filepath.FromSlash(dir)), 0o755); err != nil {
+		return nil, fmt.Errorf("create attachment folder: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.root, filepath.FromSlash(att.Path)), data); err != nil {
+		return nil, fmt.Errorf("write attachment: %w", err)
+	}
+	s.mu.Lock()
+	s.attachments[att.Path] = att
+	s.mu.Unlock()
+	s.dropVariants(att.Path)
+	s.logger.Info("attachment stored", slog.String("path", att.Path), slog.Int64("size", att.Size))
+	return att, nil
+}
+
+// GetAttachment reads an attachment file from the tree.
+func (s *FSStore) GetAttachment(ctx context.Context, path string) (*Attachment, []byte, error) {
+	s.mu.RLock()
+	att, ok := s.attachments[path]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrAttachmentNotFound, path)
+	}
+	data, err := os.ReadFile(filepath.Join(s.root, filepath.FromSlash(path)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrAttachmentNotFound, path)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return att, data, nil
+}
+
+// ListAttachments returns every image and PDF found in the tree.
+func (s *FSStore) ListAttachments(ctx context.Context) ([]*Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]*Attachment, 0, len(s.attachments))
+	for _, att := range s.attachments {
+		list = append(list, att)
+	}
+	return sortAttachments(list), nil
+}
+
+// DeleteAttachment removes an attachment file from the tree.
+func (s *FSStore) DeleteAttachment(ctx context.Context, path string) error {
+	if _, err := uploaderFromContext(ctx); err != nil {
+		return err
+	}
+	s.mu.RLock()
+	_, exists := s.attachments[path]
+	s.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrAttachmentNotFound, path)
+	}
+	if err := os.Remove(filepath.Join(s.root, filepath.FromSlash(path))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete attachment: %w", err)
+	}
+	s.removePath(path)
+	s.dropVariants(path)
+	s.logger.Info("attachment deleted", slog.String("path", path))
+	return nil
+}
+
+// loadAttachment records the attachment file at path. Files that vanished are skipped.
+func (s *FSStore) loadAttachment(file string) {
+	rel, err := filepath.Rel(s.root, file)
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		return
+	}
+	ct, _ := AttachmentContentType(rel)
+	s.mu.Lock()
+	s.attachments[rel] = &Attachment{Path: rel, ContentType: ct, Size: info.Size(), Modified: info.ModTime().UTC()}
+	s.mu.Unlock()
+}
+
+// GetVariant reads a cached image variant from the hidden variant folder.
+func (s *FSStore) GetVariant(ctx context.Context, path string, width int, version string) ([]byte, error) {
+	data, err := os.ReadFile(s.variantFile(path, width, version))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrVariantNotFound, path)
+	}
+	return data, err
+}
+
+// PutVariant writes an image variant to the hidden variant folder.
+func (s *FSStore) PutVariant(ctx context.Context, path string, width int, version string, data []byte) error {
+	file := s.variantFile(path, width, version)
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("create variant folder: %w", err)
+	}
+	return writeFileAtomic(file, data)
+}
+
+func (s *FSStore) variantFile(path string, width int, version string) string {
+	return filepath.Join(s.root, fsVariantDir, filepath.FromSlash(variantName(path, width, version)))
+}
+
+// dropVariants removes the cached variants of the attachment at path.
+func (s *FSStore) dropVariants(path string) {
+	if err := os.RemoveAll(filepath.Join(s.root, fsVariantDir, filepath.FromSlash(path))); err != nil {
+		s.logger.Warn("dropping image variants failed", slog.String("path", path), slog.Any("err", err))
+	}
+}
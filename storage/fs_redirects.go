@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// fsRedirectFile is the hidden file below the content root that keeps the redirect table.
+const fsRedirectFile = ".redirects.json"
+
+// fsRenameWindow bounds how long a note renamed in the vault is remembered while waiting for
+// the event that reports its new name.
+const fsRenameWindow = time.Minute
+
+// movedNote is a note the watcher saw renamed away, keyed by its Version until it shows up
+// under another name.
+type movedNote struct {
+	slug string
+	at   time.Time
+}
+
+// loadRedirects reads the redirect table; a missing file is an empty table.
+func (s *FSStore) loadRedirects() error {
+	data, err := os.ReadFile(filepath.Join(s.root, fsRedirectFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		s.redirects = redirectTable{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading redirects: %w", err)
+	}
+	table, err := decodeRedirects(data)
+	if err != nil {
+		return err
+	}
+	s.redirects = table
+	return nil
+}
+
+// ResolveAlias resolves name against the slugs and aliases of the cached posts.
+func (s *FSStore) ResolveAlias(ctx context.Context, name string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	slug, ok := s.links.resolveName(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrPostNotFound, name)
+	}
+	return slug, nil
+}
+
+// AliasConflicts lists the aliases claimed more than once among the cached posts.
+func (s *FSStore) AliasConflicts(ctx context.Context) ([]AliasConflict, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.links.aliasConflicts(), nil
+}
+
+// ListRedirects returns the redirect table.
+func (s *FSStore) ListRedirects(ctx context.Context) ([]Redirect, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.redirects.list(), nil
+}
+
+// LookupRedirect returns the redirect from path.
+func (s *FSStore) LookupRedirect(ctx context.Context, path string) (*Redirect, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.redirects.lookup(path)
+}
+
+// PutRedirect adds the manual redirect from -> to to the table.
+func (s *FSStore) PutRedirect(ctx context.Context, from, to string) (*Redirect, error) {
+	r, err := newRedirect(ctx, from, to, RedirectManual)
+	if err != nil {
+		return nil, err
+	}
+	table, err := s.updateRedirects(func(t redirectTable) error {
+		_, err := t.put(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	stored := table[r.From]
+	return &stored, nil
+}
+
+// DeleteRedirect removes the redirect from path.
+func (s *FSStore) DeleteRedirect(ctx context.Context, from string) error {
+	if _, err := uploaderFromContext(ctx); err != nil {
+		return err
+	}
+	_, err := s.updateRedirects(func(t redirectTable) error {
+		r, err := t.lookup(from)
+		if err != nil {
+			return err
+		}
+		delete(t, r.From)
+		return nil
+	})
+	return err
+}
+
+// RenamePost renames the note file backing slug within its folder and records the redirect.
+// The cache is updated immediately; the watcher events that follow find nothing to do.
+func (s *FSStore) RenamePost(ctx context.Context, slug, newSlug string, opts WriteOptions) (string, error) {
+	slug, newSlug = canonicalSlug(slug), SanitizeFilename(newSlug)
+	r, err := newRedirect(ctx, PostPath(slug), PostPath(newSlug), RedirectRename)
+	if err != nil {
+		return "", err
+	}
+	if slug == newSlug {
+		return "", fmt.Errorf("%w: %s already has that slug", ErrInvalidRedirect, slug)
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.mu.RLock()
+	rel, exists := s.slugPaths[slug]
+	_, taken := s.slugPaths[newSlug]
+	var conflict error
+	if exists {
+		post := s.postCache[slug]
+		conflict = checkAliases(s.postCache, post, renamedPost(post, newSlug))
+	}
+	s.mu.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	raw, err := os.ReadFile(filepath.Join(s.root, rel))
+	if err != nil {
+		return "", fmt.Errorf("read post: %w", err)
+	}
+	version := ContentVersion(raw)
+	if err := checkWrite(slug, version, false, opts); err != nil {
+		return "", err
+	}
+	switch {
+	case taken:
+		return "", fmt.Errorf("%w: %s", ErrPostExists, newSlug)
+	case conflict != nil:
+		return "", conflict
+	}
+	newRel := path.Join(path.Dir(rel), newSlug)
+	if _, err := os.Stat(filepath.Join(s.root, newRel)); err == nil {
+		return "", fmt.Errorf("%w: %s", ErrPostExists, newRel)
+	}
+	if err := os.Rename(filepath.Join(s.root, rel), filepath.Join(s.root, newRel)); err != nil {
+		return "", fmt.Errorf("rename post: %w", err)
+	}
+	s.removePath(rel)
+	s.loadFile(filepath.Join(s.root, newRel))
+	if _, err := s.updateRedirects(func(t redirectTable) error {
+		_, err := t.rename(r)
+		return err
+	}); err != nil {
+		s.logger.Error("recording rename redirect failed", slog.String("from", slug), slog.String("to", newSlug), slog.Any("err", err))
+	}
+	s.logger.Info("post renamed", slog.String("from", slug), slog.String("to", newSlug), slog.String("path", newRel))
+	return version, nil
+}
+
+// updateRedirects applies fn to a copy of the table, writes it to the redirect file and then
+// serves it. Updates are serialized; the table is unchanged if fn or the write fails.
+func (s *FSStore) updateRedirects(fn func(redirectTable) error) (redirectTable, error) {
+	s.redirectMu.Lock()
+	defer s.redirectMu.Unlock()
+	s.mu.RLock()
+	next := s.redirects.clone()
+	s.mu.RUnlock()
+	if err := fn(next); err != nil {
+		return nil, err
+	}
+	data, err := next.encode()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(filepath.Join(s.root, fsRedirectFile), data); err != nil {
+		return nil, fmt.Errorf("write redirects: %w", err)
+	}
+	s.mu.Lock()
+	s.redirects = next
+	s.mu.Unlock()
+	return next, nil
+}
+
+// rememberMovedLocked notes the post at rel, which the vault reported renamed, so that loading
+// it under a new name records a redirect. Entries outlive fsRenameWindow only until the next
+// rename. Caller must hold write lock.
+func (s *FSStore) rememberMovedLocked(rel string) {
+	now := time.Now()
+	for version, m := range s.moved {
+		if now.Sub(m.at) > fsRenameWindow {
+			delete(s.moved, version)
+		}
+	}
+	if post, ok := s.postCache[s.pathSlugs[rel]]; ok {
+		s.moved[post.Version] = movedNote{slug: post.Meta.Slug, at: now}
+	}
+}
+
+// movedFromLocked returns the slug a post just loaded under a new slug was renamed from in the
+// vault, matching the document it had. Caller must hold write lock.
+func (s *FSStore) movedFromLocked(post *Post) (string, bool) {
+	m, ok := s.moved[post.Version]
+	if !ok || m.slug == post.Meta.Slug || time.Since(m.at) > fsRenameWindow {
+		return "", false
+	}
+	delete(s.moved, post.Version)
+	return m.slug, true
+}
+
+// recordVaultRename records the redirect of a note renamed in the vault itself.
+func (s *FSStore) recordVaultRename(from, to string) {
+	r := Redirect{From: PostPath(from), To: PostPath(to), Kind: RedirectRename, Created: time.Now().UTC()}
+	if _, err := s.updateRedirects(func(t redirectTable) error {
+		_, err := t.rename(r)
+		return err
+	}); err != nil {
+		s.logger.Warn("recording rename redirect failed", slog.String("from", from), slog.String("to", to), slog.Any("err", err))
+		return
+	}
+	s.logger.Info("post renamed in vault", slog.String("from", from), slog.String("to", to))
+}
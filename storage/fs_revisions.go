@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fsRevisionDir is the hidden directory below the content root that keeps the notes replaced or
+// deleted through the store: .revisions/<slug>/<id>.md, where id is the modification time of
+// the note in unix nanoseconds. Edits made in the vault itself are kept once the store replaces
+// them.
+const fsRevisionDir = ".revisions"
+
+// ListRevisions returns the note backing slug and its kept revisions, newest first.
+func (s *FSStore) ListRevisions(ctx context.Context, slug string) ([]Revision, error) {
+	slug = canonicalSlug(slug)
+	if !revisionSlug(slug) {
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	var revs []Revision
+	current, _, err := s.currentRevision(slug)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		revs = append(revs, *current)
+	}
+	entries, err := os.ReadDir(filepath.Join(s.root, fsRevisionDir, slug))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("listing revisions: %w", err)
+	}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".md")
+		if !ok || e.IsDir() || (current != nil && id == current.ID) {
+			continue
+		}
+		rev, _, err := s.keptRevision(slug, id)
+		if errors.Is(err, ErrRevisionNotFound) {
+			continue // not a revision file
+		}
+		if err != nil {
+			return nil, err
+		}
+		revs = append(revs, *rev)
+	}
+	if len(revs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	sortRevisions(revs)
+	return revs, nil
+}
+
+// GetRevision returns the note backing slug if id is its revision, a kept revision otherwise.
+func (s *FSStore) GetRevision(ctx context.Context, slug, id string) (*Revision, []byte, error) {
+	slug = canonicalSlug(slug)
+	if !revisionSlug(slug) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	current, data, err := s.currentRevision(slug)
+	if err != nil {
+		return nil, nil, err
+	}
+	if current != nil && current.ID == id {
+		return current, data, nil
+	}
+	return s.keptRevision(slug, id)
+}
+
+// revisionSlug reports whether slug names a single file, so that its revision directory stays
+// below fsRevisionDir.
+func revisionSlug(slug string) bool {
+	return !strings.ContainsAny(slug, `/\`) && !strings.Contains(slug, "..")
+}
+
+// currentRevision describes the note backing slug; nil if there is none.
+func (s *FSStore) currentRevision(slug string) (*Revision, []byte, error) {
+	s.mu.RLock()
+	rel, exists := s.slugPaths[slug]
+	s.mu.RUnlock()
+	if !exists {
+		return nil, nil, nil
+	}
+	data, modified, err := readNote(filepath.Join(s.root, rel))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("read post: %w", err)
+	}
+	rev := fsRevision(modified.UnixNano(), data)
+	rev.Current = true
+	return &rev, data, nil
+}
+
+// keptRevision reads the revision id from the revision directory of slug.
+func (s *FSStore) keptRevision(slug, id string) (*Revision, []byte, error) {
+	nanos, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || strconv.FormatInt(nanos, 10) != id {
+		return nil, nil, fmt.Errorf("%w: %s@%s", ErrRevisionNotFound, slug, id)
+	}
+	data, err := os.ReadFile(filepath.Join(s.root, fsRevisionDir, slug, id+".md"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%w: %s@%s", ErrRevisionNotFound, slug, id)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("read revision: %w", err)
+	}
+	rev := fsRevision(nanos, data)
+	return &rev, data, nil
+}
+
+// keepRevision copies the note at path, about to be replaced or removed, into the revision
+// directory of slug. A note that is already kept or no longer exists is skipped. Caller must
+// hold writeMu.
+func (s *FSStore) keepRevision(slug, path string) error {
+	data, modified, err := readNote(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read post: %w", err)
+	}
+	dir := filepath.Join(s.root, fsRevisionDir, slug)
+	target := filepath.Join(dir, strconv.FormatInt(modified.UnixNano(), 10)+".md")
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("keep revision: %w", err)
+	}
+	if err := writeFileAtomic(target, data); err != nil {
+		return fmt.Errorf("keep revision: %w", err)
+	}
+	return nil
+}
+
+// readNote returns the content and modification time of the note at path.
+func readNote(path string) ([]byte, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, info.ModTime(), nil
+}
+
+func fsRevision(nanos int64, data []byte) Revision {
+	return Revision{
+		ID:      strconv.FormatInt(nanos, 10),
+		Version: ContentVersion(data),
+		Created: time.Unix(0, nanos).UTC(),
+		Size:    int64(len(data)),
+	}
+}
@@ -0,0 +1,546 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FSStore serves posts from a plain directory of markdown files (e.g. an Obsidian vault).
+// The directory tree is watched so that added, edited, renamed and deleted notes update the
+// post cache, tag index and tag graph without a restart. Images and PDFs anywhere in the tree
+// are served as attachments. Hidden files and directories (".obsidian", ".trash", editor swap
+// files) are ignored.
+type FSStore struct {
+	changeFeed
+
+	logger  *slog.Logger
+	root    string
+	watcher *fsnotify.Watcher
+
+	writeMu   sync.Mutex // serializes post writes, see writePost
+	mu        sync.RWMutex
+	tagIndex  map[string]map[string]struct{}
+	postCache map[string]*Post
+	slugPaths map[string]string // slug -> path relative to root
+	pathSlugs map[string]string // path relative to root -> slug
+	skipped   map[string]string // path -> slug of notes left out by a slug collision, see loadFile
+	links     *linkIndex
+	// attachments maps paths relative to root to the images and PDFs found in the tree.
+	attachments map[string]*Attachment
+	redirects   redirectTable
+	moved       map[string]movedNote // Version -> note renamed away in the vault, see handleEvent
+	redirectMu  sync.Mutex           // serializes redirect table writes, see updateRedirects
+
+	edgeMap      map[string]*GraphEdge
+	graphOptions TagGraphOptions
+	graphReady   bool
+}
+
+// NewFSStore loads every markdown file below root and starts watching the tree for changes.
+// Watching stops when ctx is cancelled or Close is called.
+func NewFSStore(ctx context.Context, logger *slog.Logger, root string) (*FSStore, error) {
+	if root == "" {
+		return nil, fmt.Errorf("content directory must be provided")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolving content directory: %w", err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("opening content directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("content path %s is not a directory", abs)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("creating watcher: %w", err)
+	}
+
+	store := &FSStore{
+		logger:    logger.With("component", "fsStore", "root", abs),
+		root:      abs,
+		watcher:   watcher,
+		tagIndex:  make(map[string]map[string]struct{}),
+		postCache: make(map[string]*Post),
+		slugPaths: make(map[string]string),
+		pathSlugs: make(map[string]string),
+		skipped:   make(map[string]string),
+		links:     newLinkIndex(),
+		edgeMap:   make(map[string]*GraphEdge),
+
+		attachments: make(map[string]*Attachment),
+		moved:       make(map[string]movedNote),
+	}
+	if err := store.loadRedirects(); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	if err := store.loadTree(abs); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("loading content directory: %w", err)
+	}
+	store.logger.Info("fs preload complete", slog.Int("posts_cached", len(store.postCache)), slog.Int("distinct_tags", len(store.tagIndex)))
+
+	go store.watch(ctx)
+	return store, nil
+}
+
+// Close stops watching the content directory.
+func (s *FSStore) Close() error {
+	return s.watcher.Close()
+}
+
+// GetPost returns a post by slug (including .md) from the in-memory cache.
+func (s *FSStore) GetPost(slug string, ctx context.Context) (*Post, error) {
+	if !strings.HasSuffix(slug, ".md") {
+		slug = slug + ".md"
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.postCache[slug]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	return p, nil
+}
+
+// GetPosts returns all cached posts keyed by slug.
+func (s *FSStore) GetPosts(ctx context.Context) (map[string]*Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string]*Post, len(s.postCache))
+	for slug, p := range s.postCache {
+		result[slug] = p
+	}
+	return result, nil
+}
+
+// GetPostsByTags returns posts matching ANY or ALL of the provided tags.
+// If tags slice is empty an error is returned.
+func (s *FSStore) GetPostsByTags(ctx context.Context, tags []string, matchAll bool) ([]*Post, error) {
+	if len(tags) == 0 {
+		return nil, errors.New("no tags provided")
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return postsByTagsLocked(s.tagIndex, s.postCache, normalizeTagQuery(tags), matchAll), nil
+}
+
+// GetRelatedPosts returns posts sharing at least one tag with slug, ranked like GCSStore.GetRelatedPosts.
+func (s *FSStore) GetRelatedPosts(ctx context.Context, slug string, limit int) ([]*Post, error) {
+	post, err := s.GetPost(slug, ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return relatedPostsLocked(s.tagIndex, s.postCache, post, limit), nil
+}
+
+// BuildTagGraph constructs a graph of posts connected by shared tags.
+func (s *FSStore) BuildTagGraph(ctx context.Context, opts TagGraphOptions) (*TagGraph, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if opts.MinSharedTags < 1 {
+		opts.MinSharedTags = 1
+	}
+	if s.graphReady && s.graphOptions.MinSharedTags == opts.MinSharedTags && slicesEqual(s.graphOptions.IncludeTags, opts.IncludeTags) && s.graphOptions.MaxEdges == opts.MaxEdges {
+		return buildGraphSnapshotFromEdgeMap(s.postCache, s.edgeMap, s.tagIndex, s.links.edges(), opts), nil
+	}
+	s.edgeMap = buildEdgeMapLocked(s.tagIndex, opts.IncludeTags)
+	s.graphOptions = opts
+	s.graphReady = true
+	return buildGraphSnapshotFromEdgeMap(s.postCache, s.edgeMap, s.tagIndex, s.links.edges(), opts), nil
+}
+
+// GetPostLinks returns the outgoing wikilinks of slug and its backlinks.
+func (s *FSStore) GetPostLinks(ctx context.Context, slug string) (*PostLinks, error) {
+	slug = canonicalSlug(slug)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.postCache[slug]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	return s.links.links(slug), nil
+}
+
+func (s *FSStore) GetAbout() []byte {
+	return []byte{}
+}
+
+func (s *FSStore) GetAssets() http.Handler {
+	return nil
+}
+
+// CreatePost validates the upload like GCSStore.CreatePost and writes it into the content directory.
+// An existing note with the same slug is replaced in place (keeping its sub-directory) when opts
+// allow it; new notes are written to the root. The cache is updated immediately; the watcher
+// event that follows reloads the same content.
+func (s *FSStore) CreatePost(content []byte, originalFilename string, opts WriteOptions, ctx context.Context) (string, error) {
+	uploader, err := uploaderFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	post, err := prepareUpload(content, originalFilename, uploader)
+	if err != nil {
+		return "", err
+	}
+	rel, err := s.writePost(post, content, true, opts)
+	if err != nil {
+		return "", err
+	}
+	s.storePost(post, rel)
+	s.logger.Info("post created", slog.String("slug", post.Meta.Slug), slog.String("path", rel), slog.Int("tag_count", len(post.Meta.Tags)))
+	return post.Version, nil
+}
+
+// UpdatePost rewrites the note file backing slug in place.
+func (s *FSStore) UpdatePost(slug string, content []byte, opts WriteOptions, ctx context.Context) (string, error) {
+	uploader, err := uploaderFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	slug = canonicalSlug(slug)
+	post, err := prepareUpdate(content, slug, uploader)
+	if err != nil {
+		return "", err
+	}
+	rel, err := s.writePost(post, content, false, opts)
+	if err != nil {
+		return "", err
+	}
+	s.storePost(post, rel)
+	s.logger.Info("post updated", slog.String("slug", slug), slog.String("path", rel), slog.Int("tag_count", len(post.Meta.Tags)))
+	return post.Version, nil
+}
+
+// writePost checks opts against the note currently backing post, as it is on disk, and the
+// aliases of post against the other posts, keeps the note as a revision and replaces it with
+// content. Writes are serialized so no other upload lands between check and write; edits
+// made outside the server in that window are not detected. Returns the path written.
+func (s *FSStore) writePost(post *Post, content []byte, create bool, opts WriteOptions) (string, error) {
+	slug := post.Meta.Slug
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.mu.RLock()
+	rel, exists := s.slugPaths[slug]
+	conflict := checkAliases(s.postCache, s.postCache[slug], post)
+	s.mu.RUnlock()
+	if !exists {
+		rel = slug
+	}
+	current := ""
+	raw, err := os.ReadFile(filepath.Join(s.root, rel))
+	switch {
+	case err == nil:
+		current = ContentVersion(raw)
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", fmt.Errorf("read post: %w", err)
+	}
+	if err := checkWrite(slug, current, create, opts); err != nil {
+		return "", err
+	}
+	if conflict != nil {
+		return "", conflict
+	}
+	if err := s.keepRevision(slug, filepath.Join(s.root, rel)); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(filepath.Join(s.root, rel), content); err != nil {
+		return "", fmt.Errorf("write post: %w", err)
+	}
+	return rel, nil
+}
+
+// storePost caches a post just written to rel and announces the change.
+func (s *FSStore) storePost(post *Post, rel string) {
+	s.mu.Lock()
+	s.setPostLocked(post.Meta.Slug, rel, post)
+	s.mu.Unlock()
+	s.publish(PostChange{Slug: post.Meta.Slug})
+}
+
+// DeletePost removes the note file backing slug after keeping it as a revision. The cache is
+// updated immediately; the watcher event that follows is a no-op.
+func (s *FSStore) DeletePost(slug string, ctx context.Context) error {
+	if _, err := uploaderFromContext(ctx); err != nil {
+		return err
+	}
+	slug = canonicalSlug(slug)
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.mu.RLock()
+	rel, exists := s.slugPaths[slug]
+	s.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	if err := s.keepRevision(slug, filepath.Join(s.root, rel)); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.root, rel)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete post: %w", err)
+	}
+	s.removePath(rel)
+	return nil
+}
+
+// loadTree walks dir, registers watches for every non-hidden directory and loads all markdown
+// files and attachments.
+func (s *FSStore) loadTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && isHidden(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if err := s.watcher.Add(path); err != nil {
+				return fmt.Errorf("watching %s: %w", path, err)
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, ".md") {
+			s.loadFile(path)
+		} else if _, ok := AttachmentContentType(path); ok {
+			s.loadAttachment(path)
+		}
+		return nil
+	})
+}
+
+// loadFile parses a single note and swaps it into the cache. Unreadable or unparsable files are
+// logged and skipped so a half-written note never removes the previously cached version.
+func (s *FSStore) loadFile(path string) {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		s.logger.Warn("reading note failed", slog.String("path", rel), slog.Any("err", err))
+		return
+	}
+	post, err := parsePost(raw)
+	if err != nil {
+		s.logger.Warn("parsing note failed", slog.String("path", rel), slog.Any("err", err))
+		return
+	}
+	slug := SanitizeFilename(filepath.Base(rel))
+	post.Meta.Slug = slug
+	if strings.TrimSpace(post.Meta.Name) == "" {
+		post.Meta.Name = DeriveDisplayName(slug)
+	}
+
+	s.mu.Lock()
+	owner, taken := s.slugPaths[slug]
+	if taken && owner != rel {
+		s.skipped[rel] = slug
+		s.mu.Unlock()
+		s.logger.Warn("slug collision; keeping first note", slog.String("slug", slug), slog.String("kept", owner), slog.String("skipped", rel))
+		return
+	}
+	delete(s.skipped, rel)
+	from, renamed := "", false
+	if !taken {
+		from, renamed = s.movedFromLocked(post)
+	}
+	s.setPostLocked(slug, rel, post)
+	s.mu.Unlock()
+	s.publish(PostChange{Slug: slug})
+	if renamed {
+		s.recordVaultRename(from, slug)
+	}
+}
+
+// setPostLocked replaces the cached post for slug and incrementally updates tag index and edges.
+// Caller must hold write lock.
+func (s *FSStore) setPostLocked(slug, rel string, post *Post) {
+	post.Meta.Folder = folderOf(rel)
+	if old, ok := s.postCache[slug]; ok {
+		unindexTagsLocked(s.tagIndex, slug, old.Meta.Tags)
+		if s.graphReady {
+			incrementalRemovePostFromGraphLocked(s.edgeMap, slug)
+		}
+	}
+	s.postCache[slug] = post
+	s.slugPaths[slug] = rel
+	s.pathSlugs[rel] = slug
+	s.links.set(post)
+	indexTagsLocked(s.tagIndex, slug, post.Meta.Tags)
+	if s.graphReady {
+		incrementalAddPostToGraphLocked(s.edgeMap, post, s.tagIndex, s.graphOptions.MinSharedTags, s.graphOptions.IncludeTags)
+	}
+}
+
+// removePath drops the note or attachment at rel, or everything below rel when it was a
+// directory. A note skipped for colliding with a removed slug is loaded in its place.
+func (s *FSStore) removePath(rel string) {
+	var changes []PostChange
+	var takeover []string
+	defer func() {
+		s.publish(changes...)
+		for _, path := range takeover {
+			s.loadFile(filepath.Join(s.root, filepath.FromSlash(path)))
+		}
+	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for path := range s.attachments {
+		if path == rel || strings.HasPrefix(path, rel+"/") {
+			delete(s.attachments, path)
+		}
+	}
+	for path := range s.skipped {
+		if path == rel || strings.HasPrefix(path, rel+"/") {
+			delete(s.skipped, path)
+		}
+	}
+	for path, slug := range s.pathSlugs {
+		if path != rel && !strings.HasPrefix(path, rel+"/") {
+			continue
+		}
+		if old, ok := s.postCache[slug]; ok {
+			unindexTagsLocked(s.tagIndex, slug, old.Meta.Tags)
+		}
+		if s.graphReady {
+			incrementalRemovePostFromGraphLocked(s.edgeMap, slug)
+		}
+		delete(s.postCache, slug)
+		delete(s.slugPaths, slug)
+		delete(s.pathSlugs, path)
+		s.links.remove(slug)
+		changes = append(changes, PostChange{Slug: slug, Deleted: true})
+		s.logger.Info("post removed", slog.String("slug", slug), slog.String("path", path))
+		for other, skippedSlug := range s.skipped {
+			if skippedSlug == slug {
+				takeover = append(takeover, other)
+			}
+		}
+	}
+	slices.Sort(takeover)
+}
+
+// watch applies filesystem events until ctx is cancelled or the watcher is closed.
+func (s *FSStore) watch(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			s.watcher.Close()
+			return
+		case ev, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			s.handleEvent(ev)
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			s.logger.Warn("watcher error", slog.Any("err", err))
+		}
+	}
+}
+
+func (s *FSStore) handleEvent(ev fsnotify.Event) {
+	rel, err := filepath.Rel(s.root, ev.Name)
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	for _, part := range strings.Split(rel, "/") {
+		if isHidden(part) {
+			return
+		}
+	}
+	// A rename is reported as Rename on the old path followed by Create on the new one. A note
+	// that comes back under another slug with the same document gets a redirect.
+	if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+		if ev.Has(fsnotify.Rename) && strings.HasSuffix(rel, ".md") {
+			s.mu.Lock()
+			s.rememberMovedLocked(rel)
+			s.mu.Unlock()
+		}
+		s.removePath(rel)
+		return
+	}
+	if ev.Has(fsnotify.Create) {
+		info, err := os.Stat(ev.Name)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			if err := s.loadTree(ev.Name); err != nil {
+				s.logger.Warn("loading new directory failed", slog.String("path", rel), slog.Any("err", err))
+			}
+			return
+		}
+	}
+	if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Write) {
+		return
+	}
+	if strings.HasSuffix(ev.Name, ".md") {
+		s.loadFile(ev.Name)
+	} else if _, ok := AttachmentContentType(ev.Name); ok {
+		s.loadAttachment(ev.Name)
+	}
+}
+
+// isHidden reports whether a path segment is a dotfile or dot-directory.
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// folderOf returns the vault folder of a note's slash-separated relative path, "" for the root.
+func folderOf(rel string) string {
+	if dir := path.Dir(rel); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// writeFileAtomic writes data to a hidden temporary file next to path and renames it into place,
+// so the watcher never observes a partially written note.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
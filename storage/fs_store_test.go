@@ -0,0 +1,332 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	firebaseauth "firebase.google.com/go/v4/auth"
+
+	"github.com/soockee/cybersocke.com/session"
+)
+
+// note renders a minimal valid note with the given tags.
+func note(name string, tags ...string) string {
+	return datedNote(name, "2024-01-02", tags...)
+}
+
+// datedNote is note with an explicit updated date so ordering can be asserted.
+func datedNote(name, updated string, tags ...string) string {
+	out := "---\nname: " + name + "\nlead: lead\ncreated: 2024-01-01\nupdated: " + updated + "\npublished: true\ntags:\n"
+	for _, t := range tags {
+		out += "  - " + t + "\n"
+	}
+	return out + "---\n\n# " + name + "\n"
+}
+
+func writeNote(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitFor polls cond until it holds or the deadline passes; watcher events are asynchronous.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func newTestFSStore(t *testing.T, dir string) *FSStore {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s, err := NewFSStore(ctx, slog.Default(), dir)
+	if err != nil {
+		t.Fatalf("NewFSStore: %v", err)
+	}
+	return s
+}
+
+func hasPost(s *FSStore, slug string) bool {
+	_, err := s.GetPost(slug, context.Background())
+	return err == nil
+}
+
+func TestFSStoreLoadsVault(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, filepath.Join(dir, "Alpha Note.md"), note("Alpha", "type/note", "theme/kubernetes"))
+	writeNote(t, filepath.Join(dir, "sub", "beta.md"), note("Beta", "type/note", "theme/kubernetes"))
+	writeNote(t, filepath.Join(dir, ".obsidian", "hidden.md"), note("Hidden", "type/note"))
+	writeNote(t, filepath.Join(dir, "readme.txt"), "not a note")
+
+	s := newTestFSStore(t, dir)
+	posts, err := s.GetPosts(context.Background())
+	if err != nil {
+		t.Fatalf("GetPosts: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("expected 2 posts got %d", len(posts))
+	}
+	p, err := s.GetPost("alpha-note", context.Background())
+	if err != nil {
+		t.Fatalf("GetPost: %v", err)
+	}
+	if p.Meta.Name != "Alpha" || !p.Meta.Published || p.Meta.Folder != "" {
+		t.Fatalf("unexpected meta: %+v", p.Meta)
+	}
+	if beta, err := s.GetPost("beta", context.Background()); err != nil {
+		t.Fatalf("GetPost: %v", err)
+	} else if beta.Meta.Folder != "sub" {
+		t.Fatalf("nested note folder = %q; want sub", beta.Meta.Folder)
+	}
+	byTag, err := s.GetPostsByTags(context.Background(), []string{"theme/kubernetes"}, true)
+	if err != nil || len(byTag) != 2 {
+		t.Fatalf("GetPostsByTags = %d, %v; want 2", len(byTag), err)
+	}
+	g, err := s.BuildTagGraph(context.Background(), TagGraphOptions{MinSharedTags: 1})
+	if err != nil {
+		t.Fatalf("BuildTagGraph: %v", err)
+	}
+	if len(g.Edges) != 1 || g.Edges[0].Weight != 2 {
+		t.Fatalf("expected one edge of weight 2, got %+v", g.Edges)
+	}
+}
+
+func TestFSStoreLiveReload(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, filepath.Join(dir, "alpha.md"), note("Alpha", "type/note", "theme/kubernetes"))
+	s := newTestFSStore(t, dir)
+	ctx := context.Background()
+	if _, err := s.BuildTagGraph(ctx, TagGraphOptions{MinSharedTags: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Added note joins the index and the graph.
+	writeNote(t, filepath.Join(dir, "beta.md"), note("Beta", "type/note", "theme/kubernetes"))
+	// The Create event may observe the file before its content is written; wait for the tags.
+	waitFor(t, "beta added", func() bool {
+		posts, _ := s.GetPostsByTags(ctx, []string{"theme/kubernetes"}, false)
+		return len(posts) == 2
+	})
+	g, _ := s.BuildTagGraph(ctx, TagGraphOptions{MinSharedTags: 1})
+	if len(g.Edges) != 1 {
+		t.Fatalf("expected 1 edge after add, got %d", len(g.Edges))
+	}
+
+	// Edited note drops stale tag entries and edges.
+	writeNote(t, filepath.Join(dir, "beta.md"), note("Beta", "type/note", "theme/cloud"))
+	waitFor(t, "beta retagged", func() bool {
+		posts, _ := s.GetPostsByTags(ctx, []string{"theme/cloud"}, false)
+		return len(posts) == 1
+	})
+	if posts, _ := s.GetPostsByTags(ctx, []string{"theme/kubernetes"}, false); len(posts) != 1 {
+		t.Fatalf("stale tag index entry: %d posts for theme/kubernetes", len(posts))
+	}
+	g, _ = s.BuildTagGraph(ctx, TagGraphOptions{MinSharedTags: 2})
+	if len(g.Edges) != 0 {
+		t.Fatalf("expected no edges with 2 shared tags after retag, got %+v", g.Edges)
+	}
+
+	// Renamed note moves to its new slug.
+	if err := os.Rename(filepath.Join(dir, "beta.md"), filepath.Join(dir, "gamma.md")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "rename", func() bool { return hasPost(s, "gamma.md") && !hasPost(s, "beta.md") })
+
+	// Deleted note disappears from cache and tag index, and subscribers hear about it.
+	var mu sync.Mutex
+	deleted := map[string]bool{}
+	s.Subscribe(func(c PostChange) {
+		mu.Lock()
+		defer mu.Unlock()
+		if c.Deleted {
+			deleted[c.Slug] = true
+		}
+	})
+	if err := os.Remove(filepath.Join(dir, "gamma.md")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "delete", func() bool { return !hasPost(s, "gamma.md") })
+	waitFor(t, "delete change", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return deleted["gamma.md"]
+	})
+	if posts, _ := s.GetPostsByTags(ctx, []string{"theme/cloud"}, false); len(posts) != 0 {
+		t.Fatalf("deleted post still indexed")
+	}
+
+	// Notes in newly created directories are picked up.
+	writeNote(t, filepath.Join(dir, "nested", "delta.md"), note("Delta", "type/note", "theme/kubernetes"))
+	waitFor(t, "nested add", func() bool { return hasPost(s, "delta.md") })
+}
+
+func TestFSStoreSlugCollisionTakeover(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, filepath.Join(dir, "a", "dup.md"), note("First", "type/note"))
+	writeNote(t, filepath.Join(dir, "b", "Dup.md"), note("Second", "type/note"))
+	s := newTestFSStore(t, dir)
+	name := func() string {
+		p, err := s.GetPost("dup.md", context.Background())
+		if err != nil {
+			return ""
+		}
+		return p.Meta.Name
+	}
+	if got := name(); got != "First" {
+		t.Fatalf("kept %q; want the first note", got)
+	}
+	// Once the note holding the slug is gone the skipped one takes it over.
+	if err := os.Remove(filepath.Join(dir, "a", "dup.md")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "takeover", func() bool { return name() == "Second" })
+}
+
+func TestFSStoreCreatePost(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestFSStore(t, dir)
+	if _, err := s.CreatePost([]byte(note("Upload", "type/note", "theme/kubernetes")), "My Upload.md", WriteOptions{}, context.Background()); err == nil {
+		t.Fatalf("expected unauthenticated upload to fail")
+	}
+	ctx := context.WithValue(context.Background(), session.IdTokenKey, &firebaseauth.Token{UID: "writer"})
+	if _, err := s.CreatePost([]byte(note("Upload", "type/note", "theme/kubernetes")), "My Upload.md", WriteOptions{}, ctx); err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "my-upload.md")); err != nil {
+		t.Fatalf("expected file written: %v", err)
+	}
+	p, err := s.GetPost("my-upload.md", ctx)
+	if err != nil {
+		t.Fatalf("GetPost after create: %v", err)
+	}
+	if string(p.Content) != "\n# Upload\n" {
+		t.Fatalf("expected body without frontmatter, got %q", p.Content)
+	}
+}
+
+func TestTrustedHTMLRequiresAdmin(t *testing.T) {
+	s := newTestFSStore(t, t.TempDir())
+	trusted := strings.Replace(note("Widget", "type/note", "theme/go"), "published: true", "published: true\ntrusted_html: true", 1)
+	writer := context.WithValue(context.Background(), session.IdTokenKey, &firebaseauth.Token{UID: "writer", Claims: map[string]any{"role": "writer"}})
+	if _, err := s.CreatePost([]byte(trusted), "widget.md", WriteOptions{}, writer); !errors.Is(err, ErrTrustedHTMLForbidden) {
+		t.Fatalf("expected ErrTrustedHTMLForbidden for writer, got %v", err)
+	}
+	admin := context.WithValue(context.Background(), session.IdTokenKey, &firebaseauth.Token{UID: "admin", Claims: map[string]any{"admin": true}})
+	if _, err := s.CreatePost([]byte(trusted), "widget.md", WriteOptions{}, admin); err != nil {
+		t.Fatalf("CreatePost as admin: %v", err)
+	}
+	if p, _ := s.GetPost("widget.md", admin); p == nil || !p.Meta.TrustedHTML {
+		t.Fatalf("trusted_html not kept: %+v", p)
+	}
+	if _, err := s.UpdatePost("widget.md", []byte(trusted), WriteOptions{}, writer); !errors.Is(err, ErrTrustedHTMLForbidden) {
+		t.Fatalf("expected writer update of trusted post to fail, got %v", err)
+	}
+}
+
+func TestFSStoreUpdateAndDeletePost(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, filepath.Join(dir, "nested", "alpha.md"), note("Alpha", "type/note", "theme/kubernetes"))
+	writeNote(t, filepath.Join(dir, "beta.md"), note("Beta", "type/note", "theme/kubernetes"))
+	s := newTestFSStore(t, dir)
+	ctx := writerContext()
+	if _, err := s.BuildTagGraph(ctx, TagGraphOptions{MinSharedTags: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.UpdatePost("alpha", []byte(note("Alpha v2", "type/note", "theme/finops")), WriteOptions{}, ctx); err != nil {
+		t.Fatalf("UpdatePost: %v", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "nested", "alpha.md"))
+	if err != nil || !strings.Contains(string(raw), "Alpha v2") {
+		t.Fatalf("expected note rewritten in place: %v", err)
+	}
+	k8s, _ := s.GetPostsByTags(ctx, []string{"theme/kubernetes"}, false)
+	if len(k8s) != 1 || k8s[0].Meta.Slug != "beta.md" {
+		t.Fatalf("stale tag index after update: %d posts", len(k8s))
+	}
+	g, _ := s.BuildTagGraph(ctx, TagGraphOptions{MinSharedTags: 2})
+	if len(g.Edges) != 0 {
+		t.Fatalf("stale edges after update: %+v", g.Edges)
+	}
+	if _, err := s.UpdatePost("missing.md", []byte(note("X", "type/note", "theme/x")), WriteOptions{}, ctx); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("expected ErrPostNotFound, got %v", err)
+	}
+
+	if err := s.DeletePost("beta.md", context.Background()); err == nil {
+		t.Fatalf("expected unauthenticated delete to fail")
+	}
+	if err := s.DeletePost("beta.md", ctx); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	if hasPost(s, "beta.md") {
+		t.Fatalf("deleted post still cached")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "beta.md")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected file removed: %v", err)
+	}
+	if err := s.DeletePost("beta.md", ctx); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("expected ErrPostNotFound on second delete, got %v", err)
+	}
+}
+
+func TestWritePreconditions(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, filepath.Join(dir, "alpha.md"), note("Alpha", "type/note", "theme/kubernetes"))
+	stores := map[string]Storage{
+		"fs":     newTestFSStore(t, dir),
+		"sqlite": seedSQLiteStore(t, filepath.Join(t.TempDir(), "posts.db")),
+	}
+	ctx := writerContext()
+	for name, s := range stores {
+		p, err := s.GetPost("alpha.md", ctx)
+		if err != nil || p.Version == "" {
+			t.Fatalf("%s: GetPost = %+v, %v", name, p, err)
+		}
+		v0 := p.Version
+		doc := func(n string) []byte { return []byte(note(n, "type/note", "theme/kubernetes")) }
+
+		if _, err := s.CreatePost(doc("Other"), "Alpha.md", WriteOptions{}, ctx); !errors.Is(err, ErrPostExists) {
+			t.Fatalf("%s: upload colliding after sanitizing: %v", name, err)
+		}
+		if _, err := s.CreatePost(doc("Other"), "alpha.md", WriteOptions{IfMatch: "stale"}, ctx); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("%s: upload with stale version: %v", name, err)
+		}
+		v1, err := s.UpdatePost("alpha", doc("Alpha v2"), WriteOptions{IfMatch: v0}, ctx)
+		if err != nil || v1 == v0 {
+			t.Fatalf("%s: UpdatePost = %q, %v", name, v1, err)
+		}
+		if p, _ := s.GetPost("alpha.md", ctx); p.Version != v1 || p.Meta.Name != "Alpha v2" {
+			t.Fatalf("%s: stored %q at %q, want version %q", name, p.Meta.Name, p.Version, v1)
+		}
+		if _, err := s.UpdatePost("alpha", doc("Alpha v3"), WriteOptions{IfMatch: v0}, ctx); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("%s: update against replaced version: %v", name, err)
+		}
+		if _, err := s.CreatePost(doc("Alpha v3"), "alpha.md", WriteOptions{Overwrite: true}, ctx); err != nil {
+			t.Fatalf("%s: overwrite: %v", name, err)
+		}
+		if _, err := s.CreatePost(doc("New"), "new.md", WriteOptions{IfMatch: "*"}, ctx); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("%s: If-Match * on a missing post: %v", name, err)
+		}
+		if _, err := s.UpdatePost("missing", doc("X"), WriteOptions{IfMatch: v1}, ctx); !errors.Is(err, ErrPostNotFound) {
+			t.Fatalf("%s: update of a missing post: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// gcsAttachmentPrefix holds attachments; posts/<slug> uploads go to attachments/<slug>/<file>.
+const gcsAttachmentPrefix = "attachments/"
+
+// gcsVariantPrefix holds generated image variants, variants/<attachment path>/<width>-<version>.
+const gcsVariantPrefix = "variants/"
+
+// attachmentCacheControl lets browsers and CDNs keep attachments for a day.
+const attachmentCacheControl = "public, max-age=86400"
+
+// PutAttachment writes attachments/<slug>/<filename> and records it in the attachment cache.
+func (s *GCSStore) PutAttachment(ctx context.Context, slug, filename string, data []byte) (*Attachment, error) {
+	firebaseTok, err := uploaderFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.GetPost(slug, ctx); err != nil {
+		return nil, err
+	}
+	att, err := prepareAttachment(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	att.Path = attachmentDir(slug) + "/" + att.Path
+
+	obj := s.client.Bucket(s.bucketName).Object(gcsAttachmentPrefix + att.Path).NewWriter(ctx)
+	obj.ContentType = att.ContentType
+	obj.CacheControl = attachmentCacheControl
+	obj.Metadata = map[string]string{"uploaded_by": firebaseTok.UID}
+	if _, err := obj.Write(data); err != nil {
+		obj.Close()
+		return nil, fmt.Errorf("write object: %w", err)
+	}
+	if err := obj.Close(); err != nil {
+		return nil, fmt.Errorf("close writer: %w", err)
+	}
+	att.Modified = obj.Attrs().Updated
+
+	s.mu.Lock()
+	s.setAttachmentLocked(att)
+	s.mu.Unlock()
+	s.dropVariants(ctx, att.Path)
+	s.logger.Info("attachment stored", slog.String("path", att.Path), slog.Int64("size", att.Size))
+	return att, nil
+}
+
+// GetAttachment reads the object behind a cached attachment.
+func (s *GCSStore) GetAttachment(ctx context.Context, path string) (*Attachment, []byte, error) {
+	s.mu.RLock()
+	att, ok := s.attachments[path]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrAttachmentNotFound, path)
+	}
+	data, err := s.readObject(ctx, gcsAttachmentPrefix+path, 0)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrAttachmentNotFound, path)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return att, data, nil
+}
+
+// ListAttachments returns the cached attachments without listing the bucket.
+func (s *GCSStore) ListAttachments(ctx context.Context) ([]*Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]*Attachment, 0, len(s.attachments))
+	for _, att := range s.attachments {
+		list = append(list, att)
+	}
+	return sortAttachments(list), nil
+}
+
+// DeleteAttachment deletes attachments/<path> and drops it from the cache.
+func (s *GCSStore) DeleteAttachment(ctx context.Context, path string) error {
+	if _, err := uploaderFromContext(ctx); err != nil {
+		return err
+	}
+	err := s.client.Bucket(s.bucketName).Object(gcsAttachmentPrefix + path).Delete(ctx)
+	s.mu.Lock()
+	delete(s.attachments, path)
+	s.mu.Unlock()
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("%w: %s", ErrAttachmentNotFound, path)
+	}
+	if err != nil {
+		return fmt.Errorf("delete object: %w", err)
+	}
+	s.dropVariants(ctx, path)
+	s.logger.Info("attachment deleted", slog.String("path", path))
+	return nil
+}
+
+// GetVariant reads a cached image variant object.
+func (s *GCSStore) GetVariant(ctx context.Context, path string, width int, version string) ([]byte, error) {
+	data, err := s.readObject(ctx, gcsVariantPrefix+variantName(path, width, version), 0)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrVariantNotFound, path)
+	}
+	return data, err
+}
+
+// PutVariant writes an image variant object.
+func (s *GCSStore) PutVariant(ctx context.Context, path string, width int, version string, data []byte) error {
+	obj := s.client.Bucket(s.bucketName).Object(gcsVariantPrefix + variantName(path, width, version)).NewWriter(ctx)
+	if ct, ok := AttachmentContentType(path); ok {
+		obj.ContentType = ct
+	}
+	obj.CacheControl = attachmentCacheControl
+	if _, err := obj.Write(data); err != nil {
+		obj.Close()
+		return fmt.Errorf("write object: %w", err)
+	}
+	if err := obj.Close(); err != nil {
+		return fmt.Errorf("close writer: %w", err)
+	}
+	return nil
+}
+
+// dropVariants deletes the variant objects of the attachment at path. Failures only leave
+// unused objects behind, so they are logged.
+func (s *GCSStore) dropVariants(ctx context.Context, path string) {
+	bucket := s.client.Bucket(s.bucketName)
+	it := bucket.Objects(ctx, &storage.Query{Prefix: gcsVariantPrefix + path + "/"})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return
+		}
+		if err == nil {
+			err = bucket.Object(attrs.Name).Delete(ctx)
+		}
+		if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+			s.logger.Warn("dropping image variants failed", slog.String("path", path), slog.Any("err", err))
+			return
+		}
+	}
+}
+
+// syncAttachments replaces the attachment cache with a fresh listing of attachments/. Objects
+// with unsupported extensions are left out.
+func (s *GCSStore) syncAttachments(ctx context.Context) error {
+	q := &storage.Query{Prefix: gcsAttachmentPrefix}
+	if err := q.SetAttrSelection([]string{"Name", "ContentType", "Size", "Updated"}); err != nil {
+		return err
+	}
+	listed := map[string]*Attachment{}
+	it := s.client.Bucket(s.bucketName).Objects(ctx, q)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("listing attachments: %w", err)
+		}
+		path, ok := CleanAttachmentPath(strings.TrimPrefix(attrs.Name, gcsAttachmentPrefix))
+		if !ok {
+			continue
+		}
+		ct, ok := AttachmentContentType(path)
+		if !ok {
+			continue
+		}
+		listed[path] = &Attachment{Path: path, ContentType: ct, Size: attrs.Size, Modified: attrs.Updated}
+	}
+	s.mu.Lock()
+	s.attachments = listed
+	s.mu.Unlock()
+	return nil
+}
+
+// setAttachmentLocked records att in the attachment cache. Caller must hold write lock.
+func (s *GCSStore) setAttachmentLocked(att *Attachment) {
+	if s.attachments == nil {
+		s.attachments = make(map[string]*Attachment)
+	}
+	s.attachments[att.Path] = att
+}
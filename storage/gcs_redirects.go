@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+// gcsRedirectObject is the object keeping the redirect table, next to the posts/ prefix.
+const gcsRedirectObject = "redirects.json"
+
+// gcsRedirectAttempts bounds the retries of a redirect table update that lost a race with
+// another replica.
+const gcsRedirectAttempts = 3
+
+// ResolveAlias resolves name against the slugs and aliases of the cached posts.
+func (s *GCSStore) ResolveAlias(ctx context.Context, name string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	slug, ok := s.links.resolveName(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrPostNotFound, name)
+	}
+	return slug, nil
+}
+
+// AliasConflicts lists the aliases claimed more than once among the cached posts.
+func (s *GCSStore) AliasConflicts(ctx context.Context) ([]AliasConflict, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.links.aliasConflicts(), nil
+}
+
+// ListRedirects returns the cached redirect table.
+func (s *GCSStore) ListRedirects(ctx context.Context) ([]Redirect, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.redirects.list(), nil
+}
+
+// LookupRedirect returns the redirect from path in the cached table.
+func (s *GCSStore) LookupRedirect(ctx context.Context, path string) (*Redirect, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.redirects.lookup(path)
+}
+
+// PutRedirect adds the manual redirect from -> to to the redirect object.
+func (s *GCSStore) PutRedirect(ctx context.Context, from, to string) (*Redirect, error) {
+	r, err := newRedirect(ctx, from, to, RedirectManual)
+	if err != nil {
+		return nil, err
+	}
+	table, err := s.updateRedirects(ctx, func(t redirectTable) error {
+		_, err := t.put(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	stored := table[r.From]
+	return &stored, nil
+}
+
+// DeleteRedirect removes the redirect from path.
+func (s *GCSStore) DeleteRedirect(ctx context.Context, from string) error {
+	if _, err := uploaderFromContext(ctx); err != nil {
+		return err
+	}
+	_, err := s.updateRedirects(ctx, func(t redirectTable) error {
+		r, err := t.lookup(from)
+		if err != nil {
+			return err
+		}
+		delete(t, r.From)
+		return nil
+	})
+	return err
+}
+
+// RenamePost copies posts/<slug> to posts/<newSlug> and deletes the original, both conditional
+// on the generations involved, then records the redirect. A source changed between copy and
+// delete fails the rename and the copy is removed again.
+func (s *GCSStore) RenamePost(ctx context.Context, slug, newSlug string, opts WriteOptions) (string, error) {
+	slug, newSlug = canonicalSlug(slug), SanitizeFilename(newSlug)
+	r, err := newRedirect(ctx, PostPath(slug), PostPath(newSlug), RedirectRename)
+	if err != nil {
+		return "", err
+	}
+	if slug == newSlug {
+		return "", fmt.Errorf("%w: %s already has that slug", ErrInvalidRedirect, slug)
+	}
+	s.mu.RLock()
+	post, exists := s.postCache[slug]
+	_, taken := s.postCache[newSlug]
+	var conflict error
+	if exists {
+		conflict = checkAliases(s.postCache, post, renamedPost(post, newSlug))
+	}
+	s.mu.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	bucket := s.client.Bucket(s.bucketName)
+	src := bucket.Object("posts/" + slug)
+	attrs, err := src.Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	if err != nil {
+		return "", fmt.Errorf("stat object: %w", err)
+	}
+	if err := checkWrite(slug, strconv.FormatInt(attrs.Generation, 10), false, opts); err != nil {
+		return "", err
+	}
+	switch {
+	case taken:
+		return "", fmt.Errorf("%w: %s", ErrPostExists, newSlug)
+	case conflict != nil:
+		return "", conflict
+	}
+
+	dst := bucket.Object("posts/" + newSlug)
+	copier := dst.If(storage.Conditions{DoesNotExist: true}).CopierFrom(src.Generation(attrs.Generation))
+	copier.ContentType = attrs.ContentType
+	copier.Metadata = attrs.Metadata
+	copied, err := copier.Run(ctx)
+	if err != nil {
+		var gerr *googleapi.Error
+		if errors.As(err, &gerr) && gerr.Code == http.StatusPreconditionFailed {
+			return "", fmt.Errorf("%w: %s", ErrPostExists, newSlug)
+		}
+		return "", fmt.Errorf("copy object: %w", err)
+	}
+	if err := src.If(storage.Conditions{GenerationMatch: attrs.Generation}).Delete(ctx); err != nil {
+		if derr := dst.If(storage.Conditions{GenerationMatch: copied.Generation}).Delete(ctx); derr != nil {
+			s.logger.Error("removing copy of failed rename", slog.String("slug", newSlug), slog.Any("err", derr))
+		}
+		var gerr *googleapi.Error
+		if errors.As(err, &gerr) && gerr.Code == http.StatusPreconditionFailed {
+			return "", fmt.Errorf("%w: %s changed while renaming", ErrVersionMismatch, slug)
+		}
+		return "", fmt.Errorf("delete object: %w", err)
+	}
+
+	renamed := renamedPost(post, newSlug)
+	s.mu.Lock()
+	s.removePostLocked(slug)
+	s.setPostLocked(renamed, copied.Generation)
+	s.mu.Unlock()
+	s.publish(PostChange{Slug: slug, Deleted: true}, PostChange{Slug: newSlug})
+	if _, err := s.updateRedirects(ctx, func(t redirectTable) error {
+		_, err := t.rename(r)
+		return err
+	}); err != nil {
+		s.logger.Error("recording rename redirect failed", slog.String("from", slug), slog.String("to", newSlug), slog.Any("err", err))
+	}
+	s.logger.Info("post renamed", slog.String("from", slug), slog.String("to", newSlug))
+	return renamed.Version, nil
+}
+
+// syncRedirects reloads the redirect object if its generation changed. A missing object is an
+// empty table.
+func (s *GCSStore) syncRedirects(ctx context.Context) error {
+	handle := s.client.Bucket(s.bucketName).Object(gcsRedirectObject)
+	attrs, err := handle.Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		s.mu.Lock()
+		s.redirects, s.redirectsGen = redirectTable{}, 0
+		s.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat redirects: %w", err)
+	}
+	s.mu.RLock()
+	current := s.redirectsGen
+	s.mu.RUnlock()
+	if attrs.Generation == current {
+		return nil
+	}
+	data, err := s.readObject(ctx, gcsRedirectObject, attrs.Generation)
+	if err != nil {
+		return err
+	}
+	table, err := decodeRedirects(data)
+	if err != nil {
+		return err
+	}
+	s.setRedirects(table, attrs.Generation)
+	return nil
+}
+
+// setRedirects serves table, read from or written as generation gen, unless a newer
+// generation is served already.
+func (s *GCSStore) setRedirects(table redirectTable, gen int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if gen > s.redirectsGen {
+		s.redirects, s.redirectsGen = table, gen
+	}
+}
+
+// updateRedirects applies fn to a copy of the current table and writes it back on condition
+// that the object was not replaced meanwhile; a lost race is retried on the fresh table.
+func (s *GCSStore) updateRedirects(ctx context.Context, fn func(redirectTable) error) (redirectTable, error) {
+	s.redirectMu.Lock()
+	defer s.redirectMu.Unlock()
+	handle := s.client.Bucket(s.bucketName).Object(gcsRedirectObject)
+	for attempt := 1; ; attempt++ {
+		if err := s.syncRedirects(ctx); err != nil {
+			return nil, err
+		}
+		s.mu.RLock()
+		next, gen := s.redirects.clone(), s.redirectsGen
+		s.mu.RUnlock()
+		if err := fn(next); err != nil {
+			return nil, err
+		}
+		data, err := next.encode()
+		if err != nil {
+			return nil, err
+		}
+		cond := storage.Conditions{DoesNotExist: true}
+		if gen > 0 {
+			cond = storage.Conditions{GenerationMatch: gen}
+		}
+		w := handle.If(cond).NewWriter(ctx)
+		w.ContentType = "application/json"
+		if _, err := w.Write(data); err != nil {
+			w.Close()
+			return nil, fmt.Errorf("write redirects: %w", err)
+		}
+		err = w.Close()
+		var gerr *googleapi.Error
+		if errors.As(err, &gerr) && gerr.Code == http.StatusPreconditionFailed && attempt < gcsRedirectAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("write redirects: %w", err)
+		}
+		s.setRedirects(next, w.Attrs().Generation)
+		return next, nil
+	}
+}
+
+// recordBucketRenames records redirects for posts that disappeared from the bucket while a post
+// with the same document appeared under another slug, as a rename in the bucket itself leaves
+// them. gone are the posts dropped by a sync pass, added the ones it added.
+func (s *GCSStore) recordBucketRenames(ctx context.Context, gone, added []*Post) {
+	now := time.Now().UTC()
+	var renames []Redirect
+	for _, a := range added {
+		for _, g := range gone {
+			if sameDocument(g, a) {
+				renames = append(renames, Redirect{From: PostPath(g.Meta.Slug), To: PostPath(a.Meta.Slug), Kind: RedirectRename, Created: now})
+				break
+			}
+		}
+	}
+	if len(renames) == 0 {
+		return
+	}
+	if _, err := s.updateRedirects(ctx, func(t redirectTable) error {
+		for _, r := range renames {
+			if _, err := t.rename(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		s.logger.Warn("recording rename redirects failed", slog.Int("renames", len(renames)), slog.Any("err", err))
+		return
+	}
+	for _, r := range renames {
+		s.logger.Info("post renamed in bucket", slog.String("from", r.From), slog.String("to", r.To))
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// ListRevisions lists the generations of posts/<slug>, live and noncurrent. Without object
+// versioning on the bucket only the live generation exists.
+func (s *GCSStore) ListRevisions(ctx context.Context, slug string) ([]Revision, error) {
+	slug = canonicalSlug(slug)
+	name := "posts/" + slug
+	q := &storage.Query{Prefix: name, Versions: true}
+	if err := q.SetAttrSelection([]string{"Name", "Generation", "Created", "Deleted", "Size", "Metadata"}); err != nil {
+		return nil, err
+	}
+	var revs []Revision
+	it := s.client.Bucket(s.bucketName).Objects(ctx, q)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing revisions: %w", err)
+		}
+		if attrs.Name == name {
+			revs = append(revs, gcsRevision(attrs))
+		}
+	}
+	if len(revs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	sortRevisions(revs)
+	return revs, nil
+}
+
+// GetRevision reads generation id of posts/<slug>.
+func (s *GCSStore) GetRevision(ctx context.Context, slug, id string) (*Revision, []byte, error) {
+	slug = canonicalSlug(slug)
+	gen, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || gen <= 0 {
+		return nil, nil, fmt.Errorf("%w: %s@%s", ErrRevisionNotFound, slug, id)
+	}
+	name := "posts/" + slug
+	attrs, err := s.client.Bucket(s.bucketName).Object(name).Generation(gen).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, nil, fmt.Errorf("%w: %s@%s", ErrRevisionNotFound, slug, id)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("stat revision: %w", err)
+	}
+	data, err := s.readObject(ctx, name, gen)
+	if err != nil {
+		return nil, nil, err
+	}
+	rev := gcsRevision(attrs)
+	return &rev, data, nil
+}
+
+// gcsRevision describes one object generation. The live generation is the current revision;
+// its generation is also the post's Version.
+func gcsRevision(attrs *storage.ObjectAttrs) Revision {
+	gen := strconv.FormatInt(attrs.Generation, 10)
+	return Revision{
+		ID:         gen,
+		Version:    gen,
+		Created:    attrs.Created,
+		Size:       attrs.Size,
+		UploadedBy: attrs.Metadata["uploaded_by"],
+		Current:    attrs.Deleted.IsZero(),
+	}
+}
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// StartSync reconciles the cache with the bucket every interval until ctx is cancelled.
+// interval <= 0 disables the loop; Sync can still be triggered manually.
+func (s *GCSStore) StartSync(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		s.logger.Info("gcs background sync disabled")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+					s.logger.Warn("gcs sync failed", slog.Any("err", err))
+				}
+			}
+		}
+	}()
+	s.logger.Info("gcs background sync started", slog.Duration("interval", interval))
+}
+
+// Sync lists object generations under posts/, reparses only objects whose generation changed,
+// drops posts whose objects disappeared and updates tag index and graph edges incrementally.
+// Objects that fail to read or parse keep their previously cached version. The attachment
+// listing under attachments/ is refreshed as well.
+func (s *GCSStore) Sync(ctx context.Context) (SyncResult, error) {
+	return s.sync(ctx, false)
+}
+
+type gcsObjectVersion struct {
+	name string
+	gen  int64
+}
+
+func (s *GCSStore) sync(ctx context.Context, strict bool) (SyncResult, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	start := time.Now()
+	result := SyncResult{}
+
+	if err := s.syncAttachments(ctx); err != nil {
+		return result, err
+	}
+	if err := s.syncRedirects(ctx); err != nil {
+		return result, err
+	}
+	// Posts stored after this snapshot may be missing from the listing; only posts cached before
+	// it can have been deleted from the bucket.
+	cached := s.cachedGenerations()
+	q := &storage.Query{Prefix: "posts/"}
+	if err := q.SetAttrSelection([]string{"Name", "Generation"}); err != nil {
+		return result, err
+	}
+	listed := map[string]gcsObjectVersion{}
+	it := s.client.Bucket(s.bucketName).Objects(ctx, q)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("listing objects: %w", err)
+		}
+		if !strings.HasSuffix(attrs.Name, ".md") {
+			continue
+		}
+		listed[strings.TrimPrefix(attrs.Name, "posts/")] = gcsObjectVersion{name: attrs.Name, gen: attrs.Generation}
+	}
+	return s.reconcile(ctx, cached, listed, s.readObject, strict, start)
+}
+
+// cachedGenerations copies the generations of the cached posts.
+func (s *GCSStore) cachedGenerations() map[string]int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.generations)
+}
+
+// reconcile applies a bucket listing (slug -> object version) to the cache, fetching changed
+// objects through read. cached are the generations cached before the listing was started; only
+// those posts are dropped when the listing lacks them. Split from sync so the bookkeeping is
+// testable without a bucket.
+func (s *GCSStore) reconcile(ctx context.Context, cached map[string]int64, listed map[string]gcsObjectVersion, read func(context.Context, string, int64) ([]byte, error), strict bool, start time.Time) (SyncResult, error) {
+	result := SyncResult{}
+
+	s.mu.RLock()
+	stale := map[string]gcsObjectVersion{}
+	for slug, v := range listed {
+		if gen, ok := s.generations[slug]; !ok || gen != v.gen {
+			stale[slug] = v
+		}
+	}
+	s.mu.RUnlock()
+	removed := map[string]int64{} // slug -> generation cached before the listing was taken
+	for slug, gen := range cached {
+		if _, ok := listed[slug]; !ok {
+			removed[slug] = gen
+		}
+	}
+
+	// Download outside the lock; readers keep being served from the current cache.
+	type parsed struct {
+		post *Post
+		gen  int64
+	}
+	fresh := make(map[string]parsed, len(stale))
+	for slug, v := range stale {
+		raw, err := read(ctx, v.name, v.gen)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			continue // replaced or deleted since listing; the next pass picks up the new state
+		}
+		if err == nil {
+			var post *Post
+			if post, err = parsePost(raw); err == nil {
+				post.Meta.Slug = slug
+				if strings.TrimSpace(post.Meta.Name) == "" {
+					post.Meta.Name = DeriveDisplayName(slug)
+				}
+				fresh[slug] = parsed{post: post, gen: v.gen}
+				continue
+			}
+			err = fmt.Errorf("parsing post %s: %w", v.name, err)
+		}
+		if strict {
+			return result, err
+		}
+		result.Failed++
+		s.logger.Warn("gcs sync skipped object", slog.String("object", v.name), slog.Any("err", err))
+	}
+
+	// Writes by this process may land while the pass runs. Generations only grow, so an older
+	// listing never overwrites a newer upload, and a post stored since the snapshot is kept.
+	changes := make([]PostChange, 0, len(fresh)+len(removed))
+	var added, gone []*Post // candidates for renames in the bucket
+	s.mu.Lock()
+	for slug, p := range fresh {
+		gen, ok := s.generations[slug]
+		if ok && gen >= p.gen {
+			continue
+		}
+		if ok {
+			result.Updated++
+		} else {
+			result.Added++
+			added = append(added, p.post)
+		}
+		s.setPostLocked(p.post, p.gen)
+		changes = append(changes, PostChange{Slug: slug})
+	}
+	for slug, gen := range removed {
+		if current, ok := s.generations[slug]; !ok || current != gen {
+			continue
+		}
+		gone = append(gone, s.postCache[slug])
+		s.removePostLocked(slug)
+		result.Removed++
+		changes = append(changes, PostChange{Slug: slug, Deleted: true})
+	}
+	s.mu.Unlock()
+	s.publish(changes...)
+	if len(added) > 0 && len(gone) > 0 {
+		s.recordBucketRenames(ctx, gone, added)
+	}
+
+	result.Duration = time.Since(start)
+	if result.Added+result.Updated+result.Removed+result.Failed > 0 {
+		s.logger.Info("gcs sync complete",
+			slog.Int("added", result.Added),
+			slog.Int("updated", result.Updated),
+			slog.Int("removed", result.Removed),
+			slog.Int("failed", result.Failed),
+			slog.Duration("took", result.Duration))
+	}
+	return result, nil
+}
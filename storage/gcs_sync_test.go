@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeBucket serves object contents by name and generation for GCSStore.reconcile.
+type fakeBucket map[string]string
+
+func (b fakeBucket) read(_ context.Context, name string, gen int64) ([]byte, error) {
+	raw, ok := b[fmt.Sprintf("%s#%d", name, gen)]
+	if !ok {
+		return nil, fmt.Errorf("missing %s#%d", name, gen)
+	}
+	return []byte(raw), nil
+}
+
+func TestGCSReconcile(t *testing.T) {
+	s := seedStore()
+	s.edgeMap = make(map[string]*GraphEdge)
+	for slug := range s.postCache {
+		s.generations[slug] = 1
+	}
+	var changes []PostChange
+	s.Subscribe(func(c PostChange) { changes = append(changes, c) })
+	if _, err := s.BuildTagGraph(context.Background(), TagGraphOptions{MinSharedTags: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	bucket := fakeBucket{
+		"posts/beta.md#2":    note("Beta", "type/note", "theme/finops"),
+		"posts/epsilon.md#1": note("Epsilon", "type/note", "theme/kubernetes"),
+		"posts/broken.md#1":  "---\nname: [unterminated\n---\n",
+	}
+	listed := map[string]gcsObjectVersion{
+		"alpha.md":   {name: "posts/alpha.md", gen: 1}, // unchanged: must not be fetched
+		"beta.md":    {name: "posts/beta.md", gen: 2},  // edited in the bucket
+		"gamma.md":   {name: "posts/gamma.md", gen: 1},
+		"epsilon.md": {name: "posts/epsilon.md", gen: 1}, // added by another replica
+		"broken.md":  {name: "posts/broken.md", gen: 1},
+		// delta.md deleted from the bucket
+	}
+	res, err := s.reconcile(context.Background(), s.cachedGenerations(), listed, bucket.read, false, time.Now())
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if res.Added != 1 || res.Updated != 1 || res.Removed != 1 || res.Failed != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 published changes, got %+v", changes)
+	}
+	k8s, _ := s.GetPostsByTags(context.Background(), []string{"theme/kubernetes"}, false)
+	got := map[string]bool{}
+	for _, p := range k8s {
+		got[p.Meta.Slug] = true
+	}
+	if len(got) != 2 || !got["alpha.md"] || !got["epsilon.md"] {
+		t.Fatalf("unexpected kubernetes posts after sync: %v", got)
+	}
+	g, _ := s.BuildTagGraph(context.Background(), TagGraphOptions{MinSharedTags: 1})
+	for _, e := range g.Edges {
+		if e.From == "delta.md" || e.To == "delta.md" {
+			t.Fatalf("edge to removed post: %+v", e)
+		}
+	}
+
+	// A second pass over the same listing is a no-op apart from the still broken object.
+	changes = nil
+	res, err = s.reconcile(context.Background(), s.cachedGenerations(), listed, bucket.read, false, time.Now())
+	if err != nil || res.Added+res.Updated+res.Removed != 0 || len(changes) != 0 {
+		t.Fatalf("expected idempotent pass, got %+v %v %+v", res, err, changes)
+	}
+
+	// A stale listing never overwrites a newer local upload.
+	s.mu.Lock()
+	s.setPostLocked(buildPost("beta.md", "2024-09-01", []string{"type/note"}), 5)
+	s.mu.Unlock()
+	if _, err := s.reconcile(context.Background(), s.cachedGenerations(), listed, bucket.read, false, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if s.generations["beta.md"] != 5 {
+		t.Fatalf("newer upload overwritten by stale listing")
+	}
+
+	// A post stored between the snapshot and the listing is missing from it but kept.
+	cached := s.cachedGenerations()
+	s.mu.Lock()
+	s.setPostLocked(buildPost("zeta.md", "2024-09-02", []string{"type/note"}), 1)
+	s.mu.Unlock()
+	res, err = s.reconcile(context.Background(), cached, listed, bucket.read, false, time.Now())
+	if err != nil || res.Removed != 0 {
+		t.Fatalf("reconcile after concurrent upload = %+v, %v", res, err)
+	}
+	if _, ok := s.postCache["zeta.md"]; !ok {
+		t.Fatalf("post stored during the listing was evicted")
+	}
+	// The next pass, whose snapshot includes it, drops it once the bucket lacks it.
+	if res, _ = s.reconcile(context.Background(), s.cachedGenerations(), listed, bucket.read, false, time.Now()); res.Removed != 1 {
+		t.Fatalf("expected zeta removed by the next pass, got %+v", res)
+	}
+
+	if _, err := s.reconcile(context.Background(), s.cachedGenerations(), listed, bucket.read, true, time.Now()); err == nil {
+		t.Fatalf("expected strict reconcile to fail on broken object")
+	}
+}
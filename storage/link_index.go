@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PostLinks lists the wikilinks leaving a post and the posts linking to it.
+type PostLinks struct {
+	Slug     string    `json:"slug"`
+	Outgoing []LinkRef `json:"outgoing"` // document order, one entry per distinct target
+	Incoming []string  `json:"incoming"` // slugs of posts linking here, sorted
+}
+
+// LinkRef is a single outgoing wikilink target. Slug is empty when the target does not resolve
+// to a known post or alias.
+type LinkRef struct {
+	Target string `json:"target"`
+	Slug   string `json:"slug,omitempty"`
+}
+
+// Resolve maps a raw wikilink target (as written inside [[...]]) to a post slug using the
+// resolution recorded for this post's outgoing links.
+func (l *PostLinks) Resolve(target string) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+	key := LinkKey(target)
+	for _, ref := range l.Outgoing {
+		if ref.Slug != "" && LinkKey(ref.Target) == key {
+			return ref.Slug, true
+		}
+	}
+	return "", false
+}
+
+// WikiLink is a parsed [[target#heading|label]] occurrence. Embed marks ![[...]] transclusions.
+type WikiLink struct {
+	Target  string
+	Heading string
+	Label   string
+	Embed   bool
+}
+
+var (
+	wikiLinkRe     = regexp.MustCompile(`(!?)\[\[([^\[\]\n]+)\]\]`)
+	fencedCodeRe   = regexp.MustCompile("(?ms)^\\s*(```|~~~).*?^\\s*(```|~~~)\\s*$")
+	inlineCodeRe   = regexp.MustCompile("`[^`\n]*`")
+	linkKeyTrimSet = "#^"
+)
+
+// ParseWikiLink splits the inside of [[...]] into target, heading and label.
+// Obsidian block references (#^id) are kept in Heading with their caret.
+func ParseWikiLink(inner string) WikiLink {
+	link := WikiLink{}
+	target := inner
+	if i := strings.Index(inner, "|"); i >= 0 {
+		target, link.Label = inner[:i], strings.TrimSpace(inner[i+1:])
+	}
+	if i := strings.Index(target, "#"); i >= 0 {
+		target, link.Heading = target[:i], strings.TrimSpace(target[i+1:])
+	}
+	link.Target = strings.TrimSpace(target)
+	return link
+}
+
+// ExtractWikiLinks returns every wikilink in content, skipping fenced and inline code.
+func ExtractWikiLinks(content []byte) []WikiLink {
+	src := fencedCodeRe.ReplaceAllString(string(content), "")
+	src = inlineCodeRe.ReplaceAllString(src, "")
+	matches := wikiLinkRe.FindAllStringSubmatch(src, -1)
+	out := make([]WikiLink, 0, len(matches))
+	for _, m := range matches {
+		link := ParseWikiLink(m[2])
+		link.Embed = m[1] == "!"
+		out = append(out, link)
+	}
+	return out
+}
+
+// LinkKey normalizes a wikilink target, slug or alias so that they compare equal when Obsidian
+// would resolve them to the same note: folders, case, spacing and the .md extension are ignored.
+func LinkKey(target string) string {
+	if i := strings.IndexAny(target, linkKeyTrimSet); i >= 0 {
+		target = target[:i]
+	}
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return ""
+	}
+	return SanitizeFilename(target)
+}
+
+// linkIndex records wikilink targets per post and resolves them against slugs and aliases.
+// Adding a post or alias can resolve links that were previously dangling, so resolution is
+// recomputed over all posts, but only once per batch of changes: set and remove mark the index
+// dirty and the next read resolves it. Callers guard it with the owning store's lock; reads under
+// a shared lock resolve under resolveMu.
+type linkIndex struct {
+	resolveMu sync.Mutex
+	dirty     bool // changed since the last resolve
+
+	targets  map[string][]string            // slug -> distinct link keys in document order
+	raw      map[string][]string            // slug -> raw targets aligned with targets
+	aliases  map[string][]string            // slug -> alias keys
+	names    map[string]string              // link key -> slug
+	outgoing map[string][]string            // slug -> resolved target slugs (aligned with targets, "" if dangling)
+	incoming map[string]map[string]struct{} // slug -> linking slugs
+}
+
+func newLinkIndex() *linkIndex {
+	return &linkIndex{
+		targets:  map[string][]string{},
+		raw:      map[string][]string{},
+		aliases:  map[string][]string{},
+		names:    map[string]string{},
+		outgoing: map[string][]string{},
+		incoming: map[string]map[string]struct{}{},
+	}
+}
+
+// buildLinkIndex indexes posts in one pass; used by backends without a resident cache.
+func buildLinkIndex(posts map[string]*Post) *linkIndex {
+	idx := newLinkIndex()
+	for _, p := range posts {
+		idx.record(p)
+	}
+	idx.resolve()
+	return idx
+}
+
+// set records the links and aliases of post, replacing a previous version.
+func (idx *linkIndex) set(post *Post) {
+	idx.record(post)
+	idx.dirty = true
+}
+
+// remove drops slug; links pointing at it become dangling.
+func (idx *linkIndex) remove(slug string) {
+	if _, ok := idx.targets[slug]; !ok {
+		return
+	}
+	delete(idx.targets, slug)
+	delete(idx.raw, slug)
+	delete(idx.aliases, slug)
+	idx.dirty = true
+}
+
+// ensureResolved resolves the changes recorded since the last read.
+func (idx *linkIndex) ensureResolved() {
+	idx.resolveMu.Lock()
+	defer idx.resolveMu.Unlock()
+	if idx.dirty {
+		idx.resolve()
+		idx.dirty = false
+	}
+}
+
+func (idx *linkIndex) record(post *Post) {
+	slug := post.Meta.Slug
+	seen := map[string]struct{}{}
+	keys := []string{}
+	raw := []string{}
+	for _, l := range ExtractWikiLinks(post.Content) {
+		key := LinkKey(l.Target)
+		if key == "" {
+			continue // same-note heading link
+		}
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+		raw = append(raw, l.Target)
+	}
+	idx.targets[slug] = keys
+	idx.raw[slug] = raw
+	aliases := make([]string, 0, len(post.Meta.Aliases))
+	for _, a := range post.Meta.Aliases {
+		if key := LinkKey(a); key != "" {
+			aliases = append(aliases, key)
+		}
+	}
+	idx.aliases[slug] = aliases
+}
+
+// resolve rebuilds the name table and the resolved link maps. Slugs always win over aliases;
+// an alias claimed by several posts goes to the lexicographically smallest slug.
+func (idx *linkIndex) resolve() {
+	idx.names = make(map[string]string, len(idx.targets))
+	slugs := make([]string, 0, len(idx.targets))
+	for slug := range idx.targets {
+		slugs = append(slugs, slug)
+		idx.names[LinkKey(slug)] = slug
+	}
+	sort.Strings(slugs)
+	for _, slug := range slugs {
+		for _, key := range idx.aliases[slug] {
+			if _, taken := idx.names[key]; !taken {
+				idx.names[key] = slug
+			}
+		}
+	}
+	idx.outgoing = make(map[string][]string, len(idx.targets))
+	idx.incoming = map[string]map[string]struct{}{}
+	for slug, keys := range idx.targets {
+		resolved := make([]string, len(keys))
+		for i, key := range keys {
+			target, ok := idx.names[key]
+			if !ok || target == slug {
+				continue
+			}
+			resolved[i] = target
+			set, ok := idx.incoming[target]
+			if !ok {
+				set = map[string]struct{}{}
+				idx.incoming[target] = set
+			}
+			set[slug] = struct{}{}
+		}
+		idx.outgoing[slug] = resolved
+	}
+}
+
+// links returns the outgoing and incoming links of slug.
+func (idx *linkIndex) links(slug string) *PostLinks {
+	idx.ensureResolved()
+	out := &PostLinks{Slug: slug, Outgoing: []LinkRef{}, Incoming: []string{}}
+	raw := idx.raw[slug]
+	for i, target := range idx.outgoing[slug] {
+		out.Outgoing = append(out.Outgoing, LinkRef{Target: raw[i], Slug: target})
+	}
+	for from := range idx.incoming[slug] {
+		out.Incoming = append(out.Incoming, from)
+	}
+	sort.Strings(out.Incoming)
+	return out
+}
+
+// edges returns one directed "link" edge per resolved source -> target pair.
+func (idx *linkIndex) edges() []GraphEdge {
+	idx.ensureResolved()
+	edges := []GraphEdge{}
+	for from, targets := range idx.outgoing {
+		for _, to := range targets {
+			if to == "" {
+				continue
+			}
+			edges = append(edges, GraphEdge{From: from, To: to, Weight: 1, Type: EdgeTypeLink})
+		}
+	}
+	return edges
+}
+
+// NameResolver resolves wikilink targets against posts with the index's rules (slugs before
+// aliases), unpublished posts included. It serves link checks on content that is not stored yet.
+func NameResolver(posts map[string]*Post) func(target string) (string, bool) {
+	idx := newLinkIndex()
+	for _, p := range posts {
+		idx.targets[p.Meta.Slug] = nil
+		for _, a := range p.Meta.Aliases {
+			if key := LinkKey(a); key != "" {
+				idx.aliases[p.Meta.Slug] = append(idx.aliases[p.Meta.Slug], key)
+			}
+		}
+	}
+	idx.resolve()
+	return func(target string) (string, bool) {
+		slug, ok := idx.names[LinkKey(target)]
+		return slug, ok
+	}
+}
+
+// resolveName returns the slug a wikilink target resolves to.
+func (idx *linkIndex) resolveName(name string) (string, bool) {
+	idx.ensureResolved()
+	slug, ok := idx.names[LinkKey(name)]
+	return slug, ok
+}
+
+// aliasConflicts lists the aliases claimed by several posts or shadowed by the slug of another
+// post, sorted by alias.
+func (idx *linkIndex) aliasConflicts() []AliasConflict {
+	idx.ensureResolved()
+	claims := map[string][]string{}
+	for slug, keys := range idx.aliases {
+		for _, key := range keys {
+			if key != slug {
+				claims[key] = append(claims[key], slug)
+			}
+		}
+	}
+	out := []AliasConflict{}
+	for key, slugs := range claims {
+		sort.Strings(slugs)
+		slugs = slices.Compact(slugs)
+		if _, isSlug := idx.targets[key]; len(slugs) < 2 && !isSlug {
+			continue
+		}
+		out = append(out, AliasConflict{Alias: key, Slug: idx.names[key], Claims: slugs})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Alias < out[j].Alias })
+	return out
+}
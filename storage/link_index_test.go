@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func linkedPost(slug string, aliases []string, body string) *Post {
+	p := buildPost(slug, "2024-01-01", []string{"type/note"})
+	p.Meta.Aliases = aliases
+	p.Content = []byte(body)
+	return p
+}
+
+func TestExtractWikiLinks(t *testing.T) {
+	body := "See [[Alpha]] and [[notes/Beta Note#Setup|the setup]].\n" +
+		"Embed: ![[gamma]] and self [[#Intro]].\n" +
+		"`[[not a link]]`\n```\n[[also not]]\n```\n"
+	got := ExtractWikiLinks([]byte(body))
+	want := []WikiLink{
+		{Target: "Alpha"},
+		{Target: "notes/Beta Note", Heading: "Setup", Label: "the setup"},
+		{Target: "gamma", Embed: true},
+		{Heading: "Intro"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExtractWikiLinks = %+v\nwant %+v", got, want)
+	}
+	if LinkKey("notes/Beta Note#Setup") != "beta-note.md" || LinkKey("beta-note.md") != "beta-note.md" {
+		t.Fatalf("unexpected link keys")
+	}
+}
+
+func TestLinkIndexResolution(t *testing.T) {
+	idx := newLinkIndex()
+	idx.set(linkedPost("alpha.md", nil, "[[Beta]] [[k8s]] [[Missing]] [[alpha]]"))
+	idx.set(linkedPost("beta.md", nil, "back to [[Alpha|home]]"))
+
+	links := idx.links("alpha.md")
+	want := []LinkRef{{Target: "Beta", Slug: "beta.md"}, {Target: "k8s"}, {Target: "Missing"}, {Target: "alpha"}}
+	if !reflect.DeepEqual(links.Outgoing, want) {
+		t.Fatalf("outgoing = %+v", links.Outgoing)
+	}
+	if !reflect.DeepEqual(links.Incoming, []string{"beta.md"}) {
+		t.Fatalf("incoming = %v", links.Incoming)
+	}
+
+	// Adding a post whose alias matches a dangling link resolves it.
+	idx.set(linkedPost("kubernetes-basics.md", []string{"K8s"}, ""))
+	if slug, ok := idx.links("alpha.md").Resolve("K8S"); !ok || slug != "kubernetes-basics.md" {
+		t.Fatalf("alias not resolved: %q %v", slug, ok)
+	}
+	if in := idx.links("kubernetes-basics.md").Incoming; !reflect.DeepEqual(in, []string{"alpha.md"}) {
+		t.Fatalf("alias backlink missing: %v", in)
+	}
+
+	// Removing the target turns the link dangling again and drops the backlink.
+	idx.remove("beta.md")
+	if _, ok := idx.links("alpha.md").Resolve("Beta"); ok {
+		t.Fatalf("link to removed post still resolves")
+	}
+	if len(idx.edges()) != 1 {
+		t.Fatalf("expected only the alias edge, got %+v", idx.edges())
+	}
+}
+
+func TestLinkIndexResolvesOncePerBatch(t *testing.T) {
+	idx := newLinkIndex()
+	for i := range 50 {
+		idx.set(linkedPost(fmt.Sprintf("post-%d.md", i), nil, fmt.Sprintf("[[post-%d]]", i+1)))
+	}
+	if !idx.dirty || len(idx.outgoing) != 0 {
+		t.Fatalf("bulk load resolved eagerly")
+	}
+	// The first read resolves links to posts recorded after the linking one.
+	if slug, ok := idx.links("post-0.md").Resolve("post-1"); !ok || slug != "post-1.md" || idx.dirty {
+		t.Fatalf("link not resolved on read: %q %v", slug, ok)
+	}
+}
+
+func TestGraphIncludesLinkEdges(t *testing.T) {
+	s := seedStore()
+	s.edgeMap = make(map[string]*GraphEdge)
+	s.mu.Lock()
+	for _, seeded := range s.postCache {
+		s.links.set(seeded)
+	}
+	p := buildPost("epsilon.md", "2024-05-01", []string{"type/guide", "theme/finops"})
+	p.Content = []byte("Builds on [[Alpha]].")
+	s.setPostLocked(p, 1)
+	s.mu.Unlock()
+
+	g, err := s.BuildTagGraph(context.Background(), TagGraphOptions{MinSharedTags: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var link *GraphEdge
+	for i, e := range g.Edges {
+		if e.Type == EdgeTypeLink {
+			link = &g.Edges[i]
+		} else if e.Type != EdgeTypeTag {
+			t.Fatalf("edge without type: %+v", e)
+		}
+	}
+	if link == nil || link.From != "epsilon.md" || link.To != "alpha.md" {
+		t.Fatalf("expected epsilon -> alpha link edge, got %+v", link)
+	}
+	links, err := s.GetPostLinks(context.Background(), "alpha")
+	if err != nil || !reflect.DeepEqual(links.Incoming, []string{"epsilon.md"}) {
+		t.Fatalf("backlinks = %+v, %v", links, err)
+	}
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Options selects and configures a post storage backend.
+type Options struct {
+	Backend              string // "gcs", "fs" or "sqlite"
+	ContentDir           string // fs
+	SQLitePath           string // sqlite
+	GCSBucket            string // gcs
+	GCSCredentialsBase64 string // gcs
+	GCSSyncInterval      time.Duration
+}
+
+// Open constructs the backend named by opts.Backend. For gcs a positive GCSSyncInterval starts
+// the background reconciliation loop, bound to ctx.
+func Open(ctx context.Context, logger *slog.Logger, opts Options) (Storage, error) {
+	switch opts.Backend {
+	case "fs":
+		logger.Info("Setup FS Storage...", slog.String("dir", opts.ContentDir))
+		return NewFSStore(ctx, logger, opts.ContentDir)
+	case "sqlite":
+		logger.Info("Setup SQLite Storage...", slog.String("path", opts.SQLitePath))
+		return NewSQLiteStore(ctx, logger, opts.SQLitePath)
+	case "gcs", "":
+		logger.Info("Setup GCS Storage...", slog.String("bucket", opts.GCSBucket))
+		store, err := NewGCSStore(ctx, logger, opts.GCSBucket, opts.GCSCredentialsBase64)
+		if err != nil {
+			return nil, err
+		}
+		store.StartSync(ctx, opts.GCSSyncInterval)
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (want gcs, fs or sqlite)", opts.Backend)
+	}
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Redirect sends requests for a path that serves nothing to another URL. The table is only
+// consulted once a request would answer 404, so a redirect never hides a live post.
+type Redirect struct {
+	From      string       `json:"from"` // request path, e.g. /posts/old-name.md or /2019/some-page
+	To        string       `json:"to"`   // path on this site or absolute http(s) URL
+	Kind      RedirectKind `json:"kind"`
+	Created   time.Time    `json:"created,omitzero"`
+	CreatedBy string       `json:"createdBy,omitempty"`
+}
+
+// RedirectKind tells redirects recorded by the store from those added by hand.
+type RedirectKind string
+
+const (
+	RedirectManual RedirectKind = "manual" // added by an admin
+	RedirectRename RedirectKind = "rename" // recorded when a post was renamed
+)
+
+// AliasConflict is an alias claimed by several posts, or by one post while another post has it
+// as slug. Links and /posts/{alias} resolve it to Slug only; the other claims have no effect.
+type AliasConflict struct {
+	Alias  string   `json:"alias"`  // normalized like LinkKey: the slug a post of that name would have
+	Slug   string   `json:"slug"`   // post the alias resolves to
+	Claims []string `json:"claims"` // posts listing the alias in their frontmatter, sorted
+}
+
+// RedirectStore is implemented by backends that resolve old and alternative post URLs: aliases
+// from the frontmatter of posts, redirects recorded when posts are renamed and redirects added
+// by admins. GCS keeps the table in the redirects.json object, SQLite in a redirects table and
+// the filesystem backend in the hidden .redirects.json file of the vault.
+type RedirectStore interface {
+	// ResolveAlias returns the slug of the post name resolves to like a wikilink target: an
+	// alias of the post or its slug spelled differently. Returns ErrPostNotFound otherwise.
+	ResolveAlias(ctx context.Context, name string) (string, error)
+	// AliasConflicts lists the aliases claimed more than once, sorted by alias.
+	AliasConflicts(ctx context.Context) ([]AliasConflict, error)
+	// ListRedirects returns the redirect table, sorted by From.
+	ListRedirects(ctx context.Context) ([]Redirect, error)
+	// LookupRedirect returns the redirect for a request path, or ErrRedirectNotFound.
+	LookupRedirect(ctx context.Context, path string) (*Redirect, error)
+	// PutRedirect adds or replaces the manual redirect from -> to. Redirects are kept one hop
+	// deep: a target that is redirected itself is followed and redirects to from are pointed
+	// at to. Returns ErrInvalidRedirect for unusable paths and loops.
+	PutRedirect(ctx context.Context, from, to string) (*Redirect, error)
+	// DeleteRedirect removes the redirect from path, or returns ErrRedirectNotFound.
+	DeleteRedirect(ctx context.Context, from string) error
+	// RenamePost moves post slug to newSlug, checking opts against its current version, and
+	// records a redirect from the old post URL. Revisions stay with the old slug. Returns the
+	// Version of the renamed post, ErrPostExists if newSlug is taken and ErrAliasConflict if
+	// another post claims newSlug as alias.
+	RenamePost(ctx context.Context, slug, newSlug string, opts WriteOptions) (string, error)
+}
+
+var (
+	// ErrRedirectNotFound is returned (wrapped) when no redirect starts at a path.
+	ErrRedirectNotFound = errors.New("redirect not found")
+	// ErrInvalidRedirect is returned (wrapped) for redirects with unusable paths or that would
+	// loop.
+	ErrInvalidRedirect = errors.New("invalid redirect")
+	// ErrAliasConflict is returned (wrapped) when a write claims an alias or slug another post
+	// already claims.
+	ErrAliasConflict = errors.New("alias conflict")
+)
+
+// PostPath is the URL path of post slug, the form redirects between posts are stored in.
+func PostPath(slug string) string {
+	return "/posts/" + SanitizeFilename(slug)
+}
+
+// NormalizeRedirectPath cleans a request path for the redirect table: it must be absolute,
+// without query or fragment, and is compared without trailing slash. Post paths are keyed by
+// their sanitized slug so /posts/Old-Name and /posts/old-name.md match the same redirect.
+func NormalizeRedirectPath(p string) (string, error) {
+	p = strings.TrimSpace(p)
+	switch {
+	case !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//"):
+		return "", fmt.Errorf("%w: path %q must start with a single /", ErrInvalidRedirect, p)
+	case strings.ContainsAny(p, "?#"):
+		return "", fmt.Errorf("%w: path %q must not have a query or fragment", ErrInvalidRedirect, p)
+	}
+	p = path.Clean(p)
+	if p == "/" {
+		return "", fmt.Errorf("%w: the home page cannot be redirected", ErrInvalidRedirect)
+	}
+	if slug, ok := strings.CutPrefix(p, "/posts/"); ok && !strings.Contains(slug, "/") {
+		return PostPath(slug), nil
+	}
+	return p, nil
+}
+
+// normalizeRedirectTarget accepts a path on this site, normalized like NormalizeRedirectPath
+// but keeping a query or fragment, or an absolute http(s) URL.
+func normalizeRedirectTarget(to string) (string, error) {
+	to = strings.TrimSpace(to)
+	if !strings.HasPrefix(to, "/") {
+		u, err := url.Parse(to)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("%w: target %q must be a path or an http(s) URL", ErrInvalidRedirect, to)
+		}
+		return to, nil
+	}
+	p, rest := to, ""
+	if i := strings.IndexAny(to, "?#"); i >= 0 {
+		p, rest = to[:i], to[i:]
+	}
+	if p == "/" {
+		return to, nil
+	}
+	p, err := NormalizeRedirectPath(p)
+	if err != nil {
+		return "", err
+	}
+	return p + rest, nil
+}
+
+// newRedirect builds a redirect from -> to created by the user in ctx.
+func newRedirect(ctx context.Context, from, to string, kind RedirectKind) (Redirect, error) {
+	uploader, err := uploaderFromContext(ctx)
+	if err != nil {
+		return Redirect{}, err
+	}
+	r := Redirect{Kind: kind, Created: time.Now().UTC(), CreatedBy: uploader.UID}
+	if r.From, err = NormalizeRedirectPath(from); err != nil {
+		return Redirect{}, err
+	}
+	if r.To, err = normalizeRedirectTarget(to); err != nil {
+		return Redirect{}, err
+	}
+	return r, nil
+}
+
+// redirectTable maps normalized From paths to their redirect. The table is kept flat: no
+// target is itself redirected, so every request takes a single hop.
+type redirectTable map[string]Redirect
+
+// put adds r and returns every redirect it wrote: r, with its target followed if that is
+// redirected, and the redirects that pointed at r.From, now pointing at r.To.
+func (t redirectTable) put(r Redirect) ([]Redirect, error) {
+	if next, ok := t[r.To]; ok {
+		r.To = next.To
+	}
+	if r.To == r.From {
+		return nil, fmt.Errorf("%w: %s would redirect to itself", ErrInvalidRedirect, r.From)
+	}
+	changed := []Redirect{r}
+	for from, e := range t {
+		if e.To == r.From {
+			e.To = r.To
+			t[from] = e
+			changed = append(changed, e)
+		}
+	}
+	t[r.From] = r
+	return changed, nil
+}
+
+// rename records the redirect of a post renamed from r.From to r.To. A redirect from the new
+// path is dropped first, the post lives there now.
+func (t redirectTable) rename(r Redirect) ([]Redirect, error) {
+	delete(t, r.To)
+	return t.put(r)
+}
+
+// list returns the redirects sorted by From.
+func (t redirectTable) list() []Redirect {
+	out := make([]Redirect, 0, len(t))
+	for _, r := range t {
+		out = append(out, r)
+	}
+	slices.SortFunc(out, func(a, b Redirect) int { return strings.Compare(a.From, b.From) })
+	return out
+}
+
+// lookup returns the redirect from the request path p.
+func (t redirectTable) lookup(p string) (*Redirect, error) {
+	from, err := NormalizeRedirectPath(p)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRedirectNotFound, p)
+	}
+	r, ok := t[from]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRedirectNotFound, from)
+	}
+	return &r, nil
+}
+
+// clone copies the table so a change can be prepared without touching the served one.
+func (t redirectTable) clone() redirectTable {
+	out := make(redirectTable, len(t))
+	for from, r := range t {
+		out[from] = r
+	}
+	return out
+}
+
+// decodeRedirects parses the JSON document the filesystem and GCS backends store the table in:
+// a list of redirects sorted by From.
+func decodeRedirects(data []byte) (redirectTable, error) {
+	var list []Redirect
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parsing redirects: %w", err)
+	}
+	t := make(redirectTable, len(list))
+	for _, r := range list {
+		t[r.From] = r
+	}
+	return t, nil
+}
+
+// encode renders the table as decodeRedirects reads it.
+func (t redirectTable) encode() ([]byte, error) {
+	return json.MarshalIndent(t.list(), "", "  ")
+}
+
+// checkAliases rejects a write of post, replacing previous (nil for a new post), when post claims
+// an alias or a slug that another of posts already claims as slug or alias. Claims previous
+// already made pass, so a conflict introduced outside the store (a vault or bucket edit) does
+// not block unrelated edits; AliasConflicts reports those.
+func checkAliases(posts map[string]*Post, previous, post *Post) error {
+	slug := post.Meta.Slug
+	held := map[string]bool{slug: true}
+	if previous != nil {
+		held[previous.Meta.Slug] = true
+		for _, a := range previous.Meta.Aliases {
+			held[LinkKey(a)] = true
+		}
+	}
+	claimed := map[string]string{} // link key -> another post claiming it
+	claim := func(key, other string) {
+		if owner, ok := claimed[key]; key != "" && (!ok || other < owner) {
+			claimed[key] = other
+		}
+	}
+	for other, p := range posts {
+		if other == slug || (previous != nil && other == previous.Meta.Slug) {
+			continue
+		}
+		claim(other, other)
+		for _, a := range p.Meta.Aliases {
+			claim(LinkKey(a), other)
+		}
+	}
+	if previous == nil || previous.Meta.Slug != slug {
+		if owner, ok := claimed[slug]; ok {
+			return fmt.Errorf("%w: %s is an alias of %s", ErrAliasConflict, slug, owner)
+		}
+	}
+	for _, a := range post.Meta.Aliases {
+		key := LinkKey(a)
+		if held[key] {
+			continue
+		}
+		if owner, ok := claimed[key]; ok {
+			return fmt.Errorf("%w: alias %q of %s is claimed by %s", ErrAliasConflict, a, slug, owner)
+		}
+	}
+	return nil
+}
+
+// sameDocument reports whether a and b were parsed from the same document under different
+// slugs, which is how a rename outside the store shows up.
+func sameDocument(a, b *Post) bool {
+	return len(a.Content) > 0 && bytes.Equal(a.Content, b.Content) && a.Meta.Lead == b.Meta.Lead &&
+		a.Meta.UpdatedRaw == b.Meta.UpdatedRaw && slices.Equal(a.Meta.Tags, b.Meta.Tags)
+}
+
+// renamedPost returns a copy of post stored under newSlug, for RenamePost.
+func renamedPost(post *Post, newSlug string) *Post {
+	renamed := *post
+	renamed.Meta.Slug = newSlug
+	return &renamed
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// aliasNote renders a valid note claiming aliases.
+func aliasNote(name string, aliases ...string) []byte {
+	out := note(name, "type/note", "theme/kubernetes")
+	front := "aliases:\n"
+	for _, a := range aliases {
+		front += "  - " + a + "\n"
+	}
+	return []byte("---\n" + front + out[len("---\n"):])
+}
+
+func TestNormalizeRedirectPath(t *testing.T) {
+	cases := map[string]string{
+		"/posts/Old Name":     "/posts/old-name.md",
+		"/posts/old-name.md":  "/posts/old-name.md",
+		"/blog/2019/entry/":   "/blog/2019/entry",
+		" /a/../b ":           "/b",
+		"/posts/nested/x.md/": "/posts/nested/x.md",
+	}
+	for in, want := range cases {
+		if got, err := NormalizeRedirectPath(in); err != nil || got != want {
+			t.Errorf("NormalizeRedirectPath(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "relative", "//evil.example", "/", "/a?b=1"} {
+		if _, err := NormalizeRedirectPath(bad); !errors.Is(err, ErrInvalidRedirect) {
+			t.Errorf("NormalizeRedirectPath(%q) = %v, want ErrInvalidRedirect", bad, err)
+		}
+	}
+	if _, err := normalizeRedirectTarget("javascript:alert(1)"); !errors.Is(err, ErrInvalidRedirect) {
+		t.Errorf("script target accepted: %v", err)
+	}
+	if got, _ := normalizeRedirectTarget("/posts/New#intro"); got != "/posts/new.md#intro" {
+		t.Errorf("target fragment = %q", got)
+	}
+}
+
+func TestRedirectTable(t *testing.T) {
+	table := redirectTable{}
+	if _, err := table.put(Redirect{From: "/a", To: "/b"}); err != nil {
+		t.Fatal(err)
+	}
+	// A redirect to /a is followed, one from /b's target repoints /a.
+	if _, err := table.put(Redirect{From: "/x", To: "/a"}); err != nil || table["/x"].To != "/b" {
+		t.Fatalf("target not followed: %+v, %v", table["/x"], err)
+	}
+	changed, err := table.put(Redirect{From: "/b", To: "/c"})
+	if err != nil || len(changed) != 3 || table["/a"].To != "/c" || table["/x"].To != "/c" {
+		t.Fatalf("chain not flattened: %v %+v", err, table)
+	}
+	if _, err := table.put(Redirect{From: "/c", To: "/a"}); !errors.Is(err, ErrInvalidRedirect) {
+		t.Fatalf("loop accepted: %v", err)
+	}
+	// Renaming a post back to an old name drops the redirect from that name.
+	if _, err := table.rename(Redirect{From: "/c", To: "/b"}); err != nil || table["/c"].To != "/b" {
+		t.Fatalf("rename: %v %+v", err, table)
+	}
+	if _, ok := table["/b"]; ok || table["/a"].To != "/b" {
+		t.Fatalf("rename back left %+v", table)
+	}
+
+	data, err := table.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeRedirects(data)
+	if err != nil || !reflect.DeepEqual(decoded, table) {
+		t.Fatalf("round trip = %+v, %v", decoded, err)
+	}
+}
+
+func TestAliasConflicts(t *testing.T) {
+	posts := map[string]*Post{
+		"alpha.md": linkedPost("alpha.md", []string{"K8s", "Shared"}, ""),
+		"beta.md":  linkedPost("beta.md", []string{"shared", "Gamma", "Beta"}, ""),
+		"gamma.md": linkedPost("gamma.md", nil, ""),
+	}
+	got := buildLinkIndex(posts).aliasConflicts()
+	want := []AliasConflict{
+		{Alias: "gamma.md", Slug: "gamma.md", Claims: []string{"beta.md"}},
+		{Alias: "shared.md", Slug: "alpha.md", Claims: []string{"alpha.md", "beta.md"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("aliasConflicts = %+v\nwant %+v", got, want)
+	}
+
+	// Claims the stored version already made pass; new ones are rejected.
+	if err := checkAliases(posts, posts["beta.md"], linkedPost("beta.md", []string{"Shared", "gamma"}, "")); err != nil {
+		t.Fatalf("existing claims rejected: %v", err)
+	}
+	if err := checkAliases(posts, posts["gamma.md"], linkedPost("gamma.md", []string{"k8s"}, "")); !errors.Is(err, ErrAliasConflict) {
+		t.Fatalf("alias of another post accepted: %v", err)
+	}
+	if err := checkAliases(posts, nil, linkedPost("k8s.md", nil, "")); !errors.Is(err, ErrAliasConflict) {
+		t.Fatalf("new slug claimed as alias accepted: %v", err)
+	}
+	if err := checkAliases(posts, posts["alpha.md"], renamedPost(posts["alpha.md"], "k8s.md")); err != nil {
+		t.Fatalf("rename to own alias rejected: %v", err)
+	}
+}
+
+func TestRedirectStores(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, filepath.Join(dir, "notes", "alpha.md"), note("Alpha", "type/note", "theme/kubernetes"))
+	dbPath := filepath.Join(t.TempDir(), "posts.db")
+	stores := map[string]interface {
+		Storage
+		RedirectStore
+	}{
+		"fs":     newTestFSStore(t, dir),
+		"sqlite": seedSQLiteStore(t, dbPath),
+	}
+	ctx := writerContext()
+	for name, s := range stores {
+		if _, err := s.CreatePost(aliasNote("Kube", "K8s"), "kube.md", WriteOptions{}, ctx); err != nil {
+			t.Fatalf("%s: CreatePost: %v", name, err)
+		}
+		if _, err := s.CreatePost(aliasNote("Other", "k8s"), "other.md", WriteOptions{}, ctx); !errors.Is(err, ErrAliasConflict) {
+			t.Fatalf("%s: conflicting alias accepted: %v", name, err)
+		}
+		if slug, err := s.ResolveAlias(ctx, "K8S"); err != nil || slug != "kube.md" {
+			t.Fatalf("%s: ResolveAlias = %q, %v", name, slug, err)
+		}
+		if _, err := s.ResolveAlias(ctx, "nothing"); !errors.Is(err, ErrPostNotFound) {
+			t.Fatalf("%s: unknown alias: %v", name, err)
+		}
+
+		post, _ := s.GetPost("alpha.md", ctx)
+		if _, err := s.RenamePost(ctx, "alpha", "Kube", WriteOptions{}); !errors.Is(err, ErrPostExists) {
+			t.Fatalf("%s: rename onto a post: %v", name, err)
+		}
+		if _, err := s.RenamePost(ctx, "alpha", "k8s", WriteOptions{}); !errors.Is(err, ErrAliasConflict) {
+			t.Fatalf("%s: rename onto an alias: %v", name, err)
+		}
+		if _, err := s.RenamePost(ctx, "alpha", "Alpha Renamed", WriteOptions{IfMatch: "stale"}); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("%s: rename with stale version: %v", name, err)
+		}
+		if _, err := s.RenamePost(ctx, "alpha", "Alpha Renamed", WriteOptions{IfMatch: post.Version}); err != nil {
+			t.Fatalf("%s: RenamePost: %v", name, err)
+		}
+		if _, err := s.GetPost("alpha.md", ctx); !errors.Is(err, ErrPostNotFound) {
+			t.Fatalf("%s: old slug still served: %v", name, err)
+		}
+		if p, err := s.GetPost("alpha-renamed.md", ctx); err != nil || p.Meta.Name != "Alpha" {
+			t.Fatalf("%s: renamed post = %+v, %v", name, p, err)
+		}
+		if r, err := s.LookupRedirect(ctx, "/posts/Alpha"); err != nil || r.To != "/posts/alpha-renamed.md" || r.Kind != RedirectRename {
+			t.Fatalf("%s: rename redirect = %+v, %v", name, r, err)
+		}
+
+		if _, err := s.PutRedirect(ctx, "/old/page/", "/posts/alpha"); err != nil {
+			t.Fatalf("%s: PutRedirect: %v", name, err)
+		}
+		if r, err := s.LookupRedirect(ctx, "/old/page"); err != nil || r.To != "/posts/alpha-renamed.md" || r.CreatedBy != "writer" {
+			t.Fatalf("%s: manual redirect not flattened: %+v, %v", name, r, err)
+		}
+		if _, err := s.PutRedirect(ctx, "/posts/alpha-renamed", "/posts/alpha"); !errors.Is(err, ErrInvalidRedirect) {
+			t.Fatalf("%s: loop accepted: %v", name, err)
+		}
+		if list, err := s.ListRedirects(ctx); err != nil || len(list) != 2 || list[0].From != "/old/page" {
+			t.Fatalf("%s: ListRedirects = %+v, %v", name, list, err)
+		}
+		if err := s.DeleteRedirect(ctx, "/old/page"); err != nil {
+			t.Fatalf("%s: DeleteRedirect: %v", name, err)
+		}
+		if err := s.DeleteRedirect(ctx, "/old/page"); !errors.Is(err, ErrRedirectNotFound) {
+			t.Fatalf("%s: second delete: %v", name, err)
+		}
+	}
+
+	// The filesystem backend renames within the folder and keeps the table in the vault.
+	if _, err := os.Stat(filepath.Join(dir, "notes", "alpha-renamed.md")); err != nil {
+		t.Fatalf("renamed note not in its folder: %v", err)
+	}
+	reopened := newTestFSStore(t, dir)
+	if r, err := reopened.LookupRedirect(ctx, "/posts/alpha.md"); err != nil || r.To != "/posts/alpha-renamed.md" {
+		t.Fatalf("redirects not persisted: %+v, %v", r, err)
+	}
+	if revs, err := stores["sqlite"].(*SQLiteStore).ListRevisions(ctx, "alpha-renamed.md"); err != nil || len(revs) != 1 || !revs[0].Current {
+		t.Fatalf("renamed sqlite post revisions = %+v, %v", revs, err)
+	}
+}
+
+func TestFSStoreRecordsVaultRenames(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, filepath.Join(dir, "draft.md"), note("Draft", "type/note", "theme/kubernetes"))
+	s := newTestFSStore(t, dir)
+	if err := os.Rename(filepath.Join(dir, "draft.md"), filepath.Join(dir, "Final Title.md")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "rename redirect", func() bool {
+		r, err := s.LookupRedirect(writerContext(), "/posts/draft.md")
+		return err == nil && r.To == "/posts/final-title.md"
+	})
+	if !hasPost(s, "final-title.md") || hasPost(s, "draft.md") {
+		t.Fatalf("cache not updated after rename")
+	}
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Revision is one stored document of a post. Every write keeps the document it replaces, so
+// the revisions of a post are its audit trail; they outlive the post when it is deleted.
+type Revision struct {
+	ID         string    `json:"id"`      // GCS object generation, SQLite row id, FS modification time
+	Version    string    `json:"version"` // Post.Version of the document
+	Created    time.Time `json:"created,omitzero"`
+	Size       int64     `json:"size"`
+	UploadedBy string    `json:"uploadedBy,omitempty"`
+	Current    bool      `json:"current"` // the document the post is stored as now
+}
+
+// RevisionStore is implemented by backends that keep earlier documents of posts: GCS through
+// object versioning (which must be enabled on the bucket), SQLite in a post_revisions table
+// and the filesystem backend in the hidden .revisions directory of the vault.
+type RevisionStore interface {
+	// ListRevisions returns the revisions of slug, newest first. Returns ErrPostNotFound if
+	// slug has neither a post nor revisions.
+	ListRevisions(ctx context.Context, slug string) ([]Revision, error)
+	// GetRevision returns the revision id of slug and its document.
+	GetRevision(ctx context.Context, slug, id string) (*Revision, []byte, error)
+}
+
+// ErrRevisionNotFound is returned (wrapped) when a post has no revision with the requested id.
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// sortRevisions orders revisions newest first. The ids of every backend are increasing integers.
+func sortRevisions(revs []Revision) {
+	slices.SortFunc(revs, func(a, b Revision) int {
+		x, _ := strconv.ParseInt(a.ID, 10, 64)
+		y, _ := strconv.ParseInt(b.ID, 10, 64)
+		return cmp.Compare(y, x)
+	})
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRevisions(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, filepath.Join(dir, "alpha.md"), note("Alpha", "type/note", "theme/kubernetes"))
+	stores := map[string]interface {
+		Storage
+		RevisionStore
+	}{
+		"fs":     newTestFSStore(t, dir),
+		"sqlite": seedSQLiteStore(t, filepath.Join(t.TempDir(), "posts.db")),
+	}
+	ctx := writerContext()
+	for name, s := range stores {
+		post, _ := s.GetPost("alpha.md", ctx)
+		revs, err := s.ListRevisions(ctx, "alpha")
+		if err != nil || len(revs) != 1 || !revs[0].Current || revs[0].Version != post.Version {
+			t.Fatalf("%s: initial revisions = %+v, %v", name, revs, err)
+		}
+
+		v2, err := s.UpdatePost("alpha", []byte(note("Alpha v2", "type/note", "theme/kubernetes")), WriteOptions{}, ctx)
+		if err != nil {
+			t.Fatalf("%s: UpdatePost: %v", name, err)
+		}
+		revs, _ = s.ListRevisions(ctx, "alpha.md")
+		if len(revs) != 2 || !revs[0].Current || revs[0].Version != v2 || revs[1].Current || revs[1].Version != post.Version {
+			t.Fatalf("%s: revisions after update = %+v", name, revs)
+		}
+		rev, data, err := s.GetRevision(ctx, "alpha.md", revs[1].ID)
+		if err != nil || rev.ID != revs[1].ID || !strings.Contains(string(data), "name: Alpha\n") {
+			t.Fatalf("%s: GetRevision = %+v, %q, %v", name, rev, data, err)
+		}
+
+		if err := s.DeletePost("alpha.md", ctx); err != nil {
+			t.Fatalf("%s: DeletePost: %v", name, err)
+		}
+		revs, err = s.ListRevisions(ctx, "alpha.md")
+		if err != nil || len(revs) != 2 || revs[0].Current || revs[0].Version != v2 {
+			t.Fatalf("%s: revisions after delete = %+v, %v", name, revs, err)
+		}
+
+		if _, _, err := s.GetRevision(ctx, "alpha.md", "12x"); !errors.Is(err, ErrRevisionNotFound) {
+			t.Fatalf("%s: malformed revision id: %v", name, err)
+		}
+		if _, err := s.ListRevisions(ctx, "missing.md"); !errors.Is(err, ErrPostNotFound) {
+			t.Fatalf("%s: revisions of a missing post: %v", name, err)
+		}
+	}
+
+	// Kept revisions live in a hidden directory the vault loader ignores.
+	if _, err := os.Stat(filepath.Join(dir, fsRevisionDir, "alpha.md")); err != nil {
+		t.Fatalf("fs revisions not kept: %v", err)
+	}
+	if hasPost(stores["fs"].(*FSStore), "alpha.md") {
+		t.Fatalf("deleted post still cached")
+	}
+}
+
+func TestFSRevisionsStayInRevisionDir(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "vault")
+	writeNote(t, filepath.Join(root, "alpha.md"), note("Alpha", "type/note"))
+	writeNote(t, filepath.Join(dir, "leak.md", "1.md"), "secret")
+	s := newTestFSStore(t, root)
+	for _, slug := range []string{"../../leak", `..\..\leak`, "sub/alpha"} {
+		if revs, err := s.ListRevisions(writerContext(), slug); !errors.Is(err, ErrPostNotFound) {
+			t.Fatalf("ListRevisions(%q) = %+v, %v", slug, revs, err)
+		}
+		if _, data, err := s.GetRevision(writerContext(), slug, "1"); !errors.Is(err, ErrPostNotFound) {
+			t.Fatalf("GetRevision(%q) = %q, %v", slug, data, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// PutAttachment stores filename as <post>/<filename> in the attachments table.
+func (s *SQLiteStore) PutAttachment(ctx context.Context, slug, filename string, data []byte) (*Attachment, error) {
+	firebaseTok, err := uploaderFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.GetPost(slug, ctx); err != nil {
+		return nil, err
+	}
+	att, err := prepareAttachment(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	att.Path = attachmentDir(slug) + "/" + att.Path
+	att.Modified = att.Modified.Truncate(time.Second)
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	err = sqlitex.Execute(conn, `INSERT INTO attachments (path, content_type, size, modified_at, data, uploaded_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (path) DO UPDATE SET
+			content_type = excluded.content_type,
+			size = excluded.size,
+			modified_at = excluded.modified_at,
+			data = excluded.data,
+			uploaded_by = excluded.uploaded_by`, &sqlitex.ExecOptions{
+		Args: []any{att.Path, att.ContentType, att.Size, att.Modified.Unix(), data, firebaseTok.UID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("write attachment: %w", err)
+	}
+	if err := dropVariants(conn, att.Path); err != nil {
+		return nil, err
+	}
+	s.logger.Info("attachment stored", slog.String("path", att.Path), slog.Int64("size", att.Size))
+	return att, nil
+}
+
+// GetAttachment returns the stored attachment at path.
+func (s *SQLiteStore) GetAttachment(ctx context.Context, path string) (*Attachment, []byte, error) {
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer s.pool.Put(conn)
+	var att *Attachment
+	var data []byte
+	err = sqlitex.Execute(conn, `SELECT path, content_type, size, modified_at, data FROM attachments WHERE path = ?`, &sqlitex.ExecOptions{
+		Args: []any{path},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			att = scanAttachment(stmt)
+			data = make([]byte, stmt.ColumnLen(4))
+			stmt.ColumnBytes(4, data)
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if att == nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrAttachmentNotFound, path)
+	}
+	return att, data, nil
+}
+
+// ListAttachments returns the metadata of every stored attachment.
+func (s *SQLiteStore) ListAttachments(ctx context.Context) ([]*Attachment, error) {
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	list := []*Attachment{}
+	err = sqlitex.Execute(conn, `SELECT path, content_type, size, modified_at FROM attachments ORDER BY path`, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			list = append(list, scanAttachment(stmt))
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// DeleteAttachment removes the attachment row at path.
+func (s *SQLiteStore) DeleteAttachment(ctx context.Context, path string) error {
+	if _, err := uploaderFromContext(ctx); err != nil {
+		return err
+	}
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.pool.Put(conn)
+	if err := sqlitex.Execute(conn, `DELETE FROM attachments WHERE path = ?`, &sqlitex.ExecOptions{Args: []any{path}}); err != nil {
+		return fmt.Errorf("delete attachment: %w", err)
+	}
+	if conn.Changes() == 0 {
+		return fmt.Errorf("%w: %s", ErrAttachmentNotFound, path)
+	}
+	if err := dropVariants(conn, path); err != nil {
+		return err
+	}
+	s.logger.Info("attachment deleted", slog.String("path", path))
+	return nil
+}
+
+// GetVariant returns a cached image variant.
+func (s *SQLiteStore) GetVariant(ctx context.Context, path string, width int, version string) ([]byte, error) {
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	var data []byte
+	err = sqlitex.Execute(conn, `SELECT data FROM image_variants WHERE path = ? AND width = ? AND version = ?`, &sqlitex.ExecOptions{
+		Args: []any{path, width, version},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			data = make([]byte, stmt.ColumnLen(0))
+			stmt.ColumnBytes(0, data)
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("%w: %s", ErrVariantNotFound, path)
+	}
+	return data, nil
+}
+
+// PutVariant caches an image variant.
+func (s *SQLiteStore) PutVariant(ctx context.Context, path string, width int, version string, data []byte) error {
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.pool.Put(conn)
+	err = sqlitex.Execute(conn, `INSERT OR REPLACE INTO image_variants (path, width, version, data) VALUES (?, ?, ?, ?)`, &sqlitex.ExecOptions{
+		Args: []any{path, width, version, data},
+	})
+	if err != nil {
+		return fmt.Errorf("write variant: %w", err)
+	}
+	return nil
+}
+
+// dropVariants deletes the cached variants of the attachment at path.
+func dropVariants(conn *sqlite.Conn, path string) error {
+	if err := sqlitex.Execute(conn, `DELETE FROM image_variants WHERE path = ?`, &sqlitex.ExecOptions{Args: []any{path}}); err != nil {
+		return fmt.Errorf("drop variants: %w", err)
+	}
+	return nil
+}
+
+// scanAttachment reads the path, content_type, size and modified_at columns.
+func scanAttachment(stmt *sqlite.Stmt) *Attachment {
+	return &Attachment{
+		Path:        stmt.ColumnText(0),
+		ContentType: stmt.ColumnText(1),
+		Size:        stmt.ColumnInt64(2),
+		Modified:    time.Unix(stmt.ColumnInt64(3), 0).UTC(),
+	}
+}
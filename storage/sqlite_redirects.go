@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// sqliteRedirectColumns is the projection redirect queries select, in scanRedirect order.
+const sqliteRedirectColumns = `source, target, kind, created_at, created_by`
+
+// ResolveAlias resolves name against the slugs and aliases of all stored documents.
+func (s *SQLiteStore) ResolveAlias(ctx context.Context, name string) (string, error) {
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer s.pool.Put(conn)
+	snap, err := s.linkSnapshot(conn)
+	if err != nil {
+		return "", err
+	}
+	slug, ok := snap.index.resolveName(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrPostNotFound, name)
+	}
+	return slug, nil
+}
+
+// AliasConflicts lists the aliases claimed more than once among the stored documents.
+func (s *SQLiteStore) AliasConflicts(ctx context.Context) ([]AliasConflict, error) {
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	snap, err := s.linkSnapshot(conn)
+	if err != nil {
+		return nil, err
+	}
+	return snap.index.aliasConflicts(), nil
+}
+
+// ListRedirects returns the redirects table.
+func (s *SQLiteStore) ListRedirects(ctx context.Context) ([]Redirect, error) {
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	table, err := loadRedirects(conn)
+	if err != nil {
+		return nil, err
+	}
+	return table.list(), nil
+}
+
+// LookupRedirect returns the redirect from path.
+func (s *SQLiteStore) LookupRedirect(ctx context.Context, path string) (*Redirect, error) {
+	from, err := NormalizeRedirectPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRedirectNotFound, path)
+	}
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	var found *Redirect
+	err = sqlitex.Execute(conn, `SELECT `+sqliteRedirectColumns+` FROM redirects WHERE source = ?`, &sqlitex.ExecOptions{
+		Args: []any{from},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			r := scanRedirect(stmt)
+			found = &r
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s", ErrRedirectNotFound, from)
+	}
+	return found, nil
+}
+
+// PutRedirect adds the manual redirect from -> to and repoints the redirects leading to from.
+func (s *SQLiteStore) PutRedirect(ctx context.Context, from, to string) (*Redirect, error) {
+	r, err := newRedirect(ctx, from, to, RedirectManual)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	return s.putRedirect(conn, r)
+}
+
+func (s *SQLiteStore) putRedirect(conn *sqlite.Conn, r Redirect) (stored *Redirect, err error) {
+	end, err := sqlitex.ImmediateTransaction(conn)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+	err = s.updateRedirects(conn, func(t redirectTable) ([]Redirect, error) {
+		changed, err := t.put(r)
+		if err == nil {
+			written := t[r.From]
+			stored = &written
+		}
+		return changed, err
+	})
+	return stored, err
+}
+
+// DeleteRedirect removes the redirect from path.
+func (s *SQLiteStore) DeleteRedirect(ctx context.Context, from string) error {
+	if _, err := uploaderFromContext(ctx); err != nil {
+		return err
+	}
+	source, err := NormalizeRedirectPath(from)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrRedirectNotFound, from)
+	}
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.pool.Put(conn)
+	if err := sqlitex.Execute(conn, `DELETE FROM redirects WHERE source = ?`, &sqlitex.ExecOptions{Args: []any{source}}); err != nil {
+		return fmt.Errorf("delete redirect: %w", err)
+	}
+	if conn.Changes() == 0 {
+		return fmt.Errorf("%w: %s", ErrRedirectNotFound, source)
+	}
+	return nil
+}
+
+// RenamePost moves the post row, its tags and a first revision to newSlug and records the
+// redirect, all in one transaction.
+func (s *SQLiteStore) RenamePost(ctx context.Context, slug, newSlug string, opts WriteOptions) (string, error) {
+	slug, newSlug = canonicalSlug(slug), SanitizeFilename(newSlug)
+	r, err := newRedirect(ctx, PostPath(slug), PostPath(newSlug), RedirectRename)
+	if err != nil {
+		return "", err
+	}
+	if slug == newSlug {
+		return "", fmt.Errorf("%w: %s already has that slug", ErrInvalidRedirect, slug)
+	}
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer s.pool.Put(conn)
+	version, err := s.renamePost(conn, slug, newSlug, r, opts)
+	s.invalidateLinks()
+	if err != nil {
+		return "", err
+	}
+	s.logger.Info("post renamed", slog.String("from", slug), slog.String("to", newSlug))
+	return version, nil
+}
+
+func (s *SQLiteStore) renamePost(conn *sqlite.Conn, slug, newSlug string, r Redirect, opts WriteOptions) (version string, err error) {
+	end, err := sqlitex.ImmediateTransaction(conn)
+	if err != nil {
+		return "", err
+	}
+	defer end(&err)
+	all, err := s.postsBySlug(conn)
+	if err != nil {
+		return "", err
+	}
+	var source []byte
+	uploadedBy := ""
+	err = sqlitex.Execute(conn, `SELECT source, uploaded_by FROM posts WHERE slug = ?`, &sqlitex.ExecOptions{
+		Args: []any{slug},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			source = make([]byte, stmt.ColumnLen(0))
+			stmt.ColumnBytes(0, source)
+			uploadedBy = stmt.ColumnText(1)
+			return nil
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	post, exists := all[slug]
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	version = ContentVersion(source)
+	if err = checkWrite(slug, version, false, opts); err != nil {
+		return "", err
+	}
+	if _, taken := all[newSlug]; taken {
+		return "", fmt.Errorf("%w: %s", ErrPostExists, newSlug)
+	}
+	renamed := renamedPost(post, newSlug)
+	if err = checkAliases(all, post, renamed); err != nil {
+		return "", err
+	}
+	if err = s.upsertPost(conn, renamed, source, uploadedBy); err != nil {
+		return "", err
+	}
+	if err = s.deletePost(conn, slug); err != nil {
+		return "", err
+	}
+	err = sqlitex.Execute(conn, `INSERT INTO post_revisions (slug, source, uploaded_by, created_at) VALUES (?, ?, ?, ?)`, &sqlitex.ExecOptions{
+		Args: []any{newSlug, source, r.CreatedBy, time.Now().Unix()},
+	})
+	if err != nil {
+		return "", fmt.Errorf("write revision: %w", err)
+	}
+	err = s.updateRedirects(conn, func(t redirectTable) ([]Redirect, error) {
+		return t.rename(r)
+	})
+	return version, err
+}
+
+// updateRedirects applies fn to the redirects table: fn changes the table in memory and returns
+// the redirects it wrote, which are stored along with the removal of any redirect fn dropped.
+func (s *SQLiteStore) updateRedirects(conn *sqlite.Conn, fn func(redirectTable) ([]Redirect, error)) (err error) {
+	defer sqlitex.Save(conn)(&err)
+	table, err := loadRedirects(conn)
+	if err != nil {
+		return err
+	}
+	before := table.clone()
+	changed, err := fn(table)
+	if err != nil {
+		return err
+	}
+	for from := range before {
+		if _, kept := table[from]; kept {
+			continue
+		}
+		if err = sqlitex.Execute(conn, `DELETE FROM redirects WHERE source = ?`, &sqlitex.ExecOptions{Args: []any{from}}); err != nil {
+			return fmt.Errorf("delete redirect: %w", err)
+		}
+	}
+	for _, r := range changed {
+		err = sqlitex.Execute(conn, `INSERT INTO redirects (`+sqliteRedirectColumns+`) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (source) DO UPDATE SET
+				target = excluded.target,
+				kind = excluded.kind,
+				created_at = excluded.created_at,
+				created_by = excluded.created_by`, &sqlitex.ExecOptions{
+			Args: []any{r.From, r.To, string(r.Kind), r.Created.Unix(), r.CreatedBy},
+		})
+		if err != nil {
+			return fmt.Errorf("write redirect: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadRedirects reads the whole redirects table.
+func loadRedirects(conn *sqlite.Conn) (redirectTable, error) {
+	table := redirectTable{}
+	err := sqlitex.Execute(conn, `SELECT `+sqliteRedirectColumns+` FROM redirects`, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			r := scanRedirect(stmt)
+			table[r.From] = r
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading redirects: %w", err)
+	}
+	return table, nil
+}
+
+// scanRedirect reads a row selecting sqliteRedirectColumns.
+func scanRedirect(stmt *sqlite.Stmt) Redirect {
+	r := Redirect{
+		From:      stmt.ColumnText(0),
+		To:        stmt.ColumnText(1),
+		Kind:      RedirectKind(stmt.ColumnText(2)),
+		CreatedBy: stmt.ColumnText(4),
+	}
+	if created := stmt.ColumnInt64(3); created > 0 {
+		r.Created = time.Unix(created, 0).UTC()
+	}
+	return r
+}
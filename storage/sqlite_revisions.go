@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// sqliteRevisionQuery selects the revisions of a slug in scanRevision order. The newest revision
+// of a post that still exists is the current one.
+const sqliteRevisionQuery = `SELECT r.id, r.source, r.uploaded_by, r.created_at,
+		r.id = (SELECT MAX(id) FROM post_revisions WHERE slug = r.slug) AND EXISTS (SELECT 1 FROM posts WHERE slug = r.slug)
+	FROM post_revisions r WHERE r.slug = ?`
+
+// ListRevisions returns every document written for slug, newest first.
+func (s *SQLiteStore) ListRevisions(ctx context.Context, slug string) ([]Revision, error) {
+	slug = canonicalSlug(slug)
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	var revs []Revision
+	err = sqlitex.Execute(conn, sqliteRevisionQuery+` ORDER BY r.id DESC`, &sqlitex.ExecOptions{
+		Args: []any{slug},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			rev, _ := scanRevision(stmt)
+			revs = append(revs, rev)
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	return revs, nil
+}
+
+// GetRevision returns the revision row id of slug.
+func (s *SQLiteStore) GetRevision(ctx context.Context, slug, id string) (*Revision, []byte, error) {
+	slug = canonicalSlug(slug)
+	rowID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s@%s", ErrRevisionNotFound, slug, id)
+	}
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer s.pool.Put(conn)
+	var rev *Revision
+	var data []byte
+	err = sqlitex.Execute(conn, sqliteRevisionQuery+` AND r.id = ?`, &sqlitex.ExecOptions{
+		Args: []any{slug, rowID},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			r, source := scanRevision(stmt)
+			rev, data = &r, source
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if rev == nil {
+		return nil, nil, fmt.Errorf("%w: %s@%s", ErrRevisionNotFound, slug, id)
+	}
+	return rev, data, nil
+}
+
+// scanRevision reads a row of sqliteRevisionQuery and the document it stores.
+func scanRevision(stmt *sqlite.Stmt) (Revision, []byte) {
+	source := make([]byte, stmt.ColumnLen(1))
+	stmt.ColumnBytes(1, source)
+	rev := Revision{
+		ID:         strconv.FormatInt(stmt.ColumnInt64(0), 10),
+		Version:    ContentVersion(source),
+		Size:       int64(len(source)),
+		UploadedBy: stmt.ColumnText(2),
+		Current:    stmt.ColumnBool(4),
+	}
+	if created := stmt.ColumnInt64(3); created > 0 {
+		rev.Created = time.Unix(created, 0).UTC()
+	}
+	return rev, source
+}
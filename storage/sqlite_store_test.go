@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	firebaseauth "firebase.google.com/go/v4/auth"
+
+	"github.com/soockee/cybersocke.com/session"
+)
+
+func writerContext() context.Context {
+	return context.WithValue(context.Background(), session.IdTokenKey, &firebaseauth.Token{UID: "writer"})
+}
+
+func seedSQLiteStore(t *testing.T, path string) *SQLiteStore {
+	t.Helper()
+	s, err := NewSQLiteStore(context.Background(), slog.Default(), path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	ctx := writerContext()
+	uploads := map[string][]byte{
+		"alpha.md": []byte(datedNote("Alpha", "2024-01-01", "type/note", "theme/kubernetes", "source/book")),
+		"beta.md":  []byte(datedNote("Beta", "2024-02-01", "type/note", "theme/kubernetes", "theme/cost-optimization", "source/article")),
+		"gamma.md": []byte(datedNote("Gamma", "2024-03-01", "type/note", "theme/cloud-architecture", "source/book")),
+		"delta.md": []byte(datedNote("Delta", "2024-04-01", "type/note", "theme/kubernetes", "theme/cloud-architecture", "source/paper")),
+	}
+	for name, doc := range uploads {
+		if _, err := s.CreatePost(doc, name, WriteOptions{}, ctx); err != nil {
+			t.Fatalf("CreatePost(%s): %v", name, err)
+		}
+	}
+	return s
+}
+
+func TestSQLiteStoreQueries(t *testing.T) {
+	s := seedSQLiteStore(t, filepath.Join(t.TempDir(), "posts.db"))
+	defer s.Close()
+	ctx := context.Background()
+
+	p, err := s.GetPost("delta", ctx)
+	if err != nil {
+		t.Fatalf("GetPost: %v", err)
+	}
+	if p.Meta.Name != "Delta" || !p.Meta.Published || p.Meta.Updated.IsZero() || string(p.Content) != "\n# Delta\n" {
+		t.Fatalf("unexpected post: %+v %q", p.Meta, p.Content)
+	}
+	if len(p.Meta.Tags) != 4 || p.Meta.Tags[0] != "type/note" {
+		t.Fatalf("tags not restored in order: %v", p.Meta.Tags)
+	}
+
+	any, err := s.GetPostsByTags(ctx, []string{"theme/kubernetes", "theme/cloud-architecture"}, false)
+	if err != nil || len(any) != 4 {
+		t.Fatalf("ANY query = %d, %v; want 4", len(any), err)
+	}
+	if any[0].Meta.Slug != "delta.md" {
+		t.Fatalf("expected newest first, got %s", any[0].Meta.Slug)
+	}
+	all, err := s.GetPostsByTags(ctx, []string{"theme/kubernetes", "theme/cloud-architecture"}, true)
+	if err != nil || len(all) != 1 || all[0].Meta.Slug != "delta.md" {
+		t.Fatalf("ALL query unexpected: %d %v", len(all), err)
+	}
+
+	rel, err := s.GetRelatedPosts(ctx, "beta.md", 0)
+	if err != nil {
+		t.Fatalf("GetRelatedPosts: %v", err)
+	}
+	// beta shares type/note + theme/kubernetes with alpha and delta (2 each), type/note with gamma (1).
+	if len(rel) != 3 || rel[0].Meta.Slug != "delta.md" || rel[1].Meta.Slug != "alpha.md" || rel[2].Meta.Slug != "gamma.md" {
+		t.Fatalf("unexpected related ranking: %v", slugsOf(rel))
+	}
+	if limited, _ := s.GetRelatedPosts(ctx, "beta.md", 1); len(limited) != 1 {
+		t.Fatalf("limit not applied: %d", len(limited))
+	}
+
+	g, err := s.BuildTagGraph(ctx, TagGraphOptions{MinSharedTags: 2})
+	if err != nil {
+		t.Fatalf("BuildTagGraph: %v", err)
+	}
+	if len(g.Posts) != 4 {
+		t.Fatalf("expected 4 posts got %d", len(g.Posts))
+	}
+	for _, e := range g.Edges {
+		if e.Weight < 2 || e.From >= e.To {
+			t.Fatalf("unexpected edge %+v", e)
+		}
+	}
+	// alpha-beta, alpha-delta, beta-delta share type/note + theme/kubernetes; alpha-gamma share
+	// type/note + source/book; delta-gamma share type/note + cloud-architecture. beta-gamma only share type/note.
+	if len(g.Edges) != 5 {
+		t.Fatalf("expected 5 edges with >=2 shared tags, got %+v", g.Edges)
+	}
+	filtered, _ := s.BuildTagGraph(ctx, TagGraphOptions{MinSharedTags: 1, IncludeTags: []string{"source/book"}})
+	if len(filtered.Edges) != 1 || filtered.Edges[0].From != "alpha.md" || filtered.Edges[0].To != "gamma.md" {
+		t.Fatalf("include filter not applied: %+v", filtered.Edges)
+	}
+}
+
+func TestSQLiteStorePersistsAndRetags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.db")
+	s := seedSQLiteStore(t, path)
+	// Re-upload beta without kubernetes; tag links must be replaced, not appended.
+	if _, err := s.CreatePost([]byte(datedNote("Beta", "2024-02-02", "type/note", "theme/finops")), "beta.md", WriteOptions{Overwrite: true}, writerContext()); err != nil {
+		t.Fatalf("re-upload: %v", err)
+	}
+	s.Close()
+
+	reopened, err := NewSQLiteStore(context.Background(), slog.Default(), path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	ctx := context.Background()
+	posts, err := reopened.GetPosts(ctx)
+	if err != nil || len(posts) != 4 {
+		t.Fatalf("GetPosts after reopen = %d, %v", len(posts), err)
+	}
+	k8s, _ := reopened.GetPostsByTags(ctx, []string{"theme/kubernetes"}, false)
+	if len(k8s) != 2 {
+		t.Fatalf("expected stale kubernetes link removed, got %v", slugsOf(k8s))
+	}
+	if _, err := reopened.GetPost("missing.md", ctx); err == nil {
+		t.Fatalf("expected error for missing post")
+	}
+}
+
+func slugsOf(posts []*Post) []string {
+	out := make([]string, 0, len(posts))
+	for _, p := range posts {
+		out = append(out, p.Meta.Slug)
+	}
+	return out
+}
+
+func TestSQLiteStoreUpdateAndDeletePost(t *testing.T) {
+	s := seedSQLiteStore(t, filepath.Join(t.TempDir(), "posts.db"))
+	defer s.Close()
+	ctx := writerContext()
+
+	// The slug comes from the target, not from the upload.
+	if _, err := s.UpdatePost("gamma", []byte(datedNote("Gamma v2", "2024-06-01", "type/note", "theme/kubernetes")), WriteOptions{}, ctx); err != nil {
+		t.Fatalf("UpdatePost: %v", err)
+	}
+	p, err := s.GetPost("gamma.md", ctx)
+	if err != nil || p.Meta.Name != "Gamma v2" {
+		t.Fatalf("update not visible: %v", err)
+	}
+	book, _ := s.GetPostsByTags(ctx, []string{"source/book"}, false)
+	if len(book) != 1 {
+		t.Fatalf("expected gamma dropped from source/book, got %v", slugsOf(book))
+	}
+	if _, err := s.UpdatePost("missing", []byte(datedNote("X", "2024-01-01", "type/note", "theme/x")), WriteOptions{}, ctx); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("expected ErrPostNotFound, got %v", err)
+	}
+
+	if err := s.DeletePost("delta.md", ctx); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	if _, err := s.GetPost("delta.md", ctx); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("expected deleted post gone, got %v", err)
+	}
+	// delta was the only post tagged source/paper; the tag row must be pruned with it.
+	if paper, _ := s.GetPostsByTags(ctx, []string{"source/paper"}, false); len(paper) != 0 {
+		t.Fatalf("expected no source/paper posts, got %v", slugsOf(paper))
+	}
+	g, _ := s.BuildTagGraph(ctx, TagGraphOptions{MinSharedTags: 1})
+	for _, e := range g.Edges {
+		if e.From == "delta.md" || e.To == "delta.md" {
+			t.Fatalf("edge to deleted post: %+v", e)
+		}
+	}
+	if err := s.DeletePost("delta.md", ctx); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("expected ErrPostNotFound on second delete, got %v", err)
+	}
+}
+
+func TestSQLiteStoreLinksFollowWrites(t *testing.T) {
+	s := seedSQLiteStore(t, filepath.Join(t.TempDir(), "posts.db"))
+	defer s.Close()
+	ctx := writerContext()
+	linking := []byte(datedNote("Epsilon", "2024-05-01", "type/note", "theme/kubernetes") + "See [[Zeta]].\n")
+	if _, err := s.CreatePost(linking, "epsilon.md", WriteOptions{}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	links, err := s.GetPostLinks(ctx, "epsilon")
+	if err != nil || len(links.Outgoing) != 1 || links.Outgoing[0].Slug != "" {
+		t.Fatalf("unresolved link = %+v, %v", links, err)
+	}
+	// The cached index is dropped by the write that adds the target.
+	if _, err := s.CreatePost([]byte(datedNote("Zeta", "2024-05-02", "type/note", "theme/kubernetes")), "zeta.md", WriteOptions{}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if links, _ = s.GetPostLinks(ctx, "epsilon"); links.Outgoing[0].Slug != "zeta.md" {
+		t.Fatalf("link not resolved after write: %+v", links.Outgoing)
+	}
+	if err := s.DeletePost("zeta", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if links, _ = s.GetPostLinks(ctx, "epsilon"); links.Outgoing[0].Slug != "" {
+		t.Fatalf("link to deleted post still resolved: %+v", links.Outgoing)
+	}
+}
@@ -1,31 +1,581 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitemigration"
+	"zombiezen.com/go/sqlite/sqlitex"
 )
 
+// sqliteSchema lists the schema migrations in order. Applied migrations are tracked through
+// PRAGMA user_version; append new entries and never edit ones that have shipped.
+var sqliteSchema = sqlitemigration.Schema{
+	AppID: 0x63796273, // "cybs"
+	Migrations: []string{
+		`CREATE TABLE posts (
+			slug        TEXT PRIMARY KEY,
+			name        TEXT NOT NULL,
+			lead        TEXT NOT NULL DEFAULT '',
+			updated_at  INTEGER NOT NULL DEFAULT 0, -- unix seconds parsed from frontmatter, used for ordering
+			published   INTEGER NOT NULL DEFAULT 0,
+			source      BLOB NOT NULL,              -- full uploaded document including frontmatter
+			uploaded_by TEXT NOT NULL DEFAULT ''
+		);
+		CREATE TABLE tags (
+			id   INTEGER PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE
+		);
+		CREATE TABLE post_tags (
+			post_slug TEXT NOT NULL REFERENCES posts(slug) ON DELETE CASCADE,
+			tag_id    INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+			position  INTEGER NOT NULL,
+			PRIMARY KEY (post_slug, tag_id)
+		);
+		CREATE INDEX post_tags_tag_id ON post_tags(tag_id);
+		CREATE INDEX posts_updated_at ON posts(updated_at DESC, slug);`,
+		`CREATE TABLE attachments (
+			path         TEXT PRIMARY KEY,           -- <post>/<file name>
+			content_type TEXT NOT NULL,
+			size         INTEGER NOT NULL,
+			modified_at  INTEGER NOT NULL,           -- unix seconds
+			data         BLOB NOT NULL,
+			uploaded_by  TEXT NOT NULL DEFAULT ''
+		);`,
+		`CREATE TABLE image_variants (
+			path    TEXT NOT NULL,                   -- attachment path
+			width   INTEGER NOT NULL,
+			version TEXT NOT NULL,                   -- Attachment.Version of the source
+			data    BLOB NOT NULL,
+			PRIMARY KEY (path, width, version)
+		);`,
+		`CREATE TABLE post_revisions (
+			id          INTEGER PRIMARY KEY,
+			slug        TEXT NOT NULL,               -- no foreign key: revisions outlive deleted posts
+			source      BLOB NOT NULL,
+			uploaded_by TEXT NOT NULL DEFAULT '',
+			created_at  INTEGER NOT NULL DEFAULT 0   -- unix seconds; 0 for posts stored before revisions
+		);
+		CREATE INDEX post_revisions_slug ON post_revisions(slug, id DESC);
+		INSERT INTO post_revisions (slug, source, uploaded_by) SELECT slug, source, uploaded_by FROM posts;`,
+		`CREATE TABLE redirects (
+			source     TEXT PRIMARY KEY,             -- normalized request path, see NormalizeRedirectPath
+			target     TEXT NOT NULL,
+			kind       TEXT NOT NULL,
+			created_at INTEGER NOT NULL DEFAULT 0,   -- unix seconds
+			created_by TEXT NOT NULL DEFAULT ''
+		);`,
+	},
+}
+
+// sqlitePostColumns is the projection every post query selects, in scanPost order.
+const sqlitePostColumns = `p.slug, p.name, p.source`
+
+// SQLiteStore is a durable single-file Storage backend. Posts keep their original document so
+// they are parsed exactly like the other backends; tags are normalized into tags/post_tags so
+// tag queries, related posts and the tag graph are answered in SQL.
 type SQLiteStore struct {
-	db *sqlite.Conn
+	logger *slog.Logger
+	pool   *sqlitex.Pool
+
+	linksMu  sync.Mutex
+	links    *sqliteLinks // nil until built, dropped by every write; see linkSnapshot
+	linksGen uint64       // bumped by every write so a snapshot read before it is not kept
+}
+
+// sqliteLinks is the parsed corpus and its link index, kept between writes so link lookups and
+// the tag graph do not parse every document per request.
+type sqliteLinks struct {
+	posts map[string]*Post
+	index *linkIndex
 }
 
-func NewSQLiteStore() (*SQLiteStore, error) {
-	conn, err := sqlite.OpenConn(":memory:", sqlite.OpenReadWrite)
+// NewSQLiteStore opens (or creates) the database file at path and applies pending migrations.
+func NewSQLiteStore(ctx context.Context, logger *slog.Logger, path string) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite path must be provided")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	pool, err := sqlitex.NewPool(path, sqlitex.PoolOptions{
+		PrepareConn: func(conn *sqlite.Conn) error {
+			return sqlitex.ExecuteTransient(conn, "PRAGMA foreign_keys = ON;", nil)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite database: %w", err)
+	}
+	conn, err := pool.Take(ctx)
 	if err != nil {
+		pool.Close()
 		return nil, err
 	}
-
+	err = sqlitemigration.Migrate(ctx, conn, sqliteSchema)
+	pool.Put(conn)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("migrating sqlite schema: %w", err)
+	}
 	return &SQLiteStore{
-		db: conn,
+		logger: logger.With("component", "sqliteStore", "path", path),
+		pool:   pool,
 	}, nil
 }
 
-func (s *SQLiteStore) GetPost(id string) []byte {
-	// placeholder
-	return []byte("not implemented")
+// Close releases all database connections.
+func (s *SQLiteStore) Close() error {
+	return s.pool.Close()
+}
+
+// GetPost returns a single post by slug (including .md).
+func (s *SQLiteStore) GetPost(slug string, ctx context.Context) (*Post, error) {
+	if !strings.HasSuffix(slug, ".md") {
+		slug = slug + ".md"
+	}
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	posts, err := s.queryPosts(conn, `SELECT `+sqlitePostColumns+` FROM posts p WHERE p.slug = ?`, slug)
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	return posts[0], nil
+}
+
+// GetPosts returns all posts keyed by slug.
+func (s *SQLiteStore) GetPosts(ctx context.Context) (map[string]*Post, error) {
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	return s.postsBySlug(conn)
+}
+
+// GetPostsByTags returns posts matching ANY or ALL of the provided tags, newest first.
+// If tags slice is empty an error is returned.
+func (s *SQLiteStore) GetPostsByTags(ctx context.Context, tags []string, matchAll bool) ([]*Post, error) {
+	if len(tags) == 0 {
+		return nil, errors.New("no tags provided")
+	}
+	uniq := normalizeTagQuery(tags)
+	if len(uniq) == 0 {
+		return []*Post{}, nil
+	}
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	having := ""
+	if matchAll {
+		// ALL: every requested tag must be present on the post.
+		having = fmt.Sprintf(" HAVING COUNT(DISTINCT t.id) = %d", len(uniq))
+	}
+	query := `SELECT ` + sqlitePostColumns + `
+		FROM posts p
+		JOIN post_tags pt ON pt.post_slug = p.slug
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE t.name IN (` + placeholders(len(uniq)) + `)
+		GROUP BY p.slug` + having + `
+		ORDER BY p.updated_at DESC, p.slug ASC`
+	return s.queryPosts(conn, query, stringArgs(uniq)...)
+}
+
+// GetRelatedPosts returns posts sharing at least one tag with slug, ranked by shared tag count desc,
+// then date desc, then slug asc. limit <= 0 means no cap.
+func (s *SQLiteStore) GetRelatedPosts(ctx context.Context, slug string, limit int) ([]*Post, error) {
+	post, err := s.GetPost(slug, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = -1 // SQLite: negative LIMIT means no limit
+	}
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	query := `SELECT ` + sqlitePostColumns + `
+		FROM post_tags self
+		JOIN post_tags other ON other.tag_id = self.tag_id AND other.post_slug <> self.post_slug
+		JOIN posts p ON p.slug = other.post_slug
+		WHERE self.post_slug = ?
+		GROUP BY p.slug
+		ORDER BY COUNT(*) DESC, p.updated_at DESC, p.slug ASC
+		LIMIT ?`
+	return s.queryPosts(conn, query, post.Meta.Slug, limit)
+}
+
+// BuildTagGraph computes shared-tag edges with a self-join over post_tags and projects them
+// through the same snapshot logic as the in-memory backends.
+func (s *SQLiteStore) BuildTagGraph(ctx context.Context, opts TagGraphOptions) (*TagGraph, error) {
+	if opts.MinSharedTags < 1 {
+		opts.MinSharedTags = 1
+	}
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+
+	snap, err := s.linkSnapshot(conn)
+	if err != nil {
+		return nil, err
+	}
+	tagIndex := make(map[string]map[string]struct{})
+	for slug, p := range snap.posts {
+		indexTagsLocked(tagIndex, slug, p.Meta.Tags)
+	}
+
+	include := normalizeTagQuery(opts.IncludeTags)
+	filter := ""
+	if len(include) > 0 {
+		filter = ` AND t.name IN (` + placeholders(len(include)) + `)`
+	}
+	query := `SELECT a.post_slug, b.post_slug, t.name
+		FROM post_tags a
+		JOIN post_tags b ON b.tag_id = a.tag_id AND a.post_slug < b.post_slug
+		JOIN tags t ON t.id = a.tag_id
+		WHERE 1 = 1` + filter
+	edgeMap := make(map[string]*GraphEdge)
+	err = sqlitex.ExecuteTransient(conn, query, &sqlitex.ExecOptions{
+		Args: stringArgs(include),
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			from, to := stmt.ColumnText(0), stmt.ColumnText(1)
+			key := from + "|" + to
+			edge, ok := edgeMap[key]
+			if !ok {
+				edge = &GraphEdge{From: from, To: to}
+				edgeMap[key] = edge
+			}
+			edge.SharedTags = append(edge.SharedTags, stmt.ColumnText(2))
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying tag edges: %w", err)
+	}
+	return buildGraphSnapshotFromEdgeMap(snap.posts, edgeMap, tagIndex, snap.index.edges(), opts), nil
+}
+
+// GetPostLinks resolves wikilinks across all stored documents. Resolution depends on every
+// slug and alias, so the index is built from the whole corpus and kept until the next write.
+func (s *SQLiteStore) GetPostLinks(ctx context.Context, slug string) (*PostLinks, error) {
+	slug = canonicalSlug(slug)
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(conn)
+	snap, err := s.linkSnapshot(conn)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := snap.posts[slug]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	return snap.index.links(slug), nil
+}
+
+// linkSnapshot returns the cached corpus and link index, building them if a write dropped
+// them. A snapshot built while a write commits is served once but not kept. The cache assumes
+// this store is the only writer of the database file.
+func (s *SQLiteStore) linkSnapshot(conn *sqlite.Conn) (*sqliteLinks, error) {
+	s.linksMu.Lock()
+	cached, gen := s.links, s.linksGen
+	s.linksMu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+	posts, err := s.postsBySlug(conn)
+	if err != nil {
+		return nil, err
+	}
+	snap := &sqliteLinks{posts: posts, index: buildLinkIndex(posts)}
+	s.linksMu.Lock()
+	if s.linksGen == gen {
+		s.links = snap
+	}
+	s.linksMu.Unlock()
+	return snap, nil
+}
+
+// invalidateLinks drops the link snapshot after a committed write.
+func (s *SQLiteStore) invalidateLinks() {
+	s.linksMu.Lock()
+	s.links = nil
+	s.linksGen++
+	s.linksMu.Unlock()
+}
+
+func (s *SQLiteStore) GetAbout() []byte {
+	return []byte{}
+}
+
+func (s *SQLiteStore) GetAssets() http.Handler {
+	return nil
+}
+
+// CreatePost validates the upload like GCSStore.CreatePost and upserts the post and its tags
+// in a single transaction, replacing an existing post only when opts allow it.
+func (s *SQLiteStore) CreatePost(content []byte, originalFilename string, opts WriteOptions, ctx context.Context) (string, error) {
+	firebaseTok, err := uploaderFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	post, err := prepareUpload(content, originalFilename, firebaseTok)
+	if err != nil {
+		return "", err
+	}
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer s.pool.Put(conn)
+	err = s.writePost(conn, post, content, firebaseTok.UID, true, opts)
+	s.invalidateLinks()
+	if err != nil {
+		return "", err
+	}
+	s.logger.Info("post created", slog.String("slug", post.Meta.Slug), slog.Int("tag_count", len(post.Meta.Tags)))
+	return post.Version, nil
 }
 
-func (s *SQLiteStore) CreatePost(post Post) error {
-	return errors.New("method not implemented")
+// UpdatePost replaces the stored document and tag links of an existing post.
+func (s *SQLiteStore) UpdatePost(slug string, content []byte, opts WriteOptions, ctx context.Context) (string, error) {
+	firebaseTok, err := uploaderFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	slug = canonicalSlug(slug)
+	post, err := prepareUpdate(content, slug, firebaseTok)
+	if err != nil {
+		return "", err
+	}
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer s.pool.Put(conn)
+	err = s.writePost(conn, post, content, firebaseTok.UID, false, opts)
+	s.invalidateLinks()
+	if err != nil {
+		return "", err
+	}
+	s.logger.Info("post updated", slog.String("slug", slug), slog.Int("tag_count", len(post.Meta.Tags)))
+	return post.Version, nil
+}
+
+// writePost checks opts against the stored document of the post and its aliases against the
+// other posts, upserts it and records it as the newest revision. The immediate transaction
+// takes the write lock before the check, so concurrent writers are serialized.
+func (s *SQLiteStore) writePost(conn *sqlite.Conn, post *Post, source []byte, uploadedBy string, create bool, opts WriteOptions) (err error) {
+	end, err := sqlitex.ImmediateTransaction(conn)
+	if err != nil {
+		return err
+	}
+	defer end(&err)
+	current := ""
+	err = sqlitex.Execute(conn, `SELECT source FROM posts WHERE slug = ?`, &sqlitex.ExecOptions{
+		Args: []any{post.Meta.Slug},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			stored := make([]byte, stmt.ColumnLen(0))
+			stmt.ColumnBytes(0, stored)
+			current = ContentVersion(stored)
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err = checkWrite(post.Meta.Slug, current, create, opts); err != nil {
+		return err
+	}
+	all, err := s.postsBySlug(conn)
+	if err != nil {
+		return err
+	}
+	if err = checkAliases(all, all[post.Meta.Slug], post); err != nil {
+		return err
+	}
+	if err = s.upsertPost(conn, post, source, uploadedBy); err != nil {
+		return err
+	}
+	err = sqlitex.Execute(conn, `INSERT INTO post_revisions (slug, source, uploaded_by, created_at) VALUES (?, ?, ?, ?)`, &sqlitex.ExecOptions{
+		Args: []any{post.Meta.Slug, source, uploadedBy, time.Now().Unix()},
+	})
+	if err != nil {
+		return fmt.Errorf("write revision: %w", err)
+	}
+	return nil
+}
+
+// DeletePost removes the post row; tag links cascade and orphaned tags are pruned.
+func (s *SQLiteStore) DeletePost(slug string, ctx context.Context) error {
+	if _, err := uploaderFromContext(ctx); err != nil {
+		return err
+	}
+	slug = canonicalSlug(slug)
+	conn, err := s.pool.Take(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.pool.Put(conn)
+	err = s.deletePost(conn, slug)
+	s.invalidateLinks()
+	if err != nil {
+		return err
+	}
+	s.logger.Info("post deleted", slog.String("slug", slug))
+	return nil
+}
+
+func (s *SQLiteStore) deletePost(conn *sqlite.Conn, slug string) (err error) {
+	defer sqlitex.Save(conn)(&err)
+	if err = sqlitex.Execute(conn, `DELETE FROM posts WHERE slug = ?`, &sqlitex.ExecOptions{Args: []any{slug}}); err != nil {
+		return fmt.Errorf("delete post: %w", err)
+	}
+	if conn.Changes() == 0 {
+		return fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	if err = sqlitex.Execute(conn, `DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM post_tags)`, nil); err != nil {
+		return fmt.Errorf("prune tags: %w", err)
+	}
+	return nil
+}
+
+// upsertPost writes the post row and replaces its tag links inside one transaction.
+func (s *SQLiteStore) upsertPost(conn *sqlite.Conn, post *Post, source []byte, uploadedBy string) (err error) {
+	defer sqlitex.Save(conn)(&err)
+	meta := post.Meta
+	err = sqlitex.Execute(conn, `INSERT INTO posts (slug, name, lead, updated_at, published, source, uploaded_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (slug) DO UPDATE SET
+			name = excluded.name,
+			lead = excluded.lead,
+			updated_at = excluded.updated_at,
+			published = excluded.published,
+			source = excluded.source,
+			uploaded_by = excluded.uploaded_by`, &sqlitex.ExecOptions{
+		Args: []any{meta.Slug, meta.Name, meta.Lead, meta.Updated.Unix(), meta.Published, source, uploadedBy},
+	})
+	if err != nil {
+		return fmt.Errorf("write post: %w", err)
+	}
+	if err = sqlitex.Execute(conn, `DELETE FROM post_tags WHERE post_slug = ?`, &sqlitex.ExecOptions{Args: []any{meta.Slug}}); err != nil {
+		return fmt.Errorf("clear post tags: %w", err)
+	}
+	for i, tag := range meta.Tags {
+		if err = sqlitex.Execute(conn, `INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING`, &sqlitex.ExecOptions{Args: []any{tag}}); err != nil {
+			return fmt.Errorf("write tag %s: %w", tag, err)
+		}
+		err = sqlitex.Execute(conn, `INSERT INTO post_tags (post_slug, tag_id, position) SELECT ?, id, ? FROM tags WHERE name = ?`, &sqlitex.ExecOptions{
+			Args: []any{meta.Slug, i, tag},
+		})
+		if err != nil {
+			return fmt.Errorf("link tag %s: %w", tag, err)
+		}
+	}
+	if err = sqlitex.Execute(conn, `DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM post_tags)`, nil); err != nil {
+		return fmt.Errorf("prune tags: %w", err)
+	}
+	return nil
+}
+
+// queryPosts runs a query selecting sqlitePostColumns and returns the posts in result order with
+// their tags attached. Documents are parsed with parsePost so metadata matches other backends.
+func (s *SQLiteStore) queryPosts(conn *sqlite.Conn, query string, args ...any) ([]*Post, error) {
+	posts := []*Post{}
+	err := sqlitex.ExecuteTransient(conn, query, &sqlitex.ExecOptions{
+		Args: args,
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			slug := stmt.ColumnText(0)
+			source := make([]byte, stmt.ColumnLen(2))
+			stmt.ColumnBytes(2, source)
+			post, err := parsePost(source)
+			if err != nil {
+				return fmt.Errorf("parsing stored post %s: %w", slug, err)
+			}
+			post.Meta.Slug = slug
+			post.Meta.Name = stmt.ColumnText(1)
+			post.Meta.Tags = nil
+			posts = append(posts, post)
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachTags(conn, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// postsBySlug returns every post keyed by slug.
+func (s *SQLiteStore) postsBySlug(conn *sqlite.Conn) (map[string]*Post, error) {
+	posts, err := s.queryPosts(conn, `SELECT `+sqlitePostColumns+` FROM posts p`)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*Post, len(posts))
+	for _, p := range posts {
+		result[p.Meta.Slug] = p
+	}
+	return result, nil
+}
+
+// attachTags loads normalized tags (in upload order) for the given posts.
+func (s *SQLiteStore) attachTags(conn *sqlite.Conn, posts []*Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	bySlug := make(map[string]*Post, len(posts))
+	slugs := make([]string, 0, len(posts))
+	for _, p := range posts {
+		bySlug[p.Meta.Slug] = p
+		slugs = append(slugs, p.Meta.Slug)
+	}
+	query := `SELECT pt.post_slug, t.name
+		FROM post_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.post_slug IN (` + placeholders(len(slugs)) + `)
+		ORDER BY pt.post_slug, pt.position`
+	return sqlitex.ExecuteTransient(conn, query, &sqlitex.ExecOptions{
+		Args: stringArgs(slugs),
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			if p, ok := bySlug[stmt.ColumnText(0)]; ok {
+				p.Meta.Tags = append(p.Meta.Tags, stmt.ColumnText(1))
+			}
+			return nil
+		},
+	})
+}
+
+// placeholders returns n comma separated "?" parameters for IN lists.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func stringArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
 }
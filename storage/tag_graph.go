@@ -7,13 +7,21 @@ type TagGraphOptions struct {
 	MaxEdges      int      // optional cap; 0 = unlimited
 }
 
-// GraphEdge represents an undirected edge between two posts.
-// From is always lexicographically <= To for canonicalization.
+// Edge types distinguish shared-tag edges from explicit wikilink edges.
+const (
+	EdgeTypeTag  = "tag"
+	EdgeTypeLink = "link"
+)
+
+// GraphEdge represents an edge between two posts.
+// Tag edges are undirected and From is always lexicographically <= To for canonicalization.
+// Link edges are directed from the linking post to the linked post.
 type GraphEdge struct {
 	From       string   `json:"from"`
 	To         string   `json:"to"`
 	SharedTags []string `json:"shared_tags"`
-	Weight     int      `json:"weight"` // number of shared tags
+	Weight     int      `json:"weight"` // number of shared tags; 1 for link edges
+	Type       string   `json:"type"`   // EdgeTypeTag or EdgeTypeLink
 }
 
 // TagGraph bundles posts, their edges, and a snapshot of the tag index used.
@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+)
+
+// Helper: index tags into tagIndex (must hold write lock).
+func indexTagsLocked(idx map[string]map[string]struct{}, slug string, tags []string) {
+	for _, t := range tags {
+		if strings.TrimSpace(t) == "" {
+			continue
+		}
+		set, ok := idx[t]
+		if !ok {
+			set = make(map[string]struct{})
+			idx[t] = set
+		}
+		set[slug] = struct{}{}
+	}
+}
+
+// unindexTagsLocked removes slug from the given tags and drops tags left without posts.
+// Caller must hold write lock.
+func unindexTagsLocked(idx map[string]map[string]struct{}, slug string, tags []string) {
+	for _, t := range tags {
+		set, ok := idx[t]
+		if !ok {
+			continue
+		}
+		delete(set, slug)
+		if len(set) == 0 {
+			delete(idx, t)
+		}
+	}
+}
+
+// normalizeTagQuery trims and deduplicates query tags, preserving first-seen order.
+func normalizeTagQuery(tags []string) []string {
+	uniq := make([]string, 0, len(tags))
+	seen := map[string]struct{}{}
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		uniq = append(uniq, t)
+	}
+	return uniq
+}
+
+// postsByTagsLocked resolves normalized tags against the index with ANY (union) or ALL (intersection)
+// semantics. Result is sorted by date desc then slug asc. Caller must hold at least a read lock.
+func postsByTagsLocked(tagIndex map[string]map[string]struct{}, postCache map[string]*Post, tags []string, matchAll bool) []*Post {
+	resultSlugs := map[string]struct{}{}
+	if matchAll {
+		if len(tags) == 0 {
+			return []*Post{}
+		}
+		firstSet, ok := tagIndex[tags[0]]
+		if !ok {
+			return []*Post{}
+		}
+		for slug := range firstSet {
+			resultSlugs[slug] = struct{}{}
+		}
+		for _, t := range tags[1:] {
+			set, ok := tagIndex[t]
+			if !ok {
+				// Intersection with empty set -> empty result
+				return []*Post{}
+			}
+			for slug := range resultSlugs {
+				if _, present := set[slug]; !present {
+					delete(resultSlugs, slug)
+				}
+			}
+		}
+	} else {
+		for _, t := range tags {
+			for slug := range tagIndex[t] {
+				resultSlugs[slug] = struct{}{}
+			}
+		}
+	}
+	posts := make([]*Post, 0, len(resultSlugs))
+	for slug := range resultSlugs {
+		// Only cached posts are returned; the index never references uncached slugs.
+		if p, ok := postCache[slug]; ok {
+			posts = append(posts, p)
+		}
+	}
+	// Sort by date desc (newest first) then slug asc for stability
+	sort.Slice(posts, func(i, j int) bool {
+		if posts[i].Meta.Updated.Equal(posts[j].Meta.Updated) {
+			return posts[i].Meta.Slug < posts[j].Meta.Slug
+		}
+		return posts[i].Meta.Updated.After(posts[j].Meta.Updated)
+	})
+	return posts
+}
+
+// relatedPostsLocked ranks posts sharing at least one tag with post by shared tag count desc,
+// then date desc, then slug asc. limit <= 0 means no cap. Caller must hold at least a read lock.
+func relatedPostsLocked(tagIndex map[string]map[string]struct{}, postCache map[string]*Post, post *Post, limit int) []*Post {
+	sharedCounts := map[string]int{}
+	for _, tag := range post.Meta.Tags {
+		for other := range tagIndex[tag] {
+			if other == post.Meta.Slug {
+				continue
+			}
+			sharedCounts[other]++
+		}
+	}
+	related := make([]*Post, 0, len(sharedCounts))
+	for otherSlug := range sharedCounts {
+		if p, ok := postCache[otherSlug]; ok {
+			related = append(related, p)
+		}
+	}
+	sort.Slice(related, func(i, j int) bool {
+		ci := sharedCounts[related[i].Meta.Slug]
+		cj := sharedCounts[related[j].Meta.Slug]
+		if ci != cj {
+			return ci > cj // more shared tags first
+		}
+		if !related[i].Meta.Updated.Equal(related[j].Meta.Updated) {
+			return related[i].Meta.Updated.After(related[j].Meta.Updated)
+		}
+		return related[i].Meta.Slug < related[j].Meta.Slug
+	})
+	if limit > 0 && len(related) > limit {
+		related = related[:limit]
+	}
+	return related
+}
+
+// buildEdgeMapLocked computes the full shared-tag edge map from the tag index.
+// include optionally restricts which tags produce edges. Caller must hold at least a read lock.
+func buildEdgeMapLocked(tagIndex map[string]map[string]struct{}, include []string) map[string]*GraphEdge {
+	edgeMap := make(map[string]*GraphEdge)
+	filter := map[string]struct{}{}
+	for _, t := range include {
+		filter[strings.TrimSpace(t)] = struct{}{}
+	}
+	for tag, set := range tagIndex {
+		if len(filter) > 0 {
+			if _, ok := filter[tag]; !ok {
+				continue
+			}
+		}
+		slugs := make([]string, 0, len(set))
+		for slug := range set {
+			slugs = append(slugs, slug)
+		}
+		for i := 0; i < len(slugs); i++ {
+			for j := i + 1; j < len(slugs); j++ {
+				a, b := slugs[i], slugs[j]
+				if a > b {
+					a, b = b, a
+				}
+				key := a + "|" + b
+				edge, exists := edgeMap[key]
+				if !exists {
+					edge = &GraphEdge{From: a, To: b}
+					edgeMap[key] = edge
+				}
+				edge.SharedTags = append(edge.SharedTags, tag)
+			}
+		}
+	}
+	return edgeMap
+}
+
+// snapshotTagIndex creates a copy for safe external use.
+func snapshotTagIndex(src map[string]map[string]struct{}) map[string][]string {
+	out := make(map[string][]string, len(src))
+	for tag, set := range src {
+		slugs := make([]string, 0, len(set))
+		for slug := range set {
+			slugs = append(slugs, slug)
+		}
+		sort.Strings(slugs)
+		out[tag] = slugs
+	}
+	return out
+}
+
+// incrementalAddPostToGraphLocked updates edgeMap for a newly added post.
+// Caller must hold write lock.
+func incrementalAddPostToGraphLocked(edgeMap map[string]*GraphEdge, post *Post, tagIndex map[string]map[string]struct{}, minShared int, include []string) {
+	if minShared < 1 {
+		minShared = 1
+	}
+	filter := map[string]struct{}{}
+	for _, t := range include {
+		filter[strings.TrimSpace(t)] = struct{}{}
+	}
+	for _, tag := range post.Meta.Tags {
+		if len(filter) > 0 {
+			if _, ok := filter[tag]; !ok {
+				continue
+			}
+		}
+		set := tagIndex[tag]
+		for other := range set {
+			if other == post.Meta.Slug {
+				continue
+			}
+			a, b := post.Meta.Slug, other
+			if a > b {
+				a, b = b, a
+			}
+			key := a + "|" + b
+			edge, exists := edgeMap[key]
+			if !exists {
+				edge = &GraphEdge{From: a, To: b}
+				edgeMap[key] = edge
+			}
+			edge.SharedTags = append(edge.SharedTags, tag)
+		}
+	}
+	// We defer weight assignment & filtering to snapshot function to keep incremental path minimal.
+}
+
+// incrementalRemovePostFromGraphLocked drops every edge touching slug.
+// Caller must hold write lock.
+func incrementalRemovePostFromGraphLocked(edgeMap map[string]*GraphEdge, slug string) {
+	for key, edge := range edgeMap {
+		if edge.From == slug || edge.To == slug {
+			delete(edgeMap, key)
+		}
+	}
+}
+
+// buildGraphSnapshotFromEdgeMap converts edgeMap into TagGraph honoring options. linkEdges are
+// appended after the shared-tag edges; MinSharedTags does not apply to them but MaxEdges does.
+func buildGraphSnapshotFromEdgeMap(postCache map[string]*Post, edgeMap map[string]*GraphEdge, tagIndex map[string]map[string]struct{}, linkEdges []GraphEdge, opts TagGraphOptions) *TagGraph {
+	edges := make([]GraphEdge, 0, len(edgeMap)+len(linkEdges))
+	for _, e := range edgeMap {
+		if len(e.SharedTags) < opts.MinSharedTags {
+			continue
+		}
+		// ensure deterministic order of SharedTags & weight
+		copyTags := append([]string(nil), e.SharedTags...)
+		sort.Strings(copyTags)
+		edge := GraphEdge{From: e.From, To: e.To, SharedTags: copyTags, Weight: len(copyTags), Type: EdgeTypeTag}
+		edges = append(edges, edge)
+	}
+	edges = append(edges, linkEdges...)
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Type != edges[j].Type {
+			return edges[i].Type == EdgeTypeTag
+		}
+		if edges[i].Weight != edges[j].Weight {
+			return edges[i].Weight > edges[j].Weight
+		}
+		if edges[i].From == edges[j].From {
+			return edges[i].To < edges[j].To
+		}
+		return edges[i].From < edges[j].From
+	})
+	if opts.MaxEdges > 0 && len(edges) > opts.MaxEdges {
+		edges = edges[:opts.MaxEdges]
+	}
+	posts := make([]*Post, 0, len(postCache))
+	for _, p := range postCache {
+		posts = append(posts, p)
+	}
+	return &TagGraph{Posts: posts, Edges: edges, TagIndex: snapshotTagIndex(tagIndex)}
+}
+
+// slicesEqual compares two string slices ignoring order; used for option re-use check.
+func slicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	// compare ordered copies
+	aCopy := append([]string(nil), a...)
+	bCopy := append([]string(nil), b...)
+	sort.Strings(aCopy)
+	sort.Strings(bCopy)
+	for i := range aCopy {
+		if aCopy[i] != bCopy[i] {
+			return false
+		}
+	}
+	return true
+}
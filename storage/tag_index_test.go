@@ -14,7 +14,7 @@ func buildPost(slug string, updated string, tags []string) *Post {
 }
 
 func seedStore() *GCSStore {
-	s := &GCSStore{tagIndex: make(map[string]map[string]struct{}), postCache: make(map[string]*Post), logger: slog.Default()}
+	s := &GCSStore{tagIndex: make(map[string]map[string]struct{}), postCache: make(map[string]*Post), generations: make(map[string]int64), links: newLinkIndex(), logger: slog.Default()}
 	posts := []*Post{
 		buildPost("alpha.md", "2024-01-01", []string{"type/note", "theme/kubernetes", "source/book"}),
 		buildPost("beta.md", "2024-02-01", []string{"type/note", "theme/kubernetes", "theme/cost-optimization", "source/article"}),
@@ -133,3 +133,39 @@ func TestIncrementalGraphUpdate(t *testing.T) {
 		t.Fatalf("expected edge count increase > %d got %d", edgeCount, len(updated.Edges))
 	}
 }
+
+func TestGCSSetAndRemovePostLocked(t *testing.T) {
+	s := seedStore()
+	s.edgeMap = make(map[string]*GraphEdge)
+	opts := TagGraphOptions{MinSharedTags: 1}
+	if _, err := s.BuildTagGraph(context.Background(), opts); err != nil {
+		t.Fatalf("initial build error: %v", err)
+	}
+	// Re-tag beta so it no longer shares kubernetes with alpha and delta.
+	s.mu.Lock()
+	s.setPostLocked(buildPost("beta.md", "2024-02-02", []string{"type/guide", "theme/finops"}), 2)
+	s.mu.Unlock()
+	k8s, _ := s.GetPostsByTags(context.Background(), []string{"theme/kubernetes"}, false)
+	if len(k8s) != 2 {
+		t.Fatalf("expected stale kubernetes entry removed, got %d", len(k8s))
+	}
+	g, _ := s.BuildTagGraph(context.Background(), opts)
+	for _, e := range g.Edges {
+		if e.From == "beta.md" || e.To == "beta.md" {
+			t.Fatalf("expected no edges for retagged beta, got %+v", e)
+		}
+	}
+
+	s.mu.Lock()
+	s.removePostLocked("delta.md")
+	s.mu.Unlock()
+	g, _ = s.BuildTagGraph(context.Background(), opts)
+	if len(g.Posts) != 3 {
+		t.Fatalf("expected 3 posts after removal, got %d", len(g.Posts))
+	}
+	for _, e := range g.Edges {
+		if e.From == "delta.md" || e.To == "delta.md" {
+			t.Fatalf("edge to removed post: %+v", e)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package storage
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TaxonomyVersion is the schema version understood by this build. Files declaring a newer
+// version are rejected rather than half-applied.
+const TaxonomyVersion = 1
+
+//go:embed taxonomy.yaml
+var defaultTaxonomy []byte
+
+// Taxonomy defines the tag families a post may use and their cardinalities. It is loaded from
+// a versioned YAML or JSON file and is read-only once loaded.
+type Taxonomy struct {
+	Version  int         `yaml:"version" json:"version"`
+	Families []TagFamily `yaml:"families" json:"families"`
+
+	byName map[string]*TagFamily
+}
+
+// TagFamily is one tag prefix (e.g. "theme" for theme/*). Max 0 means unbounded. An empty
+// Values list accepts any value. Deprecated maps old values to their replacement; an empty
+// replacement rejects the value. ReplacedBy deprecates the whole family in favour of another.
+type TagFamily struct {
+	Name        string            `yaml:"name" json:"name"`
+	Description string            `yaml:"description" json:"description,omitempty"`
+	Min         int               `yaml:"min" json:"min"`
+	Max         int               `yaml:"max" json:"max"`
+	Values      []string          `yaml:"values" json:"values,omitempty"`
+	Deprecated  map[string]string `yaml:"deprecated" json:"deprecated,omitempty"`
+	ReplacedBy  string            `yaml:"replacedBy" json:"replacedBy,omitempty"`
+}
+
+// Allows reports whether value is acceptable for the family's closed value list.
+func (f *TagFamily) Allows(value string) bool {
+	if len(f.Values) == 0 {
+		return true
+	}
+	for _, v := range f.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// DeprecatedValues returns the deprecated values of the family in sorted order.
+func (f *TagFamily) DeprecatedValues() []string {
+	out := make([]string, 0, len(f.Deprecated))
+	for v := range f.Deprecated {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Cardinality renders the allowed tag count, e.g. "1-2", "0-3" or "at least 1".
+func (f *TagFamily) Cardinality() string {
+	if f.Max == 0 {
+		return fmt.Sprintf("at least %d", f.Min)
+	}
+	return fmt.Sprintf("%d-%d", f.Min, f.Max)
+}
+
+var activeTaxonomy atomic.Pointer[Taxonomy]
+
+func init() {
+	t, err := ParseTaxonomy(defaultTaxonomy, "yaml")
+	if err != nil {
+		panic(fmt.Sprintf("embedded taxonomy: %v", err))
+	}
+	activeTaxonomy.Store(t)
+}
+
+// ActiveTaxonomy returns the taxonomy ValidateTags currently enforces.
+func ActiveTaxonomy() *Taxonomy { return activeTaxonomy.Load() }
+
+// SetTaxonomy replaces the taxonomy enforced by ValidateTags. Call it at startup, before posts
+// are uploaded.
+func SetTaxonomy(t *Taxonomy) {
+	if t != nil {
+		activeTaxonomy.Store(t)
+	}
+}
+
+// LoadTaxonomyFile reads a taxonomy from path; ".json" files are decoded as JSON, anything else
+// as YAML.
+func LoadTaxonomyFile(path string) (*Taxonomy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read taxonomy: %w", err)
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+	return ParseTaxonomy(data, format)
+}
+
+// ParseTaxonomy decodes and checks a taxonomy document in the given format ("yaml" or "json").
+func ParseTaxonomy(data []byte, format string) (*Taxonomy, error) {
+	t := &Taxonomy{}
+	var err error
+	switch format {
+	case "json":
+		err = json.Unmarshal(data, t)
+	case "yaml":
+		err = yaml.UnmarshalStrict(data, t)
+	default:
+		return nil, fmt.Errorf("unknown taxonomy format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode taxonomy: %w", err)
+	}
+	if err := t.init(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// init checks the schema for internal consistency and builds the name lookup.
+func (t *Taxonomy) init() error {
+	if t.Version < 1 || t.Version > TaxonomyVersion {
+		return fmt.Errorf("unsupported taxonomy version %d (want 1-%d)", t.Version, TaxonomyVersion)
+	}
+	if len(t.Families) == 0 {
+		return errors.New("taxonomy defines no families")
+	}
+	t.byName = make(map[string]*TagFamily, len(t.Families))
+	for i := range t.Families {
+		f := &t.Families[i]
+		f.Name = strings.TrimSpace(f.Name)
+		if f.Name == "" || strings.Contains(f.Name, "/") {
+			return fmt.Errorf("invalid tag family name %q", f.Name)
+		}
+		if _, dup := t.byName[f.Name]; dup {
+			return fmt.Errorf("duplicate tag family %q", f.Name)
+		}
+		if f.Min < 0 || f.Max < 0 || (f.Max > 0 && f.Min > f.Max) {
+			return fmt.Errorf("tag family %q: invalid cardinality %d-%d", f.Name, f.Min, f.Max)
+		}
+		t.byName[f.Name] = f
+	}
+	for i := range t.Families {
+		f := &t.Families[i]
+		if f.ReplacedBy == "" {
+			continue
+		}
+		next, ok := t.byName[f.ReplacedBy]
+		if !ok || next.ReplacedBy != "" {
+			return fmt.Errorf("tag family %q: replacement %q must be an active family", f.Name, f.ReplacedBy)
+		}
+		if f.Min > 0 {
+			return fmt.Errorf("tag family %q: a replaced family cannot require tags", f.Name)
+		}
+	}
+	return nil
+}
+
+// Family looks up a family by name.
+func (t *Taxonomy) Family(name string) (*TagFamily, bool) {
+	f, ok := t.byName[name]
+	return f, ok
+}
+
+// Validate enforces the taxonomy on meta.Tags. Blank and duplicate tags are dropped and
+// deprecated tags are rewritten to their replacement; the normalized list replaces meta.Tags.
+// It fails with the ValidationErrors of Check.
+func (t *Taxonomy) Validate(meta *PostMeta) error {
+	return t.Check(meta).err()
+}
+
+// Check is Validate returning every issue: one error per offending tag and family, and a
+// warning for each tag that normalization drops or rewrites. meta.Tags is only normalized when
+// there are no errors.
+func (t *Taxonomy) Check(meta *PostMeta) ValidationErrors {
+	var issues ValidationErrors
+	counts := map[string]int{}
+	unique := map[string]struct{}{}
+	filtered := make([]string, 0, len(meta.Tags))
+	for i, raw := range meta.Tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		tag := strings.TrimSpace(raw)
+		if tag == "" {
+			issues.add(SeverityWarning, field, "blank tag dropped")
+			continue
+		}
+		parts := strings.SplitN(tag, "/", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			issues.add(SeverityError, field, fmt.Sprintf("tag %q must be family/value", tag))
+			continue
+		}
+		family, ok := t.byName[parts[0]]
+		if !ok {
+			issues.add(SeverityError, field, "unknown tag family: "+parts[0])
+			continue
+		}
+		if family.ReplacedBy != "" {
+			family = t.byName[family.ReplacedBy]
+		}
+		value := parts[1]
+		if repl, deprecated := family.Deprecated[value]; deprecated {
+			if repl == "" {
+				issues.add(SeverityError, field, fmt.Sprintf("tag %s/%s is deprecated", family.Name, value))
+				continue
+			}
+			value = repl
+		}
+		if !family.Allows(value) {
+			issues.add(SeverityError, field, fmt.Sprintf("tag %s/%s is not an allowed %s/* value", family.Name, value, family.Name))
+			continue
+		}
+		normalized := family.Name + "/" + value
+		if normalized != tag {
+			issues.add(SeverityWarning, field, fmt.Sprintf("tag %s is stored as %s", tag, normalized))
+		}
+		if _, dup := unique[normalized]; dup {
+			issues.add(SeverityWarning, field, fmt.Sprintf("duplicate tag %s dropped", normalized))
+			continue
+		}
+		unique[normalized] = struct{}{}
+		counts[family.Name]++
+		filtered = append(filtered, normalized)
+	}
+	for i := range t.Families {
+		f := &t.Families[i]
+		c := counts[f.Name]
+		if c >= f.Min && (f.Max == 0 || c <= f.Max) {
+			continue
+		}
+		if f.Min == 0 && f.Max == 1 {
+			issues.add(SeverityError, "tags", fmt.Sprintf("multiple %s/* tags not allowed", f.Name))
+			continue
+		}
+		issues.add(SeverityError, "tags", fmt.Sprintf("%s/* tags must be %s (got %d)", f.Name, f.Cardinality(), c))
+	}
+	if !issues.HasErrors() {
+		meta.Tags = filtered // normalized
+	}
+	return issues
+}
+
+// ValidateTags enforces the active taxonomy's tag families and cardinalities.
+func ValidateTags(meta *PostMeta) error {
+	return ActiveTaxonomy().Validate(meta)
+}
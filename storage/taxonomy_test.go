@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultTaxonomyMatchesBuiltinRules(t *testing.T) {
+	tax := ActiveTaxonomy()
+	if tax.Version != TaxonomyVersion {
+		t.Fatalf("version = %d", tax.Version)
+	}
+	cases := []struct {
+		tags    []string
+		wantErr string
+	}{
+		{[]string{"type/note", "theme/k8s"}, ""},
+		{[]string{"theme/k8s"}, "type/* tags must be 1-2 (got 0)"},
+		{[]string{"type/a", "type/b", "type/c", "theme/k8s"}, "type/* tags must be 1-2 (got 3)"},
+		{[]string{"type/note", "theme/a", "theme/b", "theme/c", "theme/d", "theme/e", "theme/f"}, "theme/* tags must be 1-5 (got 6)"},
+		{[]string{"type/note", "theme/k8s", "source/a", "source/b"}, "multiple source/* tags not allowed"},
+		{[]string{"type/note", "theme/k8s", "colour/red"}, "unknown tag family: colour"},
+		{[]string{"type/note", "theme/k8s", "loose"}, `tag "loose" must be family/value`},
+	}
+	for _, c := range cases {
+		err := ValidateTags(&PostMeta{Tags: c.tags})
+		switch {
+		case c.wantErr == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", c.tags, err)
+		case c.wantErr != "" && (err == nil || err.Error() != c.wantErr):
+			t.Errorf("%v: error = %v, want %q", c.tags, err, c.wantErr)
+		}
+	}
+}
+
+const testTaxonomy = `
+version: 1
+families:
+  - name: type
+    min: 1
+    max: 1
+    values: [article, note]
+    deprecated:
+      post: article
+      draft: ""
+  - name: theme
+    min: 1
+  - name: topic
+    replacedBy: theme
+`
+
+func TestTaxonomyValuesAndDeprecations(t *testing.T) {
+	tax, err := ParseTaxonomy([]byte(testTaxonomy), "yaml")
+	if err != nil {
+		t.Fatalf("ParseTaxonomy: %v", err)
+	}
+	meta := &PostMeta{Tags: []string{"type/post", " topic/k8s ", "theme/k8s", ""}}
+	if err := tax.Validate(meta); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if want := []string{"type/article", "theme/k8s"}; !reflect.DeepEqual(meta.Tags, want) {
+		t.Fatalf("normalized tags = %v, want %v", meta.Tags, want)
+	}
+	if err := tax.Validate(&PostMeta{Tags: []string{"type/draft", "theme/k8s"}}); err == nil || !strings.Contains(err.Error(), "deprecated") {
+		t.Fatalf("expected deprecated value rejected, got %v", err)
+	}
+	if err := tax.Validate(&PostMeta{Tags: []string{"type/video", "theme/k8s"}}); err == nil || !strings.Contains(err.Error(), "not an allowed") {
+		t.Fatalf("expected value outside the list rejected, got %v", err)
+	}
+	if err := tax.Validate(&PostMeta{Tags: []string{"type/note", "theme/a", "theme/b", "theme/c", "theme/d", "theme/e", "theme/f"}}); err != nil {
+		t.Fatalf("unbounded family rejected: %v", err)
+	}
+}
+
+func TestLoadTaxonomyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "taxonomy.json")
+	doc := `{"version":1,"families":[{"name":"type","min":1,"max":2}]}`
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tax, err := LoadTaxonomyFile(path)
+	if err != nil {
+		t.Fatalf("LoadTaxonomyFile: %v", err)
+	}
+	if f, ok := tax.Family("type"); !ok || f.Max != 2 {
+		t.Fatalf("family not loaded: %+v", tax.Families)
+	}
+
+	bad := map[string]string{
+		"future version":   "version: 2\nfamilies: [{name: type}]",
+		"duplicate family": "version: 1\nfamilies: [{name: type}, {name: type}]",
+		"min above max":    "version: 1\nfamilies: [{name: type, min: 3, max: 1}]",
+		"dangling replace": "version: 1\nfamilies: [{name: type, replacedBy: kind}]",
+		"unknown key":      "version: 1\nfamilies: [{name: type, maximum: 1}]",
+	}
+	for name, doc := range bad {
+		if _, err := ParseTaxonomy([]byte(doc), "yaml"); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -2,6 +2,10 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"maps"
 	"net/http"
 	"slices"
@@ -14,29 +18,100 @@ type Storage interface {
 	GetPosts(ctx context.Context) (map[string]*Post, error)
 	GetPostsByTags(ctx context.Context, tags []string, matchAll bool) ([]*Post, error)
 	GetRelatedPosts(ctx context.Context, slug string, limit int) ([]*Post, error)
+	// GetPostLinks returns the wikilinks leaving slug and the posts linking to it.
+	GetPostLinks(ctx context.Context, slug string) (*PostLinks, error)
 	GetAbout() []byte
 	GetAssets() http.Handler
 
-	CreatePost(data []byte, originalFilename string, ctx context.Context) error
+	// CreatePost stores an upload under the slug derived from originalFilename and returns the
+	// Version written. An existing post with that slug is only replaced when opts allow it
+	// (ErrPostExists otherwise); see WriteOptions.
+	CreatePost(data []byte, originalFilename string, opts WriteOptions, ctx context.Context) (string, error)
+	// UpdatePost replaces the document of an existing post; the slug is kept regardless of the
+	// frontmatter. Returns the Version written, ErrPostNotFound if slug does not exist and
+	// ErrVersionMismatch if opts.IfMatch is not its current version.
+	UpdatePost(slug string, data []byte, opts WriteOptions, ctx context.Context) (string, error)
+	// DeletePost removes a post and its tag index entries and graph edges.
+	// Returns ErrPostNotFound if slug does not exist.
+	DeletePost(slug string, ctx context.Context) error
 }
 
+// ErrPostNotFound is returned (wrapped) when a post slug does not exist in the backend.
+var ErrPostNotFound = errors.New("post not found")
+
+// ErrPostExists is returned (wrapped) by CreatePost when the slug is taken and the write does
+// not ask to replace it. Different filenames can sanitize to the same slug.
+var ErrPostExists = errors.New("post already exists")
+
+// ErrVersionMismatch is returned (wrapped) when a write expects a version of the post other than
+// the stored one, usually because it was changed since the writer read it.
+var ErrVersionMismatch = errors.New("post version mismatch")
+
+// WriteOptions are the preconditions of CreatePost and UpdatePost. Backends check them and
+// write in one step, so concurrent writers cannot both succeed against the same version.
+type WriteOptions struct {
+	// IfMatch is the Version of the post the writer last read; the write fails with
+	// ErrVersionMismatch if the stored post is at another version or missing. "*" matches any
+	// existing post. Empty skips the check.
+	IfMatch string
+	// Overwrite lets CreatePost replace an existing post without naming its version.
+	Overwrite bool
+}
+
+// checkWrite applies opts to a write of slug whose stored version is current ("" when the post
+// does not exist). create tells CreatePost, which may add a post, from UpdatePost.
+func checkWrite(slug, current string, create bool, opts WriteOptions) error {
+	if current == "" && !create {
+		return fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	switch {
+	case opts.IfMatch == "":
+	case current == "":
+		return fmt.Errorf("%w: %s does not exist", ErrVersionMismatch, slug)
+	case opts.IfMatch != "*" && opts.IfMatch != current:
+		return fmt.Errorf("%w: %s is at version %s", ErrVersionMismatch, slug, current)
+	default:
+		return nil
+	}
+	if create && current != "" && !opts.Overwrite {
+		return fmt.Errorf("%w: %s", ErrPostExists, slug)
+	}
+	return nil
+}
+
+// ContentVersion is the Version of a post stored as the document data by backends without
+// native object versions: a digest of the document, so any edit changes it.
+func ContentVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:12])
+}
+
+// ErrTrustedHTMLForbidden is returned when a non-admin uploads a post marked trusted_html.
+var ErrTrustedHTMLForbidden = errors.New("trusted_html requires the admin role")
+
 type PostMeta struct {
 	Name         string    `yaml:"name"`
 	Slug         string    `yaml:"slug"` // derived from filename; frontmatter value ignored on upload
 	Tags         []string  `yaml:"tags"`
 	Aliases      []string  `yaml:"aliases"`
-	Lead         string    `yaml:"lead"`      // short summary (can substitute description)
-	CreatedRaw   string    `yaml:"created"`   // raw created date (YYYY-MM-DD) from frontmatter
-	Created      time.Time `yaml:"-"`         // parsed created date (strict date only)
-	UpdatedRaw   string    `yaml:"updated"`   // raw timestamp string from frontmatter (flexible formats)
-	Updated      time.Time `yaml:"-"`         // parsed canonical time (set during validation / parse)
-	PublishedRaw string    `yaml:"published"` // raw published value (string/bool); parsed in validation
-	Published    bool      `yaml:"-"`         // parsed boolean
+	Lead         string    `yaml:"lead"`         // short summary (can substitute description)
+	CreatedRaw   string    `yaml:"created"`      // raw created date (YYYY-MM-DD) from frontmatter
+	Created      time.Time `yaml:"-"`            // parsed created date (strict date only)
+	UpdatedRaw   string    `yaml:"updated"`      // raw timestamp string from frontmatter (flexible formats)
+	Updated      time.Time `yaml:"-"`            // parsed canonical time (set during validation / parse)
+	PublishedRaw string    `yaml:"published"`    // raw published value (string/bool); parsed in validation
+	Published    bool      `yaml:"-"`            // parsed boolean
+	TrustedHTML  bool      `yaml:"trusted_html"` // render raw HTML unsanitized; only admins may upload it
+	Cover        string    `yaml:"cover"`        // image shown on post cards: attachment name ([[wikilink]] allowed) or URL
+	Folder       string    `yaml:"-"`            // vault folder the note lives in ("" for the root); filesystem backend only
 }
 
 type Post struct {
 	Meta    PostMeta
 	Content []byte
+	// Version identifies the stored document, for WriteOptions.IfMatch: the object generation
+	// in GCS, ContentVersion elsewhere.
+	Version string
 }
 
 func SortPostMap(posts map[string]*Post) []*Post {
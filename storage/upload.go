@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	firebaseauth "firebase.google.com/go/v4/auth"
+
+	"github.com/soockee/cybersocke.com/parser/frontmatter"
+	"github.com/soockee/cybersocke.com/session"
+)
+
+// uploaderFromContext returns the verified Firebase token injected by the authentication middleware.
+// Every write path requires it; presence implies prior successful verification.
+func uploaderFromContext(ctx context.Context) (*firebaseauth.Token, error) {
+	tok, _ := ctx.Value(session.IdTokenKey).(*firebaseauth.Token)
+	if tok == nil {
+		return nil, fmt.Errorf("unauthorized: firebase token missing")
+	}
+	return tok, nil
+}
+
+// prepareUpload runs the upload pipeline shared by all writable backends: frontmatter parsing,
+// slug derivation from the original filename (any frontmatter slug is ignored), metadata and tag validation.
+// Invalid documents fail with ValidationErrors listing every issue.
+// Only admins may mark a post trusted_html. The returned post carries the body without
+// frontmatter, matching what parsePost caches.
+func prepareUpload(content []byte, originalFilename string, uploader *firebaseauth.Token) (*Post, error) {
+	post, issues := checkUpload(content, originalFilename)
+	if err := issues.err(); err != nil {
+		return nil, err
+	}
+	if post.Meta.TrustedHTML && !session.HasRole(uploader, "admin") {
+		return nil, ErrTrustedHTMLForbidden
+	}
+	return post, nil
+}
+
+// checkUpload parses and validates an upload. post is nil when the frontmatter does not parse.
+func checkUpload(content []byte, originalFilename string) (*Post, ValidationErrors) {
+	post := &Post{}
+	body, err := frontmatter.Parse(strings.NewReader(string(content)), &post.Meta)
+	if err != nil {
+		return nil, frontmatterIssue(err)
+	}
+	post.Content = body
+	post.Version = ContentVersion(content)
+	post.Meta.Slug = SanitizeFilename(originalFilename)
+	return post, ValidatePost(&post.Meta, content)
+}
+
+// CheckUpload is a dry run of an upload: it runs the pipeline of CreatePost on content without
+// storing anything and returns the post as it would be stored together with every issue found,
+// warnings included. post is nil when the frontmatter does not parse. A trusted_html flag the
+// caller in ctx may not set is reported and cleared.
+func CheckUpload(content []byte, originalFilename string, ctx context.Context) (*Post, ValidationErrors) {
+	post, issues := checkUpload(content, originalFilename)
+	if post != nil && post.Meta.TrustedHTML {
+		uploader, _ := uploaderFromContext(ctx)
+		if !session.HasRole(uploader, "admin") {
+			forbidden := ValidationErrors{{Field: "trusted_html", Message: ErrTrustedHTMLForbidden.Error(), Severity: SeverityError}}
+			forbidden.Locate(content)
+			issues = append(issues, forbidden...)
+			post.Meta.TrustedHTML = false
+		}
+	}
+	return post, issues
+}
+
+// prepareUpdate is prepareUpload for an existing slug: the document is validated the same way but
+// the slug always comes from the target post, never from the upload.
+func prepareUpdate(content []byte, slug string, uploader *firebaseauth.Token) (*Post, error) {
+	post, err := prepareUpload(content, slug, uploader)
+	if err != nil {
+		return nil, err
+	}
+	post.Meta.Slug = slug
+	return post, nil
+}
+
+// RewriteMeta returns the post document doc with its frontmatter set to meta, for edits made on
+// the server. Unknown keys, key order and the body are kept. Parsed fields that disagree with
+// their raw value (Created, Updated, Published) are written back; the slug, derived from the
+// file name, stays as the document has it.
+func RewriteMeta(doc []byte, meta PostMeta) ([]byte, error) {
+	var current PostMeta
+	if _, err := frontmatter.Parse(bytes.NewReader(doc), &current); err != nil {
+		return nil, err
+	}
+	meta.Slug = current.Slug
+	if !meta.Created.IsZero() && !parseDate(meta.CreatedRaw).Equal(meta.Created) {
+		meta.CreatedRaw = meta.Created.Format("2006-01-02")
+	}
+	if !meta.Updated.IsZero() && !parseTimestamp(meta.UpdatedRaw).Equal(meta.Updated) {
+		meta.UpdatedRaw = meta.Updated.Format(time.RFC3339)
+	}
+	if published, ok := parsePublished(meta.PublishedRaw); !ok || published != meta.Published {
+		meta.PublishedRaw = strconv.FormatBool(meta.Published)
+	}
+	return frontmatter.Rewrite(doc, meta)
+}
+
+// canonicalSlug appends the .md extension used as cache key by every backend.
+func canonicalSlug(slug string) string {
+	if !strings.HasSuffix(slug, ".md") {
+		return slug + ".md"
+	}
+	return slug
+}
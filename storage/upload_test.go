@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRewriteMeta(t *testing.T) {
+	doc := "+++\nname = \"K8s\"\nsource = \"import\"\ntags = [\"type/note\", \"theme/kubernetes\"]\nlead = \"l\"\ncreated = 2024-01-01\nupdated = \"2024-01-02\"\npublished = false\n+++\n\n# K8s\n"
+	post, err := prepareUpload([]byte(doc), "k8s.md", nil)
+	if err != nil {
+		t.Fatalf("prepareUpload: %v", err)
+	}
+	meta := post.Meta
+	meta.Lead = "Notes on K8s"
+	meta.Published = true
+	meta.Updated = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	out, err := RewriteMeta([]byte(doc), meta)
+	if err != nil {
+		t.Fatalf("RewriteMeta: %v", err)
+	}
+	want := "+++\nname = 'K8s'\nsource = 'import'\ntags = ['type/note', 'theme/kubernetes']\nlead = 'Notes on K8s'\ncreated = 2024-01-01\nupdated = '2024-03-01T12:00:00Z'\npublished = true\n+++\n\n# K8s\n"
+	if string(out) != want {
+		t.Fatalf("RewriteMeta =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestPrepareUploadReportsAllIssues(t *testing.T) {
+	doc := "---\nname: K8s\ntags:\n  - type/note\n  - colour/red\n  - \" type/note \"\ncreated: yesterday\nupdated: 2024-01-02\npublished: maybe\n---\nBody\n"
+	_, err := prepareUpload([]byte(doc), "k8s.md", nil)
+	var issues ValidationErrors
+	if !errors.As(err, &issues) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	want := ValidationErrors{
+		{Field: "lead", Message: "lead is required", Severity: SeverityError},
+		{Field: "created", Message: "created date required (YYYY-MM-DD)", Severity: SeverityError, Line: 7, Column: 1},
+		{Field: "published", Message: "invalid published value", Severity: SeverityError, Line: 9, Column: 1},
+		{Field: "tags[1]", Message: "unknown tag family: colour", Severity: SeverityError, Line: 5, Column: 3},
+		{Field: "tags[2]", Message: "duplicate tag type/note dropped", Severity: SeverityWarning, Line: 6, Column: 3},
+		{Field: "tags", Message: "theme/* tags must be 1-5 (got 0)", Severity: SeverityError, Line: 3, Column: 1},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Fatalf("issues =\n%+v\nwant\n%+v", []ValidationIssue(issues), []ValidationIssue(want))
+	}
+
+	_, err = prepareUpload([]byte("---\nname: K8s\ntags: [a\n---\n"), "k8s.md", nil)
+	if !errors.As(err, &issues) || len(issues) != 1 || issues[0].Line == 0 {
+		t.Fatalf("expected a located frontmatter issue, got %+v (%v)", issues, err)
+	}
+}
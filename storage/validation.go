@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/soockee/cybersocke.com/parser/frontmatter"
+)
+
+// Severity grades a validation issue: errors reject a post, warnings report what validation
+// changed about an accepted one.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// ValidationIssue is one problem found in a post's frontmatter. Field is the path of the key it
+// concerns, e.g. "created" or "tags[2]"; empty for the document as a whole. Line and Column
+// locate the key in the uploaded document when known.
+type ValidationIssue struct {
+	Field    string   `json:"field"`
+	Message  string   `json:"message"`
+	Severity Severity `json:"severity"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+}
+
+// ValidationErrors lists every issue found in a post. Validation returns it as error when at
+// least one issue is an error.
+type ValidationErrors []ValidationIssue
+
+// Error joins the messages of the error issues.
+func (v ValidationErrors) Error() string {
+	var msgs []string
+	for _, issue := range v {
+		if issue.Severity == SeverityError {
+			msgs = append(msgs, issue.Message)
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// HasErrors reports whether any issue is an error.
+func (v ValidationErrors) HasErrors() bool {
+	for _, issue := range v {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *ValidationErrors) add(severity Severity, field, message string) {
+	*v = append(*v, ValidationIssue{Field: field, Message: message, Severity: severity})
+}
+
+// err returns v as error if it holds errors, nil otherwise.
+func (v ValidationErrors) err() error {
+	if v.HasErrors() {
+		return v
+	}
+	return nil
+}
+
+// Locate fills in the line and column of every issue whose field is written in doc. Issues on
+// a sequence item the document does not place fall back to the sequence.
+func (v ValidationErrors) Locate(doc []byte) {
+	positions, _ := frontmatter.Positions(doc) // unlocated issues are still reported
+	for i := range v {
+		pos, ok := positions[v[i].Field]
+		if !ok {
+			field, _, _ := strings.Cut(v[i].Field, "[")
+			pos, ok = positions[field]
+		}
+		if ok {
+			v[i].Line, v[i].Column = pos.Line, pos.Column
+		}
+	}
+}
+
+// ValidatePost runs the upload checks on meta, the frontmatter parsed from doc: the metadata
+// rules of Check and the active taxonomy. All issues are returned, located in doc.
+func ValidatePost(meta *PostMeta, doc []byte) ValidationErrors {
+	issues := meta.Check()
+	issues = append(issues, ActiveTaxonomy().Check(meta)...)
+	issues.Locate(doc)
+	return issues
+}
+
+// frontmatterIssue reports frontmatter that fails to parse as a validation error.
+func frontmatterIssue(err error) ValidationErrors {
+	issue := ValidationIssue{Message: "invalid frontmatter: " + err.Error(), Severity: SeverityError}
+	var serr *frontmatter.SyntaxError
+	if errors.As(err, &serr) {
+		issue.Line = serr.Line
+	}
+	return ValidationErrors{issue}
+}
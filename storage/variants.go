@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strconv"
+)
+
+// VariantStore is implemented by backends that cache resized copies of image attachments. A
+// variant is keyed by the attachment's path, its width and the attachment's Version, so a
+// replaced image never serves stale copies. Backends drop the variants of a path when its
+// attachment is replaced or deleted.
+type VariantStore interface {
+	// GetVariant returns a cached variant, or ErrVariantNotFound.
+	GetVariant(ctx context.Context, path string, width int, version string) ([]byte, error)
+	// PutVariant caches a variant. It needs no signed-in user; variants are derived data.
+	PutVariant(ctx context.Context, path string, width int, version string, data []byte) error
+}
+
+// ErrVariantNotFound is returned (wrapped) when no variant is cached for a key.
+var ErrVariantNotFound = errors.New("image variant not found")
+
+// Version identifies the content of an attachment; it changes whenever the file is replaced.
+func (a *Attachment) Version() string {
+	return strconv.FormatInt(a.Modified.UnixNano(), 36) + "-" + strconv.FormatInt(a.Size, 36)
+}
+
+// variantName is the file or object name of a variant below the backend's variant root. All
+// variants of an attachment share the <path>/ prefix.
+func variantName(attachmentPath string, width int, version string) string {
+	return attachmentPath + "/" + strconv.Itoa(width) + "-" + version + path.Ext(attachmentPath)
+}